@@ -1,7 +1,7 @@
 // API client for Dendrite file manager
 class DendriteAPI {
     constructor() {
-        this.baseURL = '/api';
+        this.baseURL = basePath() + '/api';
     }
 
     async request(url, options = {}) {
@@ -109,6 +109,19 @@ class DendriteAPI {
         });
     }
 
+    // Rename file or directory within its parent directory
+    async renameFile(path, newName) {
+        const normalizedPath = path.startsWith('/') ? path.substring(1) : path;
+
+        return this.requestJSON(`/files/${encodeURIComponent(normalizedPath)}/rename`, {
+            method: 'POST',
+            headers: {
+                'Content-Type': 'application/json'
+            },
+            body: JSON.stringify({ newName })
+        });
+    }
+
     // Copy file or directory
     async copyFile(sourcePath, destPath) {
         // Normalize paths - remove leading slash for API URL construction
@@ -7,6 +7,8 @@ class MonacoEditorApp {
         this.modified = false;
         this.jwt = null;
         this.toastTimeout = null;
+        // Path the application is mounted at, injected by the server
+        this.basePath = (window.DENDRITE_CONFIG && window.DENDRITE_CONFIG.basePath) || '';
         this.init();
     }
     
@@ -58,8 +60,11 @@ class MonacoEditorApp {
         // Detect preferred color scheme
         const isDarkMode = window.matchMedia && window.matchMedia('(prefers-color-scheme: dark)').matches;
         
+        const disabled = (window.DENDRITE_CONFIG && window.DENDRITE_CONFIG.disabledOperations) || [];
         this.editor = monaco.editor.create(document.getElementById('editor-container'), {
             value: '// Loading file...',
+            // Files cannot be saved when editing is disabled on the server
+            readOnly: disabled.includes('edit'),
             language: 'plaintext',
             theme: isDarkMode ? 'vs-dark' : 'vs',
             automaticLayout: true,
@@ -156,7 +161,7 @@ class MonacoEditorApp {
             // Remove leading slash if present
             const cleanPath = this.filePath.startsWith('/') ? this.filePath.substring(1) : this.filePath;
             
-            const response = await fetch(`/api/files/${encodeURIComponent(cleanPath)}/raw`, { headers });
+            const response = await fetch(`${this.basePath}/api/files/${encodeURIComponent(cleanPath)}/raw`, { headers });
             if (!response.ok) {
                 throw new Error(`Failed to load file: ${response.statusText}`);
             }
@@ -206,7 +211,7 @@ class MonacoEditorApp {
             // Remove leading slash if present
             const cleanPath = this.filePath.startsWith('/') ? this.filePath.substring(1) : this.filePath;
             
-            const response = await fetch(`/api/files/${encodeURIComponent(cleanPath)}/raw`, {
+            const response = await fetch(`${this.basePath}/api/files/${encodeURIComponent(cleanPath)}/raw`, {
                 method: 'PUT',
                 headers: headers,
                 body: content
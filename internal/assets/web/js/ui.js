@@ -58,6 +58,8 @@ class UI {
         // Drag and drop for file upload
         this.setupDragAndDrop();
         
+        this.hideDisabledOperations();
+        
         // Handle JWT authentication if present
         this.handleJWTAuthentication();
         
@@ -272,8 +274,12 @@ class UI {
             return '/'; // JWT paths always start at root
         }
         
-        // First try to get path from URL pathname
+        // First try to get path from URL pathname, relative to the base path
         let path = window.location.pathname;
+        const base = basePath();
+        if (base && (path === base || path.startsWith(base + '/'))) {
+            path = path.substring(base.length) || '/';
+        }
         
         // If pathname is just '/', check for legacy query parameter format
         if (path === '/' || path === '') {
@@ -309,8 +315,8 @@ class UI {
             urlPath = path.startsWith('/') ? path : '/' + path;
         }
         
-        // Use clean path-based URLs
-        const url = new URL(window.location.origin + urlPath);
+        // Use clean path-based URLs below the base path
+        const url = new URL(window.location.origin + basePath() + urlPath);
         window.history.pushState({ path }, '', url);
     }
     
@@ -728,6 +734,34 @@ class UI {
         contextMenu.classList.remove('hidden');
     }
     
+    // Hides the controls of operations disabled on the server
+    hideDisabledOperations() {
+        const controls = {
+            'btn-upload': 'upload',
+            'btn-new-folder': 'mkdir'
+        };
+        Object.entries(controls).forEach(([id, operation]) => {
+            if (!operationEnabled(operation)) {
+                document.getElementById(id).classList.add('hidden');
+            }
+        });
+        
+        const menuItems = {
+            'edit-modal': ['edit'],
+            'edit-window': ['edit'],
+            'cut': ['move'],
+            'copy': ['copy'],
+            'paste': ['move', 'copy'],
+            'rename': ['move'],
+            'delete': ['delete']
+        };
+        Object.entries(menuItems).forEach(([action, operations]) => {
+            if (!operations.some(operationEnabled)) {
+                document.querySelector(`[data-action="${action}"]`).classList.add('hidden');
+            }
+        });
+    }
+    
     updateContextMenuItems() {
         const openItem = document.querySelector('[data-action="open"]');
         const editModalItem = document.querySelector('[data-action="edit-modal"]');
@@ -793,7 +827,7 @@ class UI {
     
     openEditorWindow(filePath) {
         // Open editor in a new window without browser controls
-        const editorUrl = `/editor.html?path=${encodeURIComponent(filePath)}`;
+        const editorUrl = `${basePath()}/editor.html?path=${encodeURIComponent(filePath)}`;
         const windowName = `dendrite_editor_${filePath.replace(/[^a-z0-9]/gi, '_')}`;
         
         // Use maximum restrictions to hide browser chrome
@@ -836,7 +870,7 @@ class UI {
         filenameSpan.textContent = filename;
         
         // Set the iframe source
-        iframe.src = `/editor.html?path=${encodeURIComponent(filePath)}&mode=modal`;
+        iframe.src = `${basePath()}/editor.html?path=${encodeURIComponent(filePath)}&mode=modal`;
         
         // Show the modal
         modal.classList.remove('hidden');
@@ -1159,7 +1193,7 @@ class UI {
             e.preventDefault();
             fileListContainer.style.backgroundColor = '';
             
-            if (e.dataTransfer.files.length > 0) {
+            if (e.dataTransfer.files.length > 0 && operationEnabled('upload')) {
                 const files = Array.from(e.dataTransfer.files);
                 this.uploadFiles(files);
             }
@@ -1186,7 +1220,7 @@ class UI {
         switch (e.key) {
             case 'x':
             case 'X':
-                if (isCtrlOrCmd && this.selectedFiles.size > 0) {
+                if (isCtrlOrCmd && this.selectedFiles.size > 0 && operationEnabled('move')) {
                     e.preventDefault();
                     const selectedPaths = Array.from(this.selectedFiles);
                     window.clipboard.cut(selectedPaths);
@@ -1196,7 +1230,7 @@ class UI {
                 
             case 'c':
             case 'C':
-                if (isCtrlOrCmd && this.selectedFiles.size > 0) {
+                if (isCtrlOrCmd && this.selectedFiles.size > 0 && operationEnabled('copy')) {
                     e.preventDefault();
                     const selectedPaths = Array.from(this.selectedFiles);
                     window.clipboard.copy(selectedPaths);
@@ -1214,7 +1248,7 @@ class UI {
                 
             case 'Delete':
             case 'Backspace':
-                if (e.key === 'Delete' && this.selectedFiles.size > 0) {
+                if (e.key === 'Delete' && this.selectedFiles.size > 0 && operationEnabled('delete')) {
                     e.preventDefault();
                     this.deleteSelectedFiles();
                 }
@@ -1232,7 +1266,7 @@ class UI {
                 break;
                 
             case 'F2':
-                if (this.selectedFiles.size === 1) {
+                if (this.selectedFiles.size === 1 && operationEnabled('move')) {
                     e.preventDefault();
                     const selectedPath = Array.from(this.selectedFiles)[0];
                     this.renameFile(selectedPath);
@@ -1383,21 +1417,8 @@ class UI {
         try {
             showLoading();
             
-            // Construct new path (same directory, new name)
-            const parentPath = getParentPath(path);
-            const newPath = parentPath === '/' ? `/${trimmedName}` : `${parentPath}/${trimmedName}`;
-            
-            // Check if destination already exists
-            const files = await this.api.listFiles(parentPath);
-            const nameExists = files.some(file => file.name === trimmedName);
-            
-            if (nameExists) {
-                showError(`A file or folder with the name "${trimmedName}" already exists.`);
-                return;
-            }
-            
-            // Perform the rename (move operation)
-            await this.api.moveFile(path, newPath);
+            // The server rejects names that collide with a sibling
+            await this.api.renameFile(path, trimmedName);
             
             showSuccess(`Successfully renamed to "${trimmedName}"`);
             await this.refresh();
@@ -1,5 +1,18 @@
 // Utility functions for Dendrite file manager
 
+// Path the application is mounted at behind a reverse proxy (e.g. '/dendrite'),
+// injected by the server; empty when served from the root
+function basePath() {
+    return (window.DENDRITE_CONFIG && window.DENDRITE_CONFIG.basePath) || '';
+}
+
+// Whether the server allows an operation; whole-instance policies can disable
+// uploads, deletes and other changes (see disabled_operations)
+function operationEnabled(operation) {
+    const disabled = (window.DENDRITE_CONFIG && window.DENDRITE_CONFIG.disabledOperations) || [];
+    return !disabled.includes(operation);
+}
+
 // Format file size in human readable format
 function formatFileSize(bytes) {
     if (bytes === 0) return '0 Bytes';
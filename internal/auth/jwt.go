@@ -23,9 +23,19 @@ type Claims struct {
 	Directories []DirMapping `json:"directories"`
 	Quota       string       `json:"quota"`
 	Expires     string       `json:"expires"`
+	// DownloadRate overrides the server's download rate limit for this
+	// token, e.g. "1MB" per second
+	DownloadRate string `json:"downloadRate,omitempty"`
+	// Scope limits what the token may be used for; empty allows everything
+	// the directories permit, ScopeDownload only downloading files
+	Scope string `json:"scope,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// ScopeDownload marks tokens that may only download files, such as the
+// tokens Dendrite issues for the links of a feed
+const ScopeDownload = "download"
+
 // contextKey is used for storing values in context
 type contextKey string
 
@@ -34,22 +44,46 @@ const (
 	ClaimsContextKey contextKey = "jwt_claims"
 )
 
+// MiddlewareOptions holds optional limits enforced by the JWT middleware
+type MiddlewareOptions struct {
+	// MaxDirectories rejects tokens with more directory mappings with 400.
+	// Zero or a negative value disables the limit.
+	MaxDirectories int
+	// QueryToken reports whether a request may carry its token in the
+	// "token" query parameter instead of the Authorization header, for
+	// clients such as feed readers that cannot set headers
+	QueryToken func(r *http.Request) bool
+	// Public reports whether a request without any token may proceed. Such
+	// requests reach the handler without claims in their context.
+	Public func(r *http.Request) bool
+}
+
 // JWTMiddleware creates a middleware that validates JWT tokens
 func JWTMiddleware(secret string) mux.MiddlewareFunc {
+	return JWTMiddlewareWithOptions(secret, MiddlewareOptions{})
+}
+
+// JWTMiddlewareWithOptions creates a middleware that validates JWT tokens
+// and enforces the given limits before any handler works with the claims
+func JWTMiddlewareWithOptions(secret string, opts MiddlewareOptions) mux.MiddlewareFunc {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			// Extract token from Authorization header
 			authHeader := r.Header.Get("Authorization")
-			if authHeader == "" {
+			tokenString := ""
+			switch {
+			case authHeader == "" && opts.QueryToken != nil && opts.QueryToken(r) &&
+				r.URL.Query().Get("token") != "":
+				tokenString = r.URL.Query().Get("token")
+			case authHeader == "" && opts.Public != nil && opts.Public(r):
+				next.ServeHTTP(w, r)
+				return
+			case authHeader == "":
 				http.Error(w, "Missing authorization header", http.StatusUnauthorized)
 				return
-			}
-
-			// Check for Bearer token
-			tokenString := ""
-			if strings.HasPrefix(authHeader, "Bearer ") {
+			case strings.HasPrefix(authHeader, "Bearer "):
 				tokenString = strings.TrimPrefix(authHeader, "Bearer ")
-			} else {
+			default:
 				http.Error(w, "Invalid authorization header format", http.StatusUnauthorized)
 				return
 			}
@@ -88,6 +122,13 @@ func JWTMiddleware(secret string) mux.MiddlewareFunc {
 				}
 			}
 
+			// Bound the per-request work done for every directory mapping
+			if opts.MaxDirectories > 0 && len(claims.Directories) > opts.MaxDirectories {
+				http.Error(w, fmt.Sprintf("Token carries %d directories, at most %d are allowed",
+					len(claims.Directories), opts.MaxDirectories), http.StatusBadRequest)
+				return
+			}
+
 			// Store claims in context for use by handlers
 			ctx := context.WithValue(r.Context(), ClaimsContextKey, claims)
 			next.ServeHTTP(w, r.WithContext(ctx))
@@ -101,6 +142,11 @@ func GetClaimsFromContext(ctx context.Context) (*Claims, bool) {
 	return claims, ok
 }
 
+// SignToken creates a JWT for the claims, signed with HS256
+func SignToken(claims *Claims, secret string) (string, error) {
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+}
+
 // ValidateJWTString validates a JWT string and returns the claims
 func ValidateJWTString(tokenString string, secret string) (*Claims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
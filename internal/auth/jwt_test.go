@@ -239,4 +239,24 @@ func TestValidateJWTString(t *testing.T) {
 		assert.Error(t, err)
 		assert.Nil(t, validatedClaims)
 	})
-}
\ No newline at end of file
+}
+
+func TestSignToken(t *testing.T) {
+	secret := "test-secret-that-is-at-least-32-characters-long"
+	claims := &Claims{
+		Directories: []DirMapping{{Source: "user/downloads", Virtual: "/downloads"}},
+		Expires:     time.Now().Add(time.Hour).Format(time.RFC3339),
+		Scope:       ScopeDownload,
+	}
+
+	tokenString, err := SignToken(claims, secret)
+	require.NoError(t, err)
+
+	validatedClaims, err := ValidateJWTString(tokenString, secret)
+	require.NoError(t, err)
+	assert.Equal(t, claims.Directories, validatedClaims.Directories)
+	assert.Equal(t, ScopeDownload, validatedClaims.Scope)
+
+	_, err = ValidateJWTString(tokenString, "another-secret-that-is-at-least-32-characters")
+	assert.Error(t, err)
+}
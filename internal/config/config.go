@@ -12,25 +12,263 @@ import (
 type DirMapping struct {
 	Source  string `mapstructure:"source" json:"source"`
 	Virtual string `mapstructure:"virtual" json:"virtual"`
+	// AutoExtract unpacks uploaded .zip and .tar.gz archives into a folder
+	// named after the archive
+	AutoExtract bool `mapstructure:"auto_extract" json:"-"`
+	// DeleteArchive removes an archive after it was extracted automatically
+	DeleteArchive bool `mapstructure:"delete_archive" json:"-"`
+	// LowercasePaths presents all paths below the mapping in lower case and
+	// resolves them against the disk case-insensitively
+	LowercasePaths bool `mapstructure:"lowercase_paths" json:"-"`
+	// MaxFiles caps the number of entries in each directory of the mapping.
+	// Zero applies max_files_per_directory, a negative value disables it.
+	MaxFiles int `mapstructure:"max_files" json:"-"`
+	// Quota limits the storage of the mapping, e.g. "10GB", in addition to
+	// the global quota. Empty means only the global quota applies.
+	Quota string `mapstructure:"quota" json:"-"`
+	// QuotaBytes is the parsed Quota
+	QuotaBytes int64 `mapstructure:"-" json:"-"`
 }
 
 // MainConfig holds the main configuration settings
 type MainConfig struct {
 	Listen    string `mapstructure:"listen"`
 	Quota     string `mapstructure:"quota"`
+	// QuotaAccounting is "logical" (default) to count file sizes or "blocks" to
+	// count the disk blocks actually allocated, which is smaller for sparse files
+	QuotaAccounting string `mapstructure:"quota_accounting"`
+	// FlattenSingleRoot presents a single directory mapping as the root so
+	// that paths do not carry the mapping prefix
+	FlattenSingleRoot bool `mapstructure:"flatten_single_root"`
+	// StrictDestinations makes move and copy fail when the parent directory
+	// of the destination does not exist instead of creating it
+	StrictDestinations bool `mapstructure:"strict_destinations"`
+	// CaseCollisions controls whether uploads, moves and copies fail when a
+	// name differs from an existing one only in case: "auto" (default) where
+	// the filesystem is case-insensitive, "always" or "never"
+	CaseCollisions string `mapstructure:"case_collisions"`
+	// BasePath mounts all routes below a path prefix such as "/dendrite" when
+	// a reverse proxy forwards a sub path without stripping it
+	BasePath string `mapstructure:"base_path"`
+	// QuotaExclude lists virtual path prefixes whose contents do not count
+	// against the quota, e.g. scratch or cache directories
+	QuotaExclude []string `mapstructure:"quota_exclude"`
+	// DisjointVirtualPaths rejects directory mappings whose virtual paths are
+	// nested within each other, as is always done for JWT tokens
+	DisjointVirtualPaths bool `mapstructure:"disjoint_virtual_paths"`
+	// QuotaReconcileInterval enables tracking the quota usage in memory.
+	// Operations adjust it by their size change and every mapping is walked
+	// again after this many seconds. Zero walks all mappings on every check.
+	QuotaReconcileInterval int `mapstructure:"quota_reconcile_interval"`
+	// MinFreeSpace rejects writes that would leave less free space on the
+	// underlying filesystem, e.g. "5GB", independent of the quota
+	MinFreeSpace string `mapstructure:"min_free_space"`
+	// MinFreeSpaceBytes is the parsed MinFreeSpace
+	MinFreeSpaceBytes int64 `mapstructure:"-"`
+	// RecursiveWorkers is the number of files read in parallel by recursive
+	// copies and ZIP downloads. Zero or one processes files one at a time.
+	RecursiveWorkers int `mapstructure:"recursive_workers"`
+	// MaxFilesPerUpload caps the number of file parts in a multipart upload
+	// request. Zero selects the default of 100, a negative value disables it.
+	MaxFilesPerUpload int `mapstructure:"max_files_per_upload"`
+	// MaxFilesPerDirectory caps the number of entries in each directory,
+	// unless the mapping sets max_files. Zero or a negative value disables it.
+	MaxFilesPerDirectory int `mapstructure:"max_files_per_directory"`
+	// RedactErrors replaces internal error messages sent to clients with a
+	// reference to the logged detail and removes physical paths from others
+	RedactErrors bool `mapstructure:"redact_errors"`
+	// DisabledOperations turns off write operations for the whole instance,
+	// see Operations
+	DisabledOperations []string `mapstructure:"disabled_operations"`
+	// ReadOnly rejects every request that modifies files, for deployments
+	// that only offer browsing and downloads
+	ReadOnly bool `mapstructure:"read_only"`
+	// ShutdownTimeout is the number of seconds requests in flight may take to
+	// finish after SIGINT or SIGTERM. Zero selects the default of 30 seconds.
+	ShutdownTimeout int `mapstructure:"shutdown_timeout"`
+	// TLSCert and TLSKey are the PEM files of the certificate and private key.
+	// When both are set the server answers HTTPS instead of HTTP.
+	TLSCert string `mapstructure:"tls_cert"`
+	TLSKey  string `mapstructure:"tls_key"`
+	// TLSAutoRedirect starts a second listener on TLSRedirectListen that
+	// redirects plain HTTP requests to HTTPS
+	TLSAutoRedirect bool `mapstructure:"tls_auto_redirect"`
+	// TLSRedirectListen is the address of the redirect listener, ":80" when
+	// empty
+	TLSRedirectListen string `mapstructure:"tls_redirect_listen"`
+	// TrashDir enables the trash: deletes move files into a directory of this
+	// name, such as ".trash", in the root of their mapped directory instead
+	// of removing them. Empty deletes permanently.
+	TrashDir string `mapstructure:"trash_dir"`
 }
 
+// Operations lists the operations that can be disabled with
+// disabled_operations. Renames are moves.
+var Operations = []string{"upload", "edit", "mkdir", "move", "copy", "delete", "chmod", "replace"}
+
 // JWTAuthConfig holds JWT authentication configuration
 type JWTAuthConfig struct {
 	JWTSecret string `mapstructure:"jwt_secret"`
 	BaseDir   string `mapstructure:"base_dir"`
+	// MaxDirectories caps the number of directory mappings a token may carry.
+	// Zero selects the default, a negative value disables the limit.
+	MaxDirectories int `mapstructure:"max_directories"`
+	// PublicPaths lists virtual paths that can be browsed and downloaded
+	// without a token. Each one is served from the same path below BaseDir.
+	PublicPaths []string `mapstructure:"public_paths"`
+}
+
+// SPAConfig holds settings for the single page application fallback
+type SPAConfig struct {
+	// Routes lists path prefixes handled by client-side routing. When empty,
+	// every unknown path is answered with index.html.
+	Routes []string `mapstructure:"routes"`
+	// NotFoundPage is an optional HTML file served for unknown paths
+	NotFoundPage string `mapstructure:"not_found_page"`
+}
+
+// CopyConfig holds settings for copy operations
+type CopyConfig struct {
+	// Reflink tries a copy-on-write clone before falling back to a byte copy
+	Reflink bool `mapstructure:"reflink"`
+	// PreserveOwnership copies the owner and group of the source to the copy
+	// when the server has the privileges to do so
+	PreserveOwnership bool `mapstructure:"preserve_ownership"`
+}
+
+// StaticConfig holds caching settings for the embedded web UI assets
+type StaticConfig struct {
+	// MaxAge is the Cache-Control max-age in seconds for CSS, JS and images.
+	// Zero selects the default of one year, a negative value disables caching.
+	MaxAge int `mapstructure:"max_age"`
+}
+
+// DownloadConfig holds settings for file downloads
+type DownloadConfig struct {
+	// Disposition is the default Content-Disposition, "attachment" or "inline".
+	// HTML, SVG and other scriptable content is always sent as attachment.
+	Disposition string `mapstructure:"disposition"`
+	// RateLimit caps the transfer rate of every download, e.g. "512KB" or
+	// "10MB" per second. Empty means unlimited.
+	RateLimit string `mapstructure:"rate_limit"`
+	// RateLimitBytes is the parsed RateLimit in bytes per second
+	RateLimitBytes int64 `mapstructure:"-"`
+	// ZipChangedFiles is the default handling of files that change while a
+	// ZIP archive is created: "include" (default), "skip" or "fail"
+	ZipChangedFiles string `mapstructure:"zip_changed_files"`
+	// InlineTypes lists the MIME types ("image/png", "image/*") and file
+	// extensions (".pdf") that may be displayed inline. Empty selects a safe
+	// default; scriptable content is never displayed inline.
+	InlineTypes []string `mapstructure:"inline_types"`
+}
+
+// ListingConfig holds limits for directory listings
+type ListingConfig struct {
+	// MaxEntries caps the number of entries returned for a directory. Zero
+	// selects the default, a negative value disables the limit.
+	MaxEntries int `mapstructure:"max_entries"`
+	// OnLimit is "truncate" (default) to return the entries the filesystem
+	// lists first, an arbitrary subset, or "error" to reject the listing
+	OnLimit string `mapstructure:"on_limit"`
+	// CacheTTL keeps directory listings in memory for this many seconds.
+	// Zero or a negative value disables the cache.
+	CacheTTL int `mapstructure:"cache_ttl"`
+	// SniffMime detects the MIME type of files with unknown extensions from
+	// their first bytes, which costs a read per file. Stat always does so.
+	SniffMime bool `mapstructure:"sniff_mime"`
+}
+
+// LoggingConfig holds access log settings
+type LoggingConfig struct {
+	// AccessLog enables one log line per request
+	AccessLog bool `mapstructure:"access_log"`
+	// SampleRate is the fraction of successful requests that are logged (0 to 1)
+	SampleRate float64 `mapstructure:"sample_rate"`
+	// AlwaysLogErrors logs 4xx and 5xx responses regardless of sampling
+	AlwaysLogErrors bool `mapstructure:"always_log_errors"`
+	// TrustedProxies lists IPs or CIDR ranges of reverse proxies whose
+	// X-Forwarded-For header is used to determine the client address
+	TrustedProxies []string `mapstructure:"trusted_proxies"`
+	// QuotaDenials logs every operation rejected for exceeding the quota
+	QuotaDenials bool `mapstructure:"quota_denials"`
+	// Format of the access log: "text" (default) for lines in the server
+	// log or "json" for JSON lines on stdout
+	Format string `mapstructure:"format"`
+	// Level selects the logged requests: "error" only logs 4xx and 5xx
+	// responses, "info" (default) samples as configured and "debug" logs
+	// every request with its query and user agent
+	Level string `mapstructure:"level"`
+}
+
+// DecompressionConfig holds limits for compressed uploads and extracted
+// archives that protect against compression bombs
+type DecompressionConfig struct {
+	// MaxSize caps the decompressed size of a request body, file part or
+	// archive, e.g. "10GB". Empty means unlimited.
+	MaxSize string `mapstructure:"max_size"`
+	// MaxSizeBytes is the parsed MaxSize
+	MaxSizeBytes int64 `mapstructure:"-"`
+	// MaxRatio caps the ratio of decompressed to compressed size. Zero
+	// selects the default of 1000, a negative value disables the check.
+	MaxRatio float64 `mapstructure:"max_ratio"`
+}
+
+// TempCleanupConfig holds settings for removing orphaned temporary files
+type TempCleanupConfig struct {
+	// Interval runs the cleanup every this many seconds. Zero or a negative
+	// value disables it.
+	Interval int `mapstructure:"interval"`
+	// MaxAge is the age in seconds after which a temporary file is considered
+	// orphaned. Zero selects the default of one day.
+	MaxAge int `mapstructure:"max_age"`
+}
+
+// ExtractionConfig holds settings for extracting uploaded archives
+type ExtractionConfig struct {
+	// Symlinks controls symbolic link entries in archives: "reject" (default)
+	// fails the extraction, "skip" ignores them and "contained" creates links
+	// whose target stays within the extracted directory
+	Symlinks string `mapstructure:"symlinks"`
+}
+
+// UploadsConfig holds settings for resumable uploads
+type UploadsConfig struct {
+	// SessionTTL is the number of seconds a resumable upload session stays
+	// valid after its last chunk. Zero selects the default of one day.
+	SessionTTL int `mapstructure:"session_ttl"`
+}
+
+// CORSConfig holds settings for cross-origin requests to the API
+type CORSConfig struct {
+	// AllowedOrigins lists the origins, such as "https://app.example.com",
+	// whose scripts may call the API. "*" allows every origin. Empty sends
+	// no CORS headers.
+	AllowedOrigins []string `mapstructure:"allowed_origins"`
+	// MaxAge is the number of seconds browsers may cache a preflight
+	// response. Zero selects the default of ten minutes.
+	MaxAge int `mapstructure:"max_age"`
 }
 
 // Config holds the application configuration
 type Config struct {
-	Main        MainConfig     `mapstructure:"main"`
-	JWTAuth     JWTAuthConfig  `mapstructure:"jwt_auth"`
-	Directories []DirMapping   `mapstructure:"directories"`
+	Main          MainConfig          `mapstructure:"main"`
+	JWTAuth       JWTAuthConfig       `mapstructure:"jwt_auth"`
+	SPA           SPAConfig           `mapstructure:"spa"`
+	Copy          CopyConfig          `mapstructure:"copy"`
+	Static        StaticConfig        `mapstructure:"static"`
+	Download      DownloadConfig      `mapstructure:"download"`
+	Listing       ListingConfig       `mapstructure:"listing"`
+	Logging       LoggingConfig       `mapstructure:"logging"`
+	TempCleanup   TempCleanupConfig   `mapstructure:"temp_cleanup"`
+	Decompression DecompressionConfig `mapstructure:"decompression"`
+	Extraction    ExtractionConfig    `mapstructure:"extraction"`
+	Uploads       UploadsConfig       `mapstructure:"uploads"`
+	CORS          CORSConfig          `mapstructure:"cors"`
+	// Mime maps file extensions to the MIME types reported in listings,
+	// overriding the built-in and system types. The loader stores the
+	// extensions in lower case with a leading dot.
+	Mime map[string]string `mapstructure:"mime"`
+	Directories   []DirMapping        `mapstructure:"directories"`
 	
 	// Computed fields (not from config file)
 	QuotaBytes int64
@@ -42,23 +280,96 @@ type Config struct {
 	BaseDir   string
 }
 
+// basePathPattern matches a cleaned base path: empty or segments of URL-safe
+// characters, each starting with a slash
+var basePathPattern = regexp.MustCompile(`^(/[A-Za-z0-9._~-]+)*$`)
+
+// NormalizeBasePath returns the base path with a leading and without a
+// trailing slash; the root yields an empty string
+func NormalizeBasePath(basePath string) (string, error) {
+	basePath = strings.TrimSpace(basePath)
+	if basePath == "" || basePath == "/" {
+		return "", nil
+	}
+	basePath = "/" + strings.Trim(basePath, "/")
+	if !basePathPattern.MatchString(basePath) {
+		return "", fmt.Errorf("invalid base_path: %s (expected a path such as /dendrite)", basePath)
+	}
+	return basePath, nil
+}
+
+// ParseRate parses a transfer rate in bytes per second such as "65536",
+// "512KB" or "10MB"
+func ParseRate(value string) (int64, error) {
+	rate, err := ParseSize(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid rate: %w", err)
+	}
+	return rate, nil
+}
+
+// ParseSize parses a positive size in bytes such as "65536", "512KB", "10GB"
+// or "1TB"
+func ParseSize(value string) (int64, error) {
+	re := regexp.MustCompile(`^(\d+(?:\.\d+)?)(B|KB|MB|GB|TB)?$`)
+	matches := re.FindStringSubmatch(strings.ToUpper(strings.TrimSpace(value)))
+	if len(matches) != 3 {
+		return 0, fmt.Errorf("invalid size format: %s (expected format: 65536, 512KB, 10GB)", value)
+	}
+
+	number, err := strconv.ParseFloat(matches[1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size value: %s", matches[1])
+	}
+
+	var multiplier float64
+	switch matches[2] {
+	case "", "B":
+		multiplier = 1
+	case "KB":
+		multiplier = 1024
+	case "MB":
+		multiplier = 1024 * 1024
+	case "GB":
+		multiplier = 1024 * 1024 * 1024
+	case "TB":
+		multiplier = 1024 * 1024 * 1024 * 1024
+	}
+
+	size := int64(number * multiplier)
+	if size <= 0 {
+		return 0, fmt.Errorf("invalid size: %s (must be positive)", value)
+	}
+	return size, nil
+}
+
 // ParseQuota parses the quota string and sets QuotaBytes
 func ParseQuota(cfg *Config) error {
 	if cfg.Quota == "" {
 		return nil
 	}
 
+	quota, err := ParseQuotaValue(cfg.Quota)
+	if err != nil {
+		return err
+	}
+	cfg.QuotaBytes = quota
+	return nil
+}
+
+// ParseQuotaValue parses a quota such as "500MB", "1GB" or "2TB" into bytes
+func ParseQuotaValue(quota string) (int64, error) {
 	// Regular expression to match number and unit (e.g., "1GB", "500MB", "2TB")
 	re := regexp.MustCompile(`^(\d+(?:\.\d+)?)(MB|GB|TB)$`)
-	matches := re.FindStringSubmatch(strings.ToUpper(cfg.Quota))
+	matches := re.FindStringSubmatch(strings.ToUpper(quota))
 
 	if len(matches) != 3 {
-		return fmt.Errorf("invalid quota format: %s (expected format: 1GB, 500MB, 2TB)", cfg.Quota)
+		return 0, fmt.Errorf("invalid quota format: %s (expected format: 1GB, 500MB, 2TB)", quota)
 	}
 
 	value, err := strconv.ParseFloat(matches[1], 64)
 	if err != nil {
-		return fmt.Errorf("invalid quota value: %s", matches[1])
+		return 0, fmt.Errorf("invalid quota value: %s", matches[1])
 	}
 
 	unit := matches[2]
@@ -72,9 +383,8 @@ func ParseQuota(cfg *Config) error {
 	case "TB":
 		multiplier = 1024 * 1024 * 1024 * 1024
 	default:
-		return fmt.Errorf("unsupported quota unit: %s", unit)
+		return 0, fmt.Errorf("unsupported quota unit: %s", unit)
 	}
 
-	cfg.QuotaBytes = int64(value * float64(multiplier))
-	return nil
+	return int64(value * float64(multiplier)), nil
 }
\ No newline at end of file
@@ -69,4 +69,86 @@ func TestParseQuota(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		rate        string
+		expected    int64
+		expectError bool
+	}{
+		{rate: "65536", expected: 65536},
+		{rate: "512KB", expected: 512 * 1024},
+		{rate: "10mb", expected: 10 * 1024 * 1024},
+		{rate: "1.5MB", expected: int64(1.5 * 1024 * 1024)},
+		{rate: "0", expectError: true},
+		{rate: "fast", expectError: true},
+		{rate: "", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rate, func(t *testing.T) {
+			rate, err := ParseRate(tt.rate)
+			if tt.expectError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, rate)
+		})
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		size        string
+		expected    int64
+		expectError bool
+	}{
+		{size: "4096", expected: 4096},
+		{size: "10GB", expected: 10 * 1024 * 1024 * 1024},
+		{size: "1tb", expected: 1024 * 1024 * 1024 * 1024},
+		{size: "-1GB", expectError: true},
+		{size: "huge", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.size, func(t *testing.T) {
+			size, err := ParseSize(tt.size)
+			if tt.expectError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, size)
+		})
+	}
+}
+
+func TestNormalizeBasePath(t *testing.T) {
+	tests := []struct {
+		basePath    string
+		expected    string
+		expectError bool
+	}{
+		{basePath: "", expected: ""},
+		{basePath: "/", expected: ""},
+		{basePath: "/dendrite", expected: "/dendrite"},
+		{basePath: "dendrite/", expected: "/dendrite"},
+		{basePath: "/apps/files/", expected: "/apps/files"},
+		{basePath: "/my files", expectError: true},
+		{basePath: "/a//b", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.basePath, func(t *testing.T) {
+			basePath, err := NormalizeBasePath(tt.basePath)
+			if tt.expectError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, basePath)
+		})
+	}
+}
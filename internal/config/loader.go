@@ -1,10 +1,15 @@
 package config
 
 import (
+	"crypto/tls"
 	"fmt"
 	"log"
+	"mime"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/spf13/pflag"
@@ -36,6 +41,13 @@ func LoadConfig() (*Config, error) {
 	pflag.String("quota", "", "storage quota (overrides config)")
 	pflag.String("jwt-secret", "", "JWT secret (overrides config)")
 	pflag.String("base-dir", "", "base directory for JWT mode")
+	pflag.Bool("read-only", false, "reject all modifications (overrides config)")
+	pflag.String("tls-cert", "", "TLS certificate file (overrides config)")
+	pflag.String("tls-key", "", "TLS private key file (overrides config)")
+	pflag.Bool("tls-auto-redirect", false, "redirect HTTP requests on port 80 to HTTPS (overrides config)")
+	pflag.StringSlice("cors-origin", []string{}, "allow cross-origin API requests from this origin (can be repeated)")
+	pflag.String("trash-dir", "", "move deleted files into a directory of this name, e.g. .trash (overrides config)")
+	pflag.String("log-level", "", "enable the access log with level error, info or debug (overrides config)")
 	pflag.Parse()
 
 	// Bind flags to viper
@@ -43,6 +55,10 @@ func LoadConfig() (*Config, error) {
 		return nil, fmt.Errorf("error binding flags: %w", err)
 	}
 
+	// Log all successful requests and every error once access logging is enabled
+	viper.SetDefault("logging.sample_rate", 1.0)
+	viper.SetDefault("logging.always_log_errors", true)
+
 	// Track configuration sources
 	source := &configSource{}
 
@@ -110,6 +126,33 @@ func LoadConfig() (*Config, error) {
 		cfg.BaseDir = cfg.JWTAuth.BaseDir
 	}
 
+	if viper.GetBool("read-only") {
+		cfg.Main.ReadOnly = true
+	}
+
+	if tlsCert := viper.GetString("tls-cert"); tlsCert != "" {
+		cfg.Main.TLSCert = tlsCert
+	}
+	if tlsKey := viper.GetString("tls-key"); tlsKey != "" {
+		cfg.Main.TLSKey = tlsKey
+	}
+	if viper.GetBool("tls-auto-redirect") {
+		cfg.Main.TLSAutoRedirect = true
+	}
+
+	if origins := viper.GetStringSlice("cors-origin"); len(origins) > 0 {
+		cfg.CORS.AllowedOrigins = origins
+	}
+
+	if trashDir := viper.GetString("trash-dir"); trashDir != "" {
+		cfg.Main.TrashDir = trashDir
+	}
+
+	if logLevel := viper.GetString("log-level"); logLevel != "" {
+		cfg.Logging.Level = logLevel
+		cfg.Logging.AccessLog = true
+	}
+
 	// Set defaults if nothing was specified
 	if cfg.Listen == "" {
 		cfg.Listen = "127.0.0.1:3000"
@@ -127,6 +170,47 @@ func LoadConfig() (*Config, error) {
 		}
 	}
 
+	for i, dir := range cfg.Directories {
+		if dir.Quota == "" {
+			continue
+		}
+		quota, err := ParseQuotaValue(dir.Quota)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing quota of directory %s: %w", dir.Virtual, err)
+		}
+		cfg.Directories[i].QuotaBytes = quota
+	}
+
+	if cfg.Download.RateLimit != "" {
+		rate, err := ParseRate(cfg.Download.RateLimit)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing download rate_limit: %w", err)
+		}
+		cfg.Download.RateLimitBytes = rate
+	}
+
+	basePath, err := NormalizeBasePath(cfg.Main.BasePath)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Main.BasePath = basePath
+
+	if cfg.Decompression.MaxSize != "" {
+		size, err := ParseSize(cfg.Decompression.MaxSize)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing decompression max_size: %w", err)
+		}
+		cfg.Decompression.MaxSizeBytes = size
+	}
+
+	if cfg.Main.MinFreeSpace != "" {
+		size, err := ParseSize(cfg.Main.MinFreeSpace)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing min_free_space: %w", err)
+		}
+		cfg.Main.MinFreeSpaceBytes = size
+	}
+
 	// Log final configuration (without secrets)
 	log.Printf("Configuration loaded:")
 	log.Printf("  Listen: %s", cfg.Listen)
@@ -177,6 +261,123 @@ func parseDirMapping(mapping string) (DirMapping, error) {
 
 // validateConfig validates the configuration
 func validateConfig(cfg *Config, source *configSource) error {
+	switch cfg.Download.Disposition {
+	case "", "inline", "attachment":
+	default:
+		return fmt.Errorf("invalid download disposition: %s (expected inline or attachment)", cfg.Download.Disposition)
+	}
+
+	for _, inlineType := range cfg.Download.InlineTypes {
+		if !strings.HasPrefix(inlineType, ".") && !strings.Contains(inlineType, "/") {
+			return fmt.Errorf("invalid download inline_types entry: %s (expected a MIME type or .extension)",
+				inlineType)
+		}
+	}
+
+	switch cfg.Download.ZipChangedFiles {
+	case "", "include", "skip", "fail":
+	default:
+		return fmt.Errorf("invalid download zip_changed_files: %s (expected include, skip or fail)",
+			cfg.Download.ZipChangedFiles)
+	}
+
+	switch cfg.Main.QuotaAccounting {
+	case "", "logical", "blocks":
+	default:
+		return fmt.Errorf("invalid quota_accounting: %s (expected logical or blocks)", cfg.Main.QuotaAccounting)
+	}
+
+	switch cfg.Main.CaseCollisions {
+	case "", "auto", "always", "never":
+	default:
+		return fmt.Errorf("invalid case_collisions: %s (expected auto, always or never)", cfg.Main.CaseCollisions)
+	}
+
+	for _, operation := range cfg.Main.DisabledOperations {
+		if !slices.Contains(Operations, operation) {
+			return fmt.Errorf("invalid disabled_operations entry: %s (expected one of %s)",
+				operation, strings.Join(Operations, ", "))
+		}
+	}
+
+	for _, prefix := range cfg.Main.QuotaExclude {
+		if !strings.HasPrefix(prefix, "/") {
+			return fmt.Errorf("invalid quota_exclude path: %s (must start with /)", prefix)
+		}
+	}
+
+	if trashDir := cfg.Main.TrashDir; trashDir != "" &&
+		(trashDir == "." || trashDir == ".." || strings.ContainsAny(trashDir, `/\`)) {
+		return fmt.Errorf("invalid trash_dir: %s (expected a directory name such as .trash)", trashDir)
+	}
+
+	switch cfg.Extraction.Symlinks {
+	case "", "reject", "skip", "contained":
+	default:
+		return fmt.Errorf("invalid extraction symlinks: %s (expected reject, skip or contained)", cfg.Extraction.Symlinks)
+	}
+
+	switch cfg.Listing.OnLimit {
+	case "", "truncate", "error":
+	default:
+		return fmt.Errorf("invalid listing on_limit: %s (expected truncate or error)", cfg.Listing.OnLimit)
+	}
+
+	switch cfg.Logging.Format {
+	case "", "text", "json":
+	default:
+		return fmt.Errorf("invalid logging format: %s (expected text or json)", cfg.Logging.Format)
+	}
+
+	switch cfg.Logging.Level {
+	case "", "error", "info", "debug":
+	default:
+		return fmt.Errorf("invalid logging level: %s (expected error, info or debug)", cfg.Logging.Level)
+	}
+
+	if cfg.Logging.SampleRate < 0 || cfg.Logging.SampleRate > 1 {
+		return fmt.Errorf("invalid logging sample_rate: %v (expected a value between 0 and 1)", cfg.Logging.SampleRate)
+	}
+
+	if err := validateTLS(&cfg.Main); err != nil {
+		return err
+	}
+
+	mimeTypes := make(map[string]string, len(cfg.Mime))
+	for ext, mimeType := range cfg.Mime {
+		if ext = strings.TrimPrefix(strings.ToLower(ext), "."); ext == "" || strings.ContainsAny(ext, "./\\") {
+			return fmt.Errorf("invalid mime extension: %q (expected an extension such as svg)", ext)
+		}
+		if _, _, err := mime.ParseMediaType(mimeType); err != nil {
+			return fmt.Errorf("invalid mime type for %s: %q: %w", ext, mimeType, err)
+		}
+		mimeTypes["."+ext] = mimeType
+	}
+	if cfg.Mime != nil {
+		cfg.Mime = mimeTypes
+	}
+
+	for _, origin := range cfg.CORS.AllowedOrigins {
+		if origin == "*" {
+			continue
+		}
+		if u, err := url.Parse(origin); err != nil || u.Scheme == "" || u.Host == "" || u.Path != "" {
+			return fmt.Errorf("invalid cors allowed_origins entry: %s (expected an origin such as https://example.com)",
+				origin)
+		}
+	}
+
+	// Validate custom 404 page for the SPA fallback
+	if cfg.SPA.NotFoundPage != "" {
+		info, err := os.Stat(cfg.SPA.NotFoundPage)
+		if err != nil {
+			return fmt.Errorf("cannot access not_found_page %s: %w", cfg.SPA.NotFoundPage, err)
+		}
+		if info.IsDir() {
+			return fmt.Errorf("not_found_page is a directory: %s", cfg.SPA.NotFoundPage)
+		}
+	}
+
 	// JWT mode validation
 	if cfg.JWTSecret != "" {
 		// JWT mode requires base_dir
@@ -222,6 +423,10 @@ func validateConfig(cfg *Config, source *configSource) error {
 		// Update base dir to absolute path
 		cfg.BaseDir = absPath
 
+		if err := validatePublicPaths(cfg.JWTAuth.PublicPaths, cfg.BaseDir); err != nil {
+			return err
+		}
+
 		// In JWT mode, directories configuration is not allowed
 		if len(cfg.Directories) > 0 {
 			// Generate context-aware error message
@@ -243,8 +448,15 @@ func validateConfig(cfg *Config, source *configSource) error {
 				"(or use JWT mode with --jwt-secret and --base-dir)")
 		}
 
+		// Expand glob sources into one mapping per matching directory
+		expanded, err := expandDirMappings(cfg.Directories)
+		if err != nil {
+			return err
+		}
+		cfg.Directories = expanded
+
 		// Validate and resolve all directory paths
-		virtualPaths := make(map[string]bool)
+		virtualPaths := make([]string, 0, len(cfg.Directories))
 		for i, dir := range cfg.Directories {
 			// Validate directory fields are not empty
 			if strings.TrimSpace(dir.Source) == "" {
@@ -289,13 +501,105 @@ func validateConfig(cfg *Config, source *configSource) error {
 				return fmt.Errorf("virtual path must start with /: %s", dir.Virtual)
 			}
 
-			// Check for duplicate virtual paths
-			if virtualPaths[dir.Virtual] {
-				return fmt.Errorf("duplicate virtual path: %s", dir.Virtual)
+			virtualPaths = append(virtualPaths, dir.Virtual)
+		}
+
+		if err := ValidateVirtualPaths(virtualPaths, !cfg.Main.DisjointVirtualPaths); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// expandDirMappings expands mappings with a glob source (e.g. "/srv/tenants/*")
+// into one mapping per matching directory. The virtual path may contain a
+// "{name}" placeholder that is replaced with the matched directory name;
+// otherwise the name is appended to the virtual path.
+func expandDirMappings(dirs []DirMapping) ([]DirMapping, error) {
+	result := make([]DirMapping, 0, len(dirs))
+	for _, dir := range dirs {
+		if !strings.ContainsAny(dir.Source, "*?[") {
+			result = append(result, dir)
+			continue
+		}
+
+		matches, err := filepath.Glob(dir.Source)
+		if err != nil {
+			return nil, fmt.Errorf("invalid glob pattern %s: %w", dir.Source, err)
+		}
+
+		found := 0
+		for _, match := range matches {
+			info, err := os.Stat(match)
+			if err != nil || !info.IsDir() {
+				log.Printf("Skipping glob match %s: not a directory", match)
+				continue
+			}
+
+			name := filepath.Base(match)
+			virtual := strings.ReplaceAll(dir.Virtual, "{name}", name)
+			if virtual == dir.Virtual {
+				virtual = path.Join(dir.Virtual, name)
 			}
-			virtualPaths[dir.Virtual] = true
+
+			// Keep the per-mapping options of the pattern
+			expanded := dir
+			expanded.Source = match
+			expanded.Virtual = virtual
+			result = append(result, expanded)
+			found++
+		}
+
+		if found == 0 {
+			return nil, fmt.Errorf("glob pattern matches no directories: %s", dir.Source)
 		}
 	}
 
+	return result, nil
+}
+
+// validatePublicPaths checks that every public path is a distinct virtual path
+// naming an existing directory below the base directory
+func validatePublicPaths(publicPaths []string, baseDir string) error {
+	for _, publicPath := range publicPaths {
+		if !strings.HasPrefix(publicPath, "/") || path.Clean(publicPath) == "/" {
+			return fmt.Errorf("public path must start with / and name a directory: %q", publicPath)
+		}
+		info, err := os.Stat(filepath.Join(baseDir, filepath.FromSlash(path.Clean(publicPath))))
+		if err != nil {
+			return fmt.Errorf("cannot access public path %s: %w", publicPath, err)
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("public path is not a directory: %s", publicPath)
+		}
+	}
+	if err := ValidateVirtualPaths(publicPaths, false); err != nil {
+		return fmt.Errorf("invalid public paths: %w", err)
+	}
+	return nil
+}
+
+// validateTLS checks that the certificate and key are configured together
+// and can be loaded, so that a broken setup fails at startup instead of on
+// the first connection
+func validateTLS(main *MainConfig) error {
+	if main.TLSCert == "" && main.TLSKey == "" {
+		if main.TLSAutoRedirect {
+			return fmt.Errorf("tls_auto_redirect requires tls_cert and tls_key")
+		}
+		return nil
+	}
+	if main.TLSCert == "" || main.TLSKey == "" {
+		return fmt.Errorf("tls_cert and tls_key must be set together")
+	}
+	for _, file := range []string{main.TLSCert, main.TLSKey} {
+		if _, err := os.Stat(file); err != nil {
+			return fmt.Errorf("cannot access TLS file %s: %w", file, err)
+		}
+	}
+	if _, err := tls.LoadX509KeyPair(main.TLSCert, main.TLSKey); err != nil {
+		return fmt.Errorf("invalid TLS certificate or key: %w", err)
+	}
 	return nil
 }
@@ -1,9 +1,12 @@
 package config
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestParseDirMapping(t *testing.T) {
@@ -84,4 +87,64 @@ func TestParseDirMapping(t *testing.T) {
 			assert.Equal(t, tt.wantVirtual, got.Virtual)
 		})
 	}
-}
\ No newline at end of file
+}
+
+func TestExpandDirMappingsGlob(t *testing.T) {
+	tenants := t.TempDir()
+	for _, name := range []string{"alpha", "beta"} {
+		require.NoError(t, os.Mkdir(filepath.Join(tenants, name), 0750))
+	}
+	// Files matching the glob are not exposed
+	require.NoError(t, os.WriteFile(filepath.Join(tenants, "README"), []byte("x"), 0600))
+
+	t.Run("placeholder in virtual path", func(t *testing.T) {
+		got, err := expandDirMappings([]DirMapping{
+			{Source: filepath.Join(tenants, "*"), Virtual: "/tenants/{name}"},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []DirMapping{
+			{Source: filepath.Join(tenants, "alpha"), Virtual: "/tenants/alpha"},
+			{Source: filepath.Join(tenants, "beta"), Virtual: "/tenants/beta"},
+		}, got)
+	})
+
+	t.Run("name appended without placeholder", func(t *testing.T) {
+		got, err := expandDirMappings([]DirMapping{
+			{Source: "/static", Virtual: "/static"},
+			{Source: filepath.Join(tenants, "a*"), Virtual: "/t"},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []DirMapping{
+			{Source: "/static", Virtual: "/static"},
+			{Source: filepath.Join(tenants, "alpha"), Virtual: "/t/alpha"},
+		}, got)
+	})
+
+	t.Run("no matching directories", func(t *testing.T) {
+		_, err := expandDirMappings([]DirMapping{
+			{Source: filepath.Join(tenants, "z*"), Virtual: "/t/{name}"},
+		})
+		assert.EqualError(t, err, "glob pattern matches no directories: "+filepath.Join(tenants, "z*"))
+	})
+
+	t.Run("expanded virtual path collides", func(t *testing.T) {
+		cfg := &Config{
+			Directories: []DirMapping{
+				{Source: filepath.Join(tenants, "alpha"), Virtual: "/tenants/beta"},
+				{Source: filepath.Join(tenants, "*"), Virtual: "/tenants/{name}"},
+			},
+		}
+		err := validateConfig(cfg, &configSource{})
+		assert.EqualError(t, err, "duplicate virtual path: /tenants/beta")
+	})
+
+	t.Run("expanded mappings pass validation", func(t *testing.T) {
+		cfg := &Config{
+			Directories: []DirMapping{
+				{Source: filepath.Join(tenants, "*"), Virtual: "/tenants/{name}"},
+			},
+		}
+		require.NoError(t, validateConfig(cfg, &configSource{}))
+		assert.Len(t, cfg.Directories, 2)
+	})
+}
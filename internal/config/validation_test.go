@@ -1,9 +1,16 @@
 package config
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"math/big"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/spf13/pflag"
 	"github.com/stretchr/testify/assert"
@@ -102,6 +109,28 @@ func TestValidateConfigValidDirectories(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+// TestValidateConfigPublicPaths tests the public paths of JWT mode
+func TestValidateConfigPublicPaths(t *testing.T) {
+	baseDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(baseDir, "public", "docs"), 0750))
+	require.NoError(t, os.WriteFile(filepath.Join(baseDir, "file.txt"), []byte("x"), 0600))
+
+	validate := func(publicPaths ...string) error {
+		return validateConfig(&Config{
+			JWTSecret: "test-secret-that-is-at-least-32-characters-long",
+			BaseDir:   baseDir,
+			JWTAuth:   JWTAuthConfig{PublicPaths: publicPaths},
+		}, &configSource{})
+	}
+
+	assert.NoError(t, validate("/public"))
+	assert.ErrorContains(t, validate("public"), "must start with /")
+	assert.ErrorContains(t, validate("/"), "must start with /")
+	assert.ErrorContains(t, validate("/missing"), "cannot access public path /missing")
+	assert.ErrorContains(t, validate("/file.txt"), "public path is not a directory")
+	assert.ErrorContains(t, validate("/public", "/public/docs"), "overlaps")
+}
+
 // TestLoadConfigWithEmptyFields tests that TOML configs with empty fields are rejected
 func TestLoadConfigWithEmptyFields(t *testing.T) {
 	tmpDir := t.TempDir()
@@ -169,6 +198,19 @@ virtual = "/test"
 `,
 			wantError: "directory mapping has empty 'source' field",
 		},
+		{
+			name: "invalid directory quota in TOML",
+			toml: `
+[main]
+listen = "127.0.0.1:3000"
+
+[[directories]]
+source = "/tmp"
+virtual = "/test"
+quota = "lots"
+`,
+			wantError: "error parsing quota of directory /test: invalid quota format: lots",
+		},
 	}
 	
 	for _, tc := range testCases {
@@ -195,4 +237,121 @@ virtual = "/test"
 			assert.Contains(t, err.Error(), tc.wantError)
 		})
 	}
-}
\ No newline at end of file
+}
+
+func TestValidateConfigDisabledOperations(t *testing.T) {
+	cfg := &Config{Directories: []DirMapping{{Source: t.TempDir(), Virtual: "/data"}}}
+	cfg.Main.DisabledOperations = []string{"upload", "delete"}
+	require.NoError(t, validateConfig(cfg, &configSource{}))
+
+	cfg.Main.DisabledOperations = []string{"rename"}
+	assert.ErrorContains(t, validateConfig(cfg, &configSource{}), "invalid disabled_operations entry: rename")
+}
+
+func TestValidateConfigLogging(t *testing.T) {
+	cfg := &Config{Directories: []DirMapping{{Source: t.TempDir(), Virtual: "/data"}}}
+	cfg.Logging = LoggingConfig{Format: "json", Level: "debug"}
+	require.NoError(t, validateConfig(cfg, &configSource{}))
+
+	cfg.Logging = LoggingConfig{Format: "xml"}
+	assert.ErrorContains(t, validateConfig(cfg, &configSource{}), "invalid logging format: xml")
+
+	cfg.Logging = LoggingConfig{Level: "trace"}
+	assert.ErrorContains(t, validateConfig(cfg, &configSource{}), "invalid logging level: trace")
+}
+
+// writeTestCertificate writes a self-signed certificate and its key to dir
+func writeTestCertificate(t *testing.T, dir string) (certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	require.NoError(t, os.WriteFile(certFile, certPEM, 0600))
+	require.NoError(t, os.WriteFile(keyFile, keyPEM, 0600))
+	return certFile, keyFile
+}
+
+func TestValidateConfigTLS(t *testing.T) {
+	tmpDir := t.TempDir()
+	certFile, keyFile := writeTestCertificate(t, tmpDir)
+	missing := filepath.Join(tmpDir, "missing.pem")
+
+	testCases := []struct {
+		name      string
+		main      MainConfig
+		wantError string
+	}{
+		{name: "disabled", main: MainConfig{}},
+		{name: "valid", main: MainConfig{TLSCert: certFile, TLSKey: keyFile, TLSAutoRedirect: true}},
+		{name: "only cert", main: MainConfig{TLSCert: certFile}, wantError: "tls_cert and tls_key must be set together"},
+		{name: "only key", main: MainConfig{TLSKey: keyFile}, wantError: "tls_cert and tls_key must be set together"},
+		{name: "missing cert", main: MainConfig{TLSCert: missing, TLSKey: keyFile}, wantError: "cannot access TLS file"},
+		{name: "missing key", main: MainConfig{TLSCert: certFile, TLSKey: missing}, wantError: "cannot access TLS file"},
+		{name: "key as cert", main: MainConfig{TLSCert: keyFile, TLSKey: keyFile}, wantError: "invalid TLS certificate"},
+		{
+			name:      "redirect without TLS",
+			main:      MainConfig{TLSAutoRedirect: true},
+			wantError: "tls_auto_redirect requires tls_cert and tls_key",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := &Config{Main: tc.main, Directories: []DirMapping{{Source: tmpDir, Virtual: "/data"}}}
+			err := validateConfig(cfg, &configSource{})
+			if tc.wantError == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tc.wantError)
+		})
+	}
+}
+
+func TestValidateConfigCORS(t *testing.T) {
+	cfg := &Config{Directories: []DirMapping{{Source: t.TempDir(), Virtual: "/data"}}}
+	cfg.CORS.AllowedOrigins = []string{"https://app.example.com", "http://localhost:5173", "*"}
+	require.NoError(t, validateConfig(cfg, &configSource{}))
+
+	for _, origin := range []string{"app.example.com", "https://app.example.com/path", "https://"} {
+		cfg.CORS.AllowedOrigins = []string{origin}
+		assert.ErrorContains(t, validateConfig(cfg, &configSource{}), "invalid cors allowed_origins entry", origin)
+	}
+}
+
+func TestValidateConfigMime(t *testing.T) {
+	cfg := &Config{Directories: []DirMapping{{Source: t.TempDir(), Virtual: "/data"}}}
+	cfg.Mime = map[string]string{"HEIC": "image/heic", ".md": "text/plain; charset=utf-8"}
+	require.NoError(t, validateConfig(cfg, &configSource{}))
+	assert.Equal(t, map[string]string{".heic": "image/heic", ".md": "text/plain; charset=utf-8"}, cfg.Mime)
+
+	cfg.Mime = map[string]string{"svg": "not a type"}
+	assert.ErrorContains(t, validateConfig(cfg, &configSource{}), "invalid mime type for svg")
+
+	cfg.Mime = map[string]string{".": "text/plain"}
+	assert.ErrorContains(t, validateConfig(cfg, &configSource{}), "invalid mime extension")
+}
+
+func TestValidateConfigTrashDir(t *testing.T) {
+	cfg := &Config{Directories: []DirMapping{{Source: t.TempDir(), Virtual: "/data"}}}
+	cfg.Main.TrashDir = ".trash"
+	require.NoError(t, validateConfig(cfg, &configSource{}))
+
+	for _, trashDir := range []string{".", "..", "a/b", `a\b`} {
+		cfg.Main.TrashDir = trashDir
+		assert.ErrorContains(t, validateConfig(cfg, &configSource{}), "invalid trash_dir", trashDir)
+	}
+}
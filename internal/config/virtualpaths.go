@@ -0,0 +1,35 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// ValidateVirtualPaths checks the virtual paths of a set of directory
+// mappings, from the configuration or from a JWT token. Paths must be unique
+// after normalization; unless allowNested is set, no path may also lie within
+// another one, which includes a mapping of "/" next to others.
+func ValidateVirtualPaths(virtualPaths []string, allowNested bool) error {
+	cleaned := make([]string, len(virtualPaths))
+	for i, virtualPath := range virtualPaths {
+		cleaned[i] = path.Clean("/" + strings.TrimSpace(virtualPath))
+	}
+
+	for i := range cleaned {
+		for j := i + 1; j < len(cleaned); j++ {
+			a, b := cleaned[i], cleaned[j]
+			if a == b {
+				return fmt.Errorf("duplicate virtual path: %s", a)
+			}
+			if allowNested {
+				continue
+			}
+			if a == "/" || b == "/" || strings.HasPrefix(b, a+"/") || strings.HasPrefix(a, b+"/") {
+				return fmt.Errorf("virtual path %s overlaps %s", a, b)
+			}
+		}
+	}
+
+	return nil
+}
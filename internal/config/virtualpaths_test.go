@@ -0,0 +1,47 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateVirtualPaths(t *testing.T) {
+	tests := []struct {
+		name        string
+		paths       []string
+		allowNested bool
+		expectError string
+	}{
+		{name: "distinct paths", paths: []string{"/docs", "/docs2", "/media"}},
+		{name: "single root", paths: []string{"/"}},
+		{name: "empty set", paths: nil},
+		{name: "duplicate", paths: []string{"/docs", "/media", "/docs"}, expectError: "duplicate virtual path: /docs"},
+		{
+			name:        "duplicate after normalization",
+			paths:       []string{"/docs/", "docs"},
+			expectError: "duplicate virtual path: /docs",
+		},
+		{
+			name:        "duplicate with nesting allowed",
+			paths:       []string{"/docs", "/docs"},
+			allowNested: true,
+			expectError: "duplicate virtual path: /docs",
+		},
+		{name: "nested", paths: []string{"/docs", "/docs/sub"}, expectError: "virtual path /docs overlaps /docs/sub"},
+		{name: "nested reversed", paths: []string{"/a/b", "/a"}, expectError: "virtual path /a/b overlaps /a"},
+		{name: "root next to others", paths: []string{"/docs", "/"}, expectError: "virtual path /docs overlaps /"},
+		{name: "nesting allowed", paths: []string{"/", "/docs", "/docs/sub"}, allowNested: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateVirtualPaths(tt.paths, tt.allowNested)
+			if tt.expectError != "" {
+				assert.EqualError(t, err, tt.expectError)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package filesystem
+
+import (
+	"fmt"
+	"os"
+	"path"
+
+	"dendrite/internal/format"
+)
+
+// prepareTransfers resolves every source of a batch that is moved or copied
+// into destDir one by one. Sources that cannot be transferred, e.g. because
+// their name already exists in destDir, get their error in the results and
+// no entry in the returned transfers. An unusable destination fails the
+// whole batch.
+func (m *Manager) prepareTransfers(verb string, sources []string, destDir string) (
+	[]BatchMoveResult, []*batchMove, error) {
+	if len(sources) == 0 {
+		return nil, nil, fmt.Errorf("no sources given")
+	}
+
+	destPhysical, err := m.resolvePath(destDir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid destination path: %w", err)
+	}
+	if !m.isPathSafe(destPhysical) {
+		return nil, nil, fmt.Errorf("access denied: path outside managed directory")
+	}
+	if info, err := os.Stat(destPhysical); err == nil && !info.IsDir() {
+		return nil, nil, fmt.Errorf("destination is not a directory: %s", destDir)
+	}
+
+	results := make([]BatchMoveResult, len(sources))
+	transfers := make([]*batchMove, len(sources))
+	claimed := make(map[string]string, len(sources))
+	for i, source := range sources {
+		results[i] = BatchMoveResult{Source: source, Dest: path.Join("/", destDir, path.Base(source))}
+		transfer, err := m.validateTransfer(verb, source, results[i].Dest)
+		if err == nil {
+			if other, ok := claimed[transfer.physicalDest]; ok {
+				err = fmt.Errorf("destination already exists: %s is also the destination of %s", results[i].Dest, other)
+			}
+		}
+		if err != nil {
+			results[i].Error = err.Error()
+			continue
+		}
+		claimed[transfer.physicalDest] = source
+		transfers[i] = &transfer
+	}
+	return results, transfers, nil
+}
+
+// MoveInto moves every source into the directory destDir, keeping the names.
+// Each source is moved on its own like with MoveFile; sources whose name
+// already exists in destDir are not moved, and failures are only reported
+// in the results.
+func (m *Manager) MoveInto(sources []string, destDir string) ([]BatchMoveResult, error) {
+	results, transfers, err := m.prepareTransfers("move", sources, destDir)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, transfer := range transfers {
+		if transfer == nil {
+			continue
+		}
+		if err := m.MoveFile(transfer.virtualSource, transfer.virtualDest); err != nil {
+			results[i].Error = err.Error()
+		}
+	}
+	return results, nil
+}
+
+// CopyInto copies every source into the directory destDir, keeping the names.
+// The quota is checked once for the combined size of all sources, so that a
+// batch exceeding it copies nothing. Otherwise each source is copied on its
+// own like with CopyFile; sources whose name already exists in destDir are
+// not copied, and failures are only reported in the results.
+func (m *Manager) CopyInto(sources []string, destDir string) ([]BatchMoveResult, error) {
+	results, transfers, err := m.prepareTransfers("copy", sources, destDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var copySize int64
+	for _, transfer := range transfers {
+		if transfer == nil {
+			continue
+		}
+		info, err := os.Stat(transfer.physicalSource)
+		if err != nil {
+			continue // Reported by CopyFile
+		}
+		if info.IsDir() {
+			size, _ := m.calculateDirectorySize(transfer.physicalSource)
+			copySize += size
+		} else {
+			copySize += info.Size()
+		}
+	}
+
+	if m.quotaApplies(destDir) {
+		used, limit, err := m.quotaState(destDir)
+		if err != nil {
+			return results, fmt.Errorf("failed to calculate current usage: %w", err)
+		}
+		if used+copySize > limit {
+			m.recordQuotaDenial("copy", destDir, used, copySize, limit)
+			return results, fmt.Errorf("copy would exceed quota limit (current: %s, copy size: %s, limit: %s)",
+				format.FileSize(used), format.FileSize(copySize), format.FileSize(limit))
+		}
+	}
+
+	for i, transfer := range transfers {
+		if transfer == nil {
+			continue
+		}
+		if err := m.CopyFile(transfer.virtualSource, transfer.virtualDest); err != nil {
+			results[i].Error = err.Error()
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,91 @@
+package filesystem
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMoveInto(t *testing.T) {
+	t.Run("collision and partial failure", func(t *testing.T) {
+		m, tmpDir := setupMoveBatch(t)
+		results, err := m.MoveInto([]string{"/test/a.txt", "/test/b.txt", "/test/missing.txt", "/test/sub"},
+			"/test/target")
+		require.NoError(t, err)
+		require.Len(t, results, 4)
+
+		assert.Empty(t, results[0].Error)
+		assert.Equal(t, "/test/target/a.txt", results[0].Dest)
+		assert.Contains(t, results[1].Error, "already exists")
+		assert.Contains(t, results[2].Error, "not found")
+		assert.Empty(t, results[3].Error)
+
+		assert.FileExists(t, filepath.Join(tmpDir, "target", "a.txt"))
+		assert.FileExists(t, filepath.Join(tmpDir, "target", "sub", "a.txt"))
+		assert.FileExists(t, filepath.Join(tmpDir, "b.txt"))
+		content, err := os.ReadFile(filepath.Join(tmpDir, "target", "b.txt"))
+		require.NoError(t, err)
+		assert.Equal(t, "target/b.txt", string(content))
+	})
+
+	t.Run("sources sharing a name", func(t *testing.T) {
+		m, tmpDir := setupMoveBatch(t)
+		results, err := m.MoveInto([]string{"/test/a.txt", "/test/sub/a.txt"}, "/test/new")
+		require.NoError(t, err)
+		assert.Empty(t, results[0].Error)
+		assert.Contains(t, results[1].Error, "already exists")
+		assert.FileExists(t, filepath.Join(tmpDir, "sub", "a.txt"))
+	})
+
+	t.Run("destination is a file", func(t *testing.T) {
+		m, _ := setupMoveBatch(t)
+		_, err := m.MoveInto([]string{"/test/a.txt"}, "/test/b.txt")
+		assert.ErrorContains(t, err, "not a directory")
+	})
+}
+
+func TestCopyInto(t *testing.T) {
+	t.Run("collision and partial failure", func(t *testing.T) {
+		m, tmpDir := setupMoveBatch(t)
+		results, err := m.CopyInto([]string{"/test/a.txt", "/test/b.txt", "/test/missing.txt", "/test/sub"},
+			"/test/target")
+		require.NoError(t, err)
+		require.Len(t, results, 4)
+
+		assert.Empty(t, results[0].Error)
+		assert.Contains(t, results[1].Error, "already exists")
+		assert.Contains(t, results[2].Error, "not found")
+		assert.Empty(t, results[3].Error)
+
+		assert.FileExists(t, filepath.Join(tmpDir, "a.txt"))
+		assert.FileExists(t, filepath.Join(tmpDir, "target", "a.txt"))
+		assert.FileExists(t, filepath.Join(tmpDir, "target", "sub", "a.txt"))
+	})
+
+	t.Run("into itself", func(t *testing.T) {
+		m, tmpDir := setupMoveBatch(t)
+		results, err := m.CopyInto([]string{"/test/sub"}, "/test/sub/nested")
+		require.NoError(t, err)
+		assert.Contains(t, results[0].Error, "cannot copy /test/sub into itself")
+		assert.NoDirExists(t, filepath.Join(tmpDir, "sub", "nested"))
+	})
+
+	t.Run("quota is checked for all sources", func(t *testing.T) {
+		m, tmpDir := setupMoveBatch(t)
+		used, err := m.calculateDirectorySize(tmpDir)
+		require.NoError(t, err)
+		// Room for a.txt (5 bytes) and b.txt (5 bytes) on their own, but not for both
+		m.Config.QuotaBytes = used + 8
+
+		_, err = m.CopyInto([]string{"/test/a.txt", "/test/b.txt"}, "/test/new")
+		assert.ErrorContains(t, err, "would exceed quota")
+		assert.NoDirExists(t, filepath.Join(tmpDir, "new"))
+
+		results, err := m.CopyInto([]string{"/test/a.txt"}, "/test/new")
+		require.NoError(t, err)
+		assert.Empty(t, results[0].Error)
+	})
+}
@@ -0,0 +1,73 @@
+package filesystem
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Modes for detecting names that differ only in case
+const (
+	// CaseCollisionsAuto reports a collision only where the filesystem
+	// itself treats both names as the same file (default)
+	CaseCollisionsAuto = "auto"
+	// CaseCollisionsAlways reports a collision on every filesystem
+	CaseCollisionsAlways = "always"
+	// CaseCollisionsNever disables the detection
+	CaseCollisionsNever = "never"
+)
+
+// checkCaseCollision fails when the directory of physicalPath holds an entry
+// whose name differs from the target name only in case. An entry that is the
+// same file as ignore, such as the source of a case-only rename, is allowed.
+func (m *Manager) checkCaseCollision(physicalPath, virtualPath, ignore string) error {
+	mode := m.Config.Main.CaseCollisions
+	if mode == CaseCollisionsNever {
+		return nil
+	}
+	// Without an entry under the target name the filesystem treats no other
+	// name as the same file, so the directory need not be read
+	if mode != CaseCollisionsAlways {
+		if _, err := os.Lstat(physicalPath); err != nil {
+			return nil
+		}
+	}
+
+	dir, name := filepath.Split(physicalPath)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil // A missing directory holds no colliding entries
+	}
+
+	for _, entry := range entries {
+		if entry.Name() == name || !strings.EqualFold(entry.Name(), name) {
+			continue
+		}
+		existing := filepath.Join(dir, entry.Name())
+		if ignore != "" && sameFile(existing, ignore) {
+			continue
+		}
+		// On a case-insensitive filesystem the target name resolves to the
+		// existing entry
+		if mode != CaseCollisionsAlways && !sameFile(existing, physicalPath) {
+			continue
+		}
+		return fmt.Errorf("already exists with different case: %s", path.Join(path.Dir(virtualPath), entry.Name()))
+	}
+	return nil
+}
+
+// sameFile reports whether both paths refer to the same directory entry
+func sameFile(a, b string) bool {
+	infoA, err := os.Lstat(a)
+	if err != nil {
+		return false
+	}
+	infoB, err := os.Lstat(b)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(infoA, infoB)
+}
@@ -0,0 +1,97 @@
+package filesystem
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"dendrite/internal/config"
+)
+
+func TestCaseCollisions(t *testing.T) {
+	setup := func(t *testing.T, mode string) (*Manager, string) {
+		tempDir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(tempDir, "File.txt"), []byte("original"), 0600))
+		require.NoError(t, os.WriteFile(filepath.Join(tempDir, "other.txt"), []byte("other"), 0600))
+		return New(&config.Config{
+			Main:        config.MainConfig{CaseCollisions: mode},
+			Directories: []config.DirMapping{{Source: tempDir, Virtual: "/test"}},
+		}), tempDir
+	}
+
+	t.Run("rename onto a name differing in case", func(t *testing.T) {
+		mgr, tempDir := setup(t, CaseCollisionsAlways)
+
+		err := mgr.MoveFile("/test/other.txt", "/test/file.txt")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "already exists with different case: /test/File.txt")
+
+		content, err := os.ReadFile(filepath.Join(tempDir, "File.txt"))
+		require.NoError(t, err)
+		assert.Equal(t, "original", string(content))
+	})
+
+	t.Run("case-only rename of the same file", func(t *testing.T) {
+		mgr, tempDir := setup(t, CaseCollisionsAlways)
+
+		require.NoError(t, mgr.MoveFile("/test/File.txt", "/test/file.txt"))
+		content, err := os.ReadFile(filepath.Join(tempDir, "file.txt"))
+		require.NoError(t, err)
+		assert.Equal(t, "original", string(content))
+	})
+
+	t.Run("copy onto a name differing in case", func(t *testing.T) {
+		mgr, _ := setup(t, CaseCollisionsAlways)
+
+		err := mgr.CopyFile("/test/other.txt", "/test/FILE.txt")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "already exists")
+	})
+
+	t.Run("upload with a name differing in case", func(t *testing.T) {
+		mgr, _ := setup(t, CaseCollisionsAlways)
+
+		_, err := mgr.UploadFile("/test", "file.txt", strings.NewReader("new"), 3)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "already exists")
+
+		_, err = mgr.StreamFile("/test/FILE.TXT", strings.NewReader("new"), 3)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "already exists")
+
+		// The exact name is a regular overwrite
+		result, err := mgr.UploadFile("/test", "File.txt", strings.NewReader("new"), 3)
+		require.NoError(t, err)
+		assert.False(t, result.Created)
+	})
+
+	t.Run("detection disabled", func(t *testing.T) {
+		mgr, tempDir := setup(t, CaseCollisionsNever)
+
+		_, err := mgr.UploadFile("/test", "file.txt", strings.NewReader("new"), 3)
+		require.NoError(t, err)
+
+		// The original survives wherever the filesystem is case-sensitive
+		if !sameFile(filepath.Join(tempDir, "File.txt"), filepath.Join(tempDir, "file.txt")) {
+			content, err := os.ReadFile(filepath.Join(tempDir, "File.txt"))
+			require.NoError(t, err)
+			assert.Equal(t, "original", string(content))
+		}
+	})
+
+	t.Run("auto follows the filesystem", func(t *testing.T) {
+		mgr, tempDir := setup(t, "")
+		insensitive := sameFile(filepath.Join(tempDir, "File.txt"), filepath.Join(tempDir, "FILE.TXT"))
+
+		err := mgr.MoveFile("/test/other.txt", "/test/file.txt")
+		if insensitive {
+			assert.ErrorContains(t, err, "already exists")
+		} else {
+			assert.NoError(t, err)
+		}
+	})
+}
@@ -0,0 +1,84 @@
+package filesystem
+
+import (
+	"crypto/md5" // #nosec G501 - MD5 is offered for compatibility, not for security
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+)
+
+// Checksum algorithms
+const (
+	ChecksumSHA256 = "sha256"
+	ChecksumMD5    = "md5"
+)
+
+// newChecksumHash returns the hash of a checksum algorithm
+func newChecksumHash(algo string) (hash.Hash, error) {
+	switch algo {
+	case ChecksumSHA256:
+		return sha256.New(), nil
+	case ChecksumMD5:
+		return md5.New(), nil // #nosec G401 - see import
+	default:
+		return nil, fmt.Errorf("invalid checksum algorithm: %s (expected sha256 or md5)", algo)
+	}
+}
+
+// ValidateChecksumAlgorithm fails for algorithms Checksum does not support
+func ValidateChecksumAlgorithm(algo string) error {
+	_, err := newChecksumHash(algo)
+	return err
+}
+
+// Checksum returns the hex digest of the content of the regular file at the
+// virtual path. The file is streamed through the hash, so its size does not
+// matter for the memory used.
+func (m *Manager) Checksum(virtualPath, algo string) (string, error) {
+	if err := ValidateChecksumAlgorithm(algo); err != nil {
+		return "", err
+	}
+
+	physicalPath, err := m.resolvePath(virtualPath)
+	if err != nil {
+		return "", err
+	}
+
+	if !m.isPathSafe(physicalPath) {
+		return "", fmt.Errorf("access denied: path outside managed directory")
+	}
+
+	info, err := os.Stat(physicalPath)
+	if err != nil {
+		return "", fmt.Errorf("file not found: %s", virtualPath)
+	}
+	if !info.Mode().IsRegular() {
+		return "", fmt.Errorf("not a regular file: %s", virtualPath)
+	}
+
+	return fileChecksum(physicalPath, algo)
+}
+
+// fileChecksum streams a file through the hash of algo
+func fileChecksum(physicalPath, algo string) (string, error) {
+	h, err := newChecksumHash(algo)
+	if err != nil {
+		return "", err
+	}
+
+	f, err := os.Open(physicalPath) // #nosec G304 - path is validated by the caller
+	if err != nil {
+		return "", fmt.Errorf("failed to read file: %w", err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("failed to read file: %w", err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,59 @@
+package filesystem
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"dendrite/internal/config"
+)
+
+func TestChecksum(t *testing.T) {
+	tmpDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "hello.txt"), []byte("hello world"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "empty.txt"), nil, 0600))
+	require.NoError(t, os.Mkdir(filepath.Join(tmpDir, "dir"), 0750))
+
+	mgr := New(&config.Config{
+		Directories: []config.DirMapping{{Source: tmpDir, Virtual: "/data"}},
+	})
+
+	t.Run("known digests", func(t *testing.T) {
+		sum, err := mgr.Checksum("/data/hello.txt", ChecksumSHA256)
+		require.NoError(t, err)
+		assert.Equal(t, "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9", sum)
+
+		sum, err = mgr.Checksum("/data/hello.txt", ChecksumMD5)
+		require.NoError(t, err)
+		assert.Equal(t, "5eb63bbbe01eeed093cb22bb8f5acdc3", sum)
+
+		sum, err = mgr.Checksum("/data/empty.txt", ChecksumSHA256)
+		require.NoError(t, err)
+		assert.Equal(t, "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", sum)
+	})
+
+	t.Run("stat includes the requested digest", func(t *testing.T) {
+		stat, err := mgr.StatFileWithOptions("/data/hello.txt", StatOptions{Checksum: ChecksumSHA256})
+		require.NoError(t, err)
+		assert.Equal(t, "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9", stat.SHA256)
+		assert.Empty(t, stat.MD5)
+
+		stat, err = mgr.StatFileWithOptions("/data/dir", StatOptions{Checksum: ChecksumSHA256})
+		require.NoError(t, err)
+		assert.Empty(t, stat.SHA256)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		_, err := mgr.Checksum("/data/hello.txt", "sha1")
+		assert.ErrorContains(t, err, "invalid checksum algorithm")
+
+		_, err = mgr.Checksum("/data/dir", ChecksumSHA256)
+		assert.ErrorContains(t, err, "not a regular file")
+
+		_, err = mgr.Checksum("/data/missing.txt", ChecksumSHA256)
+		assert.ErrorContains(t, err, "not found")
+	})
+}
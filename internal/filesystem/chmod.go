@@ -0,0 +1,115 @@
+package filesystem
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// chmodMask holds the permission bits that may be changed. Setuid, setgid
+// and sticky bits are never set through the API.
+const chmodMask os.FileMode = 0o777
+
+// ParseMode parses an octal permission string such as "0640" or "750"
+func ParseMode(value string) (os.FileMode, error) {
+	value = strings.TrimPrefix(strings.TrimSpace(value), "0o")
+	mode, err := strconv.ParseUint(value, 8, 32)
+	if err != nil || value == "" {
+		return 0, fmt.Errorf("invalid mode: %q (expected octal permissions like 0640)", value)
+	}
+	if os.FileMode(mode)&^chmodMask != 0 {
+		return 0, fmt.Errorf("invalid mode: %04o (only permission bits 0777 may be set)", mode)
+	}
+	return os.FileMode(mode), nil
+}
+
+// DirModeFor derives a directory mode from a file mode by adding the execute
+// bit wherever the read bit is set, so that 0640 becomes 0750
+func DirModeFor(fileMode os.FileMode) os.FileMode {
+	return fileMode | (fileMode&0o444)>>2
+}
+
+// Chmod changes the permissions of a file or directory and returns the number
+// of entries whose mode changed. Files receive fileMode and directories
+// dirMode. With recursive, everything below a directory is changed as well;
+// symbolic links are skipped so that their targets are never modified.
+func (m *Manager) Chmod(virtualPath string, fileMode, dirMode os.FileMode, recursive bool) (int, error) {
+	if fileMode&^chmodMask != 0 || dirMode&^chmodMask != 0 {
+		return 0, fmt.Errorf("invalid mode: only permission bits 0777 may be set")
+	}
+
+	physicalPath, err := m.resolvePath(virtualPath)
+	if err != nil {
+		return 0, err
+	}
+	if physicalPath == "" || !m.isPathSafe(physicalPath) {
+		return 0, fmt.Errorf("access denied: path outside managed directory")
+	}
+
+	info, err := os.Lstat(physicalPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, fmt.Errorf("file not found: %s", virtualPath)
+		}
+		return 0, err
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		return 0, fmt.Errorf("access denied: cannot change permissions of a symbolic link")
+	}
+
+	type entry struct {
+		path  string
+		isDir bool
+		mode  os.FileMode
+	}
+	entries := []entry{{physicalPath, info.IsDir(), info.Mode().Perm()}}
+
+	if recursive && info.IsDir() {
+		err := filepath.WalkDir(physicalPath, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if p == physicalPath || d.Type()&fs.ModeSymlink != 0 {
+				return nil
+			}
+			fi, err := d.Info()
+			if err != nil {
+				return err
+			}
+			entries = append(entries, entry{p, d.IsDir(), fi.Mode().Perm()})
+			return nil
+		})
+		if err != nil {
+			if os.IsPermission(err) {
+				return 0, fmt.Errorf("access denied: %v", err)
+			}
+			return 0, fmt.Errorf("failed to walk directory: %w", err)
+		}
+	}
+
+	// Children are changed before their parents, so that a restrictive
+	// directory mode cannot lock the walk out of the tree
+	changed := 0
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+		mode := fileMode
+		if e.isDir {
+			mode = dirMode
+		}
+		if e.mode == mode {
+			continue
+		}
+		if err := os.Chmod(e.path, mode); err != nil {
+			if os.IsPermission(err) {
+				return changed, fmt.Errorf("access denied: %v", err)
+			}
+			return changed, fmt.Errorf("failed to change permissions: %w", err)
+		}
+		changed++
+	}
+
+	return changed, nil
+}
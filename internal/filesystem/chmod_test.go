@@ -0,0 +1,97 @@
+package filesystem
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"dendrite/internal/config"
+)
+
+func TestParseMode(t *testing.T) {
+	for input, want := range map[string]os.FileMode{"0640": 0o640, "750": 0o750, "0o600": 0o600} {
+		mode, err := ParseMode(input)
+		require.NoError(t, err, input)
+		assert.Equal(t, want, mode, input)
+	}
+	for _, input := range []string{"", "rwx", "0999", "4755", "01777"} {
+		_, err := ParseMode(input)
+		assert.Error(t, err, input)
+	}
+
+	assert.Equal(t, os.FileMode(0o750), DirModeFor(0o640))
+	assert.Equal(t, os.FileMode(0o755), DirModeFor(0o644))
+	assert.Equal(t, os.FileMode(0o700), DirModeFor(0o600))
+}
+
+func TestChmodRecursive(t *testing.T) {
+	tempDir := t.TempDir()
+	tree := filepath.Join(tempDir, "tree")
+	require.NoError(t, os.MkdirAll(filepath.Join(tree, "a", "b"), 0700))
+	for _, file := range []string{"top.sh", "a/one.txt", "a/b/two.txt"} {
+		require.NoError(t, os.WriteFile(filepath.Join(tree, file), []byte(file), 0600))
+	}
+	outside := filepath.Join(t.TempDir(), "outside.txt")
+	require.NoError(t, os.WriteFile(outside, []byte("x"), 0600))
+	require.NoError(t, os.Symlink(outside, filepath.Join(tree, "link")))
+
+	mgr := New(&config.Config{
+		Directories: []config.DirMapping{{Source: tempDir, Virtual: "/data"}},
+	})
+
+	mode := func(rel string) os.FileMode {
+		info, err := os.Stat(filepath.Join(tree, rel))
+		require.NoError(t, err)
+		return info.Mode().Perm()
+	}
+
+	t.Run("non-recursive only changes the target", func(t *testing.T) {
+		changed, err := mgr.Chmod("/data/tree", 0o644, 0o755, false)
+		require.NoError(t, err)
+		assert.Equal(t, 1, changed)
+		assert.Equal(t, os.FileMode(0o755), mode(""))
+		assert.Equal(t, os.FileMode(0o700), mode("a"))
+	})
+
+	t.Run("recursive applies file and directory modes", func(t *testing.T) {
+		changed, err := mgr.Chmod("/data/tree", 0o640, 0o750, true)
+		require.NoError(t, err)
+		// tree, a, a/b and three files; the symlink is skipped
+		assert.Equal(t, 6, changed)
+		for _, dir := range []string{"", "a", "a/b"} {
+			assert.Equal(t, os.FileMode(0o750), mode(dir), dir)
+		}
+		for _, file := range []string{"top.sh", "a/one.txt", "a/b/two.txt"} {
+			assert.Equal(t, os.FileMode(0o640), mode(file), file)
+		}
+
+		info, err := os.Stat(outside)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o600), info.Mode().Perm(), "symlink target must not change")
+	})
+
+	t.Run("unchanged entries are not counted", func(t *testing.T) {
+		changed, err := mgr.Chmod("/data/tree", 0o640, 0o750, true)
+		require.NoError(t, err)
+		assert.Equal(t, 0, changed)
+	})
+
+	t.Run("restrictive directory mode", func(t *testing.T) {
+		changed, err := mgr.Chmod("/data/tree", 0o600, 0o700, true)
+		require.NoError(t, err)
+		assert.Equal(t, 6, changed)
+		assert.Equal(t, os.FileMode(0o600), mode("a/b/two.txt"))
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		_, err := mgr.Chmod("/data/missing", 0o640, 0o750, true)
+		assert.ErrorContains(t, err, "not found")
+		_, err = mgr.Chmod("/data/tree/link", 0o640, 0o750, false)
+		assert.ErrorContains(t, err, "access denied")
+		_, err = mgr.Chmod("/data/tree", 0o4755, 0o750, false)
+		assert.ErrorContains(t, err, "invalid mode")
+	})
+}
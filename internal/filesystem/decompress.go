@@ -0,0 +1,99 @@
+package filesystem
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"dendrite/internal/config"
+	"dendrite/internal/format"
+)
+
+// DefaultMaxCompressionRatio is used when decompression.max_ratio is not configured
+const DefaultMaxCompressionRatio = 1000
+
+// ratioFloor is the decompressed size below which the ratio is not checked.
+// Small payloads of repetitive content legitimately compress very well, and
+// decompressors read ahead, so early ratios are not meaningful.
+const ratioFloor = 1 << 20
+
+// errDecompressionLimit is wrapped by all errors reporting that compressed
+// content expands beyond the configured limits
+var errDecompressionLimit = errors.New("decompression limit exceeded")
+
+// DecompressionLimits bounds the output of decompressing uploads and
+// archives to defuse compression bombs
+type DecompressionLimits struct {
+	// MaxSize is the maximum decompressed size in bytes, zero for unlimited
+	MaxSize int64
+	// MaxRatio is the maximum ratio of decompressed to compressed size, zero
+	// for unlimited
+	MaxRatio float64
+}
+
+// DecompressionLimitsFor returns the limits configured in cfg
+func DecompressionLimitsFor(cfg *config.Config) DecompressionLimits {
+	limits := DecompressionLimits{MaxSize: cfg.Decompression.MaxSizeBytes, MaxRatio: cfg.Decompression.MaxRatio}
+	if limits.MaxRatio == 0 {
+		limits.MaxRatio = DefaultMaxCompressionRatio
+	} else if limits.MaxRatio < 0 {
+		limits.MaxRatio = 0
+	}
+	return limits
+}
+
+// check fails when decompressed bytes produced from compressed input bytes
+// exceed the limits
+func (l DecompressionLimits) check(compressed, decompressed int64) error {
+	if l.MaxSize > 0 && decompressed > l.MaxSize {
+		return fmt.Errorf("%w: content expands beyond %s", errDecompressionLimit, format.FileSize(l.MaxSize))
+	}
+	if l.MaxRatio > 0 && decompressed > ratioFloor && float64(decompressed) > l.MaxRatio*float64(compressed) {
+		return fmt.Errorf("%w: compression ratio above %g", errDecompressionLimit, l.MaxRatio)
+	}
+	return nil
+}
+
+// Reader decompresses compressed with open and enforces the limits on the
+// result. Reads fail with a decompression limit error once exceeded.
+func (l DecompressionLimits) Reader(compressed io.Reader, open func(io.Reader) (io.Reader, error)) (io.Reader, error) {
+	in := &countingReader{r: compressed}
+	r, err := open(in)
+	if err != nil {
+		return nil, err
+	}
+	if l.MaxSize <= 0 && l.MaxRatio <= 0 {
+		return r, nil
+	}
+	return &limitedDecompressor{r: r, in: in, limits: l}, nil
+}
+
+// countingReader counts the bytes read from r
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// limitedDecompressor checks the decompressed output of r against the
+// compressed input counted by in
+type limitedDecompressor struct {
+	r      io.Reader
+	in     *countingReader
+	limits DecompressionLimits
+	out    int64
+}
+
+func (d *limitedDecompressor) Read(p []byte) (int, error) {
+	n, err := d.r.Read(p)
+	d.out += int64(n)
+	if lerr := d.limits.check(d.in.n, d.out); lerr != nil {
+		return n, lerr
+	}
+	return n, err
+}
@@ -0,0 +1,50 @@
+package filesystem
+
+import (
+	"fmt"
+	"os"
+)
+
+// BatchDeleteResult reports the outcome of deleting one path of a batch
+type BatchDeleteResult struct {
+	Path   string `json:"path"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// DeleteBatch deletes every path on its own like DeleteFile and reports the
+// outcome per path. A failure does not stop the remaining deletions. Unlike
+// DeleteFile, missing paths and mapped directories are reported as errors.
+func (m *Manager) DeleteBatch(virtualPaths []string) []BatchDeleteResult {
+	results := make([]BatchDeleteResult, len(virtualPaths))
+	for i, virtualPath := range virtualPaths {
+		results[i] = BatchDeleteResult{Path: virtualPath, Status: "deleted"}
+		if err := m.deleteExisting(virtualPath); err != nil {
+			results[i].Status = "error"
+			results[i].Error = err.Error()
+		}
+	}
+	return results
+}
+
+// deleteExisting deletes a file or directory that must exist and must not be
+// the source of a mapping
+func (m *Manager) deleteExisting(virtualPath string) error {
+	physicalPath, err := m.resolvePath(virtualPath)
+	if err != nil {
+		return err
+	}
+	if !m.isPathSafe(physicalPath) {
+		return fmt.Errorf("access denied: path outside managed directory")
+	}
+	if m.isMappingRoot(physicalPath) {
+		return fmt.Errorf("access denied: cannot delete a mapped directory")
+	}
+	if _, err := os.Lstat(physicalPath); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("file not found: %s", virtualPath)
+		}
+		return err
+	}
+	return m.DeleteFile(virtualPath)
+}
@@ -0,0 +1,37 @@
+package filesystem
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"dendrite/internal/config"
+)
+
+func TestDeleteBatch(t *testing.T) {
+	tmpDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(tmpDir, "sub"), 0750))
+	for _, name := range []string{"a.txt", "b.txt", "sub/c.txt"} {
+		require.NoError(t, os.WriteFile(filepath.Join(tmpDir, filepath.FromSlash(name)), []byte(name), 0600))
+	}
+	m := New(&config.Config{Directories: []config.DirMapping{{Source: tmpDir, Virtual: "/test"}}})
+
+	results := m.DeleteBatch([]string{"/test/a.txt", "/test/missing.txt", "/test/sub/", "/test", "/test/../b.txt"})
+	require.Len(t, results, 5)
+
+	assert.Equal(t, BatchDeleteResult{Path: "/test/a.txt", Status: "deleted"}, results[0])
+	assert.Equal(t, "error", results[1].Status)
+	assert.Contains(t, results[1].Error, "not found")
+	assert.Equal(t, "deleted", results[2].Status)
+	assert.Equal(t, "error", results[3].Status)
+	assert.Contains(t, results[3].Error, "access denied")
+	assert.Equal(t, "error", results[4].Status)
+
+	assert.NoFileExists(t, filepath.Join(tmpDir, "a.txt"))
+	assert.NoDirExists(t, filepath.Join(tmpDir, "sub"))
+	assert.DirExists(t, tmpDir)
+	assert.FileExists(t, filepath.Join(tmpDir, "b.txt"))
+}
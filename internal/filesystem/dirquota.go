@@ -0,0 +1,77 @@
+package filesystem
+
+import (
+	"log"
+	"path"
+	"strings"
+
+	"dendrite/internal/config"
+)
+
+// mappingForVirtualPath returns the mapping a virtual path belongs to,
+// including the root mapping "/"
+func (m *Manager) mappingForVirtualPath(virtualPath string) (config.DirMapping, bool) {
+	virtualPath = path.Clean("/" + virtualPath)
+	for _, dir := range m.Directories {
+		if dir.Virtual == "/" || virtualPath == dir.Virtual || strings.HasPrefix(virtualPath, dir.Virtual+"/") {
+			return dir, true
+		}
+	}
+	return config.DirMapping{}, false
+}
+
+// mappingQuota returns the quota of the mapping a virtual path belongs to,
+// zero when it has none
+func (m *Manager) mappingQuota(virtualPath string) int64 {
+	if mapping, ok := m.mappingForVirtualPath(virtualPath); ok {
+		return max(mapping.QuotaBytes, 0)
+	}
+	return 0
+}
+
+// quotaState returns the usage and limit that bound writes to virtualPath.
+// The quota of the path's mapping applies in addition to the global quota,
+// and whichever leaves less room is returned. A limit of zero means
+// unlimited.
+func (m *Manager) quotaState(virtualPath string) (used, limit int64, err error) {
+	if limit = m.quotaLimit(); limit > 0 {
+		quotaInfo, err := m.GetQuotaInfo()
+		if err != nil {
+			return 0, 0, err
+		}
+		used = quotaInfo.Used
+	}
+
+	mapping, ok := m.mappingForVirtualPath(virtualPath)
+	if !ok || mapping.QuotaBytes <= 0 {
+		return used, limit, nil
+	}
+	dirUsed, err := m.mappingUsage(mapping.Source)
+	if err != nil {
+		return 0, 0, err
+	}
+	if limit <= 0 || mapping.QuotaBytes-dirUsed < limit-used {
+		return dirUsed, mapping.QuotaBytes, nil
+	}
+	return used, limit, nil
+}
+
+// GetQuotaInfoPerDir returns the usage of every mapping keyed by its virtual
+// path. The limit is the mapping's own quota; mappings without one report
+// an unlimited quota, as only the global quota applies to them. Excluded
+// mappings are left out.
+func (m *Manager) GetQuotaInfoPerDir() (map[string]QuotaInfo, error) {
+	result := make(map[string]QuotaInfo, len(m.Directories))
+	for _, dir := range m.Directories {
+		if m.isQuotaExcluded(dir.Virtual) {
+			continue
+		}
+		used, err := m.mappingUsage(dir.Source)
+		if err != nil {
+			log.Printf("Warning: failed to calculate size for %s: %v", dir.Source, err)
+			continue
+		}
+		result[dir.Virtual] = *newQuotaInfo(used, max(dir.QuotaBytes, 0))
+	}
+	return result, nil
+}
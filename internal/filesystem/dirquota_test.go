@@ -0,0 +1,102 @@
+package filesystem
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"dendrite/internal/config"
+)
+
+func TestDirectoryQuota(t *testing.T) {
+	tenantA := t.TempDir()
+	tenantB := t.TempDir()
+	cfg := &config.Config{Directories: []config.DirMapping{
+		{Source: tenantA, Virtual: "/a", QuotaBytes: 1000},
+		{Source: tenantB, Virtual: "/b"},
+	}}
+	mgr := New(cfg)
+	data := func(size int) *bytes.Reader {
+		return bytes.NewReader(make([]byte, size))
+	}
+
+	_, err := mgr.UploadFile("/a", "first.bin", data(600), 600)
+	require.NoError(t, err)
+
+	t.Run("writes to the directory are checked against its quota", func(t *testing.T) {
+		_, err := mgr.UploadFile("/a", "second.bin", data(600), 600)
+		assert.ErrorContains(t, err, "upload would exceed quota limit")
+		_, err = mgr.StreamFile("/a/second.bin", data(600), 600)
+		assert.ErrorIs(t, err, errQuotaExceeded)
+		_, err = mgr.WriteFile("/a/second.bin", make([]byte, 600))
+		assert.ErrorContains(t, err, "quota exceeded")
+		assert.ErrorContains(t, mgr.CopyFile("/a/first.bin", "/a/copy.bin"), "copy would exceed quota limit")
+		assert.NoFileExists(t, filepath.Join(tenantA, "second.bin"))
+	})
+
+	t.Run("other directories fall back to the global quota", func(t *testing.T) {
+		_, err := mgr.UploadFile("/b", "big.bin", data(5000), 5000)
+		require.NoError(t, err)
+		require.NoError(t, mgr.CopyFile("/a/first.bin", "/b/copy.bin"))
+	})
+
+	t.Run("the global quota still caps the total", func(t *testing.T) {
+		cfg.QuotaBytes = 6000
+		defer func() { cfg.QuotaBytes = 0 }()
+
+		// The directory quota leaves 400 bytes, the global quota none
+		_, err := mgr.UploadFile("/a", "small.bin", data(300), 300)
+		assert.ErrorContains(t, err, "upload would exceed quota limit")
+	})
+
+	t.Run("usage is reported per directory", func(t *testing.T) {
+		perDir, err := mgr.GetQuotaInfoPerDir()
+		require.NoError(t, err)
+		require.Len(t, perDir, 2)
+
+		assert.Equal(t, int64(600), perDir["/a"].Used)
+		assert.Equal(t, int64(1000), perDir["/a"].Limit)
+		assert.Equal(t, int64(400), perDir["/a"].Available)
+
+		assert.Equal(t, int64(5600), perDir["/b"].Used)
+		assert.Equal(t, int64(-1), perDir["/b"].Available)
+		assert.Equal(t, "unlimited", perDir["/b"].LimitHuman)
+	})
+
+	t.Run("excluded paths are not checked", func(t *testing.T) {
+		cfg.Main.QuotaExclude = []string{"/a/scratch"}
+		defer func() { cfg.Main.QuotaExclude = nil }()
+
+		_, err := mgr.UploadFile("/a/scratch", "big.bin", data(2000), 2000)
+		require.NoError(t, err)
+		require.NoError(t, os.RemoveAll(filepath.Join(tenantA, "scratch")))
+	})
+}
+
+func TestWriteFileGlobalQuota(t *testing.T) {
+	tenantA := t.TempDir()
+	tenantB := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(tenantA, "a.bin"), make([]byte, 600), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(tenantB, "b.bin"), make([]byte, 300), 0600))
+	mgr := New(&config.Config{QuotaBytes: 1000, Directories: []config.DirMapping{
+		{Source: tenantA, Virtual: "/a"},
+		{Source: tenantB, Virtual: "/b"},
+	}})
+
+	t.Run("usage of all directories counts against the global quota", func(t *testing.T) {
+		_, err := mgr.WriteFile("/b/new.bin", make([]byte, 300))
+		assert.ErrorContains(t, err, "quota exceeded")
+		assert.NoFileExists(t, filepath.Join(tenantB, "new.bin"))
+	})
+
+	t.Run("overwriting only counts the difference", func(t *testing.T) {
+		_, err := mgr.WriteFile("/b/b.bin", make([]byte, 400))
+		require.NoError(t, err)
+		_, err = mgr.WriteFile("/b/b.bin", make([]byte, 401))
+		assert.ErrorContains(t, err, "quota exceeded")
+	})
+}
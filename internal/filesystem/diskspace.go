@@ -0,0 +1,90 @@
+package filesystem
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"dendrite/internal/format"
+)
+
+// diskSpaceTTL is how long the figures of a device are reused before the
+// filesystem is queried again
+const diskSpaceTTL = 10 * time.Second
+
+// diskSpace reports the device and the free and total space of the filesystem
+// containing a path. Tests replace it to simulate several devices.
+var diskSpace = getDiskSpace
+
+// deviceSpace holds the figures of one filesystem
+type deviceSpace struct {
+	Device uint64
+	Free   int64
+	Total  int64
+}
+
+type diskSpaceEntry struct {
+	space   deviceSpace
+	checked time.Time
+}
+
+// diskSpaceCache maps source directories to the figures of their device
+var diskSpaceCache = struct {
+	sync.Mutex
+	entries map[string]diskSpaceEntry
+}{entries: make(map[string]diskSpaceEntry)}
+
+// DirectorySpace reports the filesystem a mapped directory lives on
+type DirectorySpace struct {
+	Virtual string `json:"virtual"`
+	// Device identifies the filesystem; directories sharing it share the space
+	Device string `json:"device"`
+	Free   int64  `json:"free"`
+	Total  int64  `json:"total"`
+	// Human-readable forms of the values above
+	FreeHuman  string `json:"freeHuman"`
+	TotalHuman string `json:"totalHuman"`
+}
+
+// cachedDiskSpace returns the figures for a source directory, querying the
+// filesystem at most once per diskSpaceTTL
+func cachedDiskSpace(source string) (deviceSpace, bool) {
+	diskSpaceCache.Lock()
+	defer diskSpaceCache.Unlock()
+
+	if entry, ok := diskSpaceCache.entries[source]; ok && time.Since(entry.checked) < diskSpaceTTL {
+		return entry.space, true
+	}
+	space, ok := diskSpace(source)
+	if !ok {
+		return deviceSpace{}, false
+	}
+	diskSpaceCache.entries[source] = diskSpaceEntry{space: space, checked: time.Now()}
+	return space, true
+}
+
+// DirectorySpace reports the free and total space of the device behind each
+// accessible directory, ordered by virtual path. Directories whose device
+// cannot be queried, and all directories on Windows, are left out.
+func (m *Manager) DirectorySpace() []DirectorySpace {
+	var result []DirectorySpace
+	for _, dir := range m.Directories {
+		space, ok := cachedDiskSpace(dir.Source)
+		if !ok {
+			continue
+		}
+		result = append(result, DirectorySpace{
+			Virtual:    dir.Virtual,
+			Device:     strconv.FormatUint(space.Device, 10),
+			Free:       space.Free,
+			Total:      space.Total,
+			FreeHuman:  format.FileSize(space.Free),
+			TotalHuman: format.FileSize(space.Total),
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Virtual < result[j].Virtual
+	})
+	return result
+}
@@ -0,0 +1,65 @@
+package filesystem
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"dendrite/internal/config"
+)
+
+func TestDirectorySpace(t *testing.T) {
+	fast, slow := t.TempDir(), t.TempDir()
+	cfg := &config.Config{Directories: []config.DirMapping{
+		{Source: slow, Virtual: "/slow"},
+		{Source: fast, Virtual: "/fast"},
+	}}
+
+	// Simulate each mapping living on its own device
+	devices := map[string]deviceSpace{
+		fast: {Device: 2049, Free: 100 << 30, Total: 500 << 30},
+		slow: {Device: 2065, Free: 5 << 20, Total: 2 << 40},
+	}
+	queries := 0
+	original := diskSpace
+	diskSpace = func(path string) (deviceSpace, bool) {
+		queries++
+		space, ok := devices[path]
+		return space, ok
+	}
+	t.Cleanup(func() { diskSpace = original })
+
+	t.Run("reports the device of each directory", func(t *testing.T) {
+		dirs := New(cfg).DirectorySpace()
+		require.Len(t, dirs, 2)
+		assert.Equal(t, DirectorySpace{
+			Virtual: "/fast", Device: "2049", Free: 100 << 30, Total: 500 << 30,
+			FreeHuman: "100.00 GB", TotalHuman: "500.00 GB",
+		}, dirs[0])
+		assert.Equal(t, DirectorySpace{
+			Virtual: "/slow", Device: "2065", Free: 5 << 20, Total: 2 << 40,
+			FreeHuman: "5.00 MB", TotalHuman: "2.00 TB",
+		}, dirs[1])
+	})
+
+	t.Run("figures are cached", func(t *testing.T) {
+		before := queries
+		New(cfg).DirectorySpace()
+		assert.Equal(t, before, queries)
+	})
+
+	t.Run("restricted managers only report their directories", func(t *testing.T) {
+		mgr := NewWithRestriction(cfg, []config.DirMapping{{Source: slow, Virtual: "/mine"}})
+		dirs := mgr.DirectorySpace()
+		require.Len(t, dirs, 1)
+		assert.Equal(t, "/mine", dirs[0].Virtual)
+		assert.Equal(t, "2065", dirs[0].Device)
+	})
+
+	t.Run("directories without device information are left out", func(t *testing.T) {
+		other := t.TempDir()
+		mgr := New(&config.Config{Directories: []config.DirMapping{{Source: other, Virtual: "/other"}}})
+		assert.Empty(t, mgr.DirectorySpace())
+	})
+}
@@ -0,0 +1,130 @@
+package filesystem
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// FindEmptyDirs returns the virtual paths of all directories below the given
+// virtual path that contain no files anywhere beneath them. Mapping roots are
+// never reported.
+func (m *Manager) FindEmptyDirs(virtualPath string) ([]string, error) {
+	physicalPaths, err := m.findEmptyDirs(virtualPath)
+	if err != nil {
+		return nil, err
+	}
+
+	virtualPaths := make([]string, 0, len(physicalPaths))
+	for _, physicalPath := range physicalPaths {
+		if vp, found := m.VirtualFS.GetVirtualPath(physicalPath); found {
+			virtualPaths = append(virtualPaths, vp)
+		}
+	}
+	sort.Strings(virtualPaths)
+
+	return virtualPaths, nil
+}
+
+// RemoveEmptyDirs removes all directories below the given virtual path that
+// contain no files anywhere beneath them and returns their virtual paths.
+// With dryRun set, nothing is removed.
+func (m *Manager) RemoveEmptyDirs(virtualPath string, dryRun bool) ([]string, error) {
+	physicalPaths, err := m.findEmptyDirs(virtualPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// Reverse order removes children before their parents
+	sort.Sort(sort.Reverse(sort.StringSlice(physicalPaths)))
+
+	removed := make([]string, 0, len(physicalPaths))
+	for _, physicalPath := range physicalPaths {
+		if !dryRun {
+			// os.Remove refuses non-empty directories, so files created
+			// since the scan are never deleted
+			if err := os.Remove(physicalPath); err != nil {
+				continue
+			}
+		}
+		if vp, found := m.VirtualFS.GetVirtualPath(physicalPath); found {
+			removed = append(removed, vp)
+		}
+	}
+	sort.Strings(removed)
+
+	return removed, nil
+}
+
+// findEmptyDirs returns the physical paths of empty directories below a virtual path
+func (m *Manager) findEmptyDirs(virtualPath string) ([]string, error) {
+	var searchRoots []string
+	if m.VirtualFS.IsVirtualRoot(virtualPath) && !(len(m.Directories) == 1 && m.Directories[0].Virtual == "/") {
+		for _, dir := range m.Directories {
+			searchRoots = append(searchRoots, dir.Source)
+		}
+	} else {
+		physicalPath, err := m.resolvePath(virtualPath)
+		if err != nil {
+			return nil, err
+		}
+
+		if !m.isPathSafe(physicalPath) {
+			return nil, fmt.Errorf("access denied: path outside managed directory")
+		}
+
+		info, err := os.Stat(physicalPath)
+		if err != nil {
+			return nil, fmt.Errorf("directory not found: %s", virtualPath)
+		}
+		if !info.IsDir() {
+			return nil, fmt.Errorf("path is not a directory: %s", virtualPath)
+		}
+		searchRoots = append(searchRoots, physicalPath)
+	}
+
+	// Mapping roots must never be reported or removed
+	mappingRoots := make(map[string]bool, len(m.Directories))
+	for _, dir := range m.Directories {
+		mappingRoots[filepath.Clean(dir.Source)] = true
+	}
+
+	var result []string
+	for _, root := range searchRoots {
+		m.collectEmptyDirs(filepath.Clean(root), mappingRoots, &result)
+	}
+
+	return result, nil
+}
+
+// collectEmptyDirs walks a directory depth-first and appends every directory
+// without files beneath it. It reports whether the directory itself is empty.
+func (m *Manager) collectEmptyDirs(dir string, mappingRoots map[string]bool, result *[]string) bool {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return false // Treat unreadable directories as non-empty
+	}
+
+	empty := true
+	for _, entry := range entries {
+		// Files, symlinks and other special files count as content
+		if !entry.IsDir() {
+			empty = false
+			continue
+		}
+		// Trashed directories are kept as deleted until restored
+		if m.isTrashDir(filepath.Join(dir, entry.Name())) {
+			continue
+		}
+		if !m.collectEmptyDirs(filepath.Join(dir, entry.Name()), mappingRoots, result) {
+			empty = false
+		}
+	}
+
+	if empty && !mappingRoots[dir] {
+		*result = append(*result, dir)
+	}
+
+	return empty
+}
@@ -0,0 +1,105 @@
+package filesystem
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"dendrite/internal/config"
+)
+
+// createEmptyDirsTree creates a tree mixing empty and non-empty directories
+func createEmptyDirsTree(t *testing.T, root string) {
+	t.Helper()
+	for _, dir := range []string{"empty/nested/deeper", "empty/other", "full/sub", "mixed/empty"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(root, dir), 0750))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(root, "full", "sub", "file.txt"), []byte("x"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "mixed", "file.txt"), []byte("x"), 0600))
+}
+
+func TestFindEmptyDirs(t *testing.T) {
+	tempDir := t.TempDir()
+	createEmptyDirsTree(t, tempDir)
+
+	mgr := New(&config.Config{
+		Directories: []config.DirMapping{
+			{Source: tempDir, Virtual: "/test"},
+		},
+	})
+
+	t.Run("finds nested empty directories", func(t *testing.T) {
+		dirs, err := mgr.FindEmptyDirs("/")
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			"/test/empty",
+			"/test/empty/nested",
+			"/test/empty/nested/deeper",
+			"/test/empty/other",
+			"/test/mixed/empty",
+		}, dirs)
+	})
+
+	t.Run("limited to subdirectory", func(t *testing.T) {
+		dirs, err := mgr.FindEmptyDirs("/test/mixed")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"/test/mixed/empty"}, dirs)
+	})
+
+	t.Run("empty mapping root is not reported", func(t *testing.T) {
+		emptyRoot := t.TempDir()
+		mgr := New(&config.Config{
+			Directories: []config.DirMapping{
+				{Source: emptyRoot, Virtual: "/empty"},
+			},
+		})
+		dirs, err := mgr.FindEmptyDirs("/empty")
+		require.NoError(t, err)
+		assert.Empty(t, dirs)
+	})
+
+	t.Run("missing directory", func(t *testing.T) {
+		_, err := mgr.FindEmptyDirs("/test/missing")
+		assert.EqualError(t, err, "directory not found: /test/missing")
+	})
+
+	t.Run("invalid virtual path", func(t *testing.T) {
+		_, err := mgr.FindEmptyDirs("/invalid")
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "virtual path not found")
+	})
+}
+
+func TestRemoveEmptyDirs(t *testing.T) {
+	tempDir := t.TempDir()
+	createEmptyDirsTree(t, tempDir)
+
+	mgr := New(&config.Config{
+		Directories: []config.DirMapping{
+			{Source: tempDir, Virtual: "/"},
+		},
+	})
+
+	t.Run("dry run removes nothing", func(t *testing.T) {
+		removed, err := mgr.RemoveEmptyDirs("/", true)
+		require.NoError(t, err)
+		assert.Len(t, removed, 5)
+		assert.DirExists(t, filepath.Join(tempDir, "empty", "nested", "deeper"))
+	})
+
+	t.Run("removes only empty directories", func(t *testing.T) {
+		removed, err := mgr.RemoveEmptyDirs("/", false)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"/empty", "/empty/nested", "/empty/nested/deeper", "/empty/other", "/mixed/empty"},
+			removed)
+
+		assert.NoDirExists(t, filepath.Join(tempDir, "empty"))
+		assert.NoDirExists(t, filepath.Join(tempDir, "mixed", "empty"))
+		assert.FileExists(t, filepath.Join(tempDir, "full", "sub", "file.txt"))
+		assert.FileExists(t, filepath.Join(tempDir, "mixed", "file.txt"))
+		assert.DirExists(t, tempDir)
+	})
+}
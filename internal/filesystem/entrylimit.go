@@ -0,0 +1,101 @@
+package filesystem
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"dendrite/internal/config"
+)
+
+// mappingForPhysicalPath returns the mapping whose source contains a physical
+// path, preferring the most specific one
+func (m *Manager) mappingForPhysicalPath(physicalPath string) (config.DirMapping, bool) {
+	var best config.DirMapping
+	found := false
+	for _, dir := range m.Directories {
+		rel, err := filepath.Rel(dir.Source, physicalPath)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		if !found || len(dir.Source) > len(best.Source) {
+			best, found = dir, true
+		}
+	}
+	return best, found
+}
+
+// maxDirEntries returns the number of entries allowed in a directory at the
+// physical path, or 0 when it is unlimited. The max_files of its mapping
+// takes precedence over max_files_per_directory.
+func (m *Manager) maxDirEntries(physicalPath string) int {
+	limit := m.Config.Main.MaxFilesPerDirectory
+	if mapping, ok := m.mappingForPhysicalPath(physicalPath); ok && mapping.MaxFiles != 0 {
+		limit = mapping.MaxFiles
+	}
+	return max(limit, 0)
+}
+
+// checkEntryLimit fails when creating physicalPath would push a directory
+// over its entry limit. Only the closest existing ancestor gains an entry,
+// since missing parents are created empty, and existing targets are replaced
+// without adding one.
+func (m *Manager) checkEntryLimit(physicalPath, virtualPath string) error {
+	if _, err := os.Lstat(physicalPath); err == nil {
+		return nil
+	}
+
+	dir, virtualDir := filepath.Dir(physicalPath), path.Dir(virtualPath)
+	for {
+		if info, err := os.Stat(dir); err == nil {
+			if !info.IsDir() {
+				return nil
+			}
+			break
+		}
+		if virtualDir == "/" || virtualDir == "." {
+			return nil
+		}
+		dir, virtualDir = filepath.Dir(dir), path.Dir(virtualDir)
+	}
+
+	limit := m.maxDirEntries(dir)
+	if limit == 0 {
+		return nil
+	}
+	// Unreadable directories are left to the operation itself to report
+	count, err := countDirEntries(dir, limit)
+	if err != nil || count < limit {
+		return nil
+	}
+	return fmt.Errorf("too many entries in directory: %s already holds the maximum of %d entries",
+		m.VirtualFS.NormalizePath(virtualDir), limit)
+}
+
+// countDirEntries counts the entries of a directory, stopping once limit is
+// reached. A negative limit counts all entries.
+func countDirEntries(dir string, limit int) (int, error) {
+	f, err := os.Open(dir) // #nosec G304 - path is validated by the caller
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	count := 0
+	for limit < 0 || count < limit {
+		names, err := f.Readdirnames(1024)
+		count += len(names)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	return count, nil
+}
@@ -0,0 +1,103 @@
+package filesystem
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"dendrite/internal/config"
+)
+
+func TestDirectoryEntryLimit(t *testing.T) {
+	limited, open := t.TempDir(), t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(open, "source.txt"), []byte("data"), 0600))
+
+	cfg := &config.Config{Directories: []config.DirMapping{
+		{Source: limited, Virtual: "/limited", MaxFiles: 3},
+		{Source: open, Virtual: "/open", MaxFiles: -1},
+	}}
+	cfg.Main.MaxFilesPerDirectory = 1
+	mgr := New(cfg)
+
+	t.Run("uploads up to the limit succeed", func(t *testing.T) {
+		for i := range 3 {
+			_, err := mgr.UploadFile("/limited", fmt.Sprintf("file%d.txt", i), strings.NewReader("data"), 4)
+			require.NoError(t, err)
+		}
+	})
+
+	t.Run("uploads past the limit are rejected", func(t *testing.T) {
+		_, err := mgr.UploadFile("/limited", "file3.txt", strings.NewReader("data"), 4)
+		assert.EqualError(t, err, "too many entries in directory: /limited already holds the maximum of 3 entries")
+		assert.NoFileExists(t, filepath.Join(limited, "file3.txt"))
+
+		_, err = mgr.StreamFile("/limited/new/file.txt", strings.NewReader("data"), 4)
+		assert.ErrorContains(t, err, "too many entries in directory")
+		assert.NoDirExists(t, filepath.Join(limited, "new"))
+	})
+
+	t.Run("overwriting does not add an entry", func(t *testing.T) {
+		_, err := mgr.UploadFile("/limited", "file0.txt", strings.NewReader("new"), 3)
+		assert.NoError(t, err)
+	})
+
+	t.Run("copies and folders count as well", func(t *testing.T) {
+		err := mgr.CopyFile("/open/source.txt", "/limited/copy.txt")
+		assert.ErrorContains(t, err, "too many entries in directory")
+		assert.ErrorContains(t, mgr.CreateFolder("/limited/folder"), "too many entries in directory")
+		_, err = mgr.EnsureFolder("/limited/folder")
+		assert.ErrorContains(t, err, "too many entries in directory")
+	})
+
+	t.Run("the limit applies per directory", func(t *testing.T) {
+		require.NoError(t, os.Remove(filepath.Join(limited, "file2.txt")))
+		require.NoError(t, mgr.CreateFolder("/limited/sub"))
+		for i := range 3 {
+			_, err := mgr.UploadFile("/limited/sub", fmt.Sprintf("file%d.txt", i), strings.NewReader("data"), 4)
+			require.NoError(t, err)
+		}
+	})
+
+	t.Run("mappings can disable the global limit", func(t *testing.T) {
+		for i := range 3 {
+			_, err := mgr.UploadFile("/open", fmt.Sprintf("file%d.txt", i), strings.NewReader("data"), 4)
+			require.NoError(t, err)
+		}
+	})
+
+	t.Run("stat reports the count and limit", func(t *testing.T) {
+		stat, err := mgr.StatFile("/limited/sub")
+		require.NoError(t, err)
+		require.NotNil(t, stat.Entries)
+		assert.Equal(t, 3, *stat.Entries)
+		assert.Equal(t, 3, stat.MaxFiles)
+
+		stat, err = mgr.StatFile("/open")
+		require.NoError(t, err)
+		require.NotNil(t, stat.Entries)
+		assert.Equal(t, 4, *stat.Entries)
+		assert.Zero(t, stat.MaxFiles)
+
+		stat, err = mgr.StatFile("/open/source.txt")
+		require.NoError(t, err)
+		assert.Nil(t, stat.Entries)
+	})
+}
+
+func TestGlobalDirectoryEntryLimit(t *testing.T) {
+	tempDir := t.TempDir()
+	cfg := &config.Config{Directories: []config.DirMapping{{Source: tempDir, Virtual: "/test"}}}
+	cfg.Main.MaxFilesPerDirectory = 2
+	mgr := New(cfg)
+
+	_, err := mgr.UploadFile("/test", "a.txt", strings.NewReader("a"), 1)
+	require.NoError(t, err)
+	require.NoError(t, mgr.CreateFolder("/test/dir"))
+	_, err = mgr.UploadFile("/test", "b.txt", strings.NewReader("b"), 1)
+	assert.EqualError(t, err, "too many entries in directory: /test already holds the maximum of 2 entries")
+}
@@ -0,0 +1,35 @@
+//go:build linux && (amd64 || arm64)
+
+package filesystem
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// renameExchange is the RENAME_EXCHANGE flag of renameat2
+const renameExchange = 1 << 1
+
+// exchangePaths atomically swaps two existing paths, so that every lookup
+// sees either the old or the new entry
+func exchangePaths(a, b string) error {
+	pa, err := syscall.BytePtrFromString(a)
+	if err != nil {
+		return err
+	}
+	pb, err := syscall.BytePtrFromString(b)
+	if err != nil {
+		return err
+	}
+
+	// AT_FDCWD (-100) resolves relative paths against the working directory
+	cwd := -100
+	_, _, errno := syscall.Syscall6(sysRenameat2,
+		uintptr(cwd), uintptr(unsafe.Pointer(pa)),
+		uintptr(cwd), uintptr(unsafe.Pointer(pb)),
+		renameExchange, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,7 @@
+//go:build linux && amd64
+
+package filesystem
+
+// sysRenameat2 is the renameat2 system call number, which the syscall
+// package does not define for this architecture
+const sysRenameat2 = 316
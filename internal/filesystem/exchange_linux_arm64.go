@@ -0,0 +1,8 @@
+//go:build linux && arm64
+
+package filesystem
+
+import "syscall"
+
+// sysRenameat2 is the renameat2 system call number
+const sysRenameat2 = syscall.SYS_RENAMEAT2
@@ -0,0 +1,10 @@
+//go:build !linux || !(amd64 || arm64)
+
+package filesystem
+
+import "errors"
+
+// exchangePaths is not supported on this platform
+func exchangePaths(_, _ string) error {
+	return errors.New("atomic exchange not supported on this platform")
+}
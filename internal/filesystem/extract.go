@@ -0,0 +1,551 @@
+package filesystem
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Policies for symbolic links in extracted archives
+const (
+	ExtractSymlinksReject    = "reject"
+	ExtractSymlinksSkip      = "skip"
+	ExtractSymlinksContained = "contained"
+)
+
+// extractPattern names the staging directories of ExtractArchive
+const extractPattern = ".dendrite-extract-*"
+
+// archiveSuffixes lists the archive extensions that can be extracted
+var archiveSuffixes = []string{".tar.gz", ".tgz", ".zip"}
+
+// archiveBaseName returns the file name without its archive extension and
+// whether the name denotes a supported archive
+func archiveBaseName(name string) (string, bool) {
+	lower := strings.ToLower(name)
+	for _, suffix := range archiveSuffixes {
+		if strings.HasSuffix(lower, suffix) && len(name) > len(suffix) {
+			return name[:len(name)-len(suffix)], true
+		}
+	}
+	return "", false
+}
+
+// extractArchive unpacks the archive into destDir, which must not exist yet.
+// Entries escaping destDir are rejected and at most limit bytes are written
+// (a negative limit disables the check). The decompressed content must also
+// stay within limits. Symbolic links are handled according to the symlinks
+// policy. On failure destDir is removed again.
+func extractArchive(archivePath, destDir string, limit int64, limits DecompressionLimits,
+	symlinks string) (err error) {
+	if _, err := os.Lstat(destDir); err == nil {
+		return fmt.Errorf("extraction target already exists: %s", filepath.Base(destDir))
+	}
+	if err := os.Mkdir(destDir, 0750); err != nil {
+		return fmt.Errorf("failed to create extraction directory: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = os.RemoveAll(destDir)
+		}
+	}()
+
+	x := &extractor{dest: destDir, remaining: limit, limits: limits, symlinks: symlinks}
+	return x.extract(archivePath)
+}
+
+// extractor writes archive entries below dest while tracking the size budget
+type extractor struct {
+	dest      string
+	remaining int64
+	limits    DecompressionLimits
+	// symlinks is the policy for symbolic link entries
+	symlinks string
+	// linked is set once a symbolic link was created
+	linked bool
+	// safe additionally validates the physical path of every entry when set
+	safe func(string) bool
+}
+
+// extract unpacks a ZIP or tar.gz archive, told apart by its name
+func (x *extractor) extract(archivePath string) error {
+	if strings.HasSuffix(strings.ToLower(archivePath), ".zip") {
+		return x.extractZip(archivePath)
+	}
+	return x.extractTarGz(archivePath)
+}
+
+// target returns the physical path for an archive entry name
+func (x *extractor) target(name string) (string, error) {
+	name = filepath.FromSlash(strings.ReplaceAll(name, "\\", "/"))
+	if filepath.IsAbs(name) || filepath.VolumeName(name) != "" {
+		return "", fmt.Errorf("invalid archive entry: %s", name)
+	}
+	target := filepath.Join(x.dest, name)
+	if !x.contains(target) || (x.safe != nil && !x.safe(target)) {
+		return "", fmt.Errorf("invalid archive entry: %s", name)
+	}
+	if err := x.checkParents(target); err != nil {
+		return "", err
+	}
+	return target, nil
+}
+
+// contains reports whether p lies within the destination directory
+func (x *extractor) contains(p string) bool {
+	rel, err := filepath.Rel(x.dest, p)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// checkParents rejects entries below a previously extracted symbolic link.
+// A link that stays within the destination could otherwise be combined with
+// further links to reach outside of it.
+func (x *extractor) checkParents(target string) error {
+	if !x.linked {
+		return nil
+	}
+	rel, err := filepath.Rel(x.dest, filepath.Dir(target))
+	if err != nil || rel == "." {
+		return nil
+	}
+	current := x.dest
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		current = filepath.Join(current, part)
+		if info, err := os.Lstat(current); err == nil && info.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("invalid archive entry: %s is below a symbolic link", filepath.Base(target))
+		}
+	}
+	return nil
+}
+
+// linkContained reports whether a relative link target resolves within the
+// destination when followed from dir. The target is walked one component at
+// a time instead of being cleaned as text: ".." may only lead the target, and
+// the walk must not pass through a link extracted before, whose own target
+// would change where the following components lead.
+func (x *extractor) linkContained(dir, linkTarget string) bool {
+	current := dir
+	descended := false
+	for _, part := range strings.Split(linkTarget, string(filepath.Separator)) {
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			if descended {
+				return false
+			}
+			current = filepath.Dir(current)
+		default:
+			descended = true
+			current = filepath.Join(current, part)
+			if info, err := os.Lstat(current); err == nil && info.Mode()&os.ModeSymlink != 0 {
+				return false
+			}
+		}
+		if !x.contains(current) {
+			return false
+		}
+	}
+	return true
+}
+
+// symlink handles a symbolic link entry according to the symlink policy
+func (x *extractor) symlink(name, linkTarget string) error {
+	switch x.symlinks {
+	case ExtractSymlinksSkip:
+		return nil
+	case ExtractSymlinksContained:
+	default:
+		return fmt.Errorf("symbolic link %s is not allowed", name)
+	}
+
+	target, err := x.target(name)
+	if err != nil {
+		return err
+	}
+	linkTarget = filepath.FromSlash(strings.ReplaceAll(linkTarget, "\\", "/"))
+	if linkTarget == "" || filepath.IsAbs(linkTarget) || filepath.VolumeName(linkTarget) != "" ||
+		!x.linkContained(filepath.Dir(target), linkTarget) {
+		return fmt.Errorf("symbolic link %s points outside the extracted directory", name)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(target), 0750); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	if err := os.Symlink(linkTarget, target); err != nil {
+		return fmt.Errorf("failed to create symbolic link %s: %w", name, err)
+	}
+	x.linked = true
+	return nil
+}
+
+func (x *extractor) writeFile(name string, r io.Reader) error {
+	target, err := x.target(name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0750); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	if x.remaining >= 0 {
+		r = &quotaReader{r: r, limit: x.remaining}
+	}
+
+	out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0640) // #nosec G304 - target is validated
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	written, err := io.Copy(out, r)
+	if cerr := out.Close(); cerr != nil && err == nil {
+		err = cerr
+	}
+	if errors.Is(err, errQuotaExceeded) || errors.Is(err, errDecompressionLimit) {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("failed to extract %s: %w", name, err)
+	}
+
+	if x.remaining >= 0 {
+		x.remaining -= written
+	}
+	return nil
+}
+
+func (x *extractor) mkdir(name string) error {
+	target, err := x.target(name)
+	if err != nil {
+		return err
+	}
+	return os.MkdirAll(target, 0750)
+}
+
+func (x *extractor) extractZip(archivePath string) error {
+	zr, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer func() {
+		_ = zr.Close()
+	}()
+
+	// Bombs are usually detected from the announced sizes in the central
+	// directory before anything is written. Sums that overflow can only come
+	// from forged sizes.
+	var compressed, uncompressed uint64
+	for _, f := range zr.File {
+		if compressed > math.MaxUint64-f.CompressedSize64 || uncompressed > math.MaxUint64-f.UncompressedSize64 {
+			return fmt.Errorf("%w: announced sizes overflow", errDecompressionLimit)
+		}
+		compressed += f.CompressedSize64
+		uncompressed += f.UncompressedSize64
+	}
+	if err := x.limits.check(int64(min(compressed, math.MaxInt64)), // #nosec G115 - clamped
+		int64(min(uncompressed, math.MaxInt64))); err != nil { // #nosec G115 - clamped
+		return err
+	}
+
+	// Reject archives that announce more content than allowed before writing
+	if x.remaining >= 0 && uncompressed > uint64(x.remaining) {
+		return errQuotaExceeded
+	}
+
+	// The written content is metered as well, across all entries, in case
+	// the announced sizes are wrong. Each entry consumes its compressed size
+	// from the archive.
+	meter := &limitedDecompressor{in: &countingReader{}, limits: x.limits}
+
+	for _, f := range zr.File {
+		mode := f.Mode()
+		switch {
+		case mode.IsDir():
+			if err := x.mkdir(f.Name); err != nil {
+				return err
+			}
+		case mode.IsRegular():
+			rc, err := f.Open()
+			if err != nil {
+				return fmt.Errorf("failed to read %s: %w", f.Name, err)
+			}
+			meter.r = rc
+			meter.in.n = int64(min(uint64(meter.in.n)+f.CompressedSize64, math.MaxInt64)) // #nosec G115 - clamped
+			err = x.writeFile(f.Name, meter)
+			_ = rc.Close()
+			if err != nil {
+				return err
+			}
+		case mode&os.ModeSymlink != 0:
+			linkTarget, err := readZipLink(f)
+			if err != nil {
+				return err
+			}
+			if err := x.symlink(f.Name, linkTarget); err != nil {
+				return err
+			}
+		default:
+			// Special files are not extracted
+			continue
+		}
+	}
+	return nil
+}
+
+// maxZipLinkSize caps the target length read from a ZIP symlink entry
+const maxZipLinkSize = 4096
+
+// readZipLink returns the target of a ZIP symlink entry, which is stored as
+// the entry content
+func readZipLink(f *zip.File) (string, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", f.Name, err)
+	}
+	defer func() {
+		_ = rc.Close()
+	}()
+	target, err := io.ReadAll(io.LimitReader(rc, maxZipLinkSize+1))
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", f.Name, err)
+	}
+	if len(target) > maxZipLinkSize {
+		return "", fmt.Errorf("symbolic link %s has an invalid target", f.Name)
+	}
+	return string(target), nil
+}
+
+func (x *extractor) extractTarGz(archivePath string) error {
+	f, err := os.Open(archivePath) // #nosec G304 - path is validated by the caller
+	if err != nil {
+		return fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	gz, err := x.limits.Reader(f, func(r io.Reader) (io.Reader, error) {
+		return gzip.NewReader(r)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to open archive: %w", err)
+	}
+	tr := tar.NewReader(gz)
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read archive: %w", err)
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := x.mkdir(hdr.Name); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := x.writeFile(hdr.Name, tr); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			if err := x.symlink(hdr.Name, hdr.Linkname); err != nil {
+				return err
+			}
+		case tar.TypeLink:
+			// Hard links could expose files outside the archive
+			if x.symlinks != ExtractSymlinksSkip {
+				return fmt.Errorf("hard link %s is not allowed", hdr.Name)
+			}
+		default:
+			// Special files are not extracted
+			continue
+		}
+	}
+}
+
+// AutoExtract unpacks an uploaded archive when its mapping enables
+// auto_extract. It returns the virtual path of the extracted folder, or an
+// empty string when nothing was extracted.
+func (m *Manager) AutoExtract(virtualPath string) (string, error) {
+	mapping, ok := m.VirtualFS.GetDirectoryForVirtualPath(virtualPath)
+	if !ok || !mapping.AutoExtract {
+		return "", nil
+	}
+
+	physicalPath, err := m.resolvePath(virtualPath)
+	if err != nil {
+		return "", err
+	}
+	if !m.isPathSafe(physicalPath) {
+		return "", fmt.Errorf("access denied: path outside managed directory")
+	}
+
+	base, ok := archiveBaseName(filepath.Base(physicalPath))
+	if !ok {
+		return "", nil
+	}
+
+	// The archive itself already counts against the quota
+	limit := int64(-1)
+	if m.quotaApplies(virtualPath) {
+		used, total, err := m.quotaState(virtualPath)
+		if err != nil {
+			return "", fmt.Errorf("failed to calculate current usage: %w", err)
+		}
+		limit = max(total-used, 0)
+		if mapping.DeleteArchive {
+			if info, err := os.Stat(physicalPath); err == nil {
+				limit += info.Size()
+			}
+		}
+	}
+
+	destDir := filepath.Join(filepath.Dir(physicalPath), base)
+	defer m.trackUsage(destDir, physicalPath)()
+	err = extractArchive(physicalPath, destDir, limit, DecompressionLimitsFor(m.Config), m.Config.Extraction.Symlinks)
+	if err != nil {
+		if errors.Is(err, errQuotaExceeded) {
+			return "", fmt.Errorf("quota exceeded: extracted content would exceed storage limit")
+		}
+		if errors.Is(err, errDecompressionLimit) {
+			return "", err
+		}
+		return "", fmt.Errorf("failed to extract archive: %w", err)
+	}
+
+	if mapping.DeleteArchive {
+		if err := os.Remove(physicalPath); err != nil {
+			return "", fmt.Errorf("failed to remove extracted archive: %w", err)
+		}
+	}
+
+	virtualDir, _ := m.VirtualFS.GetVirtualPath(destDir)
+	return virtualDir, nil
+}
+
+// ExtractArchive unpacks the ZIP or tar.gz archive at virtualArchivePath into
+// the directory virtualDestDir, which is created when only it is missing.
+// The archive is extracted into a staging directory first, so an entry that
+// escapes the destination or exceeds the quota leaves the destination
+// untouched. Existing files are never overwritten: a top-level entry that
+// already exists in the destination fails the extraction.
+func (m *Manager) ExtractArchive(virtualArchivePath, virtualDestDir string) error {
+	archivePath, err := m.resolvePath(virtualArchivePath)
+	if err != nil {
+		return err
+	}
+	if !m.isPathSafe(archivePath) {
+		return fmt.Errorf("access denied: path outside managed directory")
+	}
+	info, err := os.Stat(archivePath)
+	if err != nil {
+		return fmt.Errorf("file not found: %s", virtualArchivePath)
+	}
+	if _, ok := archiveBaseName(filepath.Base(archivePath)); !ok || info.IsDir() {
+		return fmt.Errorf("unsupported archive type: %s (expected .zip, .tar.gz or .tgz)", virtualArchivePath)
+	}
+
+	destDir, err := m.resolvePath(virtualDestDir)
+	if err != nil {
+		return err
+	}
+	if !m.isPathSafe(destDir) {
+		return fmt.Errorf("access denied: path outside managed directory")
+	}
+	destExists := true
+	if destInfo, err := os.Stat(destDir); err != nil {
+		if _, err := os.Stat(filepath.Dir(destDir)); err != nil {
+			return fmt.Errorf("destination directory not found: %s", virtualDestDir)
+		}
+		destExists = false
+	} else if !destInfo.IsDir() {
+		return fmt.Errorf("destination is not a directory: %s", virtualDestDir)
+	}
+
+	limit := int64(-1)
+	if m.quotaApplies(virtualDestDir) {
+		used, total, err := m.quotaState(virtualDestDir)
+		if err != nil {
+			return fmt.Errorf("failed to calculate current usage: %w", err)
+		}
+		limit = max(total-used, 0)
+	}
+
+	// Registered first, so that the usage is taken after the staging
+	// directory of a failed extraction is removed
+	defer m.trackUsage(destDir)()
+
+	// A missing destination is staged next to it and renamed as a whole
+	stagingParent := destDir
+	if !destExists {
+		stagingParent = filepath.Dir(destDir)
+	}
+	staging, err := os.MkdirTemp(stagingParent, extractPattern)
+	if err != nil {
+		return fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	defer func() {
+		_ = os.RemoveAll(staging)
+	}()
+
+	x := &extractor{
+		dest:      staging,
+		remaining: limit,
+		limits:    DecompressionLimitsFor(m.Config),
+		symlinks:  m.Config.Extraction.Symlinks,
+		safe:      m.isPathSafe,
+	}
+	if err := x.extract(archivePath); err != nil {
+		if errors.Is(err, errQuotaExceeded) {
+			return fmt.Errorf("quota exceeded: extracted content would exceed storage limit")
+		}
+		if errors.Is(err, errDecompressionLimit) {
+			return err
+		}
+		return fmt.Errorf("failed to extract archive: %w", err)
+	}
+
+	if !destExists {
+		if err := os.Rename(staging, destDir); err != nil {
+			return fmt.Errorf("failed to move extracted files into place: %w", err)
+		}
+		return nil
+	}
+	return m.mergeExtracted(staging, destDir, virtualDestDir)
+}
+
+// mergeExtracted moves the top-level entries of staging into destDir. All
+// of them are checked for collisions first, and entries already moved are
+// moved back when a later one fails.
+func (m *Manager) mergeExtracted(staging, destDir, virtualDestDir string) error {
+	entries, err := os.ReadDir(staging)
+	if err != nil {
+		return fmt.Errorf("failed to read staging directory: %w", err)
+	}
+	for _, entry := range entries {
+		if _, err := os.Lstat(filepath.Join(destDir, entry.Name())); err == nil {
+			virtualPath := path.Join(path.Clean("/"+virtualDestDir), entry.Name())
+			return fmt.Errorf("already exists: %s", m.VirtualFS.NormalizePath(virtualPath))
+		}
+	}
+
+	for i, entry := range entries {
+		if err := os.Rename(filepath.Join(staging, entry.Name()), filepath.Join(destDir, entry.Name())); err != nil {
+			for _, moved := range entries[:i] {
+				_ = os.Rename(filepath.Join(destDir, moved.Name()), filepath.Join(staging, moved.Name()))
+			}
+			return fmt.Errorf("failed to move extracted files into place: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,561 @@
+package filesystem
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"hash/crc32"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"dendrite/internal/config"
+)
+
+// buildZip creates a zip archive from name/content pairs. Names ending in a
+// slash become directories.
+func buildZip(t *testing.T, entries map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range entries {
+		w, err := zw.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+// buildTarGz creates a gzip compressed tar archive from name/content pairs
+func buildTarGz(t *testing.T, entries map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range entries {
+		require.NoError(t, tw.WriteHeader(&tar.Header{
+			Name:     name,
+			Mode:     0600,
+			Size:     int64(len(content)),
+			Typeflag: tar.TypeReg,
+		}))
+		_, err := tw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+func TestAutoExtract(t *testing.T) {
+	entries := map[string]string{"a.txt": "alpha", "dir/b.txt": "beta"}
+
+	setup := func(t *testing.T, mapping config.DirMapping, quota int64) (*Manager, string) {
+		t.Helper()
+		tempDir := t.TempDir()
+		mapping.Source = tempDir
+		mapping.Virtual = "/inbox"
+		return New(&config.Config{Directories: []config.DirMapping{mapping}, QuotaBytes: quota}), tempDir
+	}
+
+	t.Run("zip is extracted next to the archive", func(t *testing.T) {
+		mgr, root := setup(t, config.DirMapping{AutoExtract: true}, 0)
+		require.NoError(t, os.WriteFile(filepath.Join(root, "bundle.zip"), buildZip(t, entries), 0600))
+
+		extracted, err := mgr.AutoExtract("/inbox/bundle.zip")
+		require.NoError(t, err)
+		assert.Equal(t, "/inbox/bundle", extracted)
+
+		content, err := os.ReadFile(filepath.Join(root, "bundle", "dir", "b.txt"))
+		require.NoError(t, err)
+		assert.Equal(t, "beta", string(content))
+		assert.FileExists(t, filepath.Join(root, "bundle.zip"))
+	})
+
+	t.Run("tar.gz is extracted and removed", func(t *testing.T) {
+		mgr, root := setup(t, config.DirMapping{AutoExtract: true, DeleteArchive: true}, 0)
+		require.NoError(t, os.WriteFile(filepath.Join(root, "bundle.tar.gz"), buildTarGz(t, entries), 0600))
+
+		extracted, err := mgr.AutoExtract("/inbox/bundle.tar.gz")
+		require.NoError(t, err)
+		assert.Equal(t, "/inbox/bundle", extracted)
+		assert.FileExists(t, filepath.Join(root, "bundle", "a.txt"))
+		assert.NoFileExists(t, filepath.Join(root, "bundle.tar.gz"))
+	})
+
+	t.Run("mapping without auto_extract", func(t *testing.T) {
+		mgr, root := setup(t, config.DirMapping{}, 0)
+		require.NoError(t, os.WriteFile(filepath.Join(root, "bundle.zip"), buildZip(t, entries), 0600))
+
+		extracted, err := mgr.AutoExtract("/inbox/bundle.zip")
+		require.NoError(t, err)
+		assert.Empty(t, extracted)
+		assert.NoDirExists(t, filepath.Join(root, "bundle"))
+	})
+
+	t.Run("other files are ignored", func(t *testing.T) {
+		mgr, root := setup(t, config.DirMapping{AutoExtract: true}, 0)
+		require.NoError(t, os.WriteFile(filepath.Join(root, "notes.txt"), []byte("x"), 0600))
+
+		extracted, err := mgr.AutoExtract("/inbox/notes.txt")
+		require.NoError(t, err)
+		assert.Empty(t, extracted)
+	})
+
+	t.Run("entries escaping the target are rejected", func(t *testing.T) {
+		for name, archive := range map[string][]byte{
+			"evil.zip":    buildZip(t, map[string]string{"../escape.txt": "x"}),
+			"evil.tar.gz": buildTarGz(t, map[string]string{"../../escape.txt": "x"}),
+		} {
+			mgr, root := setup(t, config.DirMapping{AutoExtract: true, DeleteArchive: true}, 0)
+			require.NoError(t, os.WriteFile(filepath.Join(root, name), archive, 0600))
+
+			_, err := mgr.AutoExtract("/inbox/" + name)
+			require.Error(t, err, name)
+			assert.Contains(t, err.Error(), "invalid archive entry")
+			assert.NoFileExists(t, filepath.Join(root, "escape.txt"))
+			assert.NoDirExists(t, filepath.Join(root, "evil"))
+			assert.FileExists(t, filepath.Join(root, name), "archive is kept on failure")
+		}
+	})
+
+	t.Run("quota applies to uncompressed size", func(t *testing.T) {
+		large := map[string]string{"zeros.bin": string(make([]byte, 4096))}
+		for name, archive := range map[string][]byte{
+			"large.zip":    buildZip(t, large),
+			"large.tar.gz": buildTarGz(t, large),
+		} {
+			mgr, root := setup(t, config.DirMapping{AutoExtract: true}, 1024)
+			require.NoError(t, os.WriteFile(filepath.Join(root, name), archive, 0600))
+
+			_, err := mgr.AutoExtract("/inbox/" + name)
+			require.Error(t, err, name)
+			assert.Contains(t, err.Error(), "quota exceeded")
+			assert.NoDirExists(t, filepath.Join(root, "large"))
+		}
+	})
+
+	t.Run("existing target folder", func(t *testing.T) {
+		mgr, root := setup(t, config.DirMapping{AutoExtract: true}, 0)
+		require.NoError(t, os.WriteFile(filepath.Join(root, "bundle.zip"), buildZip(t, entries), 0600))
+		require.NoError(t, os.Mkdir(filepath.Join(root, "bundle"), 0750))
+
+		_, err := mgr.AutoExtract("/inbox/bundle.zip")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "already exists")
+		assert.DirExists(t, filepath.Join(root, "bundle"))
+	})
+}
+
+func TestAutoExtractDecompressionLimits(t *testing.T) {
+	// Four megabytes of zeros compress to a few kilobytes
+	bomb := map[string]string{"zeros.bin": string(make([]byte, 4<<20))}
+	archives := map[string][]byte{
+		"bomb.zip":    buildZip(t, bomb),
+		"bomb.tar.gz": buildTarGz(t, bomb),
+	}
+
+	tests := []struct {
+		name    string
+		limits  config.DecompressionConfig
+		wantErr string
+	}{
+		{name: "ratio limit", limits: config.DecompressionConfig{MaxRatio: 100}, wantErr: "compression ratio above 100"},
+		{name: "size limit", limits: config.DecompressionConfig{MaxSizeBytes: 1 << 20, MaxRatio: -1},
+			wantErr: "content expands beyond 1.00 MB"},
+		{name: "limits disabled", limits: config.DecompressionConfig{MaxRatio: -1}},
+	}
+
+	for _, tt := range tests {
+		for name, archive := range archives {
+			t.Run(tt.name+" "+name, func(t *testing.T) {
+				root := t.TempDir()
+				mgr := New(&config.Config{
+					Directories:   []config.DirMapping{{Source: root, Virtual: "/inbox", AutoExtract: true}},
+					Decompression: tt.limits,
+				})
+				require.NoError(t, os.WriteFile(filepath.Join(root, name), archive, 0600))
+
+				_, err := mgr.AutoExtract("/inbox/" + name)
+				if tt.wantErr == "" {
+					require.NoError(t, err)
+					assert.FileExists(t, filepath.Join(root, "bomb", "zeros.bin"))
+					return
+				}
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), "decompression limit exceeded")
+				assert.Contains(t, err.Error(), tt.wantErr)
+				assert.NoDirExists(t, filepath.Join(root, "bomb"))
+				assert.FileExists(t, filepath.Join(root, name))
+			})
+		}
+	}
+}
+
+func TestExtractZipForgedSizes(t *testing.T) {
+	// The announced sizes add up to 1000 bytes in 64 bits, while the first
+	// entry holds 3 MB.
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, entry := range []struct {
+		name     string
+		size     int
+		declared uint64
+	}{{"big.bin", 3 << 20, math.MaxUint64 - 999}, {"small.bin", 2000, 2000}} {
+		content := make([]byte, entry.size)
+		w, err := zw.CreateRaw(&zip.FileHeader{Name: entry.name, Method: zip.Store,
+			CRC32: crc32.ChecksumIEEE(content), CompressedSize64: uint64(entry.size),
+			UncompressedSize64: entry.declared})
+		require.NoError(t, err)
+		_, err = w.Write(content)
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+
+	root := t.TempDir()
+	archivePath := filepath.Join(root, "forged.zip")
+	require.NoError(t, os.WriteFile(archivePath, buf.Bytes(), 0600))
+
+	err := extractArchive(archivePath, filepath.Join(root, "forged"), -1, DecompressionLimits{MaxSize: 1 << 20},
+		ExtractSymlinksReject)
+	require.Error(t, err)
+	assert.ErrorIs(t, err, errDecompressionLimit)
+	assert.Contains(t, err.Error(), "announced sizes overflow")
+	assert.NoDirExists(t, filepath.Join(root, "forged"))
+}
+
+func TestExtractZipMetersContent(t *testing.T) {
+	content := bytes.Repeat([]byte("x"), 3<<20)
+	meter := &limitedDecompressor{in: &countingReader{n: 1 << 20}, limits: DecompressionLimits{MaxSize: 1 << 20}}
+	meter.r = bytes.NewReader(content)
+
+	x := &extractor{dest: t.TempDir(), remaining: -1}
+	err := x.writeFile("big.bin", meter)
+	require.ErrorIs(t, err, errDecompressionLimit)
+	info, err := os.Stat(filepath.Join(x.dest, "big.bin"))
+	require.NoError(t, err)
+	assert.LessOrEqual(t, info.Size(), int64(1<<20)+32*1024, "writing stops at the limit")
+}
+
+// buildTarGzWithLink creates a gzip compressed tar archive holding a file and
+// a symbolic link entry
+func buildTarGzWithLink(t *testing.T, linkName, linkTarget string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "dir/a.txt", Mode: 0600, Size: 5, Typeflag: tar.TypeReg}))
+	_, err := tw.Write([]byte("alpha"))
+	require.NoError(t, err)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: linkName, Linkname: linkTarget, Typeflag: tar.TypeSymlink}))
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+// buildZipWithLink creates a zip archive holding a symbolic link entry
+func buildZipWithLink(t *testing.T, linkName, linkTarget string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	hdr := &zip.FileHeader{Name: linkName, Method: zip.Store}
+	hdr.SetMode(os.ModeSymlink | 0777)
+	w, err := zw.CreateHeader(hdr)
+	require.NoError(t, err)
+	_, err = w.Write([]byte(linkTarget))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+func TestAutoExtractSymlinks(t *testing.T) {
+	setup := func(t *testing.T, policy string) (*Manager, string) {
+		t.Helper()
+		tempDir := t.TempDir()
+		cfg := &config.Config{
+			Directories: []config.DirMapping{{Source: tempDir, Virtual: "/inbox", AutoExtract: true}},
+			Extraction:  config.ExtractionConfig{Symlinks: policy},
+		}
+		return New(cfg), tempDir
+	}
+
+	archives := []struct {
+		name  string
+		build func(t *testing.T, linkName, linkTarget string) []byte
+	}{
+		{name: "bundle.tar.gz", build: buildTarGzWithLink},
+		{name: "bundle.zip", build: buildZipWithLink},
+	}
+
+	for _, archive := range archives {
+		t.Run(archive.name, func(t *testing.T) {
+			t.Run("links are rejected by default", func(t *testing.T) {
+				mgr, root := setup(t, "")
+				data := archive.build(t, "dir/link", "a.txt")
+				require.NoError(t, os.WriteFile(filepath.Join(root, archive.name), data, 0600))
+
+				_, err := mgr.AutoExtract("/inbox/" + archive.name)
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), "symbolic link dir/link is not allowed")
+				assert.NoDirExists(t, filepath.Join(root, "bundle"))
+			})
+
+			t.Run("escaping link is rejected when links are allowed", func(t *testing.T) {
+				mgr, root := setup(t, ExtractSymlinksContained)
+				data := archive.build(t, "dir/link", "../../../etc/passwd")
+				require.NoError(t, os.WriteFile(filepath.Join(root, archive.name), data, 0600))
+
+				_, err := mgr.AutoExtract("/inbox/" + archive.name)
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), "points outside the extracted directory")
+				assert.NoDirExists(t, filepath.Join(root, "bundle"))
+			})
+
+			t.Run("absolute link is rejected when links are allowed", func(t *testing.T) {
+				mgr, root := setup(t, ExtractSymlinksContained)
+				data := archive.build(t, "link", root)
+				require.NoError(t, os.WriteFile(filepath.Join(root, archive.name), data, 0600))
+
+				_, err := mgr.AutoExtract("/inbox/" + archive.name)
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), "points outside the extracted directory")
+			})
+
+			t.Run("contained link is created", func(t *testing.T) {
+				if runtime.GOOS == "windows" {
+					t.Skip("creating symbolic links requires privileges on Windows")
+				}
+				mgr, root := setup(t, ExtractSymlinksContained)
+				data := archive.build(t, "dir/link", "a.txt")
+				require.NoError(t, os.WriteFile(filepath.Join(root, archive.name), data, 0600))
+
+				_, err := mgr.AutoExtract("/inbox/" + archive.name)
+				require.NoError(t, err)
+				target, err := os.Readlink(filepath.Join(root, "bundle", "dir", "link"))
+				require.NoError(t, err)
+				assert.Equal(t, "a.txt", target)
+			})
+
+			t.Run("links are skipped", func(t *testing.T) {
+				mgr, root := setup(t, ExtractSymlinksSkip)
+				data := archive.build(t, "dir/link", "../../../etc/passwd")
+				require.NoError(t, os.WriteFile(filepath.Join(root, archive.name), data, 0600))
+
+				_, err := mgr.AutoExtract("/inbox/" + archive.name)
+				require.NoError(t, err)
+				_, err = os.Lstat(filepath.Join(root, "bundle", "dir", "link"))
+				assert.True(t, os.IsNotExist(err))
+			})
+		})
+	}
+}
+
+func TestExtractRejectsEntriesBelowLinks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("creating symbolic links requires privileges on Windows")
+	}
+	// "self" points at the extraction root, so "self/up" pointing to ".."
+	// looks contained but would resolve outside of it
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "self", Linkname: ".", Typeflag: tar.TypeSymlink}))
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "self/up", Linkname: "..", Typeflag: tar.TypeSymlink}))
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+
+	root := t.TempDir()
+	archivePath := filepath.Join(root, "bundle.tar.gz")
+	require.NoError(t, os.WriteFile(archivePath, buf.Bytes(), 0600))
+
+	err := extractArchive(archivePath, filepath.Join(root, "bundle"), -1, DecompressionLimits{},
+		ExtractSymlinksContained)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "below a symbolic link")
+	assert.NoDirExists(t, filepath.Join(root, "bundle"))
+}
+
+func TestExtractRejectsChainedLinks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("creating symbolic links requires privileges on Windows")
+	}
+	// "a/b" points at the extraction root, so "c" resolves two levels above
+	// it although its target looks contained as text
+	build := func(links ...[2]string) []byte {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		tw := tar.NewWriter(gz)
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: "a/", Mode: 0750, Typeflag: tar.TypeDir}))
+		for _, link := range links {
+			require.NoError(t, tw.WriteHeader(&tar.Header{Name: link[0], Linkname: link[1], Typeflag: tar.TypeSymlink}))
+		}
+		require.NoError(t, tw.Close())
+		require.NoError(t, gz.Close())
+		return buf.Bytes()
+	}
+	archives := map[string][]byte{
+		"link first":   build([2]string{"a/b", ".."}, [2]string{"c", "a/b/../.."}),
+		"link second":  build([2]string{"c", "a/b/../.."}, [2]string{"a/b", ".."}),
+		"through link": build([2]string{"a/b", ".."}, [2]string{"c", "a/b/a"}),
+	}
+
+	for name, archive := range archives {
+		t.Run(name, func(t *testing.T) {
+			root := t.TempDir()
+			archivePath := filepath.Join(root, "bundle.tar.gz")
+			require.NoError(t, os.WriteFile(archivePath, archive, 0600))
+
+			err := extractArchive(archivePath, filepath.Join(root, "bundle"), -1, DecompressionLimits{},
+				ExtractSymlinksContained)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "symbolic link c points outside the extracted directory")
+			assert.NoDirExists(t, filepath.Join(root, "bundle"))
+		})
+	}
+
+	t.Run("links to parents stay allowed", func(t *testing.T) {
+		root := t.TempDir()
+		archivePath := filepath.Join(root, "bundle.tar.gz")
+		require.NoError(t, os.WriteFile(archivePath, build([2]string{"a/b", "../a"}, [2]string{"c", "./a"}), 0600))
+		require.NoError(t, extractArchive(archivePath, filepath.Join(root, "bundle"), -1, DecompressionLimits{},
+			ExtractSymlinksContained))
+	})
+}
+
+func TestExtractArchive(t *testing.T) {
+	setup := func(t *testing.T, quota int64) (*Manager, string) {
+		t.Helper()
+		root := t.TempDir()
+		return New(&config.Config{
+			Directories: []config.DirMapping{{Source: root, Virtual: "/docs"}},
+			QuotaBytes:  quota,
+		}), root
+	}
+
+	// assertNoStaging fails when a staging directory was left behind
+	assertNoStaging := func(t *testing.T, dir string) {
+		t.Helper()
+		matches, err := filepath.Glob(filepath.Join(dir, extractPattern))
+		require.NoError(t, err)
+		assert.Empty(t, matches)
+	}
+
+	t.Run("zip is merged into an existing directory", func(t *testing.T) {
+		mgr, root := setup(t, 0)
+		require.NoError(t, os.WriteFile(filepath.Join(root, "bundle.zip"),
+			buildZip(t, map[string]string{"a.txt": "alpha", "sub/b.txt": "beta"}), 0600))
+		require.NoError(t, os.WriteFile(filepath.Join(root, "existing.txt"), []byte("kept"), 0600))
+
+		require.NoError(t, mgr.ExtractArchive("/docs/bundle.zip", "/docs"))
+
+		content, err := os.ReadFile(filepath.Join(root, "sub", "b.txt"))
+		require.NoError(t, err)
+		assert.Equal(t, "beta", string(content))
+		assert.FileExists(t, filepath.Join(root, "a.txt"))
+		assert.FileExists(t, filepath.Join(root, "existing.txt"))
+		assert.FileExists(t, filepath.Join(root, "bundle.zip"))
+		assertNoStaging(t, root)
+	})
+
+	t.Run("tar.gz is extracted into a new directory", func(t *testing.T) {
+		mgr, root := setup(t, 0)
+		require.NoError(t, os.WriteFile(filepath.Join(root, "bundle.tgz"),
+			buildTarGz(t, map[string]string{"a.txt": "alpha", "sub/b.txt": "beta"}), 0600))
+
+		require.NoError(t, mgr.ExtractArchive("/docs/bundle.tgz", "/docs/unpacked"))
+
+		content, err := os.ReadFile(filepath.Join(root, "unpacked", "sub", "b.txt"))
+		require.NoError(t, err)
+		assert.Equal(t, "beta", string(content))
+		assertNoStaging(t, root)
+	})
+
+	t.Run("unsafe entries are rejected by name", func(t *testing.T) {
+		for _, name := range []string{"../evil.txt", "sub/../../evil.txt", "/abs.txt"} {
+			mgr, root := setup(t, 0)
+			dest := filepath.Join(root, "dest")
+			require.NoError(t, os.Mkdir(dest, 0750))
+			require.NoError(t, os.WriteFile(filepath.Join(root, "bad.zip"),
+				buildZip(t, map[string]string{"ok.txt": "fine", name: "evil"}), 0600))
+
+			err := mgr.ExtractArchive("/docs/bad.zip", "/docs/dest")
+			require.Error(t, err, name)
+			assert.Contains(t, err.Error(), "invalid archive entry", name)
+			assert.Contains(t, err.Error(), filepath.Base(name), name)
+
+			entries, err := os.ReadDir(dest)
+			require.NoError(t, err)
+			assert.Empty(t, entries, name)
+			assert.NoFileExists(t, filepath.Join(root, "evil.txt"))
+		}
+	})
+
+	t.Run("existing entries are not overwritten", func(t *testing.T) {
+		mgr, root := setup(t, 0)
+		require.NoError(t, os.WriteFile(filepath.Join(root, "bundle.zip"),
+			buildZip(t, map[string]string{"a.txt": "alpha", "b.txt": "new"}), 0600))
+		require.NoError(t, os.WriteFile(filepath.Join(root, "b.txt"), []byte("old"), 0600))
+
+		err := mgr.ExtractArchive("/docs/bundle.zip", "/docs")
+		assert.ErrorContains(t, err, "already exists: /docs/b.txt")
+
+		content, err := os.ReadFile(filepath.Join(root, "b.txt"))
+		require.NoError(t, err)
+		assert.Equal(t, "old", string(content))
+		assert.NoFileExists(t, filepath.Join(root, "a.txt"))
+		assertNoStaging(t, root)
+	})
+
+	t.Run("quota covers the uncompressed size", func(t *testing.T) {
+		archive := buildZip(t, map[string]string{"big.txt": strings.Repeat("x", 10000)})
+		mgr, root := setup(t, int64(len(archive))+100)
+		require.NoError(t, os.WriteFile(filepath.Join(root, "big.zip"), archive, 0600))
+
+		err := mgr.ExtractArchive("/docs/big.zip", "/docs/unpacked")
+		assert.ErrorContains(t, err, "quota exceeded")
+		assert.NoDirExists(t, filepath.Join(root, "unpacked"))
+		assertNoStaging(t, root)
+	})
+
+	t.Run("failed extractions leave the tracked usage unchanged", func(t *testing.T) {
+		root := t.TempDir()
+		mgr := New(&config.Config{
+			Main:        config.MainConfig{QuotaReconcileInterval: 3600},
+			Directories: []config.DirMapping{{Source: root, Virtual: "/docs"}},
+		})
+		require.NoError(t, os.WriteFile(filepath.Join(root, "bundle.zip"),
+			buildZip(t, map[string]string{"a.txt": strings.Repeat("a", 1000), "b.txt": "new"}), 0600))
+		require.NoError(t, os.WriteFile(filepath.Join(root, "b.txt"), []byte("old"), 0600))
+		_, err := mgr.GetQuotaInfo()
+		require.NoError(t, err)
+
+		assert.ErrorContains(t, mgr.ExtractArchive("/docs/bundle.zip", "/docs"), "already exists")
+
+		tracked, err := mgr.GetQuotaInfo()
+		require.NoError(t, err)
+		fresh, drift, err := mgr.RecalculateUsage()
+		require.NoError(t, err)
+		assert.Equal(t, fresh.Used, tracked.Used)
+		assert.Zero(t, drift)
+	})
+
+	t.Run("invalid requests", func(t *testing.T) {
+		mgr, root := setup(t, 0)
+		require.NoError(t, os.WriteFile(filepath.Join(root, "bundle.zip"), buildZip(t, nil), 0600))
+		require.NoError(t, os.WriteFile(filepath.Join(root, "notes.txt"), []byte("text"), 0600))
+
+		assert.ErrorContains(t, mgr.ExtractArchive("/docs/missing.zip", "/docs"), "not found")
+		assert.ErrorContains(t, mgr.ExtractArchive("/docs/notes.txt", "/docs"), "unsupported archive type")
+		assert.ErrorContains(t, mgr.ExtractArchive("/docs/bundle.zip", "/docs/a/b"), "destination directory not found")
+		assert.ErrorContains(t, mgr.ExtractArchive("/docs/bundle.zip", "/docs/notes.txt"), "not a directory")
+	})
+}
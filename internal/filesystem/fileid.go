@@ -0,0 +1,145 @@
+package filesystem
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// fileIDLength is the length of a file id: device and inode as 16 hex digits each
+const fileIDLength = 32
+
+// errFileIDFound stops the walk once the file with the wanted id was found
+var errFileIDFound = errors.New("file id found")
+
+// fileIDPaths remembers where an id was last found, so that resolving it again
+// only needs a walk after the file was moved. Entries are verified on use.
+var fileIDPaths sync.Map // id -> physical path
+
+// fileIDWalk lets only one walk over the mapped trees run at a time, so that
+// requests for unknown ids cannot keep several walks busy at once
+var fileIDWalk sync.Mutex
+
+// FileID returns the stable id of a file: it survives renames and moves within
+// the same filesystem, but not copies. Ids of deleted files may be reused by
+// new files. Platforms without inode numbers have no ids, and an empty string
+// is returned.
+func FileID(info os.FileInfo) string {
+	inode, device := getFileIdentity(info)
+	if inode == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%016x%016x", device, inode)
+}
+
+// parseFileID splits a file id into its device and inode numbers
+func parseFileID(id string) (device, inode uint64, err error) {
+	if len(id) != fileIDLength {
+		return 0, 0, fmt.Errorf("invalid file id: %s", id)
+	}
+	device, err = strconv.ParseUint(id[:fileIDLength/2], 16, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid file id: %s", id)
+	}
+	inode, err = strconv.ParseUint(id[fileIDLength/2:], 16, 64)
+	if err != nil || inode == 0 {
+		return 0, 0, fmt.Errorf("invalid file id: %s", id)
+	}
+	return device, inode, nil
+}
+
+// ResolveFileID returns the current virtual path of the file or directory
+// with the given id. Only the managed directories are searched; symbolic links
+// are not followed. The trash and the subtrees excluded from the quota, which
+// hold scratch data, are left out.
+func (m *Manager) ResolveFileID(id string) (string, error) {
+	device, inode, err := parseFileID(id)
+	if err != nil {
+		return "", err
+	}
+
+	matches := func(info os.FileInfo) bool {
+		ino, dev := getFileIdentity(info)
+		return ino == inode && dev == device
+	}
+
+	lookup := func() (string, bool) {
+		cached, ok := fileIDPaths.Load(id)
+		if !ok {
+			return "", false
+		}
+		physicalPath := cached.(string)
+		info, err := os.Lstat(physicalPath)
+		if err != nil || !matches(info) || !m.isPathSafe(physicalPath) || m.inTrash(physicalPath) {
+			return "", false
+		}
+		return m.virtualPathOf(physicalPath)
+	}
+	if virtualPath, ok := lookup(); ok {
+		return virtualPath, nil
+	}
+
+	fileIDWalk.Lock()
+	defer fileIDWalk.Unlock()
+	// Another request may have found the id while this one was waiting
+	if virtualPath, ok := lookup(); ok {
+		return virtualPath, nil
+	}
+
+	excluded := m.quotaExcludedDirs()
+	for _, dir := range m.VirtualFS.Directories {
+		var found string
+		err := filepath.WalkDir(dir.Source, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil // Skip entries we can't access
+			}
+			if d.IsDir() && (m.isTrashDir(p) || excluded[filepath.Clean(p)]) {
+				return filepath.SkipDir
+			}
+			info, err := d.Info()
+			if err != nil {
+				return nil
+			}
+			if matches(info) {
+				found = p
+				return errFileIDFound
+			}
+			return nil
+		})
+		if err != nil && !errors.Is(err, errFileIDFound) {
+			return "", err
+		}
+		if found == "" || !m.isPathSafe(found) {
+			continue
+		}
+		if virtualPath, ok := m.virtualPathOf(found); ok {
+			fileIDPaths.Store(id, found)
+			return virtualPath, nil
+		}
+	}
+
+	return "", fmt.Errorf("file not found: %s", id)
+}
+
+// virtualPathOf converts a physical path within one of the mappings to its
+// virtual path
+func (m *Manager) virtualPathOf(physicalPath string) (string, bool) {
+	for _, dir := range m.VirtualFS.Directories {
+		rel, err := filepath.Rel(dir.Source, physicalPath)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+		if dir.LowercasePaths {
+			rel = strings.ToLower(rel)
+		}
+		return path.Join(dir.Virtual, rel), true
+	}
+	return "", false
+}
@@ -0,0 +1,93 @@
+//go:build !windows
+
+package filesystem
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"dendrite/internal/config"
+)
+
+func TestResolveFileID(t *testing.T) {
+	tempDir := t.TempDir()
+	otherDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(tempDir, "a"), 0750))
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "a", "doc.txt"), []byte("doc"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(otherDir, "hidden.txt"), []byte("x"), 0600))
+
+	mgr := New(&config.Config{Directories: []config.DirMapping{{Source: tempDir, Virtual: "/test"}}})
+
+	stat, err := mgr.StatFile("/test/a/doc.txt")
+	require.NoError(t, err)
+	require.Len(t, stat.ID, fileIDLength)
+
+	resolved, err := mgr.ResolveFileID(stat.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "/test/a/doc.txt", resolved)
+
+	t.Run("id survives a move", func(t *testing.T) {
+		require.NoError(t, mgr.MoveFile("/test/a/doc.txt", "/test/renamed.txt"))
+		resolved, err := mgr.ResolveFileID(stat.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "/test/renamed.txt", resolved)
+
+		moved, err := mgr.StatFile("/test/renamed.txt")
+		require.NoError(t, err)
+		assert.Equal(t, stat.ID, moved.ID)
+	})
+
+	t.Run("files outside the mappings are not found", func(t *testing.T) {
+		info, err := os.Stat(filepath.Join(otherDir, "hidden.txt"))
+		require.NoError(t, err)
+		_, err = mgr.ResolveFileID(FileID(info))
+		assert.ErrorContains(t, err, "file not found")
+	})
+
+	t.Run("malformed ids are rejected", func(t *testing.T) {
+		for _, id := range []string{"", "abc", strings.Repeat("z", fileIDLength)} {
+			_, err := mgr.ResolveFileID(id)
+			assert.ErrorContains(t, err, "invalid file id", id)
+		}
+	})
+}
+
+func TestResolveFileIDSkipsScratchDirs(t *testing.T) {
+	tempDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(tempDir, "cache"), 0750))
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "cache", "tmp.bin"), []byte("x"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "doc.txt"), []byte("doc"), 0600))
+
+	mgr := New(&config.Config{
+		Main:        config.MainConfig{TrashDir: ".trash", QuotaExclude: []string{"/test/cache"}},
+		Directories: []config.DirMapping{{Source: tempDir, Virtual: "/test"}},
+	})
+	idOf := func(physicalPath string) string {
+		t.Helper()
+		info, err := os.Stat(physicalPath)
+		require.NoError(t, err)
+		return FileID(info)
+	}
+
+	t.Run("excluded subtrees are not searched", func(t *testing.T) {
+		_, err := mgr.ResolveFileID(idOf(filepath.Join(tempDir, "cache", "tmp.bin")))
+		assert.ErrorContains(t, err, "file not found")
+	})
+
+	t.Run("trashed files are not found", func(t *testing.T) {
+		id := idOf(filepath.Join(tempDir, "doc.txt"))
+		resolved, err := mgr.ResolveFileID(id)
+		require.NoError(t, err)
+		assert.Equal(t, "/test/doc.txt", resolved)
+
+		// Neither through the remembered path nor by walking
+		require.NoError(t, mgr.DeleteFile("/test/doc.txt"))
+		_, err = mgr.ResolveFileID(id)
+		assert.ErrorContains(t, err, "file not found")
+	})
+}
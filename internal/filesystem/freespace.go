@@ -0,0 +1,47 @@
+package filesystem
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"dendrite/internal/format"
+)
+
+// diskFree reports the space available to unprivileged users on the
+// filesystem containing a path. Tests replace it to simulate a full disk.
+var diskFree = getDiskFree
+
+// checkFreeSpace rejects writing size bytes to physicalPath when this would
+// leave less than min_free_space free on its filesystem. A negative size, for
+// uploads of unknown length, only checks that the threshold is not already
+// reached. Platforms without free space information skip the check.
+func (m *Manager) checkFreeSpace(physicalPath string, size int64) error {
+	minFree := m.Config.Main.MinFreeSpaceBytes
+	if minFree <= 0 {
+		return nil
+	}
+
+	// The target and its parents may not exist yet
+	dir := physicalPath
+	for {
+		if _, err := os.Stat(dir); err == nil {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil
+		}
+		dir = parent
+	}
+
+	free, ok := diskFree(dir)
+	if !ok {
+		return nil
+	}
+	if free-max(size, 0) < minFree {
+		return fmt.Errorf("insufficient disk space: operation would leave less than %s free (available: %s)",
+			format.FileSize(minFree), format.FileSize(free))
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+//go:build !windows
+
+package filesystem
+
+import "syscall"
+
+// getDiskFree returns the space available to unprivileged users on the
+// filesystem containing path
+func getDiskFree(path string) (int64, bool) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, false
+	}
+	return int64(stat.Bavail) * int64(stat.Bsize), true // #nosec G115 - block counts fit into int64
+}
+
+// getDiskSpace returns the device and the space available to unprivileged
+// users and in total on the filesystem containing path
+func getDiskSpace(path string) (deviceSpace, bool) {
+	var st syscall.Stat_t
+	if err := syscall.Stat(path, &st); err != nil {
+		return deviceSpace{}, false
+	}
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return deviceSpace{}, false
+	}
+	return deviceSpace{
+		Device: uint64(st.Dev),                         // #nosec G115 - device numbers are never negative
+		Free:   int64(stat.Bavail) * int64(stat.Bsize), // #nosec G115 - block counts fit into int64
+		Total:  int64(stat.Blocks) * int64(stat.Bsize), // #nosec G115 - block counts fit into int64
+	}, true
+}
@@ -0,0 +1,73 @@
+//go:build !windows
+
+package filesystem
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"dendrite/internal/config"
+)
+
+func TestMinFreeSpace(t *testing.T) {
+	tempDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "small.txt"), []byte("small"), 0600))
+
+	cfg := &config.Config{Directories: []config.DirMapping{{Source: tempDir, Virtual: "/test"}}}
+	cfg.Main.MinFreeSpaceBytes = 1000
+	mgr := New(cfg)
+
+	t.Run("statfs reports the free space", func(t *testing.T) {
+		free, ok := getDiskFree(tempDir)
+		require.True(t, ok)
+		assert.Positive(t, free)
+	})
+
+	// Simulate a disk with 1500 bytes left
+	original := diskFree
+	diskFree = func(string) (int64, bool) { return 1500, true }
+	t.Cleanup(func() { diskFree = original })
+
+	t.Run("writes within the headroom succeed", func(t *testing.T) {
+		_, err := mgr.UploadFile("/test", "fits.txt", bytes.NewReader(make([]byte, 400)), 400)
+		require.NoError(t, err)
+		_, err = mgr.WriteFile("/test/written.txt", make([]byte, 400))
+		require.NoError(t, err)
+	})
+
+	t.Run("writes dropping below the threshold are rejected", func(t *testing.T) {
+		_, err := mgr.UploadFile("/test", "large.bin", bytes.NewReader(make([]byte, 600)), 600)
+		assert.ErrorContains(t, err, "insufficient disk space")
+		assert.NoFileExists(t, filepath.Join(tempDir, "large.bin"))
+
+		_, err = mgr.StreamFile("/test/new/large.bin", bytes.NewReader(make([]byte, 600)), 600)
+		assert.ErrorContains(t, err, "insufficient disk space")
+
+		_, err = mgr.WriteFile("/test/written.txt", make([]byte, 1200))
+		assert.ErrorContains(t, err, "insufficient disk space")
+	})
+
+	t.Run("copies count their full size", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(filepath.Join(tempDir, "medium.bin"), make([]byte, 600), 0600))
+		assert.ErrorContains(t, mgr.CopyFile("/test/medium.bin", "/test/copy.bin"), "insufficient disk space")
+		assert.NoError(t, mgr.CopyFile("/test/small.txt", "/test/copy.txt"))
+	})
+
+	t.Run("a full disk rejects uploads of unknown size", func(t *testing.T) {
+		diskFree = func(string) (int64, bool) { return 500, true }
+		_, err := mgr.UploadFile("/test", "unknown.txt", bytes.NewReader([]byte("x")), -1)
+		assert.ErrorContains(t, err, "insufficient disk space")
+	})
+}
+
+func TestGetDiskSpace(t *testing.T) {
+	space, ok := getDiskSpace(t.TempDir())
+	require.True(t, ok)
+	assert.Positive(t, space.Total)
+	assert.LessOrEqual(t, space.Free, space.Total)
+}
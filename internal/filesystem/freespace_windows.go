@@ -0,0 +1,13 @@
+//go:build windows
+
+package filesystem
+
+// getDiskFree is not implemented on Windows; min_free_space is not enforced
+func getDiskFree(_ string) (int64, bool) {
+	return 0, false
+}
+
+// getDiskSpace is not implemented on Windows; directories report no device
+func getDiskSpace(_ string) (deviceSpace, bool) {
+	return deviceSpace{}, false
+}
@@ -0,0 +1,80 @@
+package filesystem
+
+import (
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// tempPatterns match the temporary files and directories that uploads,
+// extractions and directory replacements create next to their destination
+var tempPatterns = []string{".dendrite-upload-*", ".dendrite-resumable-*", ".dendrite-replace-*",
+	".dendrite-extract-*", "*.dendrite-old"}
+
+// spoolPattern matches the files net/http spools large multipart uploads to
+const spoolPattern = "multipart-*"
+
+// isTempName reports whether name matches one of the patterns
+func isTempName(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// RemoveStaleTemp removes temporary files and directories older than maxAge
+// that were left behind below dirs, e.g. by a crash during an upload, as well
+// as stale multipart spool files directly in spoolDir. Each removal is logged
+// and the removed paths are returned.
+func RemoveStaleTemp(dirs []string, spoolDir string, maxAge time.Duration) []string {
+	cutoff := time.Now().Add(-maxAge)
+	var removed []string
+
+	remove := func(path string, info fs.FileInfo) {
+		if info.ModTime().After(cutoff) {
+			return
+		}
+		if err := os.RemoveAll(path); err != nil {
+			log.Printf("Failed to remove stale temporary file %s: %v", path, err)
+			return
+		}
+		log.Printf("Removed stale temporary file %s (modified %s)", path, info.ModTime().Format(time.RFC3339))
+		removed = append(removed, path)
+	}
+
+	for _, dir := range dirs {
+		_ = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || path == dir || !isTempName(d.Name(), tempPatterns) {
+				return nil // Skip entries we can't access
+			}
+			if info, err := d.Info(); err == nil {
+				remove(path, info)
+			}
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		})
+	}
+
+	if spoolDir != "" {
+		entries, err := os.ReadDir(spoolDir)
+		if err != nil {
+			return removed
+		}
+		for _, entry := range entries {
+			if entry.IsDir() || !isTempName(entry.Name(), []string{spoolPattern}) {
+				continue
+			}
+			if info, err := entry.Info(); err == nil {
+				remove(filepath.Join(spoolDir, entry.Name()), info)
+			}
+		}
+	}
+
+	return removed
+}
@@ -0,0 +1,48 @@
+package filesystem
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRemoveStaleTemp(t *testing.T) {
+	root := t.TempDir()
+	spool := t.TempDir()
+	old := time.Now().Add(-2 * time.Hour)
+
+	create := func(path string, stale bool) string {
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0750))
+		require.NoError(t, os.WriteFile(path, []byte("data"), 0600))
+		if stale {
+			require.NoError(t, os.Chtimes(path, old, old))
+		}
+		return path
+	}
+
+	staleUpload := create(filepath.Join(root, "docs", ".dendrite-upload-123"), true)
+	recentUpload := create(filepath.Join(root, "docs", ".dendrite-upload-456"), false)
+	oldRegular := create(filepath.Join(root, "docs", "report.txt"), true)
+	staleSpool := create(filepath.Join(spool, "multipart-789"), true)
+	recentSpool := create(filepath.Join(spool, "multipart-012"), false)
+	otherSpool := create(filepath.Join(spool, "unrelated.tmp"), true)
+
+	// A staging directory of an interrupted replace
+	staging := filepath.Join(root, ".dendrite-replace-345")
+	create(filepath.Join(staging, "inner.txt"), true)
+	require.NoError(t, os.Chtimes(staging, old, old))
+
+	removed := RemoveStaleTemp([]string{root}, spool, time.Hour)
+	assert.ElementsMatch(t, []string{staleUpload, staging, staleSpool}, removed)
+
+	for _, path := range []string{staleUpload, staging, staleSpool} {
+		assert.NoFileExists(t, path)
+	}
+	for _, path := range []string{recentUpload, oldRegular, recentSpool, otherSpool} {
+		assert.FileExists(t, path)
+	}
+}
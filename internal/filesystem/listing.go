@@ -0,0 +1,143 @@
+package filesystem
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// DefaultMaxEntries is the directory entry limit used when none is configured
+const DefaultMaxEntries = 100000
+
+// readDirBatch is the number of entries read from a directory at once
+const readDirBatch = 1024
+
+// errTooManyEntries is returned when a directory exceeds the entry limit and
+// the listing is configured to fail instead of truncating
+var errTooManyEntries = errors.New("too many directory entries")
+
+// Listing is the result of ListDirectory
+type Listing struct {
+	Files []FileInfo
+	// Truncated is set when the directory has more entries than the limit.
+	// Files is then an arbitrary subset of the entries, see readDirLimited.
+	Truncated bool
+}
+
+// Entry kinds accepted by FilterByType
+const (
+	FileTypeDir  = "dir"
+	FileTypeFile = "file"
+)
+
+// ValidateFileType checks that kind is empty or one of the known entry kinds
+func ValidateFileType(kind string) error {
+	switch kind {
+	case "", FileTypeDir, FileTypeFile:
+		return nil
+	}
+	return fmt.Errorf("invalid type: %s (expected dir or file)", kind)
+}
+
+// MatchesType reports whether f is of the given kind; an empty kind matches
+// every entry
+func MatchesType(f FileInfo, kind string) bool {
+	switch kind {
+	case FileTypeDir:
+		return f.IsDir
+	case FileTypeFile:
+		return !f.IsDir
+	}
+	return true
+}
+
+// FilterByType returns the entries of the given kind. The input is not modified.
+func FilterByType(files []FileInfo, kind string) []FileInfo {
+	if kind == "" {
+		return files
+	}
+	filtered := make([]FileInfo, 0, len(files))
+	for _, f := range files {
+		if MatchesType(f, kind) {
+			filtered = append(filtered, f)
+		}
+	}
+	return filtered
+}
+
+// DirsFirst returns the entries with all directories ahead of the files. The
+// order within each group is kept, so a preceding sort still applies. The
+// input is not modified.
+func DirsFirst(files []FileInfo) []FileInfo {
+	grouped := make([]FileInfo, 0, len(files))
+	for _, f := range files {
+		if f.IsDir {
+			grouped = append(grouped, f)
+		}
+	}
+	for _, f := range files {
+		if !f.IsDir {
+			grouped = append(grouped, f)
+		}
+	}
+	return grouped
+}
+
+// MaxEntries returns the configured directory entry limit, or -1 when
+// listings are unlimited
+func (m *Manager) MaxEntries() int {
+	limit := m.Config.Listing.MaxEntries
+	if limit == 0 {
+		return DefaultMaxEntries
+	}
+	if limit < 0 {
+		return -1
+	}
+	return limit
+}
+
+// readDirLimited reads the entries of a directory sorted by name, but stops
+// after the configured limit so that huge directories cannot exhaust memory.
+// A truncated read holds the entries the filesystem returned first, which are
+// an arbitrary subset rather than the first names in sort order.
+func (m *Manager) readDirLimited(dir string) (entries []os.DirEntry, truncated bool, err error) {
+	limit := m.MaxEntries()
+	if limit < 0 {
+		entries, err := os.ReadDir(dir)
+		return entries, false, err
+	}
+
+	f, err := os.Open(dir) // #nosec G304 - path is resolved from a configured mapping
+	if err != nil {
+		return nil, false, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	for len(entries) <= limit {
+		batch, err := f.ReadDir(min(readDirBatch, limit+1-len(entries)))
+		entries = append(entries, batch...)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, false, err
+		}
+	}
+
+	if len(entries) > limit {
+		if m.Config.Listing.OnLimit == "error" {
+			return nil, false, errTooManyEntries
+		}
+		entries = entries[:limit]
+		truncated = true
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+	return entries, truncated, nil
+}
@@ -0,0 +1,98 @@
+package filesystem
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"dendrite/internal/config"
+)
+
+func TestListDirectoryMaxEntries(t *testing.T) {
+	tempDir := t.TempDir()
+	for i := range 10 {
+		name := filepath.Join(tempDir, fmt.Sprintf("file%02d.txt", i))
+		require.NoError(t, os.WriteFile(name, []byte("x"), 0600))
+	}
+
+	newManager := func(listing config.ListingConfig) *Manager {
+		return New(&config.Config{
+			Directories: []config.DirMapping{{Source: tempDir, Virtual: "/test"}},
+			Listing:     listing,
+		})
+	}
+
+	t.Run("below the limit", func(t *testing.T) {
+		listing, err := newManager(config.ListingConfig{MaxEntries: 10}).ListDirectory("/test")
+		require.NoError(t, err)
+		assert.False(t, listing.Truncated)
+		assert.Len(t, listing.Files, 10)
+		assert.Equal(t, "file00.txt", listing.Files[0].Name)
+		assert.Equal(t, "file09.txt", listing.Files[9].Name)
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		listing, err := newManager(config.ListingConfig{MaxEntries: 4}).ListDirectory("/test")
+		require.NoError(t, err)
+		assert.True(t, listing.Truncated)
+		assert.Len(t, listing.Files, 4)
+		assert.IsNonDecreasing(t, []string{
+			listing.Files[0].Name, listing.Files[1].Name, listing.Files[2].Name, listing.Files[3].Name,
+		})
+	})
+
+	t.Run("error", func(t *testing.T) {
+		_, err := newManager(config.ListingConfig{MaxEntries: 4, OnLimit: "error"}).ListDirectory("/test")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "too many directory entries: /test has more than 4 entries")
+	})
+
+	t.Run("unlimited", func(t *testing.T) {
+		listing, err := newManager(config.ListingConfig{MaxEntries: -1}).ListDirectory("/test")
+		require.NoError(t, err)
+		assert.False(t, listing.Truncated)
+		assert.Len(t, listing.Files, 10)
+	})
+}
+
+func TestDirsFirst(t *testing.T) {
+	entries := []FileInfo{
+		{Name: "b.txt", Size: 300},
+		{Name: "c", IsDir: true, Size: 4096},
+		{Name: "a.txt", Size: 10},
+		{Name: "a", IsDir: true, Size: 64},
+		{Name: "d.txt", Size: 2000},
+	}
+	names := func(files []FileInfo) []string {
+		result := make([]string, 0, len(files))
+		for _, f := range files {
+			result = append(result, f.Name)
+		}
+		return result
+	}
+
+	t.Run("by name", func(t *testing.T) {
+		byName := append([]FileInfo(nil), entries...)
+		sort.Slice(byName, func(i, j int) bool { return byName[i].Name < byName[j].Name })
+
+		assert.Equal(t, []string{"a", "c", "a.txt", "b.txt", "d.txt"}, names(DirsFirst(byName)))
+	})
+
+	t.Run("by size descending", func(t *testing.T) {
+		bySize := append([]FileInfo(nil), entries...)
+		sort.Slice(bySize, func(i, j int) bool { return bySize[i].Size > bySize[j].Size })
+
+		assert.Equal(t, []string{"c", "a", "d.txt", "b.txt", "a.txt"}, names(DirsFirst(bySize)))
+	})
+
+	t.Run("input is not modified", func(t *testing.T) {
+		original := append([]FileInfo(nil), entries...)
+		DirsFirst(entries)
+		assert.Equal(t, original, entries)
+	})
+}
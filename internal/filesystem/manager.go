@@ -3,11 +3,13 @@ package filesystem
 
 import (
 	"archive/zip"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
 	"log"
 	"os"
+	"path"
 	"path/filepath"
 	"sort"
 	"strings"
@@ -22,23 +24,37 @@ type Manager struct {
 	Config      *config.Config
 	VirtualFS   *VirtualFS
 	Directories []config.DirMapping // JWT-restricted directories (subset of Config.Directories)
+
+	// QuotaBytes replaces Config.QuotaBytes for this manager when positive,
+	// e.g. with the quota claim of a token
+	QuotaBytes int64
+}
+
+// quotaLimit returns the storage limit in bytes, zero for unlimited
+func (m *Manager) quotaLimit() int64 {
+	if m.QuotaBytes > 0 {
+		return m.QuotaBytes
+	}
+	return m.Config.QuotaBytes
 }
 
 // New creates a new filesystem manager
 func New(cfg *config.Config) *Manager {
+	dirs := flattenSingleRoot(cfg, cfg.Directories)
 	return &Manager{
 		Config:      cfg,
-		VirtualFS:   NewVirtualFS(cfg.Directories),
-		Directories: cfg.Directories, // Use all configured directories
+		VirtualFS:   NewVirtualFS(dirs),
+		Directories: dirs, // Use all configured directories
 	}
 }
 
 // NewWithRestriction creates a new filesystem manager with JWT directory restrictions
 func NewWithRestriction(cfg *config.Config, jwtDirs []config.DirMapping) *Manager {
+	dirs := flattenSingleRoot(cfg, jwtDirs)
 	return &Manager{
 		Config:      cfg,
-		VirtualFS:   NewVirtualFS(jwtDirs),
-		Directories: jwtDirs, // Use only JWT-allowed directories
+		VirtualFS:   NewVirtualFS(dirs),
+		Directories: dirs, // Use only JWT-allowed directories
 	}
 }
 
@@ -51,6 +67,10 @@ type FileInfo struct {
 	ModTime  time.Time `json:"modTime"`
 	Mode     string    `json:"mode"`
 	MimeType string    `json:"mimeType,omitempty"`
+	// Inode and Device identify the underlying file on Unix systems; entries
+	// sharing both are hard links. Listings only include them on request.
+	Inode  uint64 `json:"inode,omitempty"`
+	Device uint64 `json:"device,omitempty"`
 }
 
 // QuotaInfo represents quota usage information
@@ -59,6 +79,14 @@ type QuotaInfo struct {
 	Limit     int64 `json:"limit"`
 	Available int64 `json:"available"`
 	Exceeded  bool  `json:"exceeded"`
+	// Human-readable forms of the values above, "unlimited" without a quota
+	UsedHuman      string `json:"usedHuman"`
+	LimitHuman     string `json:"limitHuman"`
+	AvailableHuman string `json:"availableHuman"`
+	// Directories reports the device space per directory, see DirectorySpace
+	Directories []DirectorySpace `json:"directories,omitempty"`
+	// PerDirectory reports the usage of each mapping, see GetQuotaInfoPerDir
+	PerDirectory map[string]QuotaInfo `json:"perDirectory,omitempty"`
 }
 
 // FileStatInfo represents detailed file stat information
@@ -75,6 +103,27 @@ type FileStatInfo struct {
 	Gid        uint32    `json:"gid"`
 	Nlink      uint64    `json:"nlink"`
 	MimeType   string    `json:"mimeType,omitempty"`
+	// ID references the file across renames, see FileID
+	ID string `json:"id,omitempty"`
+	// Content holds the file content when it was embedded, base64-encoded in JSON
+	Content []byte `json:"content,omitempty"`
+	// Entries is the number of entries of a directory
+	Entries *int `json:"entries,omitempty"`
+	// MaxFiles is the entry limit of a directory when one applies
+	MaxFiles int `json:"maxFiles,omitempty"`
+	// SHA256 and MD5 are the hex digests of a file's content when requested
+	SHA256 string `json:"sha256,omitempty"`
+	MD5    string `json:"md5,omitempty"`
+}
+
+// StatOptions controls optional parts of stat information
+type StatOptions struct {
+	// MaxEmbed embeds the content of regular files up to this size; zero
+	// disables embedding
+	MaxEmbed int64
+	// Checksum computes the digest of regular files with this algorithm,
+	// ChecksumSHA256 or ChecksumMD5; empty computes none
+	Checksum string
 }
 
 // UploadResult represents the result of a file upload
@@ -82,6 +131,20 @@ type UploadResult struct {
 	Path    string `json:"path"`
 	Size    int64  `json:"size"`
 	Message string `json:"message"`
+	// Created is false when an existing file was overwritten
+	Created bool `json:"created"`
+	// Extracted is the folder an archive was unpacked to by auto_extract
+	Extracted string `json:"extracted,omitempty"`
+	// CreatedDirs lists the virtual paths of parent directories that did not
+	// exist before the upload, outermost first
+	CreatedDirs []string `json:"createdDirs,omitempty"`
+}
+
+// WriteResult represents the result of writing a file
+type WriteResult struct {
+	// Created is false when an existing file was overwritten
+	Created bool  `json:"created"`
+	Size    int64 `json:"size"`
 }
 
 // resolvePath converts a virtual path to a physical path
@@ -90,11 +153,24 @@ func (m *Manager) resolvePath(virtualPath string) (string, error) {
 	if !found {
 		return "", fmt.Errorf("virtual path not found: %s", virtualPath)
 	}
+	if m.inTrash(physicalPath) {
+		return "", fmt.Errorf("access denied: %s is in the trash", virtualPath)
+	}
 	return physicalPath, nil
 }
 
 // ListFiles returns a list of files in the given virtual path
 func (m *Manager) ListFiles(virtualPath string) ([]FileInfo, error) {
+	listing, err := m.ListDirectory(virtualPath)
+	if err != nil {
+		return nil, err
+	}
+	return listing.Files, nil
+}
+
+// ListDirectory lists the given virtual path like ListFiles and reports
+// whether the listing was cut off at the configured maximum entry count
+func (m *Manager) ListDirectory(virtualPath string) (*Listing, error) {
 	// Handle virtual root specially
 	if m.VirtualFS.IsVirtualRoot(virtualPath) {
 		// Check if we have a single directory mapping to root
@@ -103,7 +179,11 @@ func (m *Manager) ListFiles(virtualPath string) ([]FileInfo, error) {
 			virtualPath = "/"
 		} else {
 			// Multiple mappings or non-root mappings, show virtual directories
-			return m.listVirtualRoot()
+			files, err := m.listVirtualRoot()
+			if err != nil {
+				return nil, err
+			}
+			return &Listing{Files: files}, nil
 		}
 	}
 
@@ -113,11 +193,17 @@ func (m *Manager) ListFiles(virtualPath string) ([]FileInfo, error) {
 		return nil, err
 	}
 
-	entries, err := os.ReadDir(fullPath)
+	entries, truncated, err := m.readDirLimited(fullPath)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return nil, fmt.Errorf("directory not found: %s", virtualPath)
 		}
+		if os.IsPermission(err) {
+			return nil, fmt.Errorf("access denied: no permission to read directory %s", virtualPath)
+		}
+		if errors.Is(err, errTooManyEntries) {
+			return nil, fmt.Errorf("%w: %s has more than %d entries", err, virtualPath, m.MaxEntries())
+		}
 		return nil, fmt.Errorf("failed to read directory: %w", err)
 	}
 
@@ -130,6 +216,9 @@ func (m *Manager) ListFiles(virtualPath string) ([]FileInfo, error) {
 
 		// Convert physical path back to virtual path
 		physicalPath := filepath.Join(fullPath, entry.Name())
+		if entry.IsDir() && m.isTrashDir(physicalPath) {
+			continue
+		}
 		virtualPath, _ := m.VirtualFS.GetVirtualPath(physicalPath)
 
 		fileInfo := FileInfo{
@@ -140,15 +229,16 @@ func (m *Manager) ListFiles(virtualPath string) ([]FileInfo, error) {
 			ModTime: info.ModTime(),
 			Mode:    info.Mode().String(),
 		}
+		fileInfo.Inode, fileInfo.Device = getFileIdentity(info)
 
 		if !entry.IsDir() {
-			fileInfo.MimeType = m.getMimeType(entry.Name())
+			fileInfo.MimeType = m.detectMimeType(physicalPath, info, m.Config.Listing.SniffMime)
 		}
 
 		files = append(files, fileInfo)
 	}
 
-	return files, nil
+	return &Listing{Files: files, Truncated: truncated}, nil
 }
 
 // GetQuotaInfo returns current quota usage information
@@ -156,7 +246,10 @@ func (m *Manager) GetQuotaInfo() (*QuotaInfo, error) {
 	// Calculate total size across all directories
 	var totalUsed int64
 	for _, dir := range m.Directories {
-		size, err := m.calculateDirectorySize(dir.Source)
+		if m.isQuotaExcluded(dir.Virtual) {
+			continue
+		}
+		size, err := m.mappingUsage(dir.Source)
 		if err != nil {
 			log.Printf("Warning: failed to calculate size for %s: %v", dir.Source, err)
 			continue
@@ -164,19 +257,29 @@ func (m *Manager) GetQuotaInfo() (*QuotaInfo, error) {
 		totalUsed += size
 	}
 
+	return newQuotaInfo(totalUsed, m.quotaLimit()), nil
+}
+
+// newQuotaInfo describes a usage against a limit, where zero is unlimited
+func newQuotaInfo(used, limit int64) *QuotaInfo {
 	info := &QuotaInfo{
-		Used:  totalUsed,
-		Limit: m.Config.QuotaBytes,
+		Used:  used,
+		Limit: limit,
 	}
 
-	if m.Config.QuotaBytes > 0 {
-		info.Available = m.Config.QuotaBytes - totalUsed
-		info.Exceeded = totalUsed > m.Config.QuotaBytes
+	info.UsedHuman = format.FileSize(used)
+	if limit > 0 {
+		info.Available = limit - used
+		info.Exceeded = used > limit
+		info.LimitHuman = format.FileSize(limit)
+		info.AvailableHuman = format.FileSize(max(info.Available, 0))
 	} else {
 		info.Available = -1 // Unlimited
+		info.LimitHuman = "unlimited"
+		info.AvailableHuman = "unlimited"
 	}
 
-	return info, nil
+	return info
 }
 
 // listVirtualRoot lists the virtual directories at the root level
@@ -205,14 +308,16 @@ func (m *Manager) listVirtualRoot() ([]FileInfo, error) {
 			// Get info from the physical directory
 			info, err := os.Stat(physicalPath)
 			if err == nil {
-				files = append(files, FileInfo{
+				fileInfo := FileInfo{
 					Name:    topLevel,
 					Path:    virtualPath,
 					Size:    info.Size(),
 					IsDir:   true,
 					ModTime: info.ModTime(),
 					Mode:    info.Mode().String(),
-				})
+				}
+				fileInfo.Inode, fileInfo.Device = getFileIdentity(info)
+				files = append(files, fileInfo)
 			}
 		} else {
 			// Virtual directory without direct mapping
@@ -264,21 +369,27 @@ func (m *Manager) isPathSafe(physicalPath string) bool {
 	return false
 }
 
-// calculateDirectorySize recursively calculates the total size of a directory
+// calculateDirectorySize recursively calculates the total size of a directory.
+// Subdirectories excluded from the quota are skipped.
 func (m *Manager) calculateDirectorySize(path string) (int64, error) {
 	var size int64
+	excluded := m.quotaExcludedDirs()
 
-	err := filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+	err := filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return nil // Skip files/directories we can't access
 		}
 
+		if d.IsDir() && p != path && excluded[p] {
+			return filepath.SkipDir
+		}
+
 		if !d.IsDir() {
 			info, err := d.Info()
 			if err != nil {
 				return nil // Skip files we can't stat
 			}
-			size += info.Size()
+			size += m.fileUsage(info)
 		}
 
 		return nil
@@ -287,24 +398,23 @@ func (m *Manager) calculateDirectorySize(path string) (int64, error) {
 	return size, err
 }
 
-// UploadFile uploads a file to the specified virtual path with quota checking
-func (m *Manager) UploadFile(virtualTargetPath, filename string, file io.Reader, size int64) (
-	result *UploadResult, err error) {
-	// Check quota before upload
-	if m.Config.QuotaBytes > 0 {
-		quotaInfo, err := m.GetQuotaInfo()
-		if err != nil {
-			return nil, fmt.Errorf("failed to calculate current usage: %w", err)
-		}
-
-		if quotaInfo.Used+size > m.Config.QuotaBytes {
-			return nil, fmt.Errorf("upload would exceed quota limit (current: %s, file: %s, limit: %s)",
-				format.FileSize(quotaInfo.Used),
-				format.FileSize(size),
-				format.FileSize(m.Config.QuotaBytes))
+// fileUsage returns the number of bytes a file counts against the quota. With
+// block accounting this is the allocated disk space where the platform
+// reports it, otherwise the logical size.
+func (m *Manager) fileUsage(info os.FileInfo) int64 {
+	if m.Config.Main.QuotaAccounting == "blocks" {
+		if allocated, ok := getAllocatedSize(info); ok {
+			return allocated
 		}
 	}
+	return info.Size()
+}
 
+// UploadFile uploads a file to the specified virtual path with quota checking.
+// A negative size marks content of unknown length, such as a decompressed
+// stream; the quota is then enforced while writing.
+func (m *Manager) UploadFile(virtualTargetPath, filename string, file io.Reader, size int64) (
+	result *UploadResult, err error) {
 	// Combine virtual path with filename
 	virtualFullPath := filepath.ToSlash(filepath.Join(virtualTargetPath, filename))
 
@@ -319,36 +429,121 @@ func (m *Manager) UploadFile(virtualTargetPath, filename string, file io.Reader,
 		return nil, fmt.Errorf("access denied: path outside managed directory")
 	}
 
+	if err := m.checkCaseCollision(physicalPath, virtualFullPath, ""); err != nil {
+		return nil, err
+	}
+
+	if err := checkNotDirectory(physicalPath, virtualFullPath); err != nil {
+		return nil, err
+	}
+
+	var oldSize int64
+	info, statErr := os.Stat(physicalPath)
+	created := os.IsNotExist(statErr)
+	if statErr == nil {
+		oldSize = info.Size()
+	}
+
+	// Check quota before upload. The replaced file is freed once the upload
+	// is renamed into place.
+	var quota *quotaReader
+	var used, limit int64
+	if m.quotaApplies(virtualFullPath) {
+		used, limit, err = m.quotaState(virtualFullPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to calculate current usage: %w", err)
+		}
+
+		if size < 0 {
+			quota = &quotaReader{r: file, limit: limit - used + oldSize}
+			file = quota
+		} else if used-oldSize+size > limit {
+			m.recordQuotaDenial("upload", virtualFullPath, used, size, limit)
+			return nil, fmt.Errorf("upload would exceed quota limit (current: %s, file: %s, limit: %s)",
+				format.FileSize(used),
+				format.FileSize(size),
+				format.FileSize(limit))
+		}
+	}
+
+	// The upload is staged next to the old file, which is only replaced at the end
+	if err := m.checkFreeSpace(physicalPath, size); err != nil {
+		return nil, err
+	}
+
 	// Create directory if it doesn't exist
 	dir := filepath.Dir(physicalPath)
+	if err := m.checkParentDirs(dir, path.Dir(virtualFullPath)); err != nil {
+		return nil, err
+	}
+	if err := m.checkEntryLimit(physicalPath, virtualFullPath); err != nil {
+		return nil, err
+	}
+	createdDirs := m.missingDirs(dir, path.Dir(virtualFullPath))
 	if err := os.MkdirAll(dir, 0750); err != nil {
 		return nil, fmt.Errorf("failed to create directory: %w", err)
 	}
 
-	// Create the file with secure permissions
-	outFile, err := os.OpenFile(physicalPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0640) // #nosec G302,G304
+	tmpFile, err := os.CreateTemp(dir, ".dendrite-upload-*")
 	if err != nil {
 		return nil, fmt.Errorf("failed to create file: %w", err)
 	}
+	tmpPath := tmpFile.Name()
 	defer func() {
-		if cerr := outFile.Close(); cerr != nil && err == nil {
-			err = cerr
+		// Leave an existing file untouched when the quota ran out or the
+		// content expanded beyond the decompression limits
+		if err != nil {
+			_ = os.Remove(tmpPath)
 		}
 	}()
 
 	// Copy the file content
-	written, err := io.Copy(outFile, file)
+	written, err := io.Copy(tmpFile, file)
+	if cerr := tmpFile.Close(); cerr != nil && err == nil {
+		err = cerr
+	}
+	if errors.Is(err, errQuotaExceeded) {
+		m.recordQuotaDenial("upload", virtualFullPath, used, quota.read, limit)
+		return nil, err
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to write file: %w", err)
 	}
 
+	if err := os.Chmod(tmpPath, 0640); err != nil {
+		return nil, fmt.Errorf("failed to set permissions: %w", err)
+	}
+
+	defer m.trackUsage(physicalPath)()
+	if err := os.Rename(tmpPath, physicalPath); err != nil {
+		return nil, fmt.Errorf("failed to move upload into place: %w", err)
+	}
+
 	return &UploadResult{
-		Path:    virtualFullPath,
-		Size:    written,
-		Message: "File uploaded successfully",
+		Path:        m.VirtualFS.NormalizePath(virtualFullPath),
+		Size:        written,
+		Message:     "File uploaded successfully",
+		Created:     created,
+		CreatedDirs: createdDirs,
 	}, nil
 }
 
+// missingDirs returns the virtual paths of the directories MkdirAll would
+// create for physicalDir, outermost first. virtualDir is the virtual path of
+// physicalDir; both are walked upwards in lockstep until a directory exists.
+func (m *Manager) missingDirs(physicalDir, virtualDir string) []string {
+	var missing []string
+	for virtualDir != "/" && virtualDir != "." {
+		if _, err := os.Stat(physicalDir); !os.IsNotExist(err) {
+			break
+		}
+		missing = append([]string{m.VirtualFS.NormalizePath(virtualDir)}, missing...)
+		physicalDir = filepath.Dir(physicalDir)
+		virtualDir = path.Dir(virtualDir)
+	}
+	return missing
+}
+
 // GetFilePath returns the full filesystem path for a virtual path
 func (m *Manager) GetFilePath(virtualPath string) (string, error) {
 	physicalPath, err := m.resolvePath(virtualPath)
@@ -363,7 +558,8 @@ func (m *Manager) GetFilePath(virtualPath string) (string, error) {
 	return physicalPath, nil
 }
 
-// DeleteFile deletes a file or directory
+// DeleteFile deletes a file or directory. With trash_dir it is moved into the
+// trash of its mapping instead, see RestoreTrash.
 func (m *Manager) DeleteFile(virtualPath string) error {
 	physicalPath, err := m.resolvePath(virtualPath)
 	if err != nil {
@@ -374,91 +570,186 @@ func (m *Manager) DeleteFile(virtualPath string) error {
 		return fmt.Errorf("access denied: path outside managed directory")
 	}
 
+	if m.trashEnabled() {
+		return m.moveToTrash(virtualPath, physicalPath)
+	}
+
+	defer m.trackUsage(physicalPath)()
 	return os.RemoveAll(physicalPath)
 }
 
-// MoveFile moves a file or directory from source to destination
+// MoveFile moves a file or directory from source to destination, replacing
+// an existing destination file
 func (m *Manager) MoveFile(virtualSourcePath, virtualDestPath string) error {
+	_, err := m.MoveFileWithOptions(virtualSourcePath, virtualDestPath, TransferOptions{Overwrite: OverwriteReplace})
+	return err
+}
+
+// MoveFileWithOptions moves a file or directory like MoveFile, handling an
+// existing destination according to the overwrite policy, and returns the
+// virtual path it was moved to
+func (m *Manager) MoveFileWithOptions(virtualSourcePath, virtualDestPath string, opts TransferOptions) (string, error) {
+	policy, err := ParseOverwritePolicy(opts.Overwrite)
+	if err != nil {
+		return "", err
+	}
+
 	sourcePhysicalPath, err := m.resolvePath(virtualSourcePath)
 	if err != nil {
-		return fmt.Errorf("invalid source path: %w", err)
+		return "", fmt.Errorf("invalid source path: %w", err)
 	}
 
 	destPhysicalPath, err := m.resolvePath(virtualDestPath)
 	if err != nil {
-		return fmt.Errorf("invalid destination path: %w", err)
+		return "", fmt.Errorf("invalid destination path: %w", err)
 	}
 
 	if !m.isPathSafe(sourcePhysicalPath) || !m.isPathSafe(destPhysicalPath) {
-		return fmt.Errorf("access denied: path outside managed directory")
+		return "", fmt.Errorf("access denied: path outside managed directory")
 	}
 
-	// Create destination directory if needed
+	sourceInfo, err := os.Lstat(sourcePhysicalPath)
+	isDir := err == nil && sourceInfo.IsDir()
+	virtualDestPath, destPhysicalPath, err = m.applyOverwritePolicy(policy, virtualDestPath, destPhysicalPath,
+		sourcePhysicalPath, isDir)
+	if err != nil {
+		return "", err
+	}
+
+	if err := m.checkCaseCollision(destPhysicalPath, virtualDestPath, sourcePhysicalPath); err != nil {
+		return "", err
+	}
+
+	if err := m.prepareDestination(virtualDestPath, destPhysicalPath); err != nil {
+		return "", err
+	}
+
+	defer m.trackUsage(sourcePhysicalPath, destPhysicalPath)()
+	if err := os.Rename(sourcePhysicalPath, destPhysicalPath); err != nil {
+		return "", err
+	}
+	return virtualDestPath, nil
+}
+
+// prepareDestination creates all missing parent directories of a move or
+// copy destination, like uploads do. With strict_destinations the parent
+// must already exist.
+func (m *Manager) prepareDestination(virtualDestPath, destPhysicalPath string) error {
 	destDir := filepath.Dir(destPhysicalPath)
+	if m.Config.Main.StrictDestinations {
+		info, err := os.Stat(destDir)
+		if err != nil || !info.IsDir() {
+			return fmt.Errorf("destination directory not found: %s", path.Dir(virtualDestPath))
+		}
+		return nil
+	}
+
 	if err := os.MkdirAll(destDir, 0750); err != nil {
 		return fmt.Errorf("failed to create destination directory: %w", err)
 	}
-
-	return os.Rename(sourcePhysicalPath, destPhysicalPath)
+	return nil
 }
 
-// CopyFile copies a file or directory from source to destination
+// CopyFile copies a file or directory from source to destination,
+// overwriting existing files and merging into an existing directory
 func (m *Manager) CopyFile(virtualSourcePath, virtualDestPath string) error {
+	_, err := m.CopyFileWithOptions(virtualSourcePath, virtualDestPath, TransferOptions{Overwrite: OverwriteReplace})
+	return err
+}
+
+// CopyFileWithOptions copies a file or directory like CopyFile, handling an
+// existing destination according to the overwrite policy, and returns the
+// virtual path of the copy
+func (m *Manager) CopyFileWithOptions(virtualSourcePath, virtualDestPath string, opts TransferOptions) (string, error) {
+	policy, err := ParseOverwritePolicy(opts.Overwrite)
+	if err != nil {
+		return "", err
+	}
+
 	sourcePhysicalPath, err := m.resolvePath(virtualSourcePath)
 	if err != nil {
-		return fmt.Errorf("invalid source path: %w", err)
+		return "", fmt.Errorf("invalid source path: %w", err)
 	}
 
 	destPhysicalPath, err := m.resolvePath(virtualDestPath)
 	if err != nil {
-		return fmt.Errorf("invalid destination path: %w", err)
+		return "", fmt.Errorf("invalid destination path: %w", err)
 	}
 
 	if !m.isPathSafe(sourcePhysicalPath) || !m.isPathSafe(destPhysicalPath) {
-		return fmt.Errorf("access denied: path outside managed directory")
+		return "", fmt.Errorf("access denied: path outside managed directory")
 	}
 
 	// Check if source exists
 	sourceInfo, err := os.Stat(sourcePhysicalPath)
 	if err != nil {
-		return fmt.Errorf("source file not found: %w", err)
+		return "", fmt.Errorf("source file not found: %w", err)
+	}
+
+	virtualDestPath, destPhysicalPath, err = m.applyOverwritePolicy(policy, virtualDestPath, destPhysicalPath, "",
+		sourceInfo.IsDir())
+	if err != nil {
+		return "", err
+	}
+
+	if err := m.checkCaseCollision(destPhysicalPath, virtualDestPath, ""); err != nil {
+		return "", err
+	}
+
+	copySize := sourceInfo.Size()
+	if sourceInfo.IsDir() {
+		copySize, _ = m.calculateDirectorySize(sourcePhysicalPath)
 	}
 
 	// Check quota for copy operation
-	if m.Config.QuotaBytes > 0 {
-		quotaInfo, err := m.GetQuotaInfo()
+	if m.quotaApplies(virtualDestPath) {
+		used, limit, err := m.quotaState(virtualDestPath)
 		if err != nil {
-			return fmt.Errorf("failed to calculate current usage: %w", err)
-		}
-
-		copySize := sourceInfo.Size()
-		if sourceInfo.IsDir() {
-			copySize, _ = m.calculateDirectorySize(sourcePhysicalPath)
+			return "", fmt.Errorf("failed to calculate current usage: %w", err)
 		}
 
-		if quotaInfo.Used+copySize > m.Config.QuotaBytes {
-			return fmt.Errorf("copy would exceed quota limit (current: %s, copy size: %s, limit: %s)",
-				format.FileSize(quotaInfo.Used),
+		if used+copySize > limit {
+			m.recordQuotaDenial("copy", virtualDestPath, used, copySize, limit)
+			return "", fmt.Errorf("copy would exceed quota limit (current: %s, copy size: %s, limit: %s)",
+				format.FileSize(used),
 				format.FileSize(copySize),
-				format.FileSize(m.Config.QuotaBytes))
+				format.FileSize(limit))
 		}
 	}
 
-	// Create destination directory
-	destDir := filepath.Dir(destPhysicalPath)
-	if err := os.MkdirAll(destDir, 0750); err != nil {
-		return fmt.Errorf("failed to create destination directory: %w", err)
+	if err := m.checkFreeSpace(destPhysicalPath, copySize); err != nil {
+		return "", err
 	}
 
-	if sourceInfo.IsDir() {
-		return m.copyDirectory(sourcePhysicalPath, destPhysicalPath)
+	if err := m.checkEntryLimit(destPhysicalPath, virtualDestPath); err != nil {
+		return "", err
 	}
 
-	return m.copyFile(sourcePhysicalPath, destPhysicalPath)
+	if err := m.prepareDestination(virtualDestPath, destPhysicalPath); err != nil {
+		return "", err
+	}
+
+	defer m.trackUsage(destPhysicalPath)()
+	if sourceInfo.IsDir() {
+		err = m.copyDirectory(sourcePhysicalPath, destPhysicalPath)
+	} else {
+		err = m.copyFile(sourcePhysicalPath, destPhysicalPath)
+	}
+	if err != nil {
+		return "", err
+	}
+	return virtualDestPath, nil
 }
 
 // StatFile returns detailed file stat information
 func (m *Manager) StatFile(virtualPath string) (*FileStatInfo, error) {
+	return m.StatFileWithOptions(virtualPath, StatOptions{})
+}
+
+// StatFileWithOptions returns detailed file information like StatFile and,
+// with MaxEmbed set, the content of files no larger than MaxEmbed and, with
+// Checksum set, the digest of regular files
+func (m *Manager) StatFileWithOptions(virtualPath string, opts StatOptions) (*FileStatInfo, error) {
 	physicalPath, err := m.resolvePath(virtualPath)
 	if err != nil {
 		return nil, err
@@ -480,18 +771,94 @@ func (m *Manager) StatFile(virtualPath string) (*FileStatInfo, error) {
 		IsDir:   info.IsDir(),
 		Mode:    info.Mode().String(),
 		ModTime: info.ModTime(),
+		ID:      FileID(info),
 	}
 
 	// Get system-specific stat info
 	getSysStatInfo(info, stat)
 
 	if !info.IsDir() {
-		stat.MimeType = m.getMimeType(info.Name())
+		stat.MimeType = m.detectMimeType(physicalPath, info, true)
+	} else if count, err := countDirEntries(physicalPath, -1); err == nil {
+		stat.Entries = &count
+		stat.MaxFiles = m.maxDirEntries(physicalPath)
+	}
+
+	if opts.MaxEmbed > 0 && info.Mode().IsRegular() && info.Size() <= opts.MaxEmbed {
+		content, err := readLimited(physicalPath, opts.MaxEmbed)
+		if err != nil {
+			return nil, err
+		}
+		// A file that grew beyond the limit since it was stat'ed is not embedded
+		if int64(len(content)) <= opts.MaxEmbed {
+			stat.Content = content
+		}
+	}
+
+	// Directories have no content digest and are reported without one
+	if opts.Checksum != "" && info.Mode().IsRegular() {
+		sum, err := fileChecksum(physicalPath, opts.Checksum)
+		if err != nil {
+			return nil, err
+		}
+		switch opts.Checksum {
+		case ChecksumSHA256:
+			stat.SHA256 = sum
+		case ChecksumMD5:
+			stat.MD5 = sum
+		}
 	}
 
 	return stat, nil
 }
 
+// readLimited reads at most limit+1 bytes of a file, enough to tell whether it
+// exceeds the limit
+func readLimited(physicalPath string, limit int64) ([]byte, error) {
+	file, err := os.Open(physicalPath) // #nosec G304 - path is validated by the caller
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	defer func() {
+		if cerr := file.Close(); cerr != nil {
+			log.Printf("Error closing file %s: %v", physicalPath, cerr)
+		}
+	}()
+
+	content, err := io.ReadAll(io.LimitReader(file, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	return content, nil
+}
+
+// Exists reports whether a file or directory exists at the virtual path.
+// Paths outside the configured mappings are reported as missing.
+func (m *Manager) Exists(virtualPath string) (exists, isDir bool, err error) {
+	if m.VirtualFS.IsVirtualRoot(virtualPath) {
+		return true, true, nil
+	}
+
+	physicalPath, err := m.resolvePath(virtualPath)
+	if err != nil {
+		return false, false, nil
+	}
+
+	if !m.isPathSafe(physicalPath) {
+		return false, false, fmt.Errorf("access denied: path outside managed directory")
+	}
+
+	info, err := os.Stat(physicalPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, false, nil
+	}
+	if err != nil {
+		return false, false, fmt.Errorf("failed to stat path: %w", err)
+	}
+
+	return true, info.IsDir(), nil
+}
+
 // copyFile copies a single file
 func (m *Manager) copyFile(src, dst string) (err error) {
 	sourceFile, err := os.Open(src) // #nosec G304
@@ -514,9 +881,16 @@ func (m *Manager) copyFile(src, dst string) (err error) {
 		}
 	}()
 
-	_, err = io.Copy(destFile, sourceFile)
-	if err != nil {
-		return err
+	// Try a copy-on-write clone first and fall back to a byte copy
+	cloned := false
+	if m.Config.Copy.Reflink {
+		cloned = cloneFile(destFile, sourceFile) == nil
+	}
+	if !cloned {
+		_, err = io.Copy(destFile, sourceFile)
+		if err != nil {
+			return err
+		}
 	}
 
 	// Copy file permissions
@@ -525,15 +899,47 @@ func (m *Manager) copyFile(src, dst string) (err error) {
 		return err
 	}
 
-	return os.Chmod(dst, sourceInfo.Mode())
+	if err := os.Chmod(dst, sourceInfo.Mode()); err != nil {
+		return err
+	}
+
+	return m.copyOwnership(sourceInfo, dst)
+}
+
+// copyOwnership applies the owner and group of the source to dst when
+// preserve_ownership is enabled. Missing privileges are not an error.
+func (m *Manager) copyOwnership(sourceInfo os.FileInfo, dst string) error {
+	if !m.Config.Copy.PreserveOwnership {
+		return nil
+	}
+
+	uid, gid, ok := fileOwner(sourceInfo)
+	if !ok {
+		return nil
+	}
+
+	if err := os.Lchown(dst, uid, gid); err != nil {
+		if errors.Is(err, fs.ErrPermission) {
+			return nil // Not privileged to change ownership, keep the default owner
+		}
+		return err
+	}
+
+	return nil
 }
 
 // copyDirectory recursively copies a directory
 func (m *Manager) copyDirectory(src, dst string) error {
-	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+	// Directories are created while walking, so that they exist before the
+	// files within them are copied by the workers
+	workers := newBoundedGroup(m.recursiveWorkers())
+	err := filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
+		if workers.Failed() {
+			return errWorkerFailed
+		}
 
 		// Calculate relative path
 		relPath, err := filepath.Rel(src, path)
@@ -544,15 +950,118 @@ func (m *Manager) copyDirectory(src, dst string) error {
 		destPath := filepath.Join(dst, relPath)
 
 		if d.IsDir() {
-			return os.MkdirAll(destPath, 0750)
+			if m.isTrashDir(path) {
+				return filepath.SkipDir
+			}
+			if err := os.MkdirAll(destPath, 0750); err != nil {
+				return err
+			}
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			return m.copyOwnership(info, destPath)
 		}
 
-		return m.copyFile(path, destPath)
+		workers.Go(func() error {
+			return m.copyFile(path, destPath)
+		})
+		return nil
 	})
+	if werr := workers.Wait(); werr != nil {
+		return werr
+	}
+	return err
+}
+
+// ValidatePaths checks that all virtual paths resolve to existing files or directories
+func (m *Manager) ValidatePaths(virtualPaths []string) error {
+	for _, virtualPath := range virtualPaths {
+		physicalPath, err := m.resolvePath(virtualPath)
+		if err != nil {
+			return err
+		}
+
+		if !m.isPathSafe(physicalPath) {
+			return fmt.Errorf("access denied: path outside managed directory")
+		}
+
+		if _, err := os.Stat(physicalPath); err != nil {
+			return fmt.Errorf("file not found: %s", virtualPath)
+		}
+	}
+
+	return nil
+}
+
+// zipEpoch is the fixed modification time used for deterministic archives
+var zipEpoch = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// ZipOptions controls how a ZIP archive is built
+type ZipOptions struct {
+	// Deterministic sorts all entries by path and uses a fixed modification
+	// time, so identical input produces byte-identical archives
+	Deterministic bool
+	// Exclude lists glob patterns of entries to leave out. Patterns without
+	// a slash match any single name (e.g. "node_modules"), other patterns
+	// match the whole path within the archive (e.g. "docs/*.tmp").
+	Exclude []string
+	// Layout selects how entries are named, see ZipLayoutFull,
+	// ZipLayoutRelative and ZipLayoutFlat. Empty means ZipLayoutFull.
+	Layout string
+	// ChangedFiles selects how files modified while they are read are
+	// handled, see ZipChangedInclude, ZipChangedSkip and ZipChangedFail.
+	// Empty means ZipChangedInclude.
+	ChangedFiles string
+}
+
+// ValidateExcludePatterns checks that all exclude patterns are valid globs
+func ValidateExcludePatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid exclude pattern: %s", pattern)
+		}
+	}
+	return nil
+}
+
+// excluded reports whether the archive path matches an exclude pattern
+func (o ZipOptions) excluded(zipPath string) bool {
+	zipPath = strings.Trim(filepath.ToSlash(zipPath), "/")
+	for _, pattern := range o.Exclude {
+		pattern = strings.Trim(pattern, "/")
+		if strings.Contains(pattern, "/") {
+			if ok, _ := path.Match(pattern, zipPath); ok {
+				return true
+			}
+			continue
+		}
+		for _, name := range strings.Split(zipPath, "/") {
+			if ok, _ := path.Match(pattern, name); ok {
+				return true
+			}
+		}
+	}
+	return false
 }
 
 // CreateZip creates a ZIP archive containing the specified virtual paths
-func (m *Manager) CreateZip(w io.Writer, virtualPaths []string) (err error) {
+func (m *Manager) CreateZip(w io.Writer, virtualPaths []string) error {
+	return m.CreateZipWithOptions(w, virtualPaths, ZipOptions{})
+}
+
+// CreateZipWithOptions creates a ZIP archive containing the specified virtual paths
+func (m *Manager) CreateZipWithOptions(w io.Writer, virtualPaths []string, opts ZipOptions) (err error) {
+	if err := ValidateExcludePatterns(opts.Exclude); err != nil {
+		return err
+	}
+	if err := ValidateZipLayout(opts.Layout); err != nil {
+		return err
+	}
+	if err := ValidateZipChangedFiles(opts.ChangedFiles); err != nil {
+		return err
+	}
+
 	zipWriter := zip.NewWriter(w)
 	defer func() {
 		if cerr := zipWriter.Close(); cerr != nil && err == nil {
@@ -560,25 +1069,61 @@ func (m *Manager) CreateZip(w io.Writer, virtualPaths []string) (err error) {
 		}
 	}()
 
+	if opts.Deterministic {
+		sorted := make([]string, len(virtualPaths))
+		copy(sorted, virtualPaths)
+		sort.Strings(sorted)
+		virtualPaths = sorted
+	}
+
+	rootNames := zipRootNames(virtualPaths, opts.Layout)
+	entries := newZipEntries(opts.Layout)
+
+	serial := serialZip{m: m, zw: zipWriter, opts: opts, entries: entries}
+	var sink zipSink = &serial
+	finish := func() error { return nil }
+	if workers := m.recursiveWorkers(); workers > 1 {
+		parallel := newParallelZip(serial, workers)
+		sink, finish = parallel, parallel.finish
+	}
+
+	err = m.addZipEntries(sink, virtualPaths, rootNames, opts, entries)
+	// Pending writes must complete before the archive is closed
+	if ferr := finish(); ferr != nil {
+		return ferr
+	}
+	if err != nil {
+		return err
+	}
+
+	return writeSkippedManifest(zipWriter, entries.skipped, opts)
+}
+
+// addZipEntries adds the selected paths to an archive in order
+func (m *Manager) addZipEntries(sink zipSink, virtualPaths []string, rootNames map[string]string,
+	opts ZipOptions, entries *zipEntries) error {
 	for _, virtualPath := range virtualPaths {
-		physicalPath, err := m.resolvePath(virtualPath)
-		if err != nil {
-			continue // Skip paths that can't be resolved
+		if opts.excluded(rootNames[virtualPath]) {
+			continue
 		}
 
-		if !m.isPathSafe(physicalPath) {
-			continue // Skip unsafe paths
+		// Unresolvable, unsafe and missing paths are listed in the manifest
+		physicalPath, info, reason := m.checkArchivePath(virtualPath, os.Stat)
+		if reason != "" {
+			entries.skipped = append(entries.skipped, SkippedPath{Path: virtualPath, Reason: reason})
+			continue
 		}
 
-		info, err := os.Stat(physicalPath)
-		if err != nil {
-			continue // Skip missing files
+		name, ok := entries.claim(rootNames[virtualPath])
+		if !ok {
+			continue // Already part of the archive
 		}
 
+		var err error
 		if info.IsDir() {
-			err = m.addDirToZip(zipWriter, physicalPath, virtualPath)
+			err = m.addDirToZip(sink, physicalPath, name, opts, entries)
 		} else {
-			err = m.addFileToZip(zipWriter, physicalPath, virtualPath)
+			err = sink.addFile(physicalPath, name)
 		}
 
 		if err != nil {
@@ -589,8 +1134,10 @@ func (m *Manager) CreateZip(w io.Writer, virtualPaths []string) (err error) {
 	return nil
 }
 
-// addFileToZip adds a single file to the zip archive
-func (m *Manager) addFileToZip(zw *zip.Writer, fullPath, relativePath string) error {
+// addFileToZip adds a single file to the zip archive. Files skipped because
+// they changed while reading are recorded in entries.
+func (m *Manager) addFileToZip(zw *zip.Writer, fullPath, relativePath string, opts ZipOptions,
+	entries *zipEntries) error {
 	file, err := os.Open(fullPath) // #nosec G304
 	if err != nil {
 		return err
@@ -613,18 +1160,48 @@ func (m *Manager) addFileToZip(zw *zip.Writer, fullPath, relativePath string) er
 
 	header.Name = relativePath
 	header.Method = zip.Deflate
+	if opts.Deterministic {
+		header.Modified = zipEpoch
+	}
+
+	// Skipping requires the whole content to be checked before the entry
+	// is started, as written entries cannot be taken back
+	var content io.Reader = file
+	if opts.ChangedFiles == ZipChangedSkip {
+		spooled, cleanup, err := spoolUnchanged(file, info)
+		if errors.Is(err, errFileChanged) {
+			logSkippedFile(relativePath)
+			entries.skipped = append(entries.skipped,
+				SkippedPath{Path: filepath.ToSlash(relativePath), Reason: SkipReasonChanged})
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+		content = spooled
+	}
 
 	writer, err := zw.CreateHeader(header)
 	if err != nil {
 		return err
 	}
 
-	_, err = io.Copy(writer, file)
+	if opts.ChangedFiles == ZipChangedFail {
+		if err := copyUnchanged(writer, file, info); err != nil {
+			return fmt.Errorf("%s: %w", relativePath, err)
+		}
+		return nil
+	}
+
+	_, err = io.Copy(writer, content)
 	return err
 }
 
-// addDirToZip recursively adds a directory to the zip archive
-func (m *Manager) addDirToZip(zw *zip.Writer, fullPath, relativePath string) error {
+// addDirToZip recursively adds a directory to the zip archive.
+// WalkDir visits entries in lexical order, so the entry order is stable.
+func (m *Manager) addDirToZip(sink zipSink, fullPath, relativePath string, opts ZipOptions,
+	entries *zipEntries) error {
 	return filepath.WalkDir(fullPath, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return nil // Skip files we can't access
@@ -638,18 +1215,36 @@ func (m *Manager) addDirToZip(zw *zip.Writer, fullPath, relativePath string) err
 
 		zipPath := filepath.Join(relativePath, relPath)
 
+		if opts.excluded(zipPath) || (d.IsDir() && m.isTrashDir(path)) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		// The directory itself was claimed by the caller; entries below it
+		// can only collide with an overlapping selection
+		if path != fullPath && !entries.claimExact(zipPath) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
 		if d.IsDir() {
 			// Create directory entry in zip
 			header := &zip.FileHeader{
 				Name:   zipPath + "/",
 				Method: zip.Store,
 			}
-			_, err = zw.CreateHeader(header)
-			return err
+			if opts.Deterministic {
+				header.Modified = zipEpoch
+			}
+			return sink.addDir(header)
 		}
 
 		// Add file to zip
-		return m.addFileToZip(zw, path, zipPath)
+		return sink.addFile(path, zipPath)
 	})
 }
 
@@ -668,54 +1263,50 @@ func (m *Manager) ReadFile(virtualPath string) ([]byte, error) {
 }
 
 // WriteFile writes content to a file
-func (m *Manager) WriteFile(virtualPath string, content []byte) error {
+func (m *Manager) WriteFile(virtualPath string, content []byte) (*WriteResult, error) {
 	physicalPath, err := m.resolvePath(virtualPath)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if !m.isPathSafe(physicalPath) {
-		return fmt.Errorf("access denied: path outside managed directory")
+		return nil, fmt.Errorf("access denied: path outside managed directory")
 	}
 
-	// Check quota before writing
-	if m.Config.QuotaBytes > 0 {
-		// Get current file size if it exists
-		var oldSize int64
-		if info, err := os.Stat(physicalPath); err == nil {
-			oldSize = info.Size()
-		}
+	// Get current file size if it exists
+	var oldSize int64
+	info, statErr := os.Stat(physicalPath)
+	created := os.IsNotExist(statErr)
+	if statErr == nil {
+		oldSize = info.Size()
+	}
 
-		// Calculate new size after write
+	// Check quota before writing
+	if m.quotaApplies(virtualPath) {
 		newSize := int64(len(content))
-
-		// Get directory to check quota for
-		var quotaPath string
-		for _, dir := range m.Directories {
-			if strings.HasPrefix(physicalPath, dir.Source) {
-				quotaPath = dir.Source
-				break
-			}
-		}
-
-		if quotaPath == "" {
-			return fmt.Errorf("file not in managed directory")
-		}
-
-		// Get current directory usage
-		currentUsage, err := m.calculateDirectorySize(quotaPath)
+		used, limit, err := m.quotaState(virtualPath)
 		if err != nil {
-			return fmt.Errorf("failed to calculate directory size: %w", err)
+			return nil, fmt.Errorf("failed to calculate current usage: %w", err)
 		}
 
-		// Check if new size would exceed quota
-		if currentUsage-oldSize+newSize > m.Config.QuotaBytes {
-			return fmt.Errorf("quota exceeded: operation would exceed storage limit")
+		// The old content is replaced, so only the difference counts
+		if used-oldSize+newSize > limit {
+			m.recordQuotaDenial("write", virtualPath, used, newSize, limit)
+			return nil, fmt.Errorf("quota exceeded: operation would exceed storage limit")
 		}
 	}
 
+	if err := m.checkFreeSpace(physicalPath, int64(len(content))-oldSize); err != nil {
+		return nil, err
+	}
+
 	// Write the file
-	return os.WriteFile(physicalPath, content, 0600) //nolint:gosec // Path is validated by isPathSafe
+	defer m.trackUsage(physicalPath)()
+	if err := os.WriteFile(physicalPath, content, 0600); err != nil { //nolint:gosec // Path is validated by isPathSafe
+		return nil, err
+	}
+
+	return &WriteResult{Created: created, Size: int64(len(content))}, nil
 }
 
 // GetFileInfo returns information about a file
@@ -757,9 +1348,18 @@ func (m *Manager) CreateFolder(virtualPath string) error {
 	}
 
 	// Check if directory already exists
-	if _, err := os.Stat(physicalPath); err == nil {
+	if info, err := os.Stat(physicalPath); err == nil {
+		if !info.IsDir() {
+			return fmt.Errorf("a file already exists with that name: %s", virtualPath)
+		}
 		return fmt.Errorf("directory already exists")
 	}
+	if err := m.checkParentDirs(filepath.Dir(physicalPath), path.Dir(virtualPath)); err != nil {
+		return err
+	}
+	if err := m.checkEntryLimit(physicalPath, virtualPath); err != nil {
+		return err
+	}
 
 	// Create the directory with 755 permissions
 	if err := os.MkdirAll(physicalPath, 0750); err != nil {
@@ -769,39 +1369,35 @@ func (m *Manager) CreateFolder(virtualPath string) error {
 	return nil
 }
 
-// getMimeType returns a basic MIME type based on file extension
-func (m *Manager) getMimeType(filename string) string {
-	ext := strings.ToLower(filepath.Ext(filename))
-	switch ext {
-	case ".txt", ".log":
-		return "text/plain"
-	case ".html", ".htm":
-		return "text/html"
-	case ".css":
-		return "text/css"
-	case ".js":
-		return "application/javascript"
-	case ".json":
-		return "application/json"
-	case ".pdf":
-		return "application/pdf"
-	case ".jpg", ".jpeg":
-		return "image/jpeg"
-	case ".png":
-		return "image/png"
-	case ".gif":
-		return "image/gif"
-	case ".zip":
-		return "application/zip"
-	case ".tar", ".gz":
-		return "application/gzip"
-	case ".go":
-		return "text/plain"
-	case ".md":
-		return "text/markdown"
-	case ".yaml", ".yml":
-		return "application/yaml"
-	default:
-		return "application/octet-stream"
+// EnsureFolder creates a folder unless a directory already exists at the
+// virtual path. It reports whether the folder was created and fails when the
+// path is taken by a file.
+func (m *Manager) EnsureFolder(virtualPath string) (created bool, err error) {
+	physicalPath, err := m.resolvePath(virtualPath)
+	if err != nil {
+		return false, err
+	}
+
+	if !m.isPathSafe(physicalPath) {
+		return false, fmt.Errorf("access denied: path outside managed directory")
+	}
+
+	if info, err := os.Stat(physicalPath); err == nil {
+		if !info.IsDir() {
+			return false, fmt.Errorf("a file already exists with that name: %s", virtualPath)
+		}
+		return false, nil
+	}
+	if err := m.checkParentDirs(filepath.Dir(physicalPath), path.Dir(virtualPath)); err != nil {
+		return false, err
 	}
+	if err := m.checkEntryLimit(physicalPath, virtualPath); err != nil {
+		return false, err
+	}
+
+	if err := os.MkdirAll(physicalPath, 0750); err != nil {
+		return false, fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	return true, nil
 }
@@ -1,14 +1,19 @@
 package filesystem
 
 import (
+	"archive/zip"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"dendrite/internal/config"
+	"dendrite/internal/format"
 	"bytes"
 )
 
@@ -72,6 +77,12 @@ func TestGetMimeType(t *testing.T) {
 		{"test.jpg", "image/jpeg"},
 		{"test.png", "image/png"},
 		{"test.zip", "application/zip"},
+		{"test.md", "text/markdown"},
+		{"logo.svg", "image/svg+xml"},
+		{"LOGO.SVG", "image/svg+xml"},
+		{"photo.webp", "image/webp"},
+		{"clip.mp4", "video/mp4"},
+		{"data.csv", "text/csv"},
 		{"test.unknown", "application/octet-stream"},
 		{"test", "application/octet-stream"},
 	}
@@ -84,6 +95,62 @@ func TestGetMimeType(t *testing.T) {
 	}
 }
 
+func TestGetMimeTypeOverrides(t *testing.T) {
+	cfg := &config.Config{Mime: map[string]string{
+		".md":   "text/plain",
+		".heic": "image/heic",
+	}}
+	manager := New(cfg)
+
+	assert.Equal(t, "text/plain", manager.getMimeType("README.md"))
+	assert.Equal(t, "image/heic", manager.getMimeType("photo.HEIC"))
+	assert.Equal(t, "image/svg+xml", manager.getMimeType("logo.svg"))
+}
+
+func TestSniffMimeType(t *testing.T) {
+	tempDir := t.TempDir()
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\x00\x00\x00\x01\x00\x00\x00\x01\x08\x06\x00\x00\x00")
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "picture"), png, 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "notes"), []byte("plain text notes\n"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "image.txt"), png, 0600))
+
+	cfg := &config.Config{Directories: []config.DirMapping{{Source: tempDir, Virtual: "/test"}}}
+	mgr := New(cfg)
+
+	t.Run("stat sniffs unknown extensions", func(t *testing.T) {
+		for name, want := range map[string]string{
+			"picture":   "image/png",
+			"notes":     "text/plain",
+			"image.txt": "text/plain",
+		} {
+			stat, err := mgr.StatFile("/test/" + name)
+			require.NoError(t, err)
+			assert.Equal(t, want, stat.MimeType, name)
+		}
+	})
+
+	mimeTypes := func(mgr *Manager) map[string]string {
+		files, err := mgr.ListFiles("/test")
+		require.NoError(t, err)
+		types := make(map[string]string, len(files))
+		for _, file := range files {
+			types[file.Name] = file.MimeType
+		}
+		return types
+	}
+
+	t.Run("listings only sniff when enabled", func(t *testing.T) {
+		assert.Equal(t, "application/octet-stream", mimeTypes(mgr)["picture"])
+
+		cfg := &config.Config{Directories: []config.DirMapping{{Source: tempDir, Virtual: "/test"}}}
+		cfg.Listing.SniffMime = true
+		types := mimeTypes(New(cfg))
+		assert.Equal(t, "image/png", types["picture"])
+		assert.Equal(t, "text/plain", types["notes"])
+		assert.Equal(t, "text/plain", types["image.txt"])
+	})
+}
+
 func TestManager_GetQuotaInfo(t *testing.T) {
 	tempDir, err := os.MkdirTemp("", "dendrite-test")
 	require.NoError(t, err)
@@ -149,6 +216,49 @@ func TestManager_GetQuotaInfo(t *testing.T) {
 	}
 }
 
+func TestManager_GetQuotaInfo_HumanFields(t *testing.T) {
+	tempDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "data.bin"), make([]byte, 3000), 0600))
+
+	tests := []struct {
+		name       string
+		quotaBytes int64
+	}{
+		{name: "bytes", quotaBytes: 4000},
+		{name: "kilobytes", quotaBytes: 512 * 1024},
+		{name: "megabytes", quotaBytes: 100 * 1024 * 1024},
+		{name: "gigabytes", quotaBytes: 3 * 1024 * 1024 * 1024},
+		{name: "exceeded", quotaBytes: 1000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{
+				Directories: []config.DirMapping{{Source: tempDir, Virtual: "/test"}},
+				QuotaBytes:  tt.quotaBytes,
+			}
+			info, err := New(cfg).GetQuotaInfo()
+			require.NoError(t, err)
+
+			assert.Equal(t, format.FileSize(info.Used), info.UsedHuman)
+			assert.Equal(t, format.FileSize(info.Limit), info.LimitHuman)
+			assert.Equal(t, format.FileSize(max(info.Available, 0)), info.AvailableHuman)
+		})
+	}
+
+	t.Run("unlimited", func(t *testing.T) {
+		cfg := &config.Config{
+			Directories: []config.DirMapping{{Source: tempDir, Virtual: "/test"}},
+		}
+		info, err := New(cfg).GetQuotaInfo()
+		require.NoError(t, err)
+
+		assert.Equal(t, format.FileSize(info.Used), info.UsedHuman)
+		assert.Equal(t, "unlimited", info.LimitHuman)
+		assert.Equal(t, "unlimited", info.AvailableHuman)
+	})
+}
+
 func TestManager_UploadFile_QuotaErrorMessage(t *testing.T) {
 	tempDir, err := os.MkdirTemp("", "dendrite-test-quota")
 	require.NoError(t, err)
@@ -263,6 +373,54 @@ func TestManager_CopyFile_QuotaErrorMessage(t *testing.T) {
 	assert.Equal(t, expectedError, err.Error())
 }
 
+func TestManager_MoveCopy_CreatesDestinationParents(t *testing.T) {
+	tempDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(tempDir, "src", "dir"), 0750))
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "src", "file.txt"), []byte("file"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "src", "dir", "nested.txt"), []byte("nested"), 0600))
+
+	cfg := &config.Config{
+		Directories: []config.DirMapping{
+			{Source: tempDir, Virtual: "/test"},
+		},
+	}
+	manager := New(cfg)
+
+	t.Run("copy file into missing subpath", func(t *testing.T) {
+		require.NoError(t, manager.CopyFile("/test/src/file.txt", "/test/a/b/c/file.txt"))
+		assert.FileExists(t, filepath.Join(tempDir, "a", "b", "c", "file.txt"))
+	})
+
+	t.Run("copy directory into missing subpath", func(t *testing.T) {
+		require.NoError(t, manager.CopyFile("/test/src/dir", "/test/x/y/dir"))
+		assert.FileExists(t, filepath.Join(tempDir, "x", "y", "dir", "nested.txt"))
+	})
+
+	t.Run("move into missing subpath", func(t *testing.T) {
+		require.NoError(t, manager.MoveFile("/test/src/file.txt", "/test/m/n/o/moved.txt"))
+		assert.FileExists(t, filepath.Join(tempDir, "m", "n", "o", "moved.txt"))
+		assert.NoFileExists(t, filepath.Join(tempDir, "src", "file.txt"))
+	})
+
+	t.Run("strict destinations", func(t *testing.T) {
+		strict := New(&config.Config{
+			Main:        config.MainConfig{StrictDestinations: true},
+			Directories: cfg.Directories,
+		})
+
+		err := strict.MoveFile("/test/m/n/o/moved.txt", "/test/p/q/moved.txt")
+		require.Error(t, err)
+		assert.Equal(t, "destination directory not found: /test/p/q", err.Error())
+		err = strict.CopyFile("/test/src/dir", "/test/p/q/dir")
+		require.Error(t, err)
+		assert.NoDirExists(t, filepath.Join(tempDir, "p"))
+
+		// Existing parents are fine
+		require.NoError(t, strict.CopyFile("/test/src/dir", "/test/x/dir"))
+		assert.FileExists(t, filepath.Join(tempDir, "x", "dir", "nested.txt"))
+	})
+}
+
 func TestManager_UploadFile_WithinQuota(t *testing.T) {
 	tempDir, err := os.MkdirTemp("", "dendrite-test-within-quota")
 	require.NoError(t, err)
@@ -298,6 +456,58 @@ func TestManager_UploadFile_WithinQuota(t *testing.T) {
 	assert.Equal(t, uploadSize, info.Size())
 }
 
+func TestManager_UploadFile_CreatedDirs(t *testing.T) {
+	tempDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(tempDir, "existing"), 0750))
+
+	manager := New(&config.Config{
+		Directories: []config.DirMapping{
+			{Source: tempDir, Virtual: "/test"},
+		},
+	})
+
+	result, err := manager.UploadFile("/test/existing/a/b", "file.txt", strings.NewReader("data"), 4)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/test/existing/a", "/test/existing/a/b"}, result.CreatedDirs)
+
+	// All directories exist now
+	result, err = manager.UploadFile("/test/existing/a/b", "other.txt", strings.NewReader("data"), 4)
+	require.NoError(t, err)
+	assert.Empty(t, result.CreatedDirs)
+
+	result, err = manager.StreamFile("/test/new/file.txt", strings.NewReader("data"), 4)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/test/new"}, result.CreatedDirs)
+}
+
+func TestManager_UploadFile_FailedOverwrite(t *testing.T) {
+	tempDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "file.bin"), []byte("old content"), 0600))
+
+	manager := New(&config.Config{
+		Directories: []config.DirMapping{
+			{Source: tempDir, Virtual: "/test"},
+		},
+		QuotaBytes: 100,
+	})
+
+	// The replaced file is credited, so an overwrite may use the full quota
+	_, err := manager.UploadFile("/test", "file.bin", bytes.NewReader(make([]byte, 95)), 95)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "file.bin"), []byte("old content"), 0600))
+
+	// A stream of unknown length runs out of quota while being written
+	_, err = manager.UploadFile("/test", "file.bin", bytes.NewReader(make([]byte, 200)), -1)
+	require.ErrorIs(t, err, errQuotaExceeded)
+
+	content, err := os.ReadFile(filepath.Join(tempDir, "file.bin"))
+	require.NoError(t, err)
+	assert.Equal(t, "old content", string(content))
+	matches, err := filepath.Glob(filepath.Join(tempDir, ".dendrite-upload-*"))
+	require.NoError(t, err)
+	assert.Empty(t, matches)
+}
+
 func TestVirtualPathOperations(t *testing.T) {
 	// Create test directories
 	tempDir1 := t.TempDir()
@@ -572,4 +782,209 @@ func TestStatFile(t *testing.T) {
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "virtual path not found")
 	})
-}
\ No newline at end of file
+}
+
+func TestCreateZipDeterministic(t *testing.T) {
+	tempDir := t.TempDir()
+
+	cfg := &config.Config{
+		Directories: []config.DirMapping{
+			{Source: tempDir, Virtual: "/test"},
+		},
+	}
+	mgr := New(cfg)
+
+	for name, content := range map[string]string{
+		"a.txt":         "content a",
+		"dir/b.txt":     "content b",
+		"dir/sub/c.txt": "content c",
+	} {
+		fullPath := filepath.Join(tempDir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(fullPath), 0750))
+		require.NoError(t, os.WriteFile(fullPath, []byte(content), 0600))
+	}
+
+	opts := ZipOptions{Deterministic: true}
+
+	var first bytes.Buffer
+	require.NoError(t, mgr.CreateZipWithOptions(&first, []string{"/test/dir", "/test/a.txt"}, opts))
+
+	// Touch all files and pass the paths in a different order
+	later := time.Now().Add(time.Hour)
+	require.NoError(t, filepath.WalkDir(tempDir, func(path string, _ fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		return os.Chtimes(path, later, later)
+	}))
+
+	var second bytes.Buffer
+	require.NoError(t, mgr.CreateZipWithOptions(&second, []string{"/test/a.txt", "/test/dir"}, opts))
+
+	assert.Equal(t, first.Bytes(), second.Bytes())
+
+	// Entries are sorted by path
+	reader, err := zip.NewReader(bytes.NewReader(first.Bytes()), int64(first.Len()))
+	require.NoError(t, err)
+	names := make([]string, 0, len(reader.File))
+	for _, f := range reader.File {
+		names = append(names, f.Name)
+	}
+	assert.Equal(t, []string{
+		"/test/a.txt", "/test/dir/", "/test/dir/b.txt", "/test/dir/sub/", "/test/dir/sub/c.txt",
+	}, names)
+
+	// Default archives keep the real modification times
+	var regular bytes.Buffer
+	require.NoError(t, mgr.CreateZip(&regular, []string{"/test/a.txt"}))
+	assert.NotEqual(t, first.Bytes(), regular.Bytes())
+}
+
+func TestCreateZipExclude(t *testing.T) {
+	tempDir := t.TempDir()
+	mgr := New(&config.Config{
+		Directories: []config.DirMapping{
+			{Source: tempDir, Virtual: "/test"},
+		},
+	})
+
+	for _, name := range []string{
+		"project/main.go",
+		"project/.git/HEAD",
+		"project/node_modules/lib/index.js",
+		"project/web/node_modules/x.js",
+		"project/docs/readme.md",
+		"project/docs/draft.tmp",
+		"project/build.tmp",
+	} {
+		fullPath := filepath.Join(tempDir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(fullPath), 0750))
+		require.NoError(t, os.WriteFile(fullPath, []byte(name), 0600))
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, mgr.CreateZipWithOptions(&buf, []string{"/test/project"}, ZipOptions{
+		Exclude: []string{"node_modules", ".git", "test/project/docs/*.tmp"},
+	}))
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	var files []string
+	for _, f := range zr.File {
+		if !strings.HasSuffix(f.Name, "/") {
+			files = append(files, f.Name)
+		}
+	}
+
+	assert.ElementsMatch(t, []string{
+		"/test/project/main.go",
+		"/test/project/docs/readme.md",
+		"/test/project/build.tmp",
+	}, files)
+
+	err = mgr.CreateZipWithOptions(&bytes.Buffer{}, []string{"/test/project"}, ZipOptions{Exclude: []string{"[a-"}})
+	assert.EqualError(t, err, "invalid exclude pattern: [a-")
+}
+
+func TestCreateZipLayouts(t *testing.T) {
+	tempDir := t.TempDir()
+
+	cfg := &config.Config{
+		Directories: []config.DirMapping{
+			{Source: tempDir, Virtual: "/test"},
+		},
+	}
+	mgr := New(cfg)
+
+	for name, content := range map[string]string{
+		"2023/q1/report.txt": "first",
+		"2023/q2/report.txt": "second",
+		"2023/q2/notes.txt":  "notes",
+	} {
+		fullPath := filepath.Join(tempDir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(fullPath), 0750))
+		require.NoError(t, os.WriteFile(fullPath, []byte(content), 0600))
+	}
+
+	readZip := func(t *testing.T, paths []string, layout string) map[string]string {
+		var buf bytes.Buffer
+		require.NoError(t, mgr.CreateZipWithOptions(&buf, paths, ZipOptions{Layout: layout}))
+		reader, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		require.NoError(t, err)
+
+		entries := make(map[string]string)
+		for _, f := range reader.File {
+			assert.NotContains(t, entries, f.Name, "duplicate entry %s", f.Name)
+			rc, err := f.Open()
+			require.NoError(t, err)
+			var content bytes.Buffer
+			_, err = content.ReadFrom(rc)
+			require.NoError(t, err)
+			require.NoError(t, rc.Close())
+			entries[f.Name] = content.String()
+		}
+		return entries
+	}
+
+	paths := []string{"/test/2023/q1/report.txt", "/test/2023/q2/report.txt"}
+
+	t.Run("full", func(t *testing.T) {
+		assert.Equal(t, map[string]string{
+			"/test/2023/q1/report.txt": "first",
+			"/test/2023/q2/report.txt": "second",
+		}, readZip(t, paths, ""))
+	})
+
+	t.Run("relative", func(t *testing.T) {
+		assert.Equal(t, map[string]string{
+			"q1/report.txt": "first",
+			"q2/report.txt": "second",
+		}, readZip(t, paths, ZipLayoutRelative))
+	})
+
+	t.Run("flat renames collisions", func(t *testing.T) {
+		assert.Equal(t, map[string]string{
+			"report.txt":     "first",
+			"report (2).txt": "second",
+		}, readZip(t, paths, ZipLayoutFlat))
+	})
+
+	t.Run("overlapping selection is added once", func(t *testing.T) {
+		entries := readZip(t, []string{"/test/2023/q2", "/test/2023/q2/notes.txt"}, ZipLayoutRelative)
+		assert.Equal(t, map[string]string{
+			"q2/":           "",
+			"q2/notes.txt":  "notes",
+			"q2/report.txt": "second",
+		}, entries)
+	})
+
+	t.Run("invalid layout", func(t *testing.T) {
+		var buf bytes.Buffer
+		err := mgr.CreateZipWithOptions(&buf, paths, ZipOptions{Layout: "tree"})
+		assert.ErrorContains(t, err, "invalid zip layout")
+	})
+}
+
+func TestWriteFileQuotaSiblingPrefix(t *testing.T) {
+	tempDir := t.TempDir()
+	foo := filepath.Join(tempDir, "foo")
+	foobar := filepath.Join(tempDir, "foobar")
+	require.NoError(t, os.Mkdir(foo, 0750))
+	require.NoError(t, os.Mkdir(foobar, 0750))
+	require.NoError(t, os.WriteFile(filepath.Join(foo, "full.bin"), make([]byte, 900), 0600))
+
+	// /foo comes first, so a plain prefix test charges writes to /foobar against its quota
+	mgr := New(&config.Config{
+		Directories: []config.DirMapping{
+			{Source: foo, Virtual: "/foo", QuotaBytes: 1000},
+			{Source: foobar, Virtual: "/foobar"},
+		},
+	})
+
+	_, err := mgr.WriteFile("/foobar/file.txt", make([]byte, 500))
+	require.NoError(t, err)
+	assert.FileExists(t, filepath.Join(foobar, "file.txt"))
+
+	_, err = mgr.WriteFile("/foo/file.txt", make([]byte, 500))
+	assert.ErrorContains(t, err, "quota exceeded")
+}
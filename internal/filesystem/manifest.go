@@ -0,0 +1,73 @@
+package filesystem
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"time"
+)
+
+// Manifest modes
+const (
+	// ManifestFast reports size and modification time of every file
+	ManifestFast = "fast"
+	// ManifestFull additionally reports the SHA-256 hash of every file's
+	// content, which reads the whole tree
+	ManifestFull = "full"
+)
+
+// ManifestEntry describes one file of a manifest
+type ManifestEntry struct {
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+	// Hash is the hex-encoded SHA-256 of the content in full mode
+	Hash string `json:"hash,omitempty"`
+}
+
+// ValidateManifestMode checks a manifest mode; empty selects ManifestFast
+func ValidateManifestMode(mode string) error {
+	if mode != "" && mode != ManifestFast && mode != ManifestFull {
+		return fmt.Errorf("invalid manifest mode: %s (expected fast or full)", mode)
+	}
+	return nil
+}
+
+// WalkManifest calls fn for every regular file below virtualPath, descending
+// at most maxDepth levels like WalkFiles. Directories, symbolic links and
+// special files are left out. Files that disappear during the walk are
+// skipped.
+func (m *Manager) WalkManifest(virtualPath, mode string, maxDepth int, fn func(ManifestEntry) error) error {
+	if err := ValidateManifestMode(mode); err != nil {
+		return err
+	}
+
+	return m.WalkFiles(virtualPath, maxDepth, func(f FileInfo) error {
+		if f.IsDir {
+			return nil
+		}
+		physicalPath, err := m.GetFilePath(f.Path)
+		if err != nil {
+			return err
+		}
+		info, err := os.Lstat(physicalPath)
+		if err != nil || !info.Mode().IsRegular() {
+			return nil
+		}
+
+		entry := ManifestEntry{Path: f.Path, Size: info.Size(), ModTime: info.ModTime()}
+		if mode == ManifestFull {
+			sum, err := hashFile(physicalPath, TreeHashContent)
+			if err != nil {
+				if errors.Is(err, fs.ErrNotExist) {
+					return nil
+				}
+				return err
+			}
+			entry.Hash = hex.EncodeToString(sum)
+		}
+		return fn(entry)
+	})
+}
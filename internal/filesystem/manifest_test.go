@@ -0,0 +1,72 @@
+package filesystem
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"dendrite/internal/config"
+)
+
+func TestWalkManifest(t *testing.T) {
+	files := map[string]string{
+		"a.txt":         "alpha",
+		"dir/b.txt":     "beta",
+		"dir/sub/c.txt": "gamma",
+	}
+	tempDir := t.TempDir()
+	for name, content := range files {
+		fullPath := filepath.Join(tempDir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(fullPath), 0750))
+		require.NoError(t, os.WriteFile(fullPath, []byte(content), 0600))
+	}
+	require.NoError(t, os.Mkdir(filepath.Join(tempDir, "empty"), 0750))
+	require.NoError(t, os.Symlink(filepath.Join(tempDir, "a.txt"), filepath.Join(tempDir, "link.txt")))
+
+	mgr := New(&config.Config{Directories: []config.DirMapping{{Source: tempDir, Virtual: "/data"}}})
+	collect := func(mode string, depth int) map[string]ManifestEntry {
+		entries := make(map[string]ManifestEntry)
+		err := mgr.WalkManifest("/data", mode, depth, func(e ManifestEntry) error {
+			entries[e.Path] = e
+			return nil
+		})
+		require.NoError(t, err)
+		return entries
+	}
+
+	t.Run("fast mode lists every regular file without hashes", func(t *testing.T) {
+		entries := collect(ManifestFast, 0)
+		require.Len(t, entries, len(files))
+		for name, content := range files {
+			entry := entries["/data/"+name]
+			assert.Equal(t, int64(len(content)), entry.Size, name)
+			assert.False(t, entry.ModTime.IsZero(), name)
+			assert.Empty(t, entry.Hash, name)
+		}
+	})
+
+	t.Run("full mode hashes the content", func(t *testing.T) {
+		entries := collect(ManifestFull, 0)
+		require.Len(t, entries, len(files))
+		for name, content := range files {
+			sum := sha256.Sum256([]byte(content))
+			assert.Equal(t, hex.EncodeToString(sum[:]), entries["/data/"+name].Hash, name)
+		}
+	})
+
+	t.Run("depth limits the walk", func(t *testing.T) {
+		entries := collect(ManifestFast, 2)
+		assert.Len(t, entries, 2)
+		assert.NotContains(t, entries, "/data/dir/sub/c.txt")
+	})
+
+	t.Run("invalid mode", func(t *testing.T) {
+		err := mgr.WalkManifest("/data", "slow", 0, func(ManifestEntry) error { return nil })
+		assert.ErrorContains(t, err, "invalid manifest mode")
+	})
+}
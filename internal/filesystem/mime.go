@@ -0,0 +1,105 @@
+package filesystem
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// defaultMimeTypes are reported regardless of the system's MIME table, which
+// is missing on minimal hosts and maps some extensions differently, e.g.
+// .go to text/x-go
+var defaultMimeTypes = map[string]string{
+	".txt":  "text/plain",
+	".log":  "text/plain",
+	".go":   "text/plain",
+	".md":   "text/markdown",
+	".html": "text/html",
+	".htm":  "text/html",
+	".css":  "text/css",
+	".js":   "application/javascript",
+	".json": "application/json",
+	".yaml": "application/yaml",
+	".yml":  "application/yaml",
+	".csv":  "text/csv",
+	".pdf":  "application/pdf",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".png":  "image/png",
+	".gif":  "image/gif",
+	".svg":  "image/svg+xml",
+	".webp": "image/webp",
+	".mp4":  "video/mp4",
+	".zip":  "application/zip",
+	".tar":  "application/x-tar",
+	".gz":   "application/gzip",
+	".docx": "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+}
+
+// sniffLength is the number of bytes http.DetectContentType considers
+const sniffLength = 512
+
+// mimeTypeByExtension looks up the MIME type of a file name in the configured
+// [mime] table, the defaults above and the system's table, in that order.
+// Parameters such as the charset are left out.
+func (m *Manager) mimeTypeByExtension(filename string) (string, bool) {
+	ext := strings.ToLower(filepath.Ext(filename))
+	if ext == "" {
+		return "", false
+	}
+	if mimeType, ok := m.Config.Mime[ext]; ok {
+		return mimeType, true
+	}
+	if mimeType, ok := defaultMimeTypes[ext]; ok {
+		return mimeType, true
+	}
+	if mimeType, _, _ := strings.Cut(mime.TypeByExtension(ext), ";"); mimeType != "" {
+		return strings.TrimSpace(mimeType), true
+	}
+	return "", false
+}
+
+// getMimeType returns the MIME type of a file name, application/octet-stream
+// for unknown extensions
+func (m *Manager) getMimeType(filename string) string {
+	if mimeType, ok := m.mimeTypeByExtension(filename); ok {
+		return mimeType
+	}
+	return "application/octet-stream"
+}
+
+// detectMimeType returns the MIME type of a file like getMimeType. When the
+// extension is unknown and sniff is set, the type of a regular file is
+// detected from its first bytes instead.
+func (m *Manager) detectMimeType(physicalPath string, info os.FileInfo, sniff bool) string {
+	if mimeType, ok := m.mimeTypeByExtension(info.Name()); ok {
+		return mimeType
+	}
+	if !sniff || !info.Mode().IsRegular() {
+		return "application/octet-stream"
+	}
+	return sniffMimeType(physicalPath)
+}
+
+// sniffMimeType detects the MIME type of a file from its content
+func sniffMimeType(physicalPath string) string {
+	f, err := os.Open(physicalPath) //nolint:gosec // Callers validate the path with isPathSafe
+	if err != nil {
+		return "application/octet-stream"
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	buf := make([]byte, sniffLength)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return "application/octet-stream"
+	}
+	mimeType, _, _ := strings.Cut(http.DetectContentType(buf[:n]), ";")
+	return strings.TrimSpace(mimeType)
+}
@@ -0,0 +1,178 @@
+package filesystem
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// BatchMoveResult reports the outcome of moving or copying one source of a
+// batch
+type BatchMoveResult struct {
+	Source string `json:"source"`
+	Dest   string `json:"dest"`
+	Error  string `json:"error,omitempty"`
+}
+
+// batchMove is a validated move of a batch
+type batchMove struct {
+	virtualSource, virtualDest   string
+	physicalSource, physicalDest string
+}
+
+// MoveBatch moves every source into the directory destDir, keeping the names.
+// In atomic mode all sources and the destination are validated before the
+// first move, and completed moves are rolled back when a later one fails, so
+// either all sources are moved or none. Otherwise every source is moved on its
+// own and failures are only reported in the results.
+func (m *Manager) MoveBatch(sources []string, destDir string, atomic bool) ([]BatchMoveResult, error) {
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("no sources given")
+	}
+
+	results := make([]BatchMoveResult, len(sources))
+	for i, source := range sources {
+		results[i] = BatchMoveResult{Source: source, Dest: path.Join("/", destDir, path.Base(source))}
+	}
+
+	if !atomic {
+		for i := range results {
+			if err := m.MoveFile(results[i].Source, results[i].Dest); err != nil {
+				results[i].Error = err.Error()
+			}
+		}
+		return results, nil
+	}
+
+	moves, err := m.validateBatchMove(results, destDir)
+	if err != nil {
+		return results, err
+	}
+
+	destPhysical, err := m.resolvePath(destDir)
+	if err != nil {
+		return results, fmt.Errorf("invalid destination path: %w", err)
+	}
+	createdDirs := m.missingDirs(destPhysical, path.Join("/", destDir))
+
+	tracked := make([]string, 0, 2*len(moves))
+	for _, move := range moves {
+		tracked = append(tracked, move.physicalSource, move.physicalDest)
+	}
+	defer m.trackUsage(tracked...)()
+
+	for i, move := range moves {
+		err := m.prepareDestination(move.virtualDest, move.physicalDest)
+		if err == nil {
+			err = os.Rename(move.physicalSource, move.physicalDest)
+		}
+		if err != nil {
+			results[i].Error = err.Error()
+			if rollbackErr := m.rollbackBatchMove(moves[:i], createdDirs); rollbackErr != nil {
+				return results, fmt.Errorf("failed to move %s: %w (rollback failed: %v)",
+					move.virtualSource, err, rollbackErr)
+			}
+			return results, fmt.Errorf("failed to move %s: %w", move.virtualSource, err)
+		}
+	}
+
+	return results, nil
+}
+
+// validateBatchMove checks all moves of an atomic batch up front: every
+// source must exist and no destination may exist or be claimed twice
+func (m *Manager) validateBatchMove(results []BatchMoveResult, destDir string) ([]batchMove, error) {
+	destPhysical, err := m.resolvePath(destDir)
+	if err != nil {
+		return nil, fmt.Errorf("invalid destination path: %w", err)
+	}
+	if !m.isPathSafe(destPhysical) {
+		return nil, fmt.Errorf("access denied: path outside managed directory")
+	}
+	if info, err := os.Stat(destPhysical); err == nil && !info.IsDir() {
+		return nil, fmt.Errorf("destination is not a directory: %s", destDir)
+	}
+
+	moves := make([]batchMove, len(results))
+	claimed := make(map[string]string, len(results))
+	for i := range results {
+		move, err := m.validateMove(results[i].Source, results[i].Dest)
+		if err == nil {
+			if other, ok := claimed[move.physicalDest]; ok {
+				err = fmt.Errorf("destination already exists: %s is also moved there from %s", results[i].Dest, other)
+			}
+		}
+		for j := 0; err == nil && j < i; j++ {
+			switch {
+			case isWithin(move.physicalSource, moves[j].physicalSource):
+				err = fmt.Errorf("overlapping sources: %s contains %s", results[j].Source, results[i].Source)
+			case isWithin(moves[j].physicalSource, move.physicalSource):
+				err = fmt.Errorf("overlapping sources: %s contains %s", results[i].Source, results[j].Source)
+			}
+		}
+		if err != nil {
+			results[i].Error = err.Error()
+			return nil, err
+		}
+		claimed[move.physicalDest] = results[i].Source
+		moves[i] = move
+	}
+	return moves, nil
+}
+
+// validateMove resolves a single move of a batch and checks that it can be
+// made without overwriting anything
+func (m *Manager) validateMove(virtualSource, virtualDest string) (batchMove, error) {
+	return m.validateTransfer("move", virtualSource, virtualDest)
+}
+
+// validateTransfer resolves a single move or copy of a batch, named by verb
+// in errors, and checks that it can be made without overwriting anything
+func (m *Manager) validateTransfer(verb, virtualSource, virtualDest string) (batchMove, error) {
+	move := batchMove{virtualSource: virtualSource, virtualDest: virtualDest}
+
+	var err error
+	if move.physicalSource, err = m.resolvePath(virtualSource); err != nil {
+		return move, fmt.Errorf("invalid source path: %w", err)
+	}
+	if move.physicalDest, err = m.resolvePath(virtualDest); err != nil {
+		return move, fmt.Errorf("invalid destination path: %w", err)
+	}
+	if !m.isPathSafe(move.physicalSource) || !m.isPathSafe(move.physicalDest) {
+		return move, fmt.Errorf("access denied: path outside managed directory")
+	}
+
+	if _, err := os.Lstat(move.physicalSource); err != nil {
+		return move, fmt.Errorf("source not found: %s", virtualSource)
+	}
+	if move.physicalDest == move.physicalSource ||
+		strings.HasPrefix(move.physicalDest, move.physicalSource+string(filepath.Separator)) {
+		return move, fmt.Errorf("cannot %s %s into itself", verb, virtualSource)
+	}
+	if _, err := os.Lstat(move.physicalDest); err == nil {
+		return move, fmt.Errorf("destination already exists: %s", virtualDest)
+	}
+	if err := m.checkCaseCollision(move.physicalDest, virtualDest, move.physicalSource); err != nil {
+		return move, err
+	}
+	return move, nil
+}
+
+// rollbackBatchMove undoes completed moves in reverse order and removes the
+// destination directories the batch created
+func (m *Manager) rollbackBatchMove(done []batchMove, createdDirs []string) error {
+	var firstErr error
+	for i := len(done) - 1; i >= 0; i-- {
+		if err := os.Rename(done[i].physicalDest, done[i].physicalSource); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	for i := len(createdDirs) - 1; i >= 0; i-- {
+		if physical, err := m.resolvePath(createdDirs[i]); err == nil {
+			_ = os.Remove(physical) // Only removes directories left empty
+		}
+	}
+	return firstErr
+}
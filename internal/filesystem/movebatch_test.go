@@ -0,0 +1,104 @@
+package filesystem
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"dendrite/internal/config"
+)
+
+func setupMoveBatch(t *testing.T) (*Manager, string) {
+	t.Helper()
+	tmpDir := t.TempDir()
+	for _, name := range []string{"a.txt", "b.txt", "target/b.txt", "sub/a.txt"} {
+		p := filepath.Join(tmpDir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0750))
+		require.NoError(t, os.WriteFile(p, []byte(name), 0600))
+	}
+	cfg := &config.Config{Directories: []config.DirMapping{{Source: tmpDir, Virtual: "/test"}}}
+	return New(cfg), tmpDir
+}
+
+func TestMoveBatch(t *testing.T) {
+	t.Run("moves all sources", func(t *testing.T) {
+		m, tmpDir := setupMoveBatch(t)
+		results, err := m.MoveBatch([]string{"/test/a.txt", "/test/sub"}, "/test/new/dir", true)
+		require.NoError(t, err)
+		require.Len(t, results, 2)
+		assert.Equal(t, "/test/new/dir/a.txt", results[0].Dest)
+		assert.Empty(t, results[0].Error)
+
+		assert.FileExists(t, filepath.Join(tmpDir, "new", "dir", "a.txt"))
+		assert.FileExists(t, filepath.Join(tmpDir, "new", "dir", "sub", "a.txt"))
+		assert.NoFileExists(t, filepath.Join(tmpDir, "a.txt"))
+	})
+
+	t.Run("atomic batch with collision moves nothing", func(t *testing.T) {
+		m, tmpDir := setupMoveBatch(t)
+		results, err := m.MoveBatch([]string{"/test/a.txt", "/test/b.txt"}, "/test/target", true)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "already exists")
+		assert.Empty(t, results[0].Error)
+		assert.NotEmpty(t, results[1].Error)
+
+		assert.FileExists(t, filepath.Join(tmpDir, "a.txt"))
+		assert.FileExists(t, filepath.Join(tmpDir, "b.txt"))
+		assert.NoFileExists(t, filepath.Join(tmpDir, "target", "a.txt"))
+		content, err := os.ReadFile(filepath.Join(tmpDir, "target", "b.txt"))
+		require.NoError(t, err)
+		assert.Equal(t, "target/b.txt", string(content))
+	})
+
+	t.Run("atomic batch rejects sources sharing a name", func(t *testing.T) {
+		m, tmpDir := setupMoveBatch(t)
+		_, err := m.MoveBatch([]string{"/test/a.txt", "/test/sub/a.txt"}, "/test/other", true)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "already exists")
+		assert.FileExists(t, filepath.Join(tmpDir, "a.txt"))
+		assert.NoDirExists(t, filepath.Join(tmpDir, "other"))
+	})
+
+	t.Run("atomic batch rejects nested sources", func(t *testing.T) {
+		m, tmpDir := setupMoveBatch(t)
+		_, err := m.MoveBatch([]string{"/test/sub/a.txt", "/test/sub"}, "/test/other", true)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "overlapping sources: /test/sub contains /test/sub/a.txt")
+		assert.FileExists(t, filepath.Join(tmpDir, "sub", "a.txt"))
+	})
+
+	t.Run("atomic batch rejects missing sources", func(t *testing.T) {
+		m, tmpDir := setupMoveBatch(t)
+		_, err := m.MoveBatch([]string{"/test/a.txt", "/test/missing.txt"}, "/test/target", true)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "not found")
+		assert.FileExists(t, filepath.Join(tmpDir, "a.txt"))
+	})
+
+	t.Run("rollback restores completed moves", func(t *testing.T) {
+		m, tmpDir := setupMoveBatch(t)
+		moves := make([]batchMove, 0, 1)
+		move, err := m.validateMove("/test/a.txt", "/test/new/a.txt")
+		require.NoError(t, err)
+		moves = append(moves, move)
+		created := m.missingDirs(filepath.Join(tmpDir, "new"), "/test/new")
+		require.NoError(t, m.prepareDestination(move.virtualDest, move.physicalDest))
+		require.NoError(t, os.Rename(move.physicalSource, move.physicalDest))
+
+		require.NoError(t, m.rollbackBatchMove(moves, created))
+		assert.FileExists(t, filepath.Join(tmpDir, "a.txt"))
+		assert.NoDirExists(t, filepath.Join(tmpDir, "new"))
+	})
+
+	t.Run("best effort batch moves what it can", func(t *testing.T) {
+		m, tmpDir := setupMoveBatch(t)
+		results, err := m.MoveBatch([]string{"/test/missing.txt", "/test/a.txt"}, "/test/target", false)
+		require.NoError(t, err)
+		assert.NotEmpty(t, results[0].Error)
+		assert.Empty(t, results[1].Error)
+		assert.FileExists(t, filepath.Join(tmpDir, "target", "a.txt"))
+	})
+}
@@ -0,0 +1,84 @@
+package filesystem
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+)
+
+// Overwrite policies for moves and copies whose destination already exists
+const (
+	// OverwriteError fails with an "already exists" error
+	OverwriteError = "error"
+	// OverwriteReplace replaces a file and merges a copied directory into
+	// the existing one
+	OverwriteReplace = "replace"
+	// OverwriteRename appends " (1)", " (2)" and so on to the name until it
+	// is free
+	OverwriteRename = "rename"
+)
+
+// maxRenameAttempts bounds the search for a free name with OverwriteRename
+const maxRenameAttempts = 1000
+
+// TransferOptions controls MoveFileWithOptions and CopyFileWithOptions
+type TransferOptions struct {
+	// Overwrite is one of the overwrite policies; empty means OverwriteError
+	Overwrite string
+}
+
+// ParseOverwritePolicy validates an overwrite policy. An empty policy
+// selects OverwriteError.
+func ParseOverwritePolicy(policy string) (string, error) {
+	switch policy = strings.ToLower(policy); policy {
+	case "":
+		return OverwriteError, nil
+	case OverwriteError, OverwriteReplace, OverwriteRename:
+		return policy, nil
+	}
+	return "", fmt.Errorf("invalid overwrite policy: %s (expected error, replace or rename)", policy)
+}
+
+// numberedName returns name with " (n)" inserted before the extension of a
+// file, "report (2).pdf" for "report.pdf", or appended to a directory name
+func numberedName(name string, n int, isDir bool) string {
+	ext := path.Ext(name)
+	if isDir || ext == name {
+		ext = ""
+	}
+	return fmt.Sprintf("%s (%d)%s", strings.TrimSuffix(name, ext), n, ext)
+}
+
+// applyOverwritePolicy returns the virtual and physical destination of a
+// move or copy under policy. sourcePhysical is excluded from the check, so
+// that a case-only rename on a case-insensitive filesystem is not taken for
+// a conflict with itself.
+func (m *Manager) applyOverwritePolicy(policy, virtualDest, destPhysical, sourcePhysical string,
+	isDir bool) (string, string, error) {
+	exists := func(physical string) bool {
+		_, err := os.Lstat(physical)
+		return err == nil && !sameFile(physical, sourcePhysical)
+	}
+	if policy == OverwriteReplace || !exists(destPhysical) {
+		return virtualDest, destPhysical, nil
+	}
+
+	virtualDest = m.VirtualFS.NormalizePath(path.Clean("/" + virtualDest))
+	if policy != OverwriteRename {
+		return "", "", fmt.Errorf("already exists: %s", virtualDest)
+	}
+
+	dir, name := path.Split(virtualDest)
+	for n := 1; n <= maxRenameAttempts; n++ {
+		candidate := path.Join(dir, numberedName(name, n, isDir))
+		physical, err := m.resolvePath(candidate)
+		if err != nil {
+			return "", "", err
+		}
+		if !exists(physical) {
+			return candidate, physical, nil
+		}
+	}
+	return "", "", fmt.Errorf("already exists: no free name for %s", virtualDest)
+}
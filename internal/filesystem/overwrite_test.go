@@ -0,0 +1,96 @@
+package filesystem
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"dendrite/internal/config"
+)
+
+func TestParseOverwritePolicy(t *testing.T) {
+	for input, want := range map[string]string{"": OverwriteError, "error": OverwriteError,
+		"Replace": OverwriteReplace, "rename": OverwriteRename} {
+		policy, err := ParseOverwritePolicy(input)
+		require.NoError(t, err, input)
+		assert.Equal(t, want, policy)
+	}
+	_, err := ParseOverwritePolicy("merge")
+	assert.ErrorContains(t, err, "invalid overwrite policy")
+}
+
+func TestNumberedName(t *testing.T) {
+	assert.Equal(t, "report (1).pdf", numberedName("report.pdf", 1, false))
+	assert.Equal(t, "archive.tar (2).gz", numberedName("archive.tar.gz", 2, false))
+	assert.Equal(t, ".bashrc (1)", numberedName(".bashrc", 1, false))
+	assert.Equal(t, "photos.2024 (3)", numberedName("photos.2024", 3, true))
+}
+
+func TestTransferOverwritePolicies(t *testing.T) {
+	setup := func(t *testing.T) (*Manager, string) {
+		tmpDir := t.TempDir()
+		for name, content := range map[string]string{
+			"a.txt": "source", "target/a.txt": "existing", "target/a (1).txt": "first copy",
+			"dir/x.txt": "x", "target/dir/y.txt": "y",
+		} {
+			p := filepath.Join(tmpDir, filepath.FromSlash(name))
+			require.NoError(t, os.MkdirAll(filepath.Dir(p), 0750))
+			require.NoError(t, os.WriteFile(p, []byte(content), 0600))
+		}
+		return New(&config.Config{Directories: []config.DirMapping{{Source: tmpDir, Virtual: "/test"}}}), tmpDir
+	}
+	read := func(t *testing.T, p string) string {
+		content, err := os.ReadFile(p)
+		require.NoError(t, err)
+		return string(content)
+	}
+
+	transfers := map[string]func(m *Manager, src, dst, policy string) (string, error){
+		"move": func(m *Manager, src, dst, policy string) (string, error) {
+			return m.MoveFileWithOptions(src, dst, TransferOptions{Overwrite: policy})
+		},
+		"copy": func(m *Manager, src, dst, policy string) (string, error) {
+			return m.CopyFileWithOptions(src, dst, TransferOptions{Overwrite: policy})
+		},
+	}
+	for op, transfer := range transfers {
+		t.Run(op+" error", func(t *testing.T) {
+			m, tmpDir := setup(t)
+			_, err := transfer(m, "/test/a.txt", "/test/target/a.txt", "")
+			assert.ErrorContains(t, err, "already exists: /test/target/a.txt")
+			assert.Equal(t, "existing", read(t, filepath.Join(tmpDir, "target", "a.txt")))
+			assert.FileExists(t, filepath.Join(tmpDir, "a.txt"))
+
+			dest, err := transfer(m, "/test/a.txt", "/test/target/b.txt", OverwriteError)
+			require.NoError(t, err)
+			assert.Equal(t, "/test/target/b.txt", dest)
+		})
+
+		t.Run(op+" replace", func(t *testing.T) {
+			m, tmpDir := setup(t)
+			dest, err := transfer(m, "/test/a.txt", "/test/target/a.txt", OverwriteReplace)
+			require.NoError(t, err)
+			assert.Equal(t, "/test/target/a.txt", dest)
+			assert.Equal(t, "source", read(t, filepath.Join(tmpDir, "target", "a.txt")))
+		})
+
+		t.Run(op+" rename", func(t *testing.T) {
+			m, tmpDir := setup(t)
+			dest, err := transfer(m, "/test/a.txt", "/test/target/a.txt", OverwriteRename)
+			require.NoError(t, err)
+			assert.Equal(t, "/test/target/a (2).txt", dest)
+			assert.Equal(t, "source", read(t, filepath.Join(tmpDir, "target", "a (2).txt")))
+			assert.Equal(t, "existing", read(t, filepath.Join(tmpDir, "target", "a.txt")))
+			assert.Equal(t, "first copy", read(t, filepath.Join(tmpDir, "target", "a (1).txt")))
+
+			dest, err = transfer(m, "/test/dir", "/test/target/dir", OverwriteRename)
+			require.NoError(t, err)
+			assert.Equal(t, "/test/target/dir (1)", dest)
+			assert.FileExists(t, filepath.Join(tmpDir, "target", "dir (1)", "x.txt"))
+			assert.NoFileExists(t, filepath.Join(tmpDir, "target", "dir", "x.txt"))
+		})
+	}
+}
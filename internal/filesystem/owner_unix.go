@@ -0,0 +1,16 @@
+//go:build !windows
+
+package filesystem
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileOwner returns the owner and group of a file
+func fileOwner(info os.FileInfo) (uid, gid int, ok bool) {
+	if sysstat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return int(sysstat.Uid), int(sysstat.Gid), true
+	}
+	return 0, 0, false
+}
@@ -0,0 +1,65 @@
+//go:build !windows
+
+package filesystem
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"dendrite/internal/config"
+)
+
+func TestCopyFilePreserveOwnership(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("changing file ownership requires root privileges")
+	}
+
+	const uid, gid = 12345, 23456
+
+	tempDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(tempDir, "src", "sub"), 0750))
+	srcFile := filepath.Join(tempDir, "src", "sub", "file.txt")
+	require.NoError(t, os.WriteFile(srcFile, []byte("owned"), 0600))
+	for _, path := range []string{filepath.Join(tempDir, "src"), filepath.Join(tempDir, "src", "sub"), srcFile} {
+		require.NoError(t, os.Chown(path, uid, gid))
+	}
+
+	owner := func(path string) (uint32, uint32) {
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		sysstat, ok := info.Sys().(*syscall.Stat_t)
+		require.True(t, ok)
+		return sysstat.Uid, sysstat.Gid
+	}
+
+	t.Run("enabled", func(t *testing.T) {
+		mgr := New(&config.Config{
+			Directories: []config.DirMapping{{Source: tempDir, Virtual: "/test"}},
+			Copy:        config.CopyConfig{PreserveOwnership: true},
+		})
+
+		require.NoError(t, mgr.CopyFile("/test/src", "/test/preserved"))
+
+		for _, path := range []string{"preserved", "preserved/sub", "preserved/sub/file.txt"} {
+			fileUID, fileGID := owner(filepath.Join(tempDir, path))
+			assert.Equal(t, uint32(uid), fileUID, path)
+			assert.Equal(t, uint32(gid), fileGID, path)
+		}
+	})
+
+	t.Run("disabled by default", func(t *testing.T) {
+		mgr := New(&config.Config{
+			Directories: []config.DirMapping{{Source: tempDir, Virtual: "/test"}},
+		})
+
+		require.NoError(t, mgr.CopyFile("/test/src/sub/file.txt", "/test/reset.txt"))
+
+		fileUID, _ := owner(filepath.Join(tempDir, "reset.txt"))
+		assert.Equal(t, uint32(os.Geteuid()), fileUID)
+	})
+}
@@ -0,0 +1,12 @@
+//go:build windows
+
+package filesystem
+
+import (
+	"os"
+)
+
+// fileOwner is not supported on Windows, where ownership is not expressed as UID/GID
+func fileOwner(_ os.FileInfo) (uid, gid int, ok bool) {
+	return 0, 0, false
+}
@@ -0,0 +1,138 @@
+package filesystem
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Sort keys accepted by SortSpec
+const (
+	SortByName    = "name"
+	SortBySize    = "size"
+	SortByModTime = "modTime"
+	// SortByType orders by the MIME type detected by getMimeType, which
+	// directories do not have
+	SortByType = "type"
+)
+
+// sortKeys lists the keys ParseSortSpec accepts
+var sortKeys = []string{SortByName, SortBySize, SortByModTime, SortByType}
+
+// SortSpec describes the order of listing entries
+type SortSpec struct {
+	// By is the sort key, SortByName when empty
+	By string
+	// Desc reverses the order
+	Desc bool
+	// DirsFirst lists all directories ahead of the files, each group sorted
+	// by the key
+	DirsFirst bool
+}
+
+// ParseSortSpec builds a SortSpec from the sort key and the order "asc" or
+// "desc". Keys are matched case-insensitively; empty values select ascending
+// order by name.
+func ParseSortSpec(by, order string, dirsFirst bool) (SortSpec, error) {
+	spec := SortSpec{By: SortByName, DirsFirst: dirsFirst}
+	if by != "" {
+		i := slices.IndexFunc(sortKeys, func(key string) bool {
+			return strings.EqualFold(key, by)
+		})
+		if i < 0 {
+			return spec, fmt.Errorf("invalid sort key: %s (expected name, size, modTime or type)", by)
+		}
+		spec.By = sortKeys[i]
+	}
+
+	switch order {
+	case "", "asc":
+	case "desc":
+		spec.Desc = true
+	default:
+		return spec, fmt.Errorf("invalid sort order: %s (expected asc or desc)", order)
+	}
+	return spec, nil
+}
+
+// SortFiles returns the entries in the order given by spec. Entries with
+// equal keys are ordered by name. The input is not modified.
+func SortFiles(files []FileInfo, spec SortSpec) []FileInfo {
+	sorted := slices.Clone(files)
+	slices.SortStableFunc(sorted, func(a, b FileInfo) int {
+		if spec.DirsFirst && a.IsDir != b.IsDir {
+			if a.IsDir {
+				return -1
+			}
+			return 1
+		}
+
+		var result int
+		switch spec.By {
+		case SortBySize:
+			result = cmp.Compare(a.Size, b.Size)
+		case SortByModTime:
+			result = a.ModTime.Compare(b.ModTime)
+		case SortByType:
+			result = strings.Compare(a.MimeType, b.MimeType)
+		}
+		if result == 0 {
+			result = strings.Compare(a.Name, b.Name)
+		}
+		if spec.Desc {
+			return -result
+		}
+		return result
+	})
+	return sorted
+}
+
+// ListFilesSorted lists a directory like ListFiles in the order given by spec
+func (m *Manager) ListFilesSorted(virtualPath string, spec SortSpec) ([]FileInfo, error) {
+	files, err := m.ListFiles(virtualPath)
+	if err != nil {
+		return nil, err
+	}
+	return SortFiles(files, spec), nil
+}
+
+// PagedResult is one page of a directory listing
+type PagedResult struct {
+	Files []FileInfo `json:"files"`
+	// Total is the number of entries of the whole listing
+	Total  int `json:"total"`
+	Offset int `json:"offset"`
+	Limit  int `json:"limit"`
+}
+
+// PageFiles sorts the entries and returns the page of at most limit entries
+// starting at offset. An offset beyond the end yields an empty page.
+func PageFiles(files []FileInfo, offset, limit int, spec SortSpec) PagedResult {
+	sorted := SortFiles(files, spec)
+	start := min(max(offset, 0), len(sorted))
+	end := start + min(max(limit, 0), len(sorted)-start)
+	return PagedResult{
+		Files:  sorted[start:end],
+		Total:  len(sorted),
+		Offset: offset,
+		Limit:  limit,
+	}
+}
+
+// ListFilesPaged lists a directory like ListFiles and returns the page of at
+// most limit entries starting at offset in the order given by spec
+func (m *Manager) ListFilesPaged(virtualPath string, offset, limit int, spec SortSpec) (PagedResult, error) {
+	if offset < 0 {
+		return PagedResult{}, fmt.Errorf("invalid offset: %d", offset)
+	}
+	if limit < 1 {
+		return PagedResult{}, fmt.Errorf("invalid limit: %d", limit)
+	}
+
+	files, err := m.ListFiles(virtualPath)
+	if err != nil {
+		return PagedResult{}, err
+	}
+	return PageFiles(files, offset, limit, spec), nil
+}
@@ -0,0 +1,158 @@
+package filesystem
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"dendrite/internal/config"
+)
+
+func TestListFilesPaged(t *testing.T) {
+	tmpDir := t.TempDir()
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	files := []struct {
+		name string
+		size int
+		age  time.Duration
+	}{
+		{"b.txt", 30, 3 * time.Hour},
+		{"a.txt", 10, time.Hour},
+		{"d.txt", 20, 4 * time.Hour},
+		{"c.txt", 40, 2 * time.Hour},
+	}
+	for _, f := range files {
+		fullPath := filepath.Join(tmpDir, f.name)
+		require.NoError(t, os.WriteFile(fullPath, make([]byte, f.size), 0600))
+		require.NoError(t, os.Chtimes(fullPath, base.Add(-f.age), base.Add(-f.age)))
+	}
+	require.NoError(t, os.Mkdir(filepath.Join(tmpDir, "zdir"), 0750))
+
+	mgr := New(&config.Config{Directories: []config.DirMapping{{Source: tmpDir, Virtual: "/data"}}})
+
+	names := func(result PagedResult) []string {
+		var list []string
+		for _, f := range result.Files {
+			list = append(list, f.Name)
+		}
+		return list
+	}
+
+	t.Run("pages by name", func(t *testing.T) {
+		result, err := mgr.ListFilesPaged("/data", 0, 2, SortSpec{})
+		require.NoError(t, err)
+		assert.Equal(t, 5, result.Total)
+		assert.Equal(t, []string{"a.txt", "b.txt"}, names(result))
+
+		result, err = mgr.ListFilesPaged("/data", 4, 2, SortSpec{})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"zdir"}, names(result))
+
+		result, err = mgr.ListFilesPaged("/data", 10, 2, SortSpec{})
+		require.NoError(t, err)
+		assert.Equal(t, 5, result.Total)
+		assert.Empty(t, result.Files)
+	})
+
+	t.Run("sorts by key and order", func(t *testing.T) {
+		result, err := mgr.ListFilesPaged("/data", 0, 10, SortSpec{By: SortBySize, Desc: true, DirsFirst: true})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"zdir", "c.txt", "b.txt", "d.txt", "a.txt"}, names(result))
+
+		result, err = mgr.ListFilesPaged("/data", 1, 3, SortSpec{By: SortByModTime})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"b.txt", "c.txt", "a.txt"}, names(result))
+	})
+
+	t.Run("rejects invalid pages", func(t *testing.T) {
+		_, err := mgr.ListFilesPaged("/data", -1, 10, SortSpec{})
+		assert.ErrorContains(t, err, "invalid offset")
+		_, err = mgr.ListFilesPaged("/data", 0, 0, SortSpec{})
+		assert.ErrorContains(t, err, "invalid limit")
+	})
+}
+
+func TestParseSortSpec(t *testing.T) {
+	spec, err := ParseSortSpec("", "", false)
+	require.NoError(t, err)
+	assert.Equal(t, SortSpec{By: SortByName}, spec)
+
+	spec, err = ParseSortSpec("modtime", "desc", true)
+	require.NoError(t, err)
+	assert.Equal(t, SortSpec{By: SortByModTime, Desc: true, DirsFirst: true}, spec)
+
+	spec, err = ParseSortSpec("Type", "asc", false)
+	require.NoError(t, err)
+	assert.Equal(t, SortSpec{By: SortByType}, spec)
+
+	_, err = ParseSortSpec("owner", "", false)
+	assert.ErrorContains(t, err, "invalid sort key")
+	_, err = ParseSortSpec("name", "up", false)
+	assert.ErrorContains(t, err, "invalid sort order")
+}
+
+func TestListFilesSorted(t *testing.T) {
+	tmpDir := t.TempDir()
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	entries := []struct {
+		name  string
+		dir   bool
+		size  int
+		hours int
+	}{
+		{"notes.txt", false, 300, 1},
+		{"photo.png", false, 100, 4},
+		{"archive", true, 0, 2},
+		{"data.json", false, 200, 5},
+		{"build", true, 0, 3},
+	}
+	for _, e := range entries {
+		fullPath := filepath.Join(tmpDir, e.name)
+		if e.dir {
+			require.NoError(t, os.Mkdir(fullPath, 0750))
+		} else {
+			require.NoError(t, os.WriteFile(fullPath, make([]byte, e.size), 0600))
+		}
+		modTime := base.Add(time.Duration(e.hours) * time.Hour)
+		require.NoError(t, os.Chtimes(fullPath, modTime, modTime))
+	}
+
+	mgr := New(&config.Config{Directories: []config.DirMapping{{Source: tmpDir, Virtual: "/data"}}})
+
+	tests := []struct {
+		spec     SortSpec
+		expected []string
+	}{
+		{SortSpec{By: SortByName, DirsFirst: true},
+			[]string{"archive", "build", "data.json", "notes.txt", "photo.png"}},
+		{SortSpec{By: SortByName, Desc: true, DirsFirst: true},
+			[]string{"build", "archive", "photo.png", "notes.txt", "data.json"}},
+		{SortSpec{By: SortByName},
+			[]string{"archive", "build", "data.json", "notes.txt", "photo.png"}},
+		{SortSpec{By: SortBySize, DirsFirst: true},
+			[]string{"archive", "build", "photo.png", "data.json", "notes.txt"}},
+		{SortSpec{By: SortBySize, Desc: true, DirsFirst: true},
+			[]string{"build", "archive", "notes.txt", "data.json", "photo.png"}},
+		{SortSpec{By: SortByModTime, DirsFirst: true},
+			[]string{"archive", "build", "notes.txt", "photo.png", "data.json"}},
+		{SortSpec{By: SortByModTime, Desc: true},
+			[]string{"data.json", "photo.png", "build", "archive", "notes.txt"}},
+		{SortSpec{By: SortByType, DirsFirst: true},
+			[]string{"archive", "build", "data.json", "photo.png", "notes.txt"}},
+		{SortSpec{By: SortByType, Desc: true, DirsFirst: true},
+			[]string{"build", "archive", "notes.txt", "photo.png", "data.json"}},
+	}
+	for _, tt := range tests {
+		files, err := mgr.ListFilesSorted("/data", tt.spec)
+		require.NoError(t, err)
+		names := make([]string, 0, len(files))
+		for _, f := range files {
+			names = append(names, f.Name)
+		}
+		assert.Equal(t, tt.expected, names, "%+v", tt.spec)
+	}
+}
@@ -0,0 +1,47 @@
+//go:build !windows
+
+package filesystem
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"dendrite/internal/config"
+)
+
+func TestQuotaAccountingBlocks(t *testing.T) {
+	tempDir := t.TempDir()
+
+	// A 64 MB file with a single written byte at the end
+	const logical = 64 * 1024 * 1024
+	file, err := os.Create(filepath.Join(tempDir, "disk.img"))
+	require.NoError(t, err)
+	_, err = file.WriteAt([]byte{1}, logical-1)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	newManager := func(accounting string) *Manager {
+		cfg := &config.Config{
+			Main:        config.MainConfig{QuotaAccounting: accounting},
+			Directories: []config.DirMapping{{Source: tempDir, Virtual: "/test"}},
+		}
+		return New(cfg)
+	}
+
+	info, err := newManager("").GetQuotaInfo()
+	require.NoError(t, err)
+	assert.Equal(t, int64(logical), info.Used, "logical accounting is the default")
+
+	info, err = newManager("logical").GetQuotaInfo()
+	require.NoError(t, err)
+	assert.Equal(t, int64(logical), info.Used)
+
+	info, err = newManager("blocks").GetQuotaInfo()
+	require.NoError(t, err)
+	assert.Greater(t, info.Used, int64(0))
+	assert.Less(t, info.Used, int64(logical), "sparse file should use fewer blocks than its logical size")
+}
@@ -0,0 +1,150 @@
+package filesystem
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"dendrite/internal/config"
+)
+
+// usageCache keeps the quota usage of directory mappings between requests.
+// Operations through Dendrite adjust it by the bytes they add or remove, so
+// the quota does not require a walk of every mapping per request. Entries
+// are recalculated once they are older than the reconcile interval, which
+// corrects drift from changes made outside of Dendrite.
+type usageCache struct {
+	mu      sync.Mutex
+	entries map[string]*usageEntry // keyed by physical source directory
+}
+
+type usageEntry struct {
+	used     int64
+	computed time.Time
+}
+
+// usageCaches holds one cache per configuration, shared by the managers
+// created for individual requests
+var usageCaches sync.Map // *config.Config -> *usageCache
+
+// usageCache returns the cache for the manager's configuration, or nil when
+// usage tracking is disabled
+func (m *Manager) usageCache() *usageCache {
+	if m.Config.Main.QuotaReconcileInterval <= 0 {
+		return nil
+	}
+	cache, _ := usageCaches.LoadOrStore(m.Config, &usageCache{entries: make(map[string]*usageEntry)})
+	return cache.(*usageCache)
+}
+
+// reconcileInterval returns the age after which cached usage is recalculated
+func reconcileInterval(cfg *config.Config) time.Duration {
+	return time.Duration(cfg.Main.QuotaReconcileInterval) * time.Second
+}
+
+// mappingUsage returns the bytes a mapped directory counts against the
+// quota, from the cache when it is enabled and fresh
+func (m *Manager) mappingUsage(source string) (int64, error) {
+	cache := m.usageCache()
+	if cache == nil {
+		return m.calculateDirectorySize(source)
+	}
+
+	cache.mu.Lock()
+	entry, ok := cache.entries[source]
+	if ok && time.Since(entry.computed) < reconcileInterval(m.Config) {
+		used := entry.used
+		cache.mu.Unlock()
+		return used, nil
+	}
+	cache.mu.Unlock()
+
+	used, err := m.calculateDirectorySize(source)
+	if err != nil {
+		return 0, err
+	}
+	cache.mu.Lock()
+	cache.entries[source] = &usageEntry{used: used, computed: time.Now()}
+	cache.mu.Unlock()
+	return used, nil
+}
+
+// RecalculateUsage drops the cached usage of the manager's directories and
+// walks them again. It returns the fresh quota information and the drift,
+// the difference between the fresh and the previously cached usage.
+func (m *Manager) RecalculateUsage() (*QuotaInfo, int64, error) {
+	var cachedUsed int64
+	if cache := m.usageCache(); cache != nil {
+		cache.mu.Lock()
+		for _, dir := range m.Directories {
+			if entry, ok := cache.entries[dir.Source]; ok && !m.isQuotaExcluded(dir.Virtual) {
+				cachedUsed += entry.used
+			}
+			delete(cache.entries, dir.Source)
+		}
+		cache.mu.Unlock()
+	}
+
+	info, err := m.GetQuotaInfo()
+	if err != nil {
+		return nil, 0, err
+	}
+	if m.usageCache() == nil {
+		return info, 0, nil
+	}
+	return info, info.Used - cachedUsed, nil
+}
+
+// trackUsage records the current usage of the given physical paths and
+// returns a function that adjusts the cached usage by the change since, to be
+// called once the operation finished:
+//
+//	defer m.trackUsage(physicalPath)()
+func (m *Manager) trackUsage(physicalPaths ...string) func() {
+	cache := m.usageCache()
+	if cache == nil {
+		return func() {}
+	}
+
+	before := make([]int64, len(physicalPaths))
+	for i, p := range physicalPaths {
+		before[i] = m.pathUsage(p)
+	}
+	return func() {
+		for i, p := range physicalPaths {
+			if delta := m.pathUsage(p) - before[i]; delta != 0 {
+				cache.adjust(p, delta)
+			}
+		}
+	}
+}
+
+// pathUsage returns the bytes a file or directory tree counts against the
+// quota; excluded and missing paths count zero
+func (m *Manager) pathUsage(physicalPath string) int64 {
+	if virtualPath, ok := m.VirtualFS.GetVirtualPath(physicalPath); ok && m.isQuotaExcluded(virtualPath) {
+		return 0
+	}
+	info, err := os.Lstat(physicalPath)
+	if err != nil {
+		return 0
+	}
+	if !info.IsDir() {
+		return m.fileUsage(info)
+	}
+	size, _ := m.calculateDirectorySize(physicalPath)
+	return size
+}
+
+// adjust changes the cached usage of every mapping containing physicalPath
+func (c *usageCache) adjust(physicalPath string, delta int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for source, entry := range c.entries {
+		if physicalPath == source || strings.HasPrefix(physicalPath, source+string(filepath.Separator)) {
+			entry.used += delta
+		}
+	}
+}
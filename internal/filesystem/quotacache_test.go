@@ -0,0 +1,98 @@
+package filesystem
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"dendrite/internal/config"
+)
+
+func TestUsageTracking(t *testing.T) {
+	dataDir := t.TempDir()
+	otherDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dataDir, "initial.bin"), make([]byte, 1000), 0600))
+
+	cfg := &config.Config{
+		Main: config.MainConfig{QuotaReconcileInterval: 3600, QuotaExclude: []string{"/data/cache"}},
+		Directories: []config.DirMapping{
+			{Source: dataDir, Virtual: "/data"},
+			{Source: otherDir, Virtual: "/other"},
+		},
+		QuotaBytes: 1 << 20,
+	}
+	m := New(cfg)
+
+	info, err := m.GetQuotaInfo()
+	require.NoError(t, err)
+	require.Equal(t, int64(1000), info.Used)
+
+	upload := func(dir, name string, size int) {
+		t.Helper()
+		_, err := m.UploadFile(dir, name, bytes.NewReader(make([]byte, size)), int64(size))
+		require.NoError(t, err)
+	}
+
+	// A series of mixed operations, each adjusting the tracked usage
+	upload("/data", "a.bin", 300)
+	upload("/data/dir", "b.bin", 200)
+	upload("/data/cache", "c.bin", 5000)
+	upload("/data", "a.bin", 100) // Overwrite shrinks the file
+	_, err = m.StreamFile("/other/s.bin", bytes.NewReader(make([]byte, 700)), 700)
+	require.NoError(t, err)
+	_, err = m.WriteFile("/data/w.txt", []byte(strings.Repeat("x", 50)))
+	require.NoError(t, err)
+	require.NoError(t, m.CopyFile("/data/dir", "/other/dir-copy"))
+	require.NoError(t, m.MoveFile("/data/initial.bin", "/other/initial.bin"))
+	require.NoError(t, m.MoveFile("/other/s.bin", "/data/cache/s.bin"))
+	require.NoError(t, m.DeleteFile("/data/dir"))
+	_, err = m.MoveBatch([]string{"/data/a.bin", "/data/w.txt"}, "/other/batch", true)
+	require.NoError(t, err)
+
+	tracked, err := m.GetQuotaInfo()
+	require.NoError(t, err)
+
+	fresh, drift, err := m.RecalculateUsage()
+	require.NoError(t, err)
+	assert.Equal(t, fresh.Used, tracked.Used)
+	assert.Zero(t, drift)
+	// initial 1000 + a 100 + w 50 + dir copy 200
+	assert.Equal(t, int64(1350), fresh.Used)
+}
+
+func TestUsageTrackingDrift(t *testing.T) {
+	dataDir := t.TempDir()
+	cfg := &config.Config{
+		Main:        config.MainConfig{QuotaReconcileInterval: 3600},
+		Directories: []config.DirMapping{{Source: dataDir, Virtual: "/data"}},
+	}
+	m := New(cfg)
+
+	info, err := m.GetQuotaInfo()
+	require.NoError(t, err)
+	require.Zero(t, info.Used)
+
+	// Changes made outside Dendrite are only picked up by a reconciliation
+	require.NoError(t, os.WriteFile(filepath.Join(dataDir, "outside.bin"), make([]byte, 400), 0600))
+	info, err = m.GetQuotaInfo()
+	require.NoError(t, err)
+	assert.Zero(t, info.Used)
+
+	info, drift, err := m.RecalculateUsage()
+	require.NoError(t, err)
+	assert.Equal(t, int64(400), info.Used)
+	assert.Equal(t, int64(400), drift)
+
+	t.Run("disabled tracking walks on every check", func(t *testing.T) {
+		m := New(&config.Config{Directories: cfg.Directories})
+		require.NoError(t, os.WriteFile(filepath.Join(dataDir, "more.bin"), make([]byte, 100), 0600))
+		info, err := m.GetQuotaInfo()
+		require.NoError(t, err)
+		assert.Equal(t, int64(500), info.Used)
+	})
+}
@@ -0,0 +1,43 @@
+package filesystem
+
+import (
+	"path"
+	"strings"
+)
+
+// quotaApplies reports whether writes to virtualPath are checked against the
+// quota: a global quota or one of the path's mapping must be configured and
+// the path must not be excluded
+func (m *Manager) quotaApplies(virtualPath string) bool {
+	return (m.quotaLimit() > 0 || m.mappingQuota(virtualPath) > 0) && !m.isQuotaExcluded(virtualPath)
+}
+
+// isQuotaExcluded reports whether virtualPath lies below one of the
+// configured quota_exclude prefixes
+func (m *Manager) isQuotaExcluded(virtualPath string) bool {
+	virtualPath = path.Clean("/" + virtualPath)
+	for _, prefix := range m.Config.Main.QuotaExclude {
+		prefix = path.Clean("/" + prefix)
+		if virtualPath == prefix || strings.HasPrefix(virtualPath, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// quotaExcludedDirs resolves the quota_exclude prefixes to physical
+// directories. Prefixes outside the mappings of this manager are ignored.
+func (m *Manager) quotaExcludedDirs() map[string]bool {
+	if len(m.Config.Main.QuotaExclude) == 0 {
+		return nil
+	}
+	dirs := make(map[string]bool, len(m.Config.Main.QuotaExclude))
+	for _, prefix := range m.Config.Main.QuotaExclude {
+		physicalPath, err := m.resolvePath(prefix)
+		if err != nil || !m.isPathSafe(physicalPath) {
+			continue
+		}
+		dirs[physicalPath] = true
+	}
+	return dirs
+}
@@ -0,0 +1,66 @@
+package filesystem
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"dendrite/internal/config"
+)
+
+func TestQuotaExclude(t *testing.T) {
+	dataDir := t.TempDir()
+	scratchDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dataDir, "cache", "nested"), 0750))
+	require.NoError(t, os.WriteFile(filepath.Join(dataDir, "kept.bin"), make([]byte, 100), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dataDir, "cache", "nested", "big.bin"), make([]byte, 5000), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(scratchDir, "tmp.bin"), make([]byte, 5000), 0600))
+
+	cfg := &config.Config{
+		Main: config.MainConfig{QuotaExclude: []string{"/data/cache", "/scratch"}},
+		Directories: []config.DirMapping{
+			{Source: dataDir, Virtual: "/data"},
+			{Source: scratchDir, Virtual: "/scratch"},
+		},
+		QuotaBytes: 1000,
+	}
+	m := New(cfg)
+
+	t.Run("excluded files do not count", func(t *testing.T) {
+		info, err := m.GetQuotaInfo()
+		require.NoError(t, err)
+		assert.Equal(t, int64(100), info.Used)
+		assert.False(t, info.Exceeded)
+	})
+
+	t.Run("uploads into excluded paths skip the quota", func(t *testing.T) {
+		_, err := m.UploadFile("/data/cache", "more.bin", bytes.NewReader(make([]byte, 2000)), 2000)
+		require.NoError(t, err)
+		_, err = m.UploadFile("/scratch/sub", "more.bin", bytes.NewReader(make([]byte, 2000)), 2000)
+		require.NoError(t, err)
+
+		info, err := m.GetQuotaInfo()
+		require.NoError(t, err)
+		assert.Equal(t, int64(100), info.Used)
+	})
+
+	t.Run("uploads elsewhere are still limited", func(t *testing.T) {
+		_, err := m.UploadFile("/data", "other.bin", bytes.NewReader(make([]byte, 2000)), 2000)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "quota")
+
+		_, err = m.UploadFile("/data/cachefile", "other.bin", bytes.NewReader(make([]byte, 2000)), 2000)
+		require.Error(t, err)
+	})
+
+	t.Run("excluded files remain browsable", func(t *testing.T) {
+		files, err := m.ListFiles("/data/cache/nested")
+		require.NoError(t, err)
+		require.Len(t, files, 1)
+		assert.Equal(t, "big.bin", files[0].Name)
+	})
+}
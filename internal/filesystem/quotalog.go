@@ -0,0 +1,29 @@
+package filesystem
+
+import (
+	"log"
+	"sync/atomic"
+)
+
+// quotaDenials counts operations rejected for exceeding the quota since the
+// server started, across all managers
+var quotaDenials atomic.Int64
+
+// QuotaDenials returns the number of operations rejected for exceeding the
+// quota since the server started
+func QuotaDenials() int64 {
+	return quotaDenials.Load()
+}
+
+// recordQuotaDenial counts a rejected operation and, when enabled, logs the
+// path, current usage, attempted size and the limit that was hit. For streams
+// of unknown length, attempted is the number of bytes received before the
+// upload was aborted.
+func (m *Manager) recordQuotaDenial(op, virtualPath string, used, attempted, limit int64) {
+	quotaDenials.Add(1)
+	if !m.Config.Logging.QuotaDenials {
+		return
+	}
+	log.Printf("quota denied op=%s path=%q used=%d attempted=%d limit=%d",
+		op, virtualPath, used, attempted, limit)
+}
@@ -0,0 +1,95 @@
+package filesystem
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"dendrite/internal/config"
+)
+
+// captureLog redirects the standard logger into a buffer for the test
+func captureLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	flags := log.Flags()
+	log.SetFlags(0)
+	t.Cleanup(func() {
+		log.SetOutput(os.Stderr)
+		log.SetFlags(flags)
+	})
+	return &buf
+}
+
+func TestQuotaDenialLogging(t *testing.T) {
+	tempDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "existing.bin"), make([]byte, 60), 0600))
+
+	cfg := &config.Config{
+		Directories: []config.DirMapping{{Source: tempDir, Virtual: "/data"}},
+		QuotaBytes:  100,
+		Logging:     config.LoggingConfig{QuotaDenials: true},
+	}
+	mgr := New(cfg)
+	logs := captureLog(t)
+
+	t.Run("upload", func(t *testing.T) {
+		logs.Reset()
+		before := QuotaDenials()
+
+		_, err := mgr.UploadFile("/data", "big.bin", strings.NewReader(strings.Repeat("x", 50)), 50)
+		require.Error(t, err)
+		// The user-facing error is unchanged
+		assert.Equal(t, "upload would exceed quota limit (current: 60 B, file: 50 B, limit: 100 B)", err.Error())
+
+		assert.Equal(t, before+1, QuotaDenials())
+		assert.Equal(t, "quota denied op=upload path=\"/data/big.bin\" used=60 attempted=50 limit=100\n", logs.String())
+	})
+
+	t.Run("streamed upload of unknown size", func(t *testing.T) {
+		logs.Reset()
+		before := QuotaDenials()
+
+		_, err := mgr.UploadFile("/data", "stream.bin", strings.NewReader(strings.Repeat("x", 80)), -1)
+		require.ErrorIs(t, err, errQuotaExceeded)
+		assert.Equal(t, before+1, QuotaDenials())
+		assert.Contains(t, logs.String(), `quota denied op=upload path="/data/stream.bin" used=60 attempted=80`)
+	})
+
+	t.Run("copy", func(t *testing.T) {
+		logs.Reset()
+		before := QuotaDenials()
+
+		require.Error(t, mgr.CopyFile("/data/existing.bin", "/data/copy.bin"))
+		assert.Equal(t, before+1, QuotaDenials())
+		assert.Contains(t, logs.String(), `quota denied op=copy path="/data/copy.bin" used=60 attempted=60 limit=100`)
+	})
+
+	t.Run("write", func(t *testing.T) {
+		logs.Reset()
+		before := QuotaDenials()
+
+		_, err := mgr.WriteFile("/data/new.txt", make([]byte, 41))
+		require.Error(t, err)
+		assert.Equal(t, before+1, QuotaDenials())
+		assert.Contains(t, logs.String(), `quota denied op=write path="/data/new.txt" used=60 attempted=41 limit=100`)
+	})
+
+	t.Run("logging disabled still counts", func(t *testing.T) {
+		logs.Reset()
+		before := QuotaDenials()
+		quiet := New(&config.Config{Directories: cfg.Directories, QuotaBytes: 100})
+
+		_, err := quiet.UploadFile("/data", "big.bin", strings.NewReader(strings.Repeat("x", 50)), 50)
+		require.Error(t, err)
+		assert.Equal(t, before+1, QuotaDenials())
+		assert.Empty(t, logs.String())
+	})
+}
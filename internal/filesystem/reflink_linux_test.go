@@ -0,0 +1,62 @@
+//go:build linux
+
+package filesystem
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"dendrite/internal/config"
+)
+
+func TestCloneFile(t *testing.T) {
+	tempDir := t.TempDir()
+	content := []byte("reflink test content")
+
+	srcPath := filepath.Join(tempDir, "src.txt")
+	require.NoError(t, os.WriteFile(srcPath, content, 0600))
+
+	src, err := os.Open(srcPath) // #nosec G304 - test file
+	require.NoError(t, err)
+	defer func() { _ = src.Close() }()
+
+	dst, err := os.Create(filepath.Join(tempDir, "dst.txt")) // #nosec G304 - test file
+	require.NoError(t, err)
+	defer func() { _ = dst.Close() }()
+
+	// The clone succeeds only on filesystems with reflink support (e.g. Btrfs, XFS)
+	if err := cloneFile(dst, src); err != nil {
+		t.Logf("reflink not supported on test filesystem: %v", err)
+		return
+	}
+
+	data, err := os.ReadFile(dst.Name())
+	require.NoError(t, err)
+	assert.Equal(t, content, data)
+}
+
+func TestCopyFileWithReflink(t *testing.T) {
+	tempDir := t.TempDir()
+
+	cfg := &config.Config{
+		Directories: []config.DirMapping{
+			{Source: tempDir, Virtual: "/test"},
+		},
+		Copy: config.CopyConfig{Reflink: true},
+	}
+	mgr := New(cfg)
+
+	content := []byte("content copied with reflink or fallback")
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "source.txt"), content, 0600))
+
+	// Must succeed regardless of reflink support by falling back to a byte copy
+	require.NoError(t, mgr.CopyFile("/test/source.txt", "/test/dest.txt"))
+
+	data, err := os.ReadFile(filepath.Join(tempDir, "dest.txt")) // #nosec G304 - test file
+	require.NoError(t, err)
+	assert.Equal(t, content, data)
+}
@@ -0,0 +1,78 @@
+package filesystem
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// validateNewName checks that a rename target is a single path element
+func validateNewName(name string) error {
+	switch {
+	case name == "":
+		return fmt.Errorf("invalid name: name must not be empty")
+	case name == "." || name == "..":
+		return fmt.Errorf("invalid name: %q", name)
+	case strings.ContainsAny(name, "/\\\x00"):
+		return fmt.Errorf("invalid name: %q must not contain slashes", name)
+	}
+	return nil
+}
+
+// isMappingRoot reports whether a physical path is the source of a mapping
+func (m *Manager) isMappingRoot(physicalPath string) bool {
+	for _, dir := range m.Directories {
+		if filepath.Clean(dir.Source) == filepath.Clean(physicalPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// RenameFile gives a file or directory a new name within its parent
+// directory. Unlike MoveFile it never overwrites an existing sibling.
+func (m *Manager) RenameFile(virtualPath, newName string) error {
+	if err := validateNewName(newName); err != nil {
+		return err
+	}
+
+	sourcePhysicalPath, err := m.resolvePath(virtualPath)
+	if err != nil {
+		return err
+	}
+	if !m.isPathSafe(sourcePhysicalPath) {
+		return fmt.Errorf("access denied: path outside managed directory")
+	}
+	if m.isMappingRoot(sourcePhysicalPath) {
+		return fmt.Errorf("access denied: cannot rename a mapped directory")
+	}
+	if _, err := os.Lstat(sourcePhysicalPath); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("file not found: %s", virtualPath)
+		}
+		return err
+	}
+
+	virtualDestPath := path.Join(path.Dir(path.Clean("/"+virtualPath)), newName)
+	destPhysicalPath, err := m.resolvePath(virtualDestPath)
+	if err != nil {
+		return err
+	}
+	if !m.isPathSafe(destPhysicalPath) || filepath.Dir(destPhysicalPath) != filepath.Dir(sourcePhysicalPath) {
+		return fmt.Errorf("access denied: path outside managed directory")
+	}
+
+	// A case-only rename on a case-insensitive filesystem finds the source
+	// itself under the new name
+	if _, err := os.Lstat(destPhysicalPath); err == nil && !sameFile(destPhysicalPath, sourcePhysicalPath) {
+		return fmt.Errorf("already exists: %s", m.VirtualFS.NormalizePath(virtualDestPath))
+	}
+	if err := m.checkCaseCollision(destPhysicalPath, virtualDestPath, sourcePhysicalPath); err != nil {
+		return err
+	}
+
+	defer m.trackUsage(sourcePhysicalPath, destPhysicalPath)()
+	return os.Rename(sourcePhysicalPath, destPhysicalPath)
+}
@@ -0,0 +1,63 @@
+package filesystem
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"dendrite/internal/config"
+)
+
+func TestRenameFile(t *testing.T) {
+	tempDir := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(tempDir, "docs"), 0750))
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "docs", "a.txt"), []byte("a"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "docs", "b.txt"), []byte("b"), 0600))
+
+	mgr := New(&config.Config{Directories: []config.DirMapping{{Source: tempDir, Virtual: "/test"}}})
+
+	t.Run("renames within the parent directory", func(t *testing.T) {
+		require.NoError(t, mgr.RenameFile("/test/docs/a.txt", "c.txt"))
+		assert.NoFileExists(t, filepath.Join(tempDir, "docs", "a.txt"))
+		assert.FileExists(t, filepath.Join(tempDir, "docs", "c.txt"))
+
+		require.NoError(t, mgr.RenameFile("/test/docs", "papers"))
+		assert.DirExists(t, filepath.Join(tempDir, "papers"))
+	})
+
+	t.Run("rejects invalid names", func(t *testing.T) {
+		for _, name := range []string{"", ".", "..", "x/y", "../escape", `x\y`} {
+			err := mgr.RenameFile("/test/papers/b.txt", name)
+			assert.ErrorContains(t, err, "invalid name", name)
+		}
+		assert.FileExists(t, filepath.Join(tempDir, "papers", "b.txt"))
+	})
+
+	t.Run("does not overwrite siblings", func(t *testing.T) {
+		err := mgr.RenameFile("/test/papers/b.txt", "c.txt")
+		assert.EqualError(t, err, "already exists: /test/papers/c.txt")
+
+		content, err := os.ReadFile(filepath.Join(tempDir, "papers", "c.txt"))
+		require.NoError(t, err)
+		assert.Equal(t, "a", string(content))
+	})
+
+	t.Run("case-only renames", func(t *testing.T) {
+		require.NoError(t, mgr.RenameFile("/test/papers/b.txt", "B.txt"))
+		assert.FileExists(t, filepath.Join(tempDir, "papers", "B.txt"))
+	})
+
+	t.Run("missing source", func(t *testing.T) {
+		err := mgr.RenameFile("/test/papers/missing.txt", "other.txt")
+		assert.EqualError(t, err, "file not found: /test/papers/missing.txt")
+	})
+
+	t.Run("mapped directories cannot be renamed", func(t *testing.T) {
+		err := mgr.RenameFile("/test", "other")
+		assert.ErrorContains(t, err, "access denied")
+		assert.DirExists(t, tempDir)
+	})
+}
@@ -0,0 +1,183 @@
+package filesystem
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ReplaceDirectoryFromZip extracts a ZIP archive next to the directory at
+// virtualPath and then swaps it in, so that readers see either the complete
+// old or the complete new contents. The old contents are removed afterwards.
+// The new contents may use the quota freed by the old ones.
+func (m *Manager) ReplaceDirectoryFromZip(virtualPath string, r io.Reader) error {
+	target, err := m.replaceTarget(virtualPath)
+	if err != nil {
+		return err
+	}
+	parent := filepath.Dir(target)
+
+	remaining := int64(-1)
+	var oldSize int64
+	if m.quotaApplies(virtualPath) {
+		used, limit, err := m.quotaState(virtualPath)
+		if err != nil {
+			return fmt.Errorf("failed to calculate current usage: %w", err)
+		}
+		remaining = max(limit-used, 0)
+		if oldSize, err = m.calculateDirectorySize(target); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to calculate directory size: %w", err)
+		}
+		r = &quotaReader{r: r, limit: remaining}
+	}
+
+	// The archive is spooled to disk because ZIP needs random access
+	archive, err := os.CreateTemp(parent, ".dendrite-upload-*.zip")
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer func() {
+		_ = os.Remove(archive.Name())
+	}()
+	written, err := io.Copy(archive, r)
+	if cerr := archive.Close(); cerr != nil && err == nil {
+		err = cerr
+	}
+	if err != nil {
+		if errors.Is(err, errQuotaExceeded) {
+			return err
+		}
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+
+	staging, err := os.MkdirTemp(parent, ".dendrite-replace-*")
+	if err != nil {
+		return fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	defer func() {
+		_ = os.RemoveAll(staging)
+	}()
+
+	limit := int64(-1)
+	if remaining >= 0 {
+		limit = remaining - written + oldSize
+	}
+	x := &extractor{
+		dest:      staging,
+		remaining: limit,
+		limits:    DecompressionLimitsFor(m.Config),
+		symlinks:  m.Config.Extraction.Symlinks,
+	}
+	if err := x.extractZip(archive.Name()); err != nil {
+		if errors.Is(err, errQuotaExceeded) {
+			return fmt.Errorf("quota exceeded: extracted content would exceed storage limit")
+		}
+		if errors.Is(err, errDecompressionLimit) {
+			return err
+		}
+		return fmt.Errorf("failed to extract archive: %w", err)
+	}
+
+	defer m.trackUsage(target)()
+	return swapDirectory(staging, target)
+}
+
+// ReplaceDirectoryFromStaging moves the staging directory over the directory
+// at virtualPath in one step. Both must be on the same filesystem; the old
+// contents are removed afterwards.
+func (m *Manager) ReplaceDirectoryFromStaging(virtualPath, stagingVirtualPath string) error {
+	target, err := m.replaceTarget(virtualPath)
+	if err != nil {
+		return err
+	}
+
+	staging, err := m.resolvePath(stagingVirtualPath)
+	if err != nil {
+		return fmt.Errorf("invalid staging path: %w", err)
+	}
+	if !m.isPathSafe(staging) {
+		return fmt.Errorf("access denied: path outside managed directory")
+	}
+	if staging == target || isWithin(target, staging) || isWithin(staging, target) {
+		return fmt.Errorf("invalid staging path: must not contain or be inside the target")
+	}
+
+	info, err := os.Lstat(staging)
+	if err != nil {
+		return fmt.Errorf("staging directory not found: %s", stagingVirtualPath)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("staging path is not a directory: %s", stagingVirtualPath)
+	}
+
+	defer m.trackUsage(staging, target)()
+	return swapDirectory(staging, target)
+}
+
+// replaceTarget resolves the directory to replace. It may not exist yet, but
+// must not be a file or the root of a mapping.
+func (m *Manager) replaceTarget(virtualPath string) (string, error) {
+	target, err := m.resolvePath(virtualPath)
+	if err != nil {
+		return "", err
+	}
+	if !m.isPathSafe(target) {
+		return "", fmt.Errorf("access denied: path outside managed directory")
+	}
+	for _, dir := range m.Directories {
+		if filepath.Clean(dir.Source) == target {
+			return "", fmt.Errorf("access denied: cannot replace the root of a directory mapping")
+		}
+	}
+
+	info, err := os.Lstat(target)
+	if err == nil && !info.IsDir() {
+		return "", fmt.Errorf("path already exists and is not a directory: %s", virtualPath)
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0750); err != nil {
+		return "", fmt.Errorf("failed to create directory: %w", err)
+	}
+	return target, nil
+}
+
+// isWithin reports whether p is located below dir
+func isWithin(p, dir string) bool {
+	return strings.HasPrefix(p, dir+string(filepath.Separator))
+}
+
+// swapDirectory puts staging in place of target and removes the previous
+// contents. Where supported the two are exchanged atomically; otherwise the
+// target is briefly absent between two renames, but never half-updated.
+func swapDirectory(staging, target string) error {
+	if _, err := os.Lstat(target); os.IsNotExist(err) {
+		if err := os.Rename(staging, target); err != nil {
+			return fmt.Errorf("failed to move directory into place: %w", err)
+		}
+		return nil
+	}
+
+	if err := exchangePaths(staging, target); err == nil {
+		// staging now holds the old contents
+		if err := os.RemoveAll(staging); err != nil {
+			return fmt.Errorf("failed to remove previous contents: %w", err)
+		}
+		return nil
+	}
+
+	old := target + ".dendrite-old"
+	if err := os.Rename(target, old); err != nil {
+		return fmt.Errorf("failed to move previous contents aside: %w", err)
+	}
+	if err := os.Rename(staging, target); err != nil {
+		// Put the previous contents back
+		_ = os.Rename(old, target)
+		return fmt.Errorf("failed to move directory into place: %w", err)
+	}
+	if err := os.RemoveAll(old); err != nil {
+		return fmt.Errorf("failed to remove previous contents: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package filesystem
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"dendrite/internal/config"
+)
+
+// readNames returns the sorted entry names of a directory
+func readNames(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func TestReplaceDirectoryFromZip(t *testing.T) {
+	tempDir := t.TempDir()
+	site := filepath.Join(tempDir, "site")
+	require.NoError(t, os.MkdirAll(site, 0750))
+	for _, name := range []string{"old1.html", "old2.html"} {
+		require.NoError(t, os.WriteFile(filepath.Join(site, name), []byte("old"), 0600))
+	}
+
+	mgr := New(&config.Config{
+		Directories: []config.DirMapping{{Source: tempDir, Virtual: "/data"}},
+	})
+
+	oldNames := []string{"old1.html", "old2.html"}
+	newNames := []string{"assets", "new1.html", "new2.html"}
+
+	// Readers must always see one complete version
+	var stop atomic.Bool
+	var mixed atomic.Value
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for !stop.Load() {
+			names, err := readNames(site)
+			if err != nil {
+				mixed.Store(err.Error())
+				return
+			}
+			if !assert.ObjectsAreEqual(oldNames, names) && !assert.ObjectsAreEqual(newNames, names) {
+				mixed.Store(strings.Join(names, ","))
+				return
+			}
+		}
+	}()
+
+	archive := buildZip(t, map[string]string{
+		"new1.html":      "new",
+		"new2.html":      "new",
+		"assets/app.css": "body{}",
+	})
+	err := mgr.ReplaceDirectoryFromZip("/data/site", bytes.NewReader(archive))
+	stop.Store(true)
+	wg.Wait()
+	require.NoError(t, err)
+	assert.Nil(t, mixed.Load(), "reader saw an inconsistent state")
+
+	names, err := readNames(site)
+	require.NoError(t, err)
+	assert.Equal(t, newNames, names)
+
+	// Only the site itself is left; staging and old contents are gone
+	names, err = readNames(tempDir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"site"}, names)
+
+	t.Run("missing target is created", func(t *testing.T) {
+		require.NoError(t, mgr.ReplaceDirectoryFromZip("/data/a/b/fresh", bytes.NewReader(archive)))
+		assert.FileExists(t, filepath.Join(tempDir, "a", "b", "fresh", "assets", "app.css"))
+	})
+
+	t.Run("invalid archive keeps the old contents", func(t *testing.T) {
+		err := mgr.ReplaceDirectoryFromZip("/data/site", strings.NewReader("not a zip"))
+		assert.ErrorContains(t, err, "failed to extract archive")
+		names, err := readNames(site)
+		require.NoError(t, err)
+		assert.Equal(t, newNames, names)
+	})
+
+	t.Run("mapping root cannot be replaced", func(t *testing.T) {
+		err := mgr.ReplaceDirectoryFromZip("/data", bytes.NewReader(archive))
+		assert.ErrorContains(t, err, "access denied")
+	})
+}
+
+func TestReplaceDirectoryQuota(t *testing.T) {
+	tempDir := t.TempDir()
+	site := filepath.Join(tempDir, "site")
+	require.NoError(t, os.MkdirAll(site, 0750))
+	require.NoError(t, os.WriteFile(filepath.Join(site, "old.bin"), make([]byte, 3000), 0600))
+
+	mgr := New(&config.Config{
+		Directories: []config.DirMapping{{Source: tempDir, Virtual: "/data"}},
+		QuotaBytes:  8000,
+	})
+
+	// 4000 bytes fit because the old 3000 bytes are freed
+	fits := buildZip(t, map[string]string{"new.bin": strings.Repeat("a", 4000)})
+	require.NoError(t, mgr.ReplaceDirectoryFromZip("/data/site", bytes.NewReader(fits)))
+	assert.FileExists(t, filepath.Join(site, "new.bin"))
+
+	tooBig := buildZip(t, map[string]string{"big.bin": strings.Repeat("b", 9000)})
+	err := mgr.ReplaceDirectoryFromZip("/data/site", bytes.NewReader(tooBig))
+	assert.ErrorContains(t, err, "quota exceeded")
+	assert.FileExists(t, filepath.Join(site, "new.bin"))
+}
+
+func TestReplaceDirectoryFromStaging(t *testing.T) {
+	tempDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(tempDir, "site"), 0750))
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "site", "old.html"), []byte("old"), 0600))
+	require.NoError(t, os.MkdirAll(filepath.Join(tempDir, "staging"), 0750))
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "staging", "new.html"), []byte("new"), 0600))
+
+	mgr := New(&config.Config{
+		Directories: []config.DirMapping{{Source: tempDir, Virtual: "/data"}},
+	})
+
+	require.NoError(t, mgr.ReplaceDirectoryFromStaging("/data/site", "/data/staging"))
+	names, err := readNames(filepath.Join(tempDir, "site"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"new.html"}, names)
+	assert.NoDirExists(t, filepath.Join(tempDir, "staging"))
+
+	err = mgr.ReplaceDirectoryFromStaging("/data/site", "/data/missing")
+	assert.ErrorContains(t, err, "staging directory not found")
+	err = mgr.ReplaceDirectoryFromStaging("/data/site", "/data/site/sub")
+	assert.ErrorContains(t, err, "invalid staging path")
+}
@@ -0,0 +1,370 @@
+package filesystem
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// resumablePattern names the partial files of resumable uploads, which are
+// staged next to their destination like the files of StreamFile
+const resumablePattern = ".dendrite-resumable-*"
+
+// UploadSession describes a resumable upload in progress
+type UploadSession struct {
+	ID      string    `json:"id"`
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	Offset  int64     `json:"offset"`
+	Expires time.Time `json:"expires"`
+}
+
+// uploadSession is the state of a session. Its mutex serializes chunks and
+// completion; Expires is guarded by the mutex of UploadSessions.
+type uploadSession struct {
+	UploadSession
+	physicalPath string
+	tmpPath      string
+	createdDirs  []string
+	owner        *Manager
+	mu           sync.Mutex
+	done         bool
+}
+
+// UploadSessions holds the resumable uploads of a server. It is shared by the
+// managers created for individual requests; every manager can only continue
+// sessions whose destination it resolves to the same physical path.
+type UploadSessions struct {
+	mu       sync.Mutex
+	ttl      time.Duration
+	sessions map[string]*uploadSession
+}
+
+// NewUploadSessions creates an empty session registry whose sessions expire
+// ttl after their last chunk
+func NewUploadSessions(ttl time.Duration) *UploadSessions {
+	return &UploadSessions{ttl: ttl, sessions: make(map[string]*uploadSession)}
+}
+
+// Expire removes the sessions that expired before now together with their
+// partial files and returns their IDs
+func (s *UploadSessions) Expire(now time.Time) []string {
+	return s.removeWhere(func(sess *uploadSession) bool { return now.After(sess.Expires) })
+}
+
+// Clear removes all sessions together with their partial files and returns
+// their IDs. A chunk being written finishes first.
+func (s *UploadSessions) Clear() []string {
+	return s.removeWhere(func(*uploadSession) bool { return true })
+}
+
+// removeWhere removes the sessions matched by match and discards their
+// partial files
+func (s *UploadSessions) removeWhere(match func(*uploadSession) bool) []string {
+	s.mu.Lock()
+	var removed []*uploadSession
+	for id, sess := range s.sessions {
+		if match(sess) {
+			delete(s.sessions, id)
+			removed = append(removed, sess)
+		}
+	}
+	s.mu.Unlock()
+
+	ids := make([]string, 0, len(removed))
+	for _, sess := range removed {
+		sess.mu.Lock()
+		if !sess.done {
+			sess.discard()
+		}
+		sess.mu.Unlock()
+		ids = append(ids, sess.ID)
+	}
+	return ids
+}
+
+// add registers a new session
+func (s *UploadSessions) add(sess *uploadSession) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess.Expires = time.Now().Add(s.ttl)
+	s.sessions[sess.ID] = sess
+}
+
+// remove forgets a finished session
+func (s *UploadSessions) remove(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, id)
+}
+
+// snapshot returns the public state of a session, extending its lifetime
+// when touch is set. The caller holds the session's mutex.
+func (s *UploadSessions) snapshot(sess *uploadSession, touch bool) *UploadSession {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if touch {
+		sess.Expires = time.Now().Add(s.ttl)
+	}
+	info := sess.UploadSession
+	return &info
+}
+
+// discard removes the partial file of a session. The caller holds the
+// session's mutex.
+func (sess *uploadSession) discard() {
+	defer sess.owner.trackUsage(sess.tmpPath)()
+	_ = os.Remove(sess.tmpPath)
+	sess.done = true
+}
+
+// newUploadID returns a random session ID
+func newUploadID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("failed to create upload ID: %w", err)
+	}
+	return hex.EncodeToString(id), nil
+}
+
+// uploadSession looks up a session and locks it. Sessions of destinations the
+// manager has no access to are reported as missing.
+func (m *Manager) uploadSession(sessions *UploadSessions, id string) (*uploadSession, error) {
+	sessions.Expire(time.Now())
+
+	sessions.mu.Lock()
+	sess, ok := sessions.sessions[id]
+	sessions.mu.Unlock()
+	if ok {
+		if physicalPath, err := m.resolvePath(sess.Path); err != nil || physicalPath != sess.physicalPath {
+			ok = false
+		}
+	}
+	if !ok {
+		return nil, fmt.Errorf("upload session not found: %s", id)
+	}
+
+	sess.mu.Lock()
+	if sess.done {
+		sess.mu.Unlock()
+		return nil, fmt.Errorf("upload session not found: %s", id)
+	}
+	return sess, nil
+}
+
+// checkStagedQuota fails when storing size bytes in place of oldSize bytes
+// would exceed the quota. staged is the part of the upload that is already
+// on disk and therefore included in the current usage.
+func (m *Manager) checkStagedQuota(virtualPath string, size, oldSize, staged int64) error {
+	if !m.quotaApplies(virtualPath) {
+		return nil
+	}
+	used, limit, err := m.quotaState(virtualPath)
+	if err != nil {
+		return fmt.Errorf("failed to calculate current usage: %w", err)
+	}
+	if used-staged+size-oldSize > limit {
+		m.recordQuotaDenial("upload", virtualPath, used, size, limit)
+		return errQuotaExceeded
+	}
+	return nil
+}
+
+// existingSize returns the size of the file at physicalPath, which a
+// completed upload replaces, and whether no file exists yet
+func existingSize(physicalPath, virtualPath string) (int64, bool, error) {
+	if err := checkNotDirectory(physicalPath, virtualPath); err != nil {
+		return 0, false, err
+	}
+	info, err := os.Stat(physicalPath)
+	if err != nil {
+		return 0, true, nil
+	}
+	return info.Size(), false, nil
+}
+
+// StartUpload opens a resumable upload of size bytes to virtualPath. The
+// checks of StreamFile run up front against the declared size, and the quota
+// is checked again on completion.
+func (m *Manager) StartUpload(sessions *UploadSessions, virtualPath string, size int64) (*UploadSession, error) {
+	if size < 0 {
+		return nil, fmt.Errorf("invalid upload size: %d", size)
+	}
+	virtualPath = m.VirtualFS.NormalizePath(path.Clean("/" + virtualPath))
+
+	physicalPath, err := m.resolvePath(virtualPath)
+	if err != nil {
+		return nil, fmt.Errorf("invalid virtual path: %w", err)
+	}
+	if !m.isPathSafe(physicalPath) || m.isMappingRoot(physicalPath) {
+		return nil, fmt.Errorf("access denied: path outside managed directory")
+	}
+	if err := m.checkCaseCollision(physicalPath, virtualPath, ""); err != nil {
+		return nil, err
+	}
+
+	oldSize, _, err := existingSize(physicalPath, virtualPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.checkFreeSpace(physicalPath, size); err != nil {
+		return nil, err
+	}
+	if err := m.checkStagedQuota(virtualPath, size, oldSize, 0); err != nil {
+		return nil, err
+	}
+
+	dir := filepath.Dir(physicalPath)
+	if err := m.checkParentDirs(dir, path.Dir(virtualPath)); err != nil {
+		return nil, err
+	}
+	if err := m.checkEntryLimit(physicalPath, virtualPath); err != nil {
+		return nil, err
+	}
+	createdDirs := m.missingDirs(dir, path.Dir(virtualPath))
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	id, err := newUploadID()
+	if err != nil {
+		return nil, err
+	}
+	tmpFile, err := os.CreateTemp(dir, resumablePattern)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file: %w", err)
+	}
+	if err := tmpFile.Close(); err != nil {
+		_ = os.Remove(tmpFile.Name())
+		return nil, fmt.Errorf("failed to create file: %w", err)
+	}
+
+	sess := &uploadSession{
+		UploadSession: UploadSession{ID: id, Path: virtualPath, Size: size},
+		physicalPath:  physicalPath,
+		tmpPath:       tmpFile.Name(),
+		createdDirs:   createdDirs,
+		owner:         m,
+	}
+	sessions.add(sess)
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	return sessions.snapshot(sess, false), nil
+}
+
+// UploadStatus returns the state of a session, so that a client can resume
+// at the reported offset after a failed chunk
+func (m *Manager) UploadStatus(sessions *UploadSessions, id string) (*UploadSession, error) {
+	sess, err := m.uploadSession(sessions, id)
+	if err != nil {
+		return nil, err
+	}
+	defer sess.mu.Unlock()
+	return sessions.snapshot(sess, false), nil
+}
+
+// AppendUpload writes a chunk starting at offset to a session. The offset
+// must equal the number of bytes received so far. Bytes written before a
+// chunk fails are kept and counted in the offset.
+func (m *Manager) AppendUpload(sessions *UploadSessions, id string, offset int64, r io.Reader) (*UploadSession, error) {
+	sess, err := m.uploadSession(sessions, id)
+	if err != nil {
+		return nil, err
+	}
+	defer sess.mu.Unlock()
+
+	if offset != sess.Offset {
+		return nil, fmt.Errorf("offset mismatch: expected %d, got %d", sess.Offset, offset)
+	}
+
+	f, err := os.OpenFile(sess.tmpPath, os.O_WRONLY, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open partial upload: %w", err)
+	}
+	defer m.trackUsage(sess.tmpPath)()
+
+	written, err := func() (int64, error) {
+		if _, err := f.Seek(offset, io.SeekStart); err != nil {
+			return 0, err
+		}
+		return io.Copy(f, io.LimitReader(r, sess.Size-offset))
+	}()
+	if cerr := f.Close(); cerr != nil && err == nil {
+		err = cerr
+	}
+	sess.Offset += written
+	info := sessions.snapshot(sess, true)
+	if err != nil {
+		return info, fmt.Errorf("failed to write chunk: %w", err)
+	}
+
+	// Anything beyond the declared size is rejected instead of truncated silently
+	if sess.Offset == sess.Size {
+		if n, _ := r.Read(make([]byte, 1)); n > 0 {
+			return info, fmt.Errorf("upload exceeds declared size of %d bytes", sess.Size)
+		}
+	}
+	return info, nil
+}
+
+// CompleteUpload moves a fully received upload into place and ends its
+// session. A failed quota check keeps the session, so that the upload can
+// be completed once space was freed.
+func (m *Manager) CompleteUpload(sessions *UploadSessions, id string) (*UploadResult, error) {
+	sess, err := m.uploadSession(sessions, id)
+	if err != nil {
+		return nil, err
+	}
+	defer sess.mu.Unlock()
+
+	if sess.Offset != sess.Size {
+		return nil, fmt.Errorf("incomplete upload: received %d of %d bytes", sess.Offset, sess.Size)
+	}
+
+	oldSize, created, err := existingSize(sess.physicalPath, sess.Path)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.checkStagedQuota(sess.Path, sess.Size, oldSize, sess.Size); err != nil {
+		return nil, err
+	}
+
+	if err := os.Chmod(sess.tmpPath, 0640); err != nil {
+		return nil, fmt.Errorf("failed to set permissions: %w", err)
+	}
+	if err := func() error {
+		defer m.trackUsage(sess.tmpPath, sess.physicalPath)()
+		return os.Rename(sess.tmpPath, sess.physicalPath)
+	}(); err != nil {
+		return nil, fmt.Errorf("failed to move upload into place: %w", err)
+	}
+	sess.done = true
+	sessions.remove(id)
+
+	return &UploadResult{
+		Path:        sess.Path,
+		Size:        sess.Size,
+		Message:     "File uploaded successfully",
+		Created:     created,
+		CreatedDirs: sess.createdDirs,
+	}, nil
+}
+
+// AbortUpload ends a session and removes its partial file
+func (m *Manager) AbortUpload(sessions *UploadSessions, id string) error {
+	sess, err := m.uploadSession(sessions, id)
+	if err != nil {
+		return err
+	}
+	defer sess.mu.Unlock()
+
+	sess.discard()
+	sessions.remove(id)
+	return nil
+}
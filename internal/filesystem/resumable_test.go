@@ -0,0 +1,147 @@
+package filesystem
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"dendrite/internal/config"
+)
+
+// partialFiles returns the partial files of resumable uploads in dir
+func partialFiles(t *testing.T, dir string) []string {
+	matches, err := filepath.Glob(filepath.Join(dir, resumablePattern))
+	require.NoError(t, err)
+	return matches
+}
+
+func TestResumableUpload(t *testing.T) {
+	tempDir := t.TempDir()
+	mgr := New(&config.Config{Directories: []config.DirMapping{{Source: tempDir, Virtual: "/test"}}})
+	sessions := NewUploadSessions(time.Hour)
+
+	session, err := mgr.StartUpload(sessions, "/test/sub/big.bin", 10)
+	require.NoError(t, err)
+	assert.Equal(t, "/test/sub/big.bin", session.Path)
+	assert.Equal(t, int64(0), session.Offset)
+	require.Len(t, partialFiles(t, filepath.Join(tempDir, "sub")), 1)
+
+	session, err = mgr.AppendUpload(sessions, session.ID, 0, strings.NewReader("01234"))
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), session.Offset)
+
+	t.Run("offset must match the received bytes", func(t *testing.T) {
+		_, err := mgr.AppendUpload(sessions, session.ID, 3, strings.NewReader("xx"))
+		assert.EqualError(t, err, "offset mismatch: expected 5, got 3")
+	})
+
+	t.Run("incomplete uploads cannot be completed", func(t *testing.T) {
+		_, err := mgr.CompleteUpload(sessions, session.ID)
+		assert.EqualError(t, err, "incomplete upload: received 5 of 10 bytes")
+	})
+
+	t.Run("status reports the offset to resume at", func(t *testing.T) {
+		status, err := mgr.UploadStatus(sessions, session.ID)
+		require.NoError(t, err)
+		assert.Equal(t, int64(5), status.Offset)
+	})
+
+	session, err = mgr.AppendUpload(sessions, session.ID, 5, strings.NewReader("56789"))
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), session.Offset)
+
+	result, err := mgr.CompleteUpload(sessions, session.ID)
+	require.NoError(t, err)
+	assert.True(t, result.Created)
+	assert.Equal(t, int64(10), result.Size)
+	assert.Equal(t, []string{"/test/sub"}, result.CreatedDirs)
+
+	content, err := os.ReadFile(filepath.Join(tempDir, "sub", "big.bin"))
+	require.NoError(t, err)
+	assert.Equal(t, "0123456789", string(content))
+	assert.Empty(t, partialFiles(t, filepath.Join(tempDir, "sub")))
+
+	_, err = mgr.UploadStatus(sessions, session.ID)
+	assert.EqualError(t, err, "upload session not found: "+session.ID)
+}
+
+func TestResumableUploadLimits(t *testing.T) {
+	tempDir := t.TempDir()
+	cfg := &config.Config{Directories: []config.DirMapping{{Source: tempDir, Virtual: "/test"}}, QuotaBytes: 100}
+	mgr := New(cfg)
+	sessions := NewUploadSessions(time.Hour)
+
+	t.Run("declared size is checked against the quota", func(t *testing.T) {
+		_, err := mgr.StartUpload(sessions, "/test/big.bin", 101)
+		assert.ErrorIs(t, err, errQuotaExceeded)
+		assert.Empty(t, partialFiles(t, tempDir))
+	})
+
+	t.Run("chunks beyond the declared size are rejected", func(t *testing.T) {
+		session, err := mgr.StartUpload(sessions, "/test/small.bin", 3)
+		require.NoError(t, err)
+		info, err := mgr.AppendUpload(sessions, session.ID, 0, strings.NewReader("abcdef"))
+		assert.EqualError(t, err, "upload exceeds declared size of 3 bytes")
+		assert.Equal(t, int64(3), info.Offset)
+		require.NoError(t, mgr.AbortUpload(sessions, session.ID))
+		assert.Empty(t, partialFiles(t, tempDir))
+	})
+
+	t.Run("quota is checked again on completion", func(t *testing.T) {
+		session, err := mgr.StartUpload(sessions, "/test/late.bin", 60)
+		require.NoError(t, err)
+		_, err = mgr.AppendUpload(sessions, session.ID, 0, strings.NewReader(strings.Repeat("x", 60)))
+		require.NoError(t, err)
+
+		// Another upload took the space in the meantime
+		require.NoError(t, os.WriteFile(filepath.Join(tempDir, "other.bin"), make([]byte, 50), 0600))
+		_, err = mgr.CompleteUpload(sessions, session.ID)
+		assert.ErrorIs(t, err, errQuotaExceeded)
+
+		// The session survives until space was freed
+		require.NoError(t, os.Remove(filepath.Join(tempDir, "other.bin")))
+		_, err = mgr.CompleteUpload(sessions, session.ID)
+		require.NoError(t, err)
+		assert.FileExists(t, filepath.Join(tempDir, "late.bin"))
+	})
+
+	t.Run("directories cannot be replaced", func(t *testing.T) {
+		require.NoError(t, os.Mkdir(filepath.Join(tempDir, "dir"), 0750))
+		_, err := mgr.StartUpload(sessions, "/test/dir", 1)
+		assert.EqualError(t, err, "a directory already exists with that name: /test/dir")
+	})
+}
+
+func TestResumableUploadSessions(t *testing.T) {
+	tempDir := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(tempDir, "alice"), 0750))
+	require.NoError(t, os.Mkdir(filepath.Join(tempDir, "bob"), 0750))
+	cfg := &config.Config{Directories: []config.DirMapping{{Source: tempDir, Virtual: "/test"}}}
+	sessions := NewUploadSessions(time.Hour)
+
+	alice := NewWithRestriction(cfg, []config.DirMapping{{Source: filepath.Join(tempDir, "alice"), Virtual: "/home"}})
+	bob := NewWithRestriction(cfg, []config.DirMapping{{Source: filepath.Join(tempDir, "bob"), Virtual: "/home"}})
+
+	session, err := alice.StartUpload(sessions, "/home/file.txt", 4)
+	require.NoError(t, err)
+
+	t.Run("other tokens cannot continue a session", func(t *testing.T) {
+		_, err := bob.AppendUpload(sessions, session.ID, 0, strings.NewReader("evil"))
+		assert.EqualError(t, err, "upload session not found: "+session.ID)
+		assert.ErrorContains(t, bob.AbortUpload(sessions, session.ID), "upload session not found")
+	})
+
+	t.Run("expired sessions are removed with their partial file", func(t *testing.T) {
+		assert.Empty(t, sessions.Expire(time.Now()))
+		assert.Equal(t, []string{session.ID}, sessions.Expire(time.Now().Add(2*time.Hour)))
+		assert.Empty(t, partialFiles(t, filepath.Join(tempDir, "alice")))
+
+		_, err := alice.AppendUpload(sessions, session.ID, 0, strings.NewReader("data"))
+		assert.EqualError(t, err, "upload session not found: "+session.ID)
+	})
+}
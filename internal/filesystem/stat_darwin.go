@@ -17,4 +17,22 @@ func getSysStatInfo(info os.FileInfo, stat *FileStatInfo) {
 		stat.AccessTime = time.Unix(sysstat.Atimespec.Sec, sysstat.Atimespec.Nsec)
 		stat.ChangeTime = time.Unix(sysstat.Ctimespec.Sec, sysstat.Ctimespec.Nsec)
 	}
-}
\ No newline at end of file
+}
+
+// getFileIdentity returns the inode and device numbers of a file. Entries
+// with equal inode and device are hard links to the same data.
+func getFileIdentity(info os.FileInfo) (inode, device uint64) {
+	if sysstat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return sysstat.Ino, uint64(sysstat.Dev) // Dev is int32 on Darwin
+	}
+	return 0, 0
+}
+
+// getAllocatedSize returns the disk space allocated to a file. st_blocks is
+// always counted in 512-byte units, independent of the filesystem block size.
+func getAllocatedSize(info os.FileInfo) (int64, bool) {
+	if sysstat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return sysstat.Blocks * 512, true
+	}
+	return 0, false
+}
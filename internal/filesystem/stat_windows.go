@@ -16,4 +16,16 @@ func getSysStatInfo(info os.FileInfo, stat *FileStatInfo) {
 	// Use modification time as a fallback for access and change times
 	stat.AccessTime = info.ModTime()
 	stat.ChangeTime = info.ModTime()
-}
\ No newline at end of file
+}
+
+// getFileIdentity returns zero on Windows, where os.FileInfo carries no
+// inode or device numbers
+func getFileIdentity(_ os.FileInfo) (inode, device uint64) {
+	return 0, 0
+}
+
+// getAllocatedSize is not available on Windows; quota accounting falls back to
+// the logical size
+func getAllocatedSize(_ os.FileInfo) (int64, bool) {
+	return 0, false
+}
@@ -0,0 +1,140 @@
+package filesystem
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// errQuotaExceeded is returned by quotaReader when a stream grows beyond the
+// remaining quota
+var errQuotaExceeded = errors.New("quota exceeded: upload would exceed storage limit")
+
+// quotaReader counts bytes read and fails once more than limit bytes were read.
+// It protects streamed uploads whose size is not known in advance.
+type quotaReader struct {
+	r     io.Reader
+	limit int64
+	read  int64
+}
+
+func (q *quotaReader) Read(p []byte) (int, error) {
+	n, err := q.r.Read(p)
+	q.read += int64(n)
+	if q.read > q.limit {
+		return n, errQuotaExceeded
+	}
+	return n, err
+}
+
+// StreamFile writes r to virtualPath without buffering the whole body. The
+// content is written to a temporary file next to the destination and renamed
+// into place once complete, so readers never observe partial files. Pass a
+// negative size when the length is unknown; the quota is then enforced while
+// streaming.
+func (m *Manager) StreamFile(virtualPath string, r io.Reader, size int64) (result *UploadResult, err error) {
+	physicalPath, err := m.resolvePath(virtualPath)
+	if err != nil {
+		return nil, fmt.Errorf("invalid virtual path: %w", err)
+	}
+
+	if !m.isPathSafe(physicalPath) {
+		return nil, fmt.Errorf("access denied: path outside managed directory")
+	}
+
+	if err := m.checkCaseCollision(physicalPath, virtualPath, ""); err != nil {
+		return nil, err
+	}
+
+	var oldSize int64
+	created := true
+	if info, err := os.Stat(physicalPath); err == nil {
+		created = false
+		if info.IsDir() {
+			return nil, fmt.Errorf("cannot overwrite directory: %s", virtualPath)
+		}
+		oldSize = info.Size()
+	}
+
+	// The upload is staged next to the old file, which is only replaced at the end
+	if err := m.checkFreeSpace(physicalPath, size); err != nil {
+		return nil, err
+	}
+
+	var quota *quotaReader
+	var used, limit int64
+	if m.quotaApplies(virtualPath) {
+		used, limit, err = m.quotaState(virtualPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to calculate current usage: %w", err)
+		}
+
+		// The replaced file is freed once the upload is renamed into place
+		remaining := limit - used + oldSize
+		if size >= 0 && size > remaining {
+			m.recordQuotaDenial("upload", virtualPath, used, size, limit)
+			return nil, errQuotaExceeded
+		}
+		quota = &quotaReader{r: r, limit: remaining}
+		r = quota
+	}
+
+	dir := filepath.Dir(physicalPath)
+	if err := m.checkParentDirs(dir, path.Dir(virtualPath)); err != nil {
+		return nil, err
+	}
+	if err := m.checkEntryLimit(physicalPath, virtualPath); err != nil {
+		return nil, err
+	}
+	createdDirs := m.missingDirs(dir, path.Dir(virtualPath))
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	tmpFile, err := os.CreateTemp(dir, ".dendrite-upload-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file: %w", err)
+	}
+	tmpPath := tmpFile.Name()
+	defer func() {
+		if err != nil {
+			_ = os.Remove(tmpPath)
+		}
+	}()
+
+	written, err := io.Copy(tmpFile, r)
+	if cerr := tmpFile.Close(); cerr != nil && err == nil {
+		err = cerr
+	}
+	if err != nil {
+		if errors.Is(err, errQuotaExceeded) {
+			m.recordQuotaDenial("upload", virtualPath, used, quota.read, limit)
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to write file: %w", err)
+	}
+
+	if size >= 0 && written != size {
+		return nil, fmt.Errorf("incomplete upload: received %d of %d bytes", written, size)
+	}
+
+	if err := os.Chmod(tmpPath, 0640); err != nil {
+		return nil, fmt.Errorf("failed to set permissions: %w", err)
+	}
+
+	defer m.trackUsage(physicalPath)()
+	if err := os.Rename(tmpPath, physicalPath); err != nil {
+		return nil, fmt.Errorf("failed to move upload into place: %w", err)
+	}
+
+	return &UploadResult{
+		Path:        m.VirtualFS.NormalizePath(virtualPath),
+		Size:        written,
+		Message:     "File uploaded successfully",
+		Created:     created,
+		CreatedDirs: createdDirs,
+	}, nil
+}
@@ -0,0 +1,88 @@
+package filesystem
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"dendrite/internal/config"
+)
+
+// tempFiles returns leftover temporary upload files in dir
+func tempFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	matches, err := filepath.Glob(filepath.Join(dir, ".dendrite-upload-*"))
+	require.NoError(t, err)
+	return matches
+}
+
+func TestStreamFile(t *testing.T) {
+	tempDir := t.TempDir()
+	mgr := New(&config.Config{
+		Directories: []config.DirMapping{
+			{Source: tempDir, Virtual: "/test"},
+		},
+		QuotaBytes: 100,
+	})
+
+	t.Run("known size", func(t *testing.T) {
+		result, err := mgr.StreamFile("/test/sub/a.bin", strings.NewReader("hello"), 5)
+		require.NoError(t, err)
+		assert.Equal(t, "/test/sub/a.bin", result.Path)
+		assert.Equal(t, int64(5), result.Size)
+
+		content, err := os.ReadFile(filepath.Join(tempDir, "sub", "a.bin"))
+		require.NoError(t, err)
+		assert.Equal(t, "hello", string(content))
+		assert.Empty(t, tempFiles(t, filepath.Join(tempDir, "sub")))
+	})
+
+	t.Run("unknown size", func(t *testing.T) {
+		result, err := mgr.StreamFile("/test/b.bin", strings.NewReader("stream"), -1)
+		require.NoError(t, err)
+		assert.Equal(t, int64(6), result.Size)
+	})
+
+	t.Run("declared size exceeds quota", func(t *testing.T) {
+		_, err := mgr.StreamFile("/test/big.bin", strings.NewReader(strings.Repeat("x", 200)), 200)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "quota exceeded")
+		assert.NoFileExists(t, filepath.Join(tempDir, "big.bin"))
+	})
+
+	t.Run("stream exceeds quota", func(t *testing.T) {
+		_, err := mgr.StreamFile("/test/big.bin", strings.NewReader(strings.Repeat("x", 200)), -1)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "quota exceeded")
+		assert.NoFileExists(t, filepath.Join(tempDir, "big.bin"))
+		assert.Empty(t, tempFiles(t, tempDir))
+	})
+
+	t.Run("short body", func(t *testing.T) {
+		_, err := mgr.StreamFile("/test/short.bin", strings.NewReader("abc"), 4)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "incomplete upload")
+		assert.NoFileExists(t, filepath.Join(tempDir, "short.bin"))
+	})
+
+	t.Run("directory target", func(t *testing.T) {
+		_, err := mgr.StreamFile("/test/sub", strings.NewReader("x"), 1)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "cannot overwrite directory")
+	})
+
+	t.Run("path outside mapping", func(t *testing.T) {
+		_, err := mgr.StreamFile("/test/../escape.bin", strings.NewReader("x"), 1)
+		assert.Error(t, err)
+	})
+
+	t.Run("replacing a file frees its size", func(t *testing.T) {
+		// 11 bytes used, replacing the 6-byte file leaves room for 95 bytes
+		_, err := mgr.StreamFile("/test/b.bin", strings.NewReader(strings.Repeat("y", 95)), 95)
+		require.NoError(t, err)
+	})
+}
@@ -0,0 +1,191 @@
+package filesystem
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// CreateTar streams an uncompressed tar archive of the specified virtual
+// paths. It accepts the same options as ZIP archives except ChangedFiles:
+// the size of a tar entry is fixed by its header, so a file that shrinks
+// while it is read aborts the archive and growth beyond the header size is
+// cut off. Modes are preserved and symbolic links are stored as links
+// without following them.
+func (m *Manager) CreateTar(w io.Writer, virtualPaths []string, opts ZipOptions) (err error) {
+	if err := ValidateExcludePatterns(opts.Exclude); err != nil {
+		return err
+	}
+	if err := ValidateZipLayout(opts.Layout); err != nil {
+		return err
+	}
+
+	tw := tar.NewWriter(w)
+	defer func() {
+		if cerr := tw.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
+
+	if opts.Deterministic {
+		sorted := make([]string, len(virtualPaths))
+		copy(sorted, virtualPaths)
+		sort.Strings(sorted)
+		virtualPaths = sorted
+	}
+
+	rootNames := zipRootNames(virtualPaths, opts.Layout)
+	entries := newZipEntries(opts.Layout)
+
+	for _, virtualPath := range virtualPaths {
+		if opts.excluded(rootNames[virtualPath]) {
+			continue
+		}
+
+		// Tar has no manifest; callers report skipped paths with CheckPaths
+		physicalPath, info, reason := m.checkArchivePath(virtualPath, os.Lstat)
+		if reason != "" {
+			continue
+		}
+
+		// Tar entries are relative; extracting absolute names is unsafe
+		name, ok := entries.claim(strings.TrimLeft(rootNames[virtualPath], "/"))
+		if !ok {
+			continue // Already part of the archive
+		}
+
+		var err error
+		if info.IsDir() {
+			err = m.addDirToTar(tw, physicalPath, name, opts, entries)
+		} else {
+			err = addToTar(tw, physicalPath, name, info, opts)
+		}
+
+		if err != nil {
+			return fmt.Errorf("failed to add %s to tar: %w", virtualPath, err)
+		}
+	}
+
+	return nil
+}
+
+// CreateTarGz streams a gzip-compressed tar archive of the specified virtual
+// paths with default options
+func (m *Manager) CreateTarGz(w io.Writer, virtualPaths []string) error {
+	return m.CreateTarGzWithOptions(w, virtualPaths, ZipOptions{})
+}
+
+// CreateTarGzWithOptions streams a tar archive like CreateTar through gzip
+func (m *Manager) CreateTarGzWithOptions(w io.Writer, virtualPaths []string, opts ZipOptions) (err error) {
+	gw := gzip.NewWriter(w)
+	defer func() {
+		if cerr := gw.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
+	return m.CreateTar(gw, virtualPaths, opts)
+}
+
+// addDirToTar recursively adds a directory to the tar archive.
+// WalkDir visits entries in lexical order, so the entry order is stable.
+func (m *Manager) addDirToTar(tw *tar.Writer, fullPath, relativePath string, opts ZipOptions,
+	entries *zipEntries) error {
+	return filepath.WalkDir(fullPath, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil // Skip files we can't access
+		}
+
+		relPath, err := filepath.Rel(fullPath, p)
+		if err != nil {
+			return err
+		}
+		tarPath := filepath.ToSlash(filepath.Join(relativePath, relPath))
+
+		if opts.excluded(tarPath) || (d.IsDir() && m.isTrashDir(p)) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		// The directory itself was claimed by the caller; entries below it
+		// can only collide with an overlapping selection
+		if p != fullPath && !entries.claimExact(tarPath) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return nil // Skip files we can't stat
+		}
+		return addToTar(tw, p, tarPath, info, opts)
+	})
+}
+
+// addToTar writes the header of a file, directory or symbolic link and, for
+// regular files, its content. Other file types are skipped.
+func addToTar(tw *tar.Writer, fullPath, name string, info os.FileInfo, opts ZipOptions) error {
+	var link string
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		target, err := os.Readlink(fullPath)
+		if err != nil {
+			return nil // Skip links we can't read
+		}
+		link = target
+	case info.IsDir(), info.Mode().IsRegular():
+	default:
+		return nil // Sockets, devices and pipes have no content to archive
+	}
+
+	header, err := tar.FileInfoHeader(info, link)
+	if err != nil {
+		return err
+	}
+	header.Name = name
+	if info.IsDir() {
+		header.Name += "/"
+	}
+	if opts.Deterministic {
+		header.ModTime = zipEpoch
+		header.AccessTime = zipEpoch
+		header.ChangeTime = zipEpoch
+		header.Uid, header.Gid = 0, 0
+		header.Uname, header.Gname = "", ""
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+
+	file, err := os.Open(fullPath) // #nosec G304 - path is validated by the caller
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := file.Close(); cerr != nil {
+			log.Printf("Error closing file %s: %v", fullPath, cerr)
+		}
+	}()
+
+	if _, err := io.CopyN(tw, file, header.Size); err != nil {
+		if errors.Is(err, io.EOF) {
+			return fmt.Errorf("%s: %w", name, errFileChanged)
+		}
+		return err
+	}
+	return nil
+}
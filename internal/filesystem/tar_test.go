@@ -0,0 +1,151 @@
+package filesystem
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"dendrite/internal/config"
+)
+
+func TestCreateTar(t *testing.T) {
+	tempDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(tempDir, "bin"), 0750))
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "bin", "run.sh"), []byte("#!/bin/sh\n"), 0750))
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "readme.txt"), []byte("hello"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "skip.tmp"), []byte("tmp"), 0600))
+	if runtime.GOOS != "windows" {
+		require.NoError(t, os.Symlink("readme.txt", filepath.Join(tempDir, "link.txt")))
+	}
+
+	mgr := New(&config.Config{Directories: []config.DirMapping{{Source: tempDir, Virtual: "/test"}}})
+
+	readTar := func(t *testing.T, opts ZipOptions) map[string]*tar.Header {
+		t.Helper()
+		var buf bytes.Buffer
+		require.NoError(t, mgr.CreateTar(&buf, []string{"/test"}, opts))
+
+		headers := make(map[string]*tar.Header)
+		contents := make(map[string]string)
+		tr := tar.NewReader(&buf)
+		for {
+			header, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+			content, err := io.ReadAll(tr)
+			require.NoError(t, err)
+			headers[header.Name] = header
+			contents[header.Name] = string(content)
+		}
+		assert.Equal(t, "hello", contents["test/readme.txt"])
+		return headers
+	}
+
+	t.Run("preserves paths and modes", func(t *testing.T) {
+		headers := readTar(t, ZipOptions{Exclude: []string{"*.tmp"}})
+
+		require.Contains(t, headers, "test/")
+		assert.Equal(t, byte(tar.TypeDir), headers["test/"].Typeflag)
+		require.Contains(t, headers, "test/bin/")
+		require.Contains(t, headers, "test/bin/run.sh")
+		assert.NotContains(t, headers, "test/skip.tmp")
+		if runtime.GOOS != "windows" {
+			assert.Equal(t, int64(0750), headers["test/bin/run.sh"].Mode&0777)
+			assert.Equal(t, int64(0600), headers["test/readme.txt"].Mode&0777)
+
+			require.Contains(t, headers, "test/link.txt")
+			assert.Equal(t, byte(tar.TypeSymlink), headers["test/link.txt"].Typeflag)
+			assert.Equal(t, "readme.txt", headers["test/link.txt"].Linkname)
+		}
+	})
+
+	t.Run("deterministic headers", func(t *testing.T) {
+		headers := readTar(t, ZipOptions{Deterministic: true})
+		assert.True(t, headers["test/readme.txt"].ModTime.Equal(zipEpoch))
+		assert.Zero(t, headers["test/readme.txt"].Uid)
+		assert.Empty(t, headers["test/readme.txt"].Uname)
+	})
+
+	t.Run("relative layout", func(t *testing.T) {
+		var buf bytes.Buffer
+		err := mgr.CreateTar(&buf, []string{"/test/readme.txt", "/test/bin"}, ZipOptions{Layout: ZipLayoutRelative})
+		require.NoError(t, err)
+
+		var names []string
+		tr := tar.NewReader(&buf)
+		for {
+			header, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+			names = append(names, header.Name)
+		}
+		assert.ElementsMatch(t, []string{"readme.txt", "bin/", "bin/run.sh"}, names)
+	})
+}
+
+func TestCreateTarGz(t *testing.T) {
+	tempDir := t.TempDir()
+	files := map[string]string{
+		"docs/readme.txt":          "hello",
+		"docs/guide/intro.md":      "intro",
+		"docs/guide/deep/notes.md": "notes",
+	}
+	modTime := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	for name, content := range files {
+		fullPath := filepath.Join(tempDir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(fullPath), 0750))
+		require.NoError(t, os.WriteFile(fullPath, []byte(content), 0640))
+		require.NoError(t, os.Chtimes(fullPath, modTime, modTime))
+	}
+
+	mgr := New(&config.Config{Directories: []config.DirMapping{{Source: tempDir, Virtual: "/test"}}})
+
+	var buf bytes.Buffer
+	require.NoError(t, mgr.CreateTarGz(&buf, []string{"/test/docs"}))
+
+	gr, err := gzip.NewReader(&buf)
+	require.NoError(t, err)
+	tr := tar.NewReader(gr)
+
+	var dirs []string
+	contents := make(map[string]string)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		if header.Typeflag == tar.TypeDir {
+			dirs = append(dirs, header.Name)
+			continue
+		}
+		content, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		contents[header.Name] = string(content)
+
+		assert.True(t, header.ModTime.Equal(modTime), header.Name)
+		if runtime.GOOS != "windows" {
+			assert.Equal(t, int64(0640), header.Mode&0777, header.Name)
+		}
+	}
+
+	assert.Equal(t, []string{"test/docs/", "test/docs/guide/", "test/docs/guide/deep/"}, dirs)
+	assert.Equal(t, map[string]string{
+		"test/docs/readme.txt":          "hello",
+		"test/docs/guide/intro.md":      "intro",
+		"test/docs/guide/deep/notes.md": "notes",
+	}, contents)
+}
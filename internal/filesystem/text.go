@@ -0,0 +1,158 @@
+package filesystem
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// MaxTextFileSize is the largest file ReadFileText converts
+const MaxTextFileSize = 10 * 1024 * 1024
+
+// Supported text encodings
+const (
+	EncodingUTF8    = "utf-8"
+	EncodingUTF16LE = "utf-16le"
+	EncodingUTF16BE = "utf-16be"
+	EncodingLatin1  = "iso-8859-1"
+)
+
+// ReadFileText reads a text file and converts it to UTF-8. With encoding
+// "auto" (or empty), the source encoding is detected from a byte order mark
+// or heuristics. It returns the converted text and the source encoding.
+func (m *Manager) ReadFileText(virtualPath, encoding string) (text, detected string, err error) {
+	physicalPath, err := m.resolvePath(virtualPath)
+	if err != nil {
+		return "", "", err
+	}
+
+	if !m.isPathSafe(physicalPath) {
+		return "", "", fmt.Errorf("access denied: path outside managed directory")
+	}
+
+	info, err := os.Stat(physicalPath)
+	if err != nil {
+		return "", "", fmt.Errorf("file not found: %s", virtualPath)
+	}
+	if info.IsDir() {
+		return "", "", fmt.Errorf("path is a directory: %s", virtualPath)
+	}
+	if info.Size() > MaxTextFileSize {
+		return "", "", fmt.Errorf("file too large for text conversion: %d bytes (limit: %d bytes)",
+			info.Size(), MaxTextFileSize)
+	}
+
+	data, err := os.ReadFile(physicalPath) //nolint:gosec // Path is validated by isPathSafe
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read file: %w", err)
+	}
+
+	encoding = strings.ToLower(encoding)
+	switch encoding {
+	case "", "auto":
+		encoding = detectEncoding(data)
+	case "utf8":
+		encoding = EncodingUTF8
+	case "latin1", "latin-1", "iso8859-1":
+		encoding = EncodingLatin1
+	case EncodingUTF8, EncodingUTF16LE, EncodingUTF16BE, EncodingLatin1:
+	default:
+		return "", "", fmt.Errorf("unsupported encoding: %s", encoding)
+	}
+
+	text, err = decodeText(data, encoding)
+	if err != nil {
+		return "", "", err
+	}
+
+	return text, encoding, nil
+}
+
+// detectEncoding guesses the encoding of data from its byte order mark,
+// UTF-8 validity and the distribution of zero bytes
+func detectEncoding(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xEF, 0xBB, 0xBF}):
+		return EncodingUTF8
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE}):
+		return EncodingUTF16LE
+	case bytes.HasPrefix(data, []byte{0xFE, 0xFF}):
+		return EncodingUTF16BE
+	}
+
+	// UTF-16 text without BOM has zero bytes in every other position for ASCII characters
+	sample := data
+	if len(sample) > 4096 {
+		sample = sample[:4096]
+	}
+	if len(sample) >= 2 && len(sample)%2 == 0 {
+		var evenZeros, oddZeros int
+		for i, b := range sample {
+			if b != 0 {
+				continue
+			}
+			if i%2 == 0 {
+				evenZeros++
+			} else {
+				oddZeros++
+			}
+		}
+		pairs := len(sample) / 2
+		if oddZeros*10 > pairs*4 && evenZeros*10 < pairs {
+			return EncodingUTF16LE
+		}
+		if evenZeros*10 > pairs*4 && oddZeros*10 < pairs {
+			return EncodingUTF16BE
+		}
+	}
+
+	if utf8.Valid(data) {
+		return EncodingUTF8
+	}
+
+	return EncodingLatin1
+}
+
+// decodeText converts data in the given encoding to a UTF-8 string
+func decodeText(data []byte, encoding string) (string, error) {
+	switch encoding {
+	case EncodingUTF16LE, EncodingUTF16BE:
+		if len(data)%2 != 0 {
+			return "", fmt.Errorf("invalid %s content: odd number of bytes", encoding)
+		}
+		units := make([]uint16, 0, len(data)/2)
+		for i := 0; i+1 < len(data); i += 2 {
+			if encoding == EncodingUTF16LE {
+				units = append(units, uint16(data[i])|uint16(data[i+1])<<8)
+			} else {
+				units = append(units, uint16(data[i])<<8|uint16(data[i+1]))
+			}
+		}
+		text := string(utf16.Decode(units))
+		text = strings.TrimPrefix(text, "\uFEFF")
+		if strings.ContainsRune(text, 0) {
+			return "", fmt.Errorf("binary file cannot be read as text")
+		}
+		return text, nil
+	case EncodingLatin1:
+		if bytes.IndexByte(data, 0) >= 0 {
+			return "", fmt.Errorf("binary file cannot be read as text")
+		}
+		runes := make([]rune, len(data))
+		for i, b := range data {
+			runes[i] = rune(b)
+		}
+		return string(runes), nil
+	default:
+		if bytes.IndexByte(data, 0) >= 0 {
+			return "", fmt.Errorf("binary file cannot be read as text")
+		}
+		if !utf8.Valid(data) {
+			return "", fmt.Errorf("invalid utf-8 content")
+		}
+		return strings.TrimPrefix(string(data), "\uFEFF"), nil
+	}
+}
@@ -0,0 +1,87 @@
+package filesystem
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"dendrite/internal/config"
+)
+
+// encodeUTF16LE encodes text as UTF-16LE with an optional byte order mark
+func encodeUTF16LE(text string, bom bool) []byte {
+	var data []byte
+	if bom {
+		data = append(data, 0xFF, 0xFE)
+	}
+	for _, unit := range utf16.Encode([]rune(text)) {
+		data = append(data, byte(unit), byte(unit>>8))
+	}
+	return data
+}
+
+func TestReadFileText(t *testing.T) {
+	tempDir := t.TempDir()
+	mgr := New(&config.Config{
+		Directories: []config.DirMapping{
+			{Source: tempDir, Virtual: "/test"},
+		},
+	})
+
+	files := map[string][]byte{
+		"utf16le.txt":       encodeUTF16LE("Grüße aus Köln", true),
+		"utf16le-nobom.txt": encodeUTF16LE("plain ascii text", false),
+		"utf16be.txt":       {0xFE, 0xFF, 0x00, 'h', 0x00, 'i'},
+		"latin1.txt":        {'G', 'r', 0xFC, 0xDF, 'e'},
+		"utf8.txt":          []byte("Grüße"),
+		"utf8-bom.txt":      append([]byte{0xEF, 0xBB, 0xBF}, []byte("bom")...),
+		"binary.bin":        {0x89, 'P', 'N', 'G', 0x00, 0x00, 0x01},
+	}
+	for name, data := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(tempDir, name), data, 0600))
+	}
+
+	tests := []struct {
+		name         string
+		file         string
+		encoding     string
+		wantText     string
+		wantEncoding string
+	}{
+		{"UTF-16LE with BOM", "utf16le.txt", "auto", "Grüße aus Köln", EncodingUTF16LE},
+		{"UTF-16LE without BOM", "utf16le-nobom.txt", "auto", "plain ascii text", EncodingUTF16LE},
+		{"UTF-16BE with BOM", "utf16be.txt", "", "hi", EncodingUTF16BE},
+		{"Latin-1", "latin1.txt", "auto", "Grüße", EncodingLatin1},
+		{"UTF-8", "utf8.txt", "auto", "Grüße", EncodingUTF8},
+		{"UTF-8 with BOM", "utf8-bom.txt", "auto", "bom", EncodingUTF8},
+		{"explicit Latin-1", "utf8.txt", "latin1", "GrÃ¼Ã\u009fe", EncodingLatin1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, encoding, err := mgr.ReadFileText("/test/"+tt.file, tt.encoding)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantText, text)
+			assert.Equal(t, tt.wantEncoding, encoding)
+		})
+	}
+
+	t.Run("binary file is rejected", func(t *testing.T) {
+		_, _, err := mgr.ReadFileText("/test/binary.bin", "auto")
+		assert.EqualError(t, err, "binary file cannot be read as text")
+	})
+
+	t.Run("unsupported encoding", func(t *testing.T) {
+		_, _, err := mgr.ReadFileText("/test/utf8.txt", "ebcdic")
+		assert.EqualError(t, err, "unsupported encoding: ebcdic")
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		_, _, err := mgr.ReadFileText("/test/missing.txt", "auto")
+		assert.EqualError(t, err, "file not found: /test/missing.txt")
+	})
+}
@@ -0,0 +1,366 @@
+package filesystem
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// trashManifestExt is the extension of the manifest stored next to every
+// trashed item, which is named after its ID
+const trashManifestExt = ".json"
+
+// TrashItem describes a file or directory in the trash
+type TrashItem struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// OriginalPath is the virtual path the item is restored to
+	OriginalPath string    `json:"originalPath"`
+	DeletedAt    time.Time `json:"deletedAt"`
+	IsDir        bool      `json:"isDir"`
+	Size         int64     `json:"size"`
+}
+
+// trashManifest is stored as <id>.json next to a trashed item
+type trashManifest struct {
+	// Path is the virtual path the item was deleted from. Tokens may map the
+	// same directory to other virtual paths, so restoring uses RelativePath.
+	Path string `json:"path"`
+	// RelativePath is the slash-separated location below the mapped directory
+	RelativePath string    `json:"relativePath"`
+	DeletedAt    time.Time `json:"deletedAt"`
+	IsDir        bool      `json:"isDir"`
+	Size         int64     `json:"size"`
+}
+
+// trashEnabled reports whether deletes move files into the trash
+func (m *Manager) trashEnabled() bool {
+	return m.Config.Main.TrashDir != ""
+}
+
+// trashRoots returns the trash directory of every mapping of the manager
+// keyed by the mapping's physical directory
+func (m *Manager) trashRoots() map[string]string {
+	roots := make(map[string]string, len(m.Directories))
+	if !m.trashEnabled() {
+		return roots
+	}
+	for _, dir := range m.Directories {
+		source := filepath.Clean(dir.Source)
+		roots[source] = filepath.Join(source, m.Config.Main.TrashDir)
+	}
+	return roots
+}
+
+// isTrashDir reports whether physicalPath is the trash directory of one of
+// the manager's mappings. Listings, archives and copies leave it out.
+func (m *Manager) isTrashDir(physicalPath string) bool {
+	if !m.trashEnabled() {
+		return false
+	}
+	physicalPath = filepath.Clean(physicalPath)
+	for _, trashDir := range m.trashRoots() {
+		if physicalPath == trashDir {
+			return true
+		}
+	}
+	return false
+}
+
+// inTrash reports whether physicalPath is a trash directory or lies within
+// one, which is only accessible through the trash operations
+func (m *Manager) inTrash(physicalPath string) bool {
+	if !m.trashEnabled() {
+		return false
+	}
+	physicalPath = filepath.Clean(physicalPath)
+	for _, trashDir := range m.trashRoots() {
+		if physicalPath == trashDir || strings.HasPrefix(physicalPath, trashDir+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// trashSource returns the mapped directory a physical path belongs to. With
+// nested mappings the innermost one is used.
+func (m *Manager) trashSource(physicalPath string) (string, bool) {
+	var source string
+	for root := range m.trashRoots() {
+		if (physicalPath == root || strings.HasPrefix(physicalPath, root+string(filepath.Separator))) &&
+			len(root) > len(source) {
+			source = root
+		}
+	}
+	return source, source != ""
+}
+
+// newTrashID returns a random ID for a trashed item
+func newTrashID() (string, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("failed to create trash ID: %w", err)
+	}
+	return hex.EncodeToString(id), nil
+}
+
+// moveToTrash moves a file or directory into the trash of its mapping and
+// records where it came from. Missing paths are ignored like by DeleteFile.
+func (m *Manager) moveToTrash(virtualPath, physicalPath string) error {
+	physicalPath = filepath.Clean(physicalPath)
+	info, err := os.Lstat(physicalPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	source, ok := m.trashSource(physicalPath)
+	if !ok {
+		return fmt.Errorf("access denied: path outside managed directory")
+	}
+	if physicalPath == source {
+		return fmt.Errorf("access denied: cannot delete a mapped directory")
+	}
+	relativePath, err := filepath.Rel(source, physicalPath)
+	if err != nil {
+		return err
+	}
+
+	id, err := newTrashID()
+	if err != nil {
+		return err
+	}
+	trashDir := m.trashRoots()[source]
+	if err := os.MkdirAll(trashDir, 0750); err != nil {
+		return fmt.Errorf("failed to create trash directory: %w", err)
+	}
+
+	manifest := trashManifest{
+		Path:         path.Clean("/" + virtualPath),
+		RelativePath: filepath.ToSlash(relativePath),
+		DeletedAt:    time.Now().UTC(),
+		IsDir:        info.IsDir(),
+		Size:         info.Size(),
+	}
+	if info.IsDir() {
+		manifest.Size, _ = m.calculateDirectorySize(physicalPath)
+	}
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	manifestPath := filepath.Join(trashDir, id+trashManifestExt)
+	if err := os.WriteFile(manifestPath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write trash manifest: %w", err)
+	}
+
+	itemPath := filepath.Join(trashDir, id)
+	defer m.trackUsage(physicalPath, itemPath)()
+	if err := os.Rename(physicalPath, itemPath); err != nil {
+		_ = os.Remove(manifestPath)
+		return fmt.Errorf("failed to move to trash: %w", err)
+	}
+	return nil
+}
+
+// trashEntry is an item found in one of the trash directories
+type trashEntry struct {
+	id       string
+	source   string
+	trashDir string
+	manifest trashManifest
+}
+
+// itemPath returns the physical path of the trashed item
+func (e trashEntry) itemPath() string {
+	return filepath.Join(e.trashDir, e.id)
+}
+
+// manifestPath returns the physical path of the item's manifest
+func (e trashEntry) manifestPath() string {
+	return filepath.Join(e.trashDir, e.id+trashManifestExt)
+}
+
+// originalPhysicalPath returns the physical path the item was deleted from
+func (e trashEntry) originalPhysicalPath() string {
+	return filepath.Join(e.source, filepath.FromSlash(e.manifest.RelativePath))
+}
+
+// trashEntries returns the items in the trash of every mapping. Items
+// without a readable manifest or whose manifest points outside the mapping
+// are left out.
+func (m *Manager) trashEntries() []trashEntry {
+	var entries []trashEntry
+	for source, trashDir := range m.trashRoots() {
+		files, err := os.ReadDir(trashDir)
+		if err != nil {
+			continue // No trash yet
+		}
+		for _, file := range files {
+			id, ok := strings.CutSuffix(file.Name(), trashManifestExt)
+			if !ok || file.IsDir() {
+				continue
+			}
+			entry, err := readTrashEntry(source, trashDir, id)
+			if err != nil {
+				continue
+			}
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// readTrashEntry loads the manifest of a trashed item and checks that the
+// item and its original location are usable
+func readTrashEntry(source, trashDir, id string) (trashEntry, error) {
+	entry := trashEntry{id: id, source: source, trashDir: trashDir}
+	data, err := os.ReadFile(entry.manifestPath())
+	if err != nil {
+		return entry, err
+	}
+	if err := json.Unmarshal(data, &entry.manifest); err != nil {
+		return entry, err
+	}
+	if _, err := os.Lstat(entry.itemPath()); err != nil {
+		return entry, err
+	}
+	relativePath := filepath.FromSlash(entry.manifest.RelativePath)
+	if !filepath.IsLocal(relativePath) {
+		return entry, fmt.Errorf("invalid trash manifest for %s", id)
+	}
+	return entry, nil
+}
+
+// findTrashEntry looks up a trashed item by its ID
+func (m *Manager) findTrashEntry(id string) (trashEntry, error) {
+	if !m.trashEnabled() {
+		return trashEntry{}, fmt.Errorf("trash is disabled")
+	}
+	if _, err := hex.DecodeString(id); err != nil || id == "" {
+		return trashEntry{}, fmt.Errorf("trash item not found: %s", id)
+	}
+	for source, trashDir := range m.trashRoots() {
+		if entry, err := readTrashEntry(source, trashDir, id); err == nil {
+			return entry, nil
+		}
+	}
+	return trashEntry{}, fmt.Errorf("trash item not found: %s", id)
+}
+
+// ListTrash returns the items in the trash of the manager's mappings, most
+// recently deleted first
+func (m *Manager) ListTrash() ([]TrashItem, error) {
+	if !m.trashEnabled() {
+		return nil, fmt.Errorf("trash is disabled")
+	}
+
+	items := make([]TrashItem, 0)
+	for _, entry := range m.trashEntries() {
+		originalPath, ok := m.VirtualFS.GetVirtualPath(entry.originalPhysicalPath())
+		if !ok {
+			originalPath = entry.manifest.Path
+		}
+		items = append(items, TrashItem{
+			ID:           entry.id,
+			Name:         path.Base(entry.manifest.RelativePath),
+			OriginalPath: originalPath,
+			DeletedAt:    entry.manifest.DeletedAt,
+			IsDir:        entry.manifest.IsDir,
+			Size:         entry.manifest.Size,
+		})
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if !items[i].DeletedAt.Equal(items[j].DeletedAt) {
+			return items[i].DeletedAt.After(items[j].DeletedAt)
+		}
+		return items[i].ID < items[j].ID
+	})
+	return items, nil
+}
+
+// RestoreTrash moves a trashed item back to its original location and
+// returns the virtual path it was restored to. An item existing there is
+// handled according to the overwrite policy, OverwriteError by default.
+func (m *Manager) RestoreTrash(id string, opts TransferOptions) (string, error) {
+	policy, err := ParseOverwritePolicy(opts.Overwrite)
+	if err != nil {
+		return "", err
+	}
+	entry, err := m.findTrashEntry(id)
+	if err != nil {
+		return "", err
+	}
+
+	destPhysicalPath := entry.originalPhysicalPath()
+	virtualDestPath, ok := m.VirtualFS.GetVirtualPath(destPhysicalPath)
+	if !ok || !m.isPathSafe(destPhysicalPath) || m.inTrash(destPhysicalPath) {
+		return "", fmt.Errorf("access denied: path outside managed directory")
+	}
+
+	virtualDestPath, destPhysicalPath, err = m.applyOverwritePolicy(policy, virtualDestPath, destPhysicalPath,
+		entry.itemPath(), entry.manifest.IsDir)
+	if err != nil {
+		return "", err
+	}
+	if err := m.prepareDestination(virtualDestPath, destPhysicalPath); err != nil {
+		return "", err
+	}
+	if policy == OverwriteReplace {
+		// The replaced item takes the place of the restored one in the trash
+		if err := m.moveToTrash(virtualDestPath, destPhysicalPath); err != nil {
+			return "", err
+		}
+	}
+
+	defer m.trackUsage(entry.itemPath(), destPhysicalPath)()
+	if err := os.Rename(entry.itemPath(), destPhysicalPath); err != nil {
+		return "", fmt.Errorf("failed to restore from trash: %w", err)
+	}
+	_ = os.Remove(entry.manifestPath())
+	return virtualDestPath, nil
+}
+
+// PurgeTrash permanently removes one item from the trash
+func (m *Manager) PurgeTrash(id string) error {
+	entry, err := m.findTrashEntry(id)
+	if err != nil {
+		return err
+	}
+	return m.purgeTrashEntry(entry)
+}
+
+// EmptyTrash permanently removes every item from the trash of the manager's
+// mappings and returns the number of removed items
+func (m *Manager) EmptyTrash() (int, error) {
+	if !m.trashEnabled() {
+		return 0, fmt.Errorf("trash is disabled")
+	}
+	removed := 0
+	for _, entry := range m.trashEntries() {
+		if err := m.purgeTrashEntry(entry); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// purgeTrashEntry removes a trashed item and then its manifest, so that an
+// interrupted removal stays listed and can be retried
+func (m *Manager) purgeTrashEntry(entry trashEntry) error {
+	defer m.trackUsage(entry.itemPath())()
+	if err := os.RemoveAll(entry.itemPath()); err != nil {
+		return fmt.Errorf("failed to remove %s from trash: %w", entry.id, err)
+	}
+	return os.Remove(entry.manifestPath())
+}
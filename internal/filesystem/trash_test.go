@@ -0,0 +1,183 @@
+package filesystem
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"dendrite/internal/config"
+)
+
+func setupTrash(t *testing.T) (*Manager, string) {
+	t.Helper()
+	tmpDir := t.TempDir()
+	for name, content := range map[string]string{"a.txt": "aaaa", "docs/b.txt": "bb", "docs/sub/c.txt": "c"} {
+		p := filepath.Join(tmpDir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0750))
+		require.NoError(t, os.WriteFile(p, []byte(content), 0600))
+	}
+	cfg := &config.Config{Directories: []config.DirMapping{{Source: tmpDir, Virtual: "/test"}}}
+	cfg.Main.TrashDir = ".trash"
+	return New(cfg), tmpDir
+}
+
+func TestTrashDeleteListRestore(t *testing.T) {
+	m, tmpDir := setupTrash(t)
+
+	require.NoError(t, m.DeleteFile("/test/a.txt"))
+	require.NoError(t, m.DeleteFile("/test/docs"))
+	assert.NoFileExists(t, filepath.Join(tmpDir, "a.txt"))
+	assert.NoDirExists(t, filepath.Join(tmpDir, "docs"))
+
+	files, err := m.ListFiles("/test")
+	require.NoError(t, err)
+	assert.Empty(t, files, "the trash directory is hidden")
+
+	items, err := m.ListTrash()
+	require.NoError(t, err)
+	require.Len(t, items, 2)
+	byPath := map[string]TrashItem{}
+	for _, item := range items {
+		byPath[item.OriginalPath] = item
+		assert.False(t, item.DeletedAt.IsZero())
+	}
+	assert.Equal(t, TrashItem{ID: byPath["/test/a.txt"].ID, Name: "a.txt", OriginalPath: "/test/a.txt",
+		DeletedAt: byPath["/test/a.txt"].DeletedAt, Size: 4}, byPath["/test/a.txt"])
+	assert.True(t, byPath["/test/docs"].IsDir)
+	assert.Equal(t, int64(3), byPath["/test/docs"].Size)
+
+	restored, err := m.RestoreTrash(byPath["/test/docs"].ID, TransferOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "/test/docs", restored)
+	assert.FileExists(t, filepath.Join(tmpDir, "docs", "sub", "c.txt"))
+
+	items, err = m.ListTrash()
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	assert.Equal(t, "/test/a.txt", items[0].OriginalPath)
+
+	_, err = m.RestoreTrash(byPath["/test/docs"].ID, TransferOptions{})
+	assert.ErrorContains(t, err, "trash item not found")
+}
+
+func TestTrashRestoreConflicts(t *testing.T) {
+	m, tmpDir := setupTrash(t)
+	read := func(name string) string {
+		content, err := os.ReadFile(filepath.Join(tmpDir, name))
+		require.NoError(t, err)
+		return string(content)
+	}
+	trashed := func() string {
+		require.NoError(t, m.DeleteFile("/test/a.txt"))
+		require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "a.txt"), []byte("new"), 0600))
+		items, err := m.ListTrash()
+		require.NoError(t, err)
+		return items[0].ID
+	}
+
+	id := trashed()
+	_, err := m.RestoreTrash(id, TransferOptions{})
+	assert.ErrorContains(t, err, "already exists: /test/a.txt")
+
+	restored, err := m.RestoreTrash(id, TransferOptions{Overwrite: OverwriteRename})
+	require.NoError(t, err)
+	assert.Equal(t, "/test/a (1).txt", restored)
+	assert.Equal(t, "aaaa", read("a (1).txt"))
+	assert.Equal(t, "new", read("a.txt"))
+
+	require.NoError(t, os.Rename(filepath.Join(tmpDir, "a (1).txt"), filepath.Join(tmpDir, "old.txt")))
+	require.NoError(t, m.DeleteFile("/test/old.txt"))
+	items, err := m.ListTrash()
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "old.txt"), []byte("newer"), 0600))
+	restored, err = m.RestoreTrash(items[0].ID, TransferOptions{Overwrite: OverwriteReplace})
+	require.NoError(t, err)
+	assert.Equal(t, "/test/old.txt", restored)
+	assert.Equal(t, "aaaa", read("old.txt"))
+
+	items, err = m.ListTrash()
+	require.NoError(t, err)
+	require.Len(t, items, 1, "the replaced file is trashed")
+	assert.Equal(t, int64(5), items[0].Size)
+}
+
+func TestTrashPurge(t *testing.T) {
+	m, tmpDir := setupTrash(t)
+	require.NoError(t, m.DeleteFile("/test/a.txt"))
+	require.NoError(t, m.DeleteFile("/test/docs/b.txt"))
+	require.NoError(t, m.DeleteFile("/test/docs/sub"))
+
+	items, err := m.ListTrash()
+	require.NoError(t, err)
+	require.Len(t, items, 3)
+
+	require.NoError(t, m.PurgeTrash(items[0].ID))
+	assert.ErrorContains(t, m.PurgeTrash(items[0].ID), "trash item not found")
+	assert.ErrorContains(t, m.PurgeTrash("../a.txt"), "trash item not found")
+
+	removed, err := m.EmptyTrash()
+	require.NoError(t, err)
+	assert.Equal(t, 2, removed)
+
+	items, err = m.ListTrash()
+	require.NoError(t, err)
+	assert.Empty(t, items)
+	entries, err := os.ReadDir(filepath.Join(tmpDir, ".trash"))
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestTrashQuota(t *testing.T) {
+	m, _ := setupTrash(t)
+	before, err := m.GetQuotaInfo()
+	require.NoError(t, err)
+
+	require.NoError(t, m.DeleteFile("/test/docs"))
+	after, err := m.GetQuotaInfo()
+	require.NoError(t, err)
+	// The manifest of the trashed directory counts as well
+	assert.GreaterOrEqual(t, after.Used, before.Used, "trashed bytes count against the quota")
+
+	_, err = m.EmptyTrash()
+	require.NoError(t, err)
+	after, err = m.GetQuotaInfo()
+	require.NoError(t, err)
+	assert.Equal(t, before.Used-3, after.Used)
+}
+
+func TestTrashAccess(t *testing.T) {
+	m, tmpDir := setupTrash(t)
+	require.NoError(t, m.DeleteFile("/test/a.txt"))
+
+	_, err := m.ListFiles("/test/.trash")
+	assert.ErrorContains(t, err, "access denied")
+	assert.ErrorContains(t, m.DeleteFile("/test/.trash"), "access denied")
+	_, err = m.CopyFileWithOptions("/test/.trash", "/test/copy", TransferOptions{})
+	assert.ErrorContains(t, err, "access denied")
+	assert.ErrorContains(t, m.DeleteFile("/test"), "cannot delete a mapped directory")
+
+	require.NoError(t, m.CopyFile("/test/docs", "/test/docs2"))
+	require.NoError(t, os.MkdirAll(filepath.Join(tmpDir, "empty"), 0750))
+	dirs, err := m.FindEmptyDirs("/test")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/test/empty"}, dirs)
+}
+
+func TestTrashDisabled(t *testing.T) {
+	m, tmpDir := setupTrash(t)
+	m.Config.Main.TrashDir = ""
+
+	require.NoError(t, m.DeleteFile("/test/a.txt"))
+	assert.NoFileExists(t, filepath.Join(tmpDir, "a.txt"))
+	assert.NoDirExists(t, filepath.Join(tmpDir, ".trash"))
+
+	_, err := m.ListTrash()
+	assert.ErrorContains(t, err, "trash is disabled")
+	_, err = m.RestoreTrash("00", TransferOptions{})
+	assert.ErrorContains(t, err, "trash is disabled")
+}
@@ -0,0 +1,131 @@
+package filesystem
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Tree hash modes
+const (
+	// TreeHashContent hashes the content of every file
+	TreeHashContent = "content"
+	// TreeHashMetadata hashes size and modification time only, which is much
+	// faster but only comparable between trees with preserved timestamps
+	TreeHashMetadata = "metadata"
+)
+
+// TreeHash computes a Merkle-style SHA-256 digest of the directory at the
+// virtual path. Every directory hashes the sorted list of its entries, each
+// given by type, name and the hash of the entry, so the result only depends
+// on names and contents and not on where the tree is located.
+func (m *Manager) TreeHash(virtualPath, mode string) (string, error) {
+	if mode == "" {
+		mode = TreeHashContent
+	}
+	if mode != TreeHashContent && mode != TreeHashMetadata {
+		return "", fmt.Errorf("invalid hash mode: %s (expected content or metadata)", mode)
+	}
+
+	physicalPath, err := m.resolvePath(virtualPath)
+	if err != nil {
+		return "", err
+	}
+
+	if !m.isPathSafe(physicalPath) {
+		return "", fmt.Errorf("access denied: path outside managed directory")
+	}
+
+	info, err := os.Stat(physicalPath)
+	if err != nil {
+		return "", fmt.Errorf("directory not found: %s", virtualPath)
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("not a directory: %s", virtualPath)
+	}
+
+	sum, err := hashDir(physicalPath, mode)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(sum), nil
+}
+
+// hashDir returns the hash of a directory from the hashes of its entries
+func hashDir(dir, mode string) ([]byte, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory: %w", err)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+
+	h := sha256.New()
+	for _, entry := range entries {
+		full := filepath.Join(dir, entry.Name())
+
+		var kind string
+		var sum []byte
+		switch {
+		case entry.Type()&os.ModeSymlink != 0:
+			// Links are hashed by their target and never followed
+			target, err := os.Readlink(full)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read link: %w", err)
+			}
+			kind = "l"
+			sum = sha256Sum([]byte(target))
+		case entry.IsDir():
+			kind = "d"
+			sum, err = hashDir(full, mode)
+		case entry.Type().IsRegular():
+			kind = "f"
+			sum, err = hashFile(full, mode)
+		default:
+			// Sockets, devices and pipes have no comparable content
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// The name is length-prefixed so that no two entry lists collide
+		_, _ = fmt.Fprintf(h, "%s %d:%s %x\n", kind, len(entry.Name()), entry.Name(), sum)
+	}
+	return h.Sum(nil), nil
+}
+
+// hashFile returns the hash of a file's content or metadata
+func hashFile(file, mode string) ([]byte, error) {
+	if mode == TreeHashMetadata {
+		info, err := os.Stat(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat file: %w", err)
+		}
+		return sha256Sum(fmt.Appendf(nil, "%d %d", info.Size(), info.ModTime().UnixNano())), nil
+	}
+
+	f, err := os.Open(file) // #nosec G304 - path is below a validated directory
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	return h.Sum(nil), nil
+}
+
+func sha256Sum(data []byte) []byte {
+	sum := sha256.Sum256(data)
+	return sum[:]
+}
@@ -0,0 +1,83 @@
+package filesystem
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"dendrite/internal/config"
+)
+
+func TestTreeHash(t *testing.T) {
+	files := map[string]string{
+		"a.txt":         "alpha",
+		"dir/b.txt":     "beta",
+		"dir/sub/c.txt": "gamma",
+	}
+	modTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	createTree := func(root string) {
+		for name, content := range files {
+			fullPath := filepath.Join(root, name)
+			require.NoError(t, os.MkdirAll(filepath.Dir(fullPath), 0750))
+			require.NoError(t, os.WriteFile(fullPath, []byte(content), 0600))
+			require.NoError(t, os.Chtimes(fullPath, modTime, modTime))
+		}
+		require.NoError(t, os.Mkdir(filepath.Join(root, "empty"), 0750))
+	}
+
+	left, right := t.TempDir(), t.TempDir()
+	createTree(left)
+	createTree(right)
+
+	mgr := New(&config.Config{
+		Directories: []config.DirMapping{
+			{Source: left, Virtual: "/left"},
+			{Source: right, Virtual: "/right"},
+		},
+	})
+
+	hash := func(virtualPath, mode string) string {
+		h, err := mgr.TreeHash(virtualPath, mode)
+		require.NoError(t, err)
+		return h
+	}
+
+	for _, mode := range []string{TreeHashContent, TreeHashMetadata} {
+		assert.Equal(t, hash("/left", mode), hash("/right", mode), mode)
+		assert.Len(t, hash("/left", mode), 64)
+	}
+	assert.NotEqual(t, hash("/left", TreeHashContent), hash("/left", TreeHashMetadata))
+
+	t.Run("single byte change", func(t *testing.T) {
+		before := hash("/right", TreeHashContent)
+		target := filepath.Join(right, "dir", "sub", "c.txt")
+		require.NoError(t, os.WriteFile(target, []byte("gammA"), 0600))
+		require.NoError(t, os.Chtimes(target, modTime, modTime))
+
+		assert.NotEqual(t, before, hash("/right", TreeHashContent))
+		// Same size and timestamp, so the metadata hash cannot tell
+		assert.Equal(t, hash("/left", TreeHashMetadata), hash("/right", TreeHashMetadata))
+	})
+
+	t.Run("renamed entry", func(t *testing.T) {
+		before := hash("/left", TreeHashContent)
+		require.NoError(t, os.Rename(filepath.Join(left, "empty"), filepath.Join(left, "empty2")))
+		assert.NotEqual(t, before, hash("/left", TreeHashContent))
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		_, err := mgr.TreeHash("/left", "sha1")
+		assert.ErrorContains(t, err, "invalid hash mode")
+
+		_, err = mgr.TreeHash("/left/a.txt", "")
+		assert.ErrorContains(t, err, "not a directory")
+
+		_, err = mgr.TreeHash("/left/missing", "")
+		assert.ErrorContains(t, err, "not found")
+	})
+}
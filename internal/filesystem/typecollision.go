@@ -0,0 +1,35 @@
+package filesystem
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// checkNotDirectory fails when a file would be written to a path taken by a
+// directory, which the OS reports with platform-dependent errors
+func checkNotDirectory(physicalPath, virtualPath string) error {
+	if info, err := os.Stat(physicalPath); err == nil && info.IsDir() {
+		return fmt.Errorf("a directory already exists with that name: %s", virtualPath)
+	}
+	return nil
+}
+
+// checkParentDirs fails when the closest existing ancestor of physicalDir is
+// a file, so that MkdirAll could not create the missing directories.
+// virtualDir is the virtual path of physicalDir; both are walked upwards in
+// lockstep like in missingDirs.
+func (m *Manager) checkParentDirs(physicalDir, virtualDir string) error {
+	for virtualDir != "/" && virtualDir != "." {
+		if info, err := os.Stat(physicalDir); err == nil {
+			if !info.IsDir() {
+				return fmt.Errorf("a file already exists with that name: %s", m.VirtualFS.NormalizePath(virtualDir))
+			}
+			return nil
+		}
+		physicalDir = filepath.Dir(physicalDir)
+		virtualDir = path.Dir(virtualDir)
+	}
+	return nil
+}
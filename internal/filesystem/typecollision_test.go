@@ -0,0 +1,57 @@
+package filesystem
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"dendrite/internal/config"
+)
+
+func TestTypeCollisions(t *testing.T) {
+	tempDir := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(tempDir, "reports"), 0750))
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "notes.txt"), []byte("notes"), 0600))
+
+	mgr := New(&config.Config{Directories: []config.DirMapping{{Source: tempDir, Virtual: "/test"}}})
+
+	t.Run("uploading a file over a directory", func(t *testing.T) {
+		_, err := mgr.UploadFile("/test", "reports", strings.NewReader("data"), 4)
+		assert.EqualError(t, err, "a directory already exists with that name: /test/reports")
+		assert.DirExists(t, filepath.Join(tempDir, "reports"))
+	})
+
+	t.Run("uploading below a file", func(t *testing.T) {
+		_, err := mgr.UploadFile("/test/notes.txt/sub", "file.txt", strings.NewReader("data"), 4)
+		assert.EqualError(t, err, "a file already exists with that name: /test/notes.txt")
+
+		_, err = mgr.StreamFile("/test/notes.txt/file.txt", strings.NewReader("data"), 4)
+		assert.EqualError(t, err, "a file already exists with that name: /test/notes.txt")
+	})
+
+	t.Run("creating a folder over a file", func(t *testing.T) {
+		err := mgr.CreateFolder("/test/notes.txt")
+		assert.EqualError(t, err, "a file already exists with that name: /test/notes.txt")
+		_, err = mgr.EnsureFolder("/test/notes.txt")
+		assert.EqualError(t, err, "a file already exists with that name: /test/notes.txt")
+	})
+
+	t.Run("creating a folder below a file", func(t *testing.T) {
+		err := mgr.CreateFolder("/test/notes.txt/a/b")
+		assert.EqualError(t, err, "a file already exists with that name: /test/notes.txt")
+		_, err = mgr.EnsureFolder("/test/notes.txt/a")
+		assert.EqualError(t, err, "a file already exists with that name: /test/notes.txt")
+
+		content, err := os.ReadFile(filepath.Join(tempDir, "notes.txt"))
+		require.NoError(t, err)
+		assert.Equal(t, "notes", string(content))
+	})
+
+	t.Run("existing directories are still reported as such", func(t *testing.T) {
+		assert.EqualError(t, mgr.CreateFolder("/test/reports"), "directory already exists")
+	})
+}
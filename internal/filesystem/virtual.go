@@ -3,6 +3,7 @@ package filesystem
 
 import (
 	"fmt"
+	"os"
 	"path"
 	"path/filepath"
 	"sort"
@@ -31,6 +32,17 @@ func NewVirtualFS(dirs []config.DirMapping) *VirtualFS {
 	}
 }
 
+// flattenSingleRoot maps a lone directory to "/" when flatten_single_root is
+// enabled, so that its contents appear at the root and paths omit the prefix
+func flattenSingleRoot(cfg *config.Config, dirs []config.DirMapping) []config.DirMapping {
+	if !cfg.Main.FlattenSingleRoot || len(dirs) != 1 || dirs[0].Virtual == "/" {
+		return dirs
+	}
+	flat := dirs[0]
+	flat.Virtual = "/"
+	return []config.DirMapping{flat}
+}
+
 // ResolvePath converts a virtual path to a physical path
 // Returns empty string if no mapping found
 func (vfs *VirtualFS) ResolvePath(virtualPath string) (physicalPath string, found bool) {
@@ -56,7 +68,7 @@ func (vfs *VirtualFS) ResolvePath(virtualPath string) (physicalPath string, foun
 			if relativePath == "" {
 				return dir.Source, true
 			}
-			return filepath.Join(dir.Source, relativePath), true
+			return joinMappedPath(dir, relativePath), true
 		}
 		
 		if virtualPath == dir.Virtual || strings.HasPrefix(virtualPath, dir.Virtual+"/") {
@@ -67,13 +79,50 @@ func (vfs *VirtualFS) ResolvePath(virtualPath string) (physicalPath string, foun
 			if relativePath == "" {
 				return dir.Source, true
 			}
-			return filepath.Join(dir.Source, relativePath), true
+			return joinMappedPath(dir, relativePath), true
 		}
 	}
 
 	return "", false
 }
 
+// joinMappedPath joins a relative virtual path to the source of its mapping,
+// applying the mapping's path normalization
+func joinMappedPath(dir config.DirMapping, relativePath string) string {
+	if !dir.LowercasePaths {
+		return filepath.Join(dir.Source, relativePath)
+	}
+
+	physicalPath := dir.Source
+	for _, name := range strings.Split(strings.ToLower(relativePath), "/") {
+		physicalPath = filepath.Join(physicalPath, matchNameFold(physicalPath, name))
+	}
+	return physicalPath
+}
+
+// matchNameFold returns the entry of dir whose name equals name ignoring case.
+// An exact match wins; name itself is returned when nothing matches, so that
+// new files are created in lower case.
+func matchNameFold(dir, name string) string {
+	if name == "." || name == ".." {
+		return name
+	}
+	if _, err := os.Lstat(filepath.Join(dir, name)); err == nil {
+		return name
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return name
+	}
+	for _, entry := range entries {
+		if strings.ToLower(entry.Name()) == name {
+			return entry.Name()
+		}
+	}
+	return name
+}
+
 // GetVirtualPath converts a physical path back to a virtual path
 func (vfs *VirtualFS) GetVirtualPath(physicalPath string) (virtualPath string, found bool) {
 	physicalPath = filepath.Clean(physicalPath)
@@ -87,6 +136,9 @@ func (vfs *VirtualFS) GetVirtualPath(physicalPath string) (virtualPath string, f
 			relativePath = strings.TrimPrefix(relativePath, string(filepath.Separator))
 			// Convert to forward slashes for web paths
 			relativePath = filepath.ToSlash(relativePath)
+			if dir.LowercasePaths {
+				relativePath = strings.ToLower(relativePath)
+			}
 			return path.Join(dir.Virtual, relativePath), true
 		}
 	}
@@ -129,6 +181,24 @@ func (vfs *VirtualFS) GetDirectoryForVirtualPath(virtualPath string) (config.Dir
 	return config.DirMapping{}, false
 }
 
+// NormalizePath applies the path normalization of the matching mapping to a
+// virtual path. Paths of mappings without normalization are returned as is.
+func (vfs *VirtualFS) NormalizePath(virtualPath string) string {
+	cleaned := path.Clean("/" + strings.TrimPrefix(virtualPath, "/"))
+
+	for _, dir := range vfs.Directories {
+		if dir.Virtual != "/" && cleaned != dir.Virtual && !strings.HasPrefix(cleaned, dir.Virtual+"/") {
+			continue
+		}
+		if !dir.LowercasePaths {
+			return virtualPath
+		}
+		relativePath := strings.TrimPrefix(strings.TrimPrefix(cleaned, dir.Virtual), "/")
+		return path.Join(dir.Virtual, strings.ToLower(relativePath))
+	}
+	return virtualPath
+}
+
 // IsVirtualRoot checks if the given path is the virtual root
 func (vfs *VirtualFS) IsVirtualRoot(virtualPath string) bool {
 	virtualPath = path.Clean("/" + strings.TrimPrefix(virtualPath, "/"))
@@ -0,0 +1,138 @@
+package filesystem
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"dendrite/internal/config"
+)
+
+func TestFlattenSingleRoot(t *testing.T) {
+	tempDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(tempDir, "sub"), 0750))
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "file.txt"), []byte("hello"), 0600))
+
+	cfg := &config.Config{
+		Main: config.MainConfig{FlattenSingleRoot: true},
+		Directories: []config.DirMapping{
+			{Source: tempDir, Virtual: "/documents"},
+		},
+	}
+
+	for name, mgr := range map[string]*Manager{
+		"configured directory": New(cfg),
+		"restricted directory": NewWithRestriction(cfg, cfg.Directories),
+	} {
+		t.Run(name, func(t *testing.T) {
+			files, err := mgr.ListFiles("/")
+			require.NoError(t, err)
+			paths := make([]string, 0, len(files))
+			for _, f := range files {
+				paths = append(paths, f.Path)
+			}
+			assert.ElementsMatch(t, []string{"/file.txt", "/sub"}, paths)
+
+			content, err := mgr.ReadFile("/file.txt")
+			require.NoError(t, err)
+			assert.Equal(t, "hello", string(content))
+
+			stat, err := mgr.StatFile("/file.txt")
+			require.NoError(t, err)
+			assert.Equal(t, "/file.txt", stat.Path)
+
+			result, err := mgr.UploadFile("/sub", "up.txt", strings.NewReader("x"), 1)
+			require.NoError(t, err)
+			assert.Equal(t, "/sub/up.txt", result.Path)
+			assert.FileExists(t, filepath.Join(tempDir, "sub", "up.txt"))
+
+			require.NoError(t, mgr.MoveFile("/sub/up.txt", "/moved.txt"))
+			assert.FileExists(t, filepath.Join(tempDir, "moved.txt"))
+			require.NoError(t, mgr.DeleteFile("/moved.txt"))
+
+			_, err = mgr.ReadFile("/documents/file.txt")
+			assert.Error(t, err)
+		})
+	}
+
+	t.Run("disabled keeps the prefix", func(t *testing.T) {
+		mgr := New(&config.Config{Directories: cfg.Directories})
+		files, err := mgr.ListFiles("/documents")
+		require.NoError(t, err)
+		for _, f := range files {
+			assert.True(t, strings.HasPrefix(f.Path, "/documents/"), f.Path)
+		}
+	})
+
+	t.Run("multiple mappings are not flattened", func(t *testing.T) {
+		mgr := New(&config.Config{
+			Main: config.MainConfig{FlattenSingleRoot: true},
+			Directories: []config.DirMapping{
+				{Source: tempDir, Virtual: "/documents"},
+				{Source: filepath.Join(tempDir, "sub"), Virtual: "/other"},
+			},
+		})
+		files, err := mgr.ListFiles("/")
+		require.NoError(t, err)
+		names := make([]string, 0, len(files))
+		for _, f := range files {
+			names = append(names, f.Name)
+		}
+		assert.ElementsMatch(t, []string{"documents", "other"}, names)
+	})
+}
+
+func TestLowercasePaths(t *testing.T) {
+	tempDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(tempDir, "Reports", "Q1"), 0750))
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "Reports", "Q1", "Summary.PDF"), []byte("pdf"), 0600))
+
+	mgr := New(&config.Config{
+		Directories: []config.DirMapping{
+			{Source: tempDir, Virtual: "/docs", LowercasePaths: true},
+		},
+	})
+
+	t.Run("listing presents lower case paths", func(t *testing.T) {
+		files, err := mgr.ListFiles("/docs/reports/q1")
+		require.NoError(t, err)
+		require.Len(t, files, 1)
+		assert.Equal(t, "/docs/reports/q1/summary.pdf", files[0].Path)
+	})
+
+	t.Run("virtual path round-trips to the physical file", func(t *testing.T) {
+		physical, found := mgr.VirtualFS.ResolvePath("/docs/reports/q1/summary.pdf")
+		require.True(t, found)
+		assert.Equal(t, filepath.Join(tempDir, "Reports", "Q1", "Summary.PDF"), physical)
+
+		virtual, found := mgr.VirtualFS.GetVirtualPath(physical)
+		require.True(t, found)
+		assert.Equal(t, "/docs/reports/q1/summary.pdf", virtual)
+	})
+
+	t.Run("mixed case requests resolve to the same file", func(t *testing.T) {
+		for _, p := range []string{"/docs/Reports/Q1/Summary.PDF", "/docs/REPORTS/q1/summary.pdf/"} {
+			content, err := mgr.ReadFile(p)
+			require.NoError(t, err, p)
+			assert.Equal(t, "pdf", string(content))
+		}
+	})
+
+	t.Run("new files are created in lower case", func(t *testing.T) {
+		result, err := mgr.UploadFile("/docs/reports", "New.TXT", strings.NewReader("x"), 1)
+		require.NoError(t, err)
+		assert.Equal(t, "/docs/reports/new.txt", result.Path)
+		assert.FileExists(t, filepath.Join(tempDir, "Reports", "new.txt"))
+	})
+
+	t.Run("other mappings are unchanged", func(t *testing.T) {
+		other := NewVirtualFS([]config.DirMapping{{Source: tempDir, Virtual: "/raw"}})
+		virtual, found := other.GetVirtualPath(filepath.Join(tempDir, "Reports"))
+		require.True(t, found)
+		assert.Equal(t, "/raw/Reports", virtual)
+	})
+}
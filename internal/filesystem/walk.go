@@ -0,0 +1,46 @@
+package filesystem
+
+import (
+	"log"
+	"strings"
+)
+
+// MaxWalkDepth is the deepest level a recursive listing descends to
+const MaxWalkDepth = 64
+
+// WalkFiles calls fn for every entry below virtualPath, descending at most
+// maxDepth levels (1 lists only the direct children). Directories are
+// visited one at a time, so memory use does not grow with the size of the
+// tree. Symbolic links to directories are not followed and unreadable
+// subdirectories are skipped.
+func (m *Manager) WalkFiles(virtualPath string, maxDepth int, fn func(FileInfo) error) error {
+	if maxDepth <= 0 || maxDepth > MaxWalkDepth {
+		maxDepth = MaxWalkDepth
+	}
+	return m.walk(virtualPath, 1, maxDepth, fn)
+}
+
+func (m *Manager) walk(virtualPath string, depth, maxDepth int, fn func(FileInfo) error) error {
+	listing, err := m.ListDirectory(virtualPath)
+	if err != nil {
+		// Subdirectories may be unreadable, removed during the walk or purely
+		// virtual; they must not abort the whole listing
+		if depth > 1 && (strings.Contains(err.Error(), "access denied") || strings.Contains(err.Error(), "not found")) {
+			log.Printf("Skipping directory %s in recursive listing: %v", virtualPath, err)
+			return nil
+		}
+		return err
+	}
+
+	for _, file := range listing.Files {
+		if err := fn(file); err != nil {
+			return err
+		}
+		if file.IsDir && depth < maxDepth {
+			if err := m.walk(file.Path, depth+1, maxDepth, fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package filesystem
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// errWorkerFailed stops a walk once a worker failed; the worker's own error
+// is reported by Wait
+var errWorkerFailed = errors.New("worker failed")
+
+// recursiveWorkers returns the number of files processed in parallel by
+// recursive operations, at least one
+func (m *Manager) recursiveWorkers() int {
+	return max(m.Config.Main.RecursiveWorkers, 1)
+}
+
+// boundedGroup runs functions on at most a fixed number of goroutines and
+// keeps the first error
+type boundedGroup struct {
+	sem    chan struct{}
+	wg     sync.WaitGroup
+	once   sync.Once
+	err    error
+	failed atomic.Bool
+}
+
+func newBoundedGroup(workers int) *boundedGroup {
+	return &boundedGroup{sem: make(chan struct{}, workers)}
+}
+
+// Go runs fn once a worker is free
+func (g *boundedGroup) Go(fn func() error) {
+	g.sem <- struct{}{}
+	g.wg.Add(1)
+	go func() {
+		defer func() {
+			<-g.sem
+			g.wg.Done()
+		}()
+		if err := fn(); err != nil {
+			g.once.Do(func() {
+				g.err = err
+				g.failed.Store(true)
+			})
+		}
+	}()
+}
+
+// Failed reports whether a function returned an error, so that callers can
+// stop scheduling more work
+func (g *boundedGroup) Failed() bool {
+	return g.failed.Load()
+}
+
+// Wait waits for all functions and returns the first error
+func (g *boundedGroup) Wait() error {
+	g.wg.Wait()
+	return g.err
+}
@@ -0,0 +1,131 @@
+package filesystem
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+)
+
+// Handling of files that change while they are added to a ZIP archive
+const (
+	// ZipChangedInclude adds files as read, even if they changed (default)
+	ZipChangedInclude = "include"
+	// ZipChangedSkip leaves changed files out and lists them in a trailing
+	// manifest entry
+	ZipChangedSkip = "skip"
+	// ZipChangedFail aborts the archive when a file changed
+	ZipChangedFail = "fail"
+)
+
+// ZipSkippedManifest is the name of the entry listing skipped paths, added
+// as the last entry of an archive when paths were skipped
+const ZipSkippedManifest = "dendrite-skipped.txt"
+
+// zipSpoolMemory is the size up to which files are held in memory while
+// checking them for changes; larger files are spooled to a temporary file
+const zipSpoolMemory = 4 << 20
+
+// errFileChanged reports a file modified while it was read
+var errFileChanged = errors.New("file changed while it was archived")
+
+// zipFileRead is called after a file's content was read for an archive and
+// before it is checked for changes. Tests use it to simulate concurrent writes.
+var zipFileRead = func(string) {}
+
+// ValidateZipChangedFiles checks that mode is empty or a known handling of
+// changed files
+func ValidateZipChangedFiles(mode string) error {
+	switch mode {
+	case "", ZipChangedInclude, ZipChangedSkip, ZipChangedFail:
+		return nil
+	}
+	return fmt.Errorf("invalid changed files handling: %s (expected include, skip or fail)", mode)
+}
+
+// copyUnchanged copies the content of file as described by info, which was
+// taken before reading, and fails with errFileChanged when the size or
+// modification time differ afterwards
+func copyUnchanged(w io.Writer, file *os.File, info os.FileInfo) error {
+	_, err := io.CopyN(w, file, info.Size())
+	if errors.Is(err, io.EOF) {
+		return errFileChanged // The file shrank
+	}
+	if err != nil {
+		return err
+	}
+
+	zipFileRead(file.Name())
+
+	after, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if after.Size() != info.Size() || !after.ModTime().Equal(info.ModTime()) {
+		return errFileChanged
+	}
+	return nil
+}
+
+// spoolUnchanged reads the file into memory or a temporary file and returns
+// its content once it is known not to have changed while reading. The
+// returned cleanup function releases the spooled copy.
+func spoolUnchanged(file *os.File, info os.FileInfo) (io.Reader, func(), error) {
+	if info.Size() <= zipSpoolMemory {
+		var buf bytes.Buffer
+		if err := copyUnchanged(&buf, file, info); err != nil {
+			return nil, nil, err
+		}
+		return &buf, func() {}, nil
+	}
+
+	spool, err := os.CreateTemp("", "dendrite-zip-*")
+	if err != nil {
+		return nil, nil, err
+	}
+	cleanup := func() {
+		_ = spool.Close()
+		_ = os.Remove(spool.Name())
+	}
+	if err := copyUnchanged(spool, file, info); err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	return spool, cleanup, nil
+}
+
+// writeSkippedManifest adds the manifest entry listing the paths left out of
+// the archive, one per line with the reason
+func writeSkippedManifest(zw *zip.Writer, skipped []SkippedPath, opts ZipOptions) error {
+	if len(skipped) == 0 {
+		return nil
+	}
+	header := &zip.FileHeader{Name: ZipSkippedManifest, Method: zip.Deflate}
+	if opts.Deterministic {
+		header.Modified = zipEpoch
+	}
+	w, err := zw.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	var content strings.Builder
+	content.WriteString("The following paths were left out of the archive:\n")
+	for _, s := range skipped {
+		fmt.Fprintf(&content, "%s: %s\n", s.Path, s.Reason)
+	}
+	_, err = io.WriteString(w, content.String())
+	return err
+}
+
+// logSkippedFile warns about a file left out of an archive
+func logSkippedFile(name string) {
+	log.Printf("Warning: skipped %s in ZIP archive: %v", name, errFileChanged)
+}
@@ -0,0 +1,87 @@
+package filesystem
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"dendrite/internal/config"
+)
+
+func TestCreateZipChangedFiles(t *testing.T) {
+	tempDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "stable.txt"), []byte("stable"), 0600))
+	growing := filepath.Join(tempDir, "growing.log")
+
+	mgr := New(&config.Config{Directories: []config.DirMapping{{Source: tempDir, Virtual: "/test"}}})
+
+	// Append to growing.log whenever it was read for the archive
+	original := zipFileRead
+	zipFileRead = func(name string) {
+		if filepath.Base(name) != "growing.log" {
+			return
+		}
+		f, err := os.OpenFile(growing, os.O_APPEND|os.O_WRONLY, 0600)
+		require.NoError(t, err)
+		_, err = f.WriteString(" more")
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+	}
+	t.Cleanup(func() { zipFileRead = original })
+
+	createZip := func(t *testing.T, mode string) (map[string]string, error) {
+		t.Helper()
+		require.NoError(t, os.WriteFile(growing, []byte("line"), 0600))
+		var buf bytes.Buffer
+		err := mgr.CreateZipWithOptions(&buf, []string{"/test"}, ZipOptions{ChangedFiles: mode})
+		if err != nil {
+			return nil, err
+		}
+		reader, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		require.NoError(t, err)
+		entries := make(map[string]string)
+		for _, f := range reader.File {
+			rc, err := f.Open()
+			require.NoError(t, err)
+			content, err := io.ReadAll(rc)
+			require.NoError(t, err)
+			require.NoError(t, rc.Close())
+			entries[f.Name] = string(content)
+		}
+		return entries, nil
+	}
+
+	t.Run("skip leaves the file out and reports it", func(t *testing.T) {
+		entries, err := createZip(t, ZipChangedSkip)
+		require.NoError(t, err)
+		assert.Equal(t, "stable", entries["/test/stable.txt"])
+		assert.NotContains(t, entries, "/test/growing.log")
+		require.Contains(t, entries, ZipSkippedManifest)
+		assert.Contains(t, entries[ZipSkippedManifest], "/test/growing.log")
+	})
+
+	t.Run("fail aborts the archive", func(t *testing.T) {
+		_, err := createZip(t, ZipChangedFail)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "growing.log")
+		assert.ErrorIs(t, err, errFileChanged)
+	})
+
+	t.Run("include keeps the file", func(t *testing.T) {
+		entries, err := createZip(t, "")
+		require.NoError(t, err)
+		assert.Contains(t, entries, "/test/growing.log")
+		assert.NotContains(t, entries, ZipSkippedManifest)
+	})
+
+	t.Run("invalid mode", func(t *testing.T) {
+		_, err := createZip(t, "ignore")
+		assert.Error(t, err)
+	})
+}
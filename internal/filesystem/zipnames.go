@@ -0,0 +1,113 @@
+package filesystem
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Layouts for entry names in ZIP downloads
+const (
+	// ZipLayoutFull names entries by their full virtual path (default)
+	ZipLayoutFull = "full"
+	// ZipLayoutRelative names entries relative to the deepest directory
+	// shared by all selected paths
+	ZipLayoutRelative = "relative"
+	// ZipLayoutFlat puts every selected path at the archive root and renames
+	// entries whose names collide, e.g. "report (2).txt"
+	ZipLayoutFlat = "flat"
+)
+
+// ValidateZipLayout checks that layout is empty or a known ZIP layout
+func ValidateZipLayout(layout string) error {
+	switch layout {
+	case "", ZipLayoutFull, ZipLayoutRelative, ZipLayoutFlat:
+		return nil
+	}
+	return fmt.Errorf("invalid zip layout: %s (expected full, relative or flat)", layout)
+}
+
+// zipEntries tracks the names written to an archive so that no two entries
+// share a name
+type zipEntries struct {
+	names map[string]bool
+	// rename gives colliding entries a numbered name instead of skipping them
+	rename bool
+	// skipped lists the requested paths and entries left out of the archive
+	skipped []SkippedPath
+}
+
+func newZipEntries(layout string) *zipEntries {
+	return &zipEntries{names: make(map[string]bool), rename: layout == ZipLayoutFlat}
+}
+
+// claim reserves name and returns the name to use. It returns false when the
+// entry is a duplicate that must be skipped.
+func (z *zipEntries) claim(name string) (string, bool) {
+	if z.claimExact(name) {
+		return name, true
+	}
+	if !z.rename {
+		return "", false
+	}
+
+	ext := path.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+	for i := 2; ; i++ {
+		candidate := fmt.Sprintf("%s (%d)%s", stem, i, ext)
+		if !z.names[candidate] {
+			z.names[candidate] = true
+			return candidate, true
+		}
+	}
+}
+
+// claimExact reserves name without renaming and reports whether it was free
+func (z *zipEntries) claimExact(name string) bool {
+	if z.names[name] {
+		return false
+	}
+	z.names[name] = true
+	return true
+}
+
+// zipRootNames maps each selected virtual path to the name of its entry in
+// the archive according to layout
+func zipRootNames(virtualPaths []string, layout string) map[string]string {
+	names := make(map[string]string, len(virtualPaths))
+	switch layout {
+	case ZipLayoutRelative:
+		base := commonParent(virtualPaths)
+		for _, p := range virtualPaths {
+			name := strings.TrimPrefix(strings.TrimPrefix(path.Clean("/"+p), base), "/")
+			if name == "" {
+				name = p // The selection is the root itself
+			}
+			names[p] = name
+		}
+	case ZipLayoutFlat:
+		for _, p := range virtualPaths {
+			names[p] = path.Base(path.Clean("/" + p))
+		}
+	default:
+		for _, p := range virtualPaths {
+			names[p] = p
+		}
+	}
+	return names
+}
+
+// commonParent returns the deepest directory containing all paths
+func commonParent(virtualPaths []string) string {
+	if len(virtualPaths) == 0 {
+		return "/"
+	}
+	base := path.Dir(path.Clean("/" + virtualPaths[0]))
+	for _, p := range virtualPaths[1:] {
+		dir := path.Dir(path.Clean("/" + p))
+		for base != "/" && dir != base && !strings.HasPrefix(dir, base+"/") {
+			base = path.Dir(base)
+		}
+	}
+	return base
+}
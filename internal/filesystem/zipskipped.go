@@ -0,0 +1,50 @@
+package filesystem
+
+import (
+	"os"
+)
+
+// Reasons for leaving a requested path out of an archive
+const (
+	SkipReasonInvalid = "invalid path"
+	SkipReasonDenied  = "access denied"
+	SkipReasonMissing = "not found"
+	SkipReasonChanged = "changed while it was read"
+)
+
+// SkippedPath is a path left out of an archive and the reason why
+type SkippedPath struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+// checkArchivePath returns the physical path and file info of a path to
+// archive, or the reason it has to be skipped
+func (m *Manager) checkArchivePath(virtualPath string, stat func(string) (os.FileInfo, error)) (
+	string, os.FileInfo, string) {
+	physicalPath, err := m.resolvePath(virtualPath)
+	if err != nil {
+		return "", nil, SkipReasonInvalid
+	}
+	if !m.isPathSafe(physicalPath) {
+		return "", nil, SkipReasonDenied
+	}
+	info, err := stat(physicalPath)
+	if err != nil {
+		return "", nil, SkipReasonMissing
+	}
+	return physicalPath, info, ""
+}
+
+// CheckPaths splits virtual paths into those that can be archived and those
+// that would be skipped, so that clients can be told before streaming starts
+func (m *Manager) CheckPaths(virtualPaths []string) (valid []string, skipped []SkippedPath) {
+	for _, virtualPath := range virtualPaths {
+		if _, _, reason := m.checkArchivePath(virtualPath, os.Stat); reason != "" {
+			skipped = append(skipped, SkippedPath{Path: virtualPath, Reason: reason})
+			continue
+		}
+		valid = append(valid, virtualPath)
+	}
+	return valid, skipped
+}
@@ -0,0 +1,57 @@
+package filesystem
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"dendrite/internal/config"
+)
+
+func TestCreateZipReportsSkippedPaths(t *testing.T) {
+	tempDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "a.txt"), []byte("a"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "b.txt"), []byte("b"), 0600))
+
+	mgr := New(&config.Config{Directories: []config.DirMapping{{Source: tempDir, Virtual: "/test"}}})
+	paths := []string{"/test/a.txt", "/test/missing.txt", "/other/file.txt", "/test/b.txt"}
+
+	t.Run("check paths splits valid and skipped", func(t *testing.T) {
+		valid, skipped := mgr.CheckPaths(paths)
+		assert.Equal(t, []string{"/test/a.txt", "/test/b.txt"}, valid)
+		assert.Equal(t, []SkippedPath{
+			{Path: "/test/missing.txt", Reason: SkipReasonMissing},
+			{Path: "/other/file.txt", Reason: SkipReasonInvalid},
+		}, skipped)
+	})
+
+	t.Run("archive lists skipped paths in the manifest", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, mgr.CreateZip(&buf, paths))
+
+		reader, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		require.NoError(t, err)
+		entries := make(map[string]string)
+		for _, f := range reader.File {
+			rc, err := f.Open()
+			require.NoError(t, err)
+			content, err := io.ReadAll(rc)
+			require.NoError(t, err)
+			require.NoError(t, rc.Close())
+			entries[f.Name] = string(content)
+		}
+
+		assert.Equal(t, "a", entries["/test/a.txt"])
+		assert.Equal(t, "b", entries["/test/b.txt"])
+		require.Contains(t, entries, ZipSkippedManifest)
+		assert.Contains(t, entries[ZipSkippedManifest], "/test/missing.txt: not found\n")
+		assert.Contains(t, entries[ZipSkippedManifest], "/other/file.txt: invalid path\n")
+		assert.NotContains(t, entries[ZipSkippedManifest], "a.txt")
+	})
+}
@@ -0,0 +1,237 @@
+package filesystem
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// zipPrefetchSize is the largest file read and compressed ahead by a worker.
+// Larger files are streamed by the writer, bounding the memory held by
+// prepared entries.
+const zipPrefetchSize = 1 << 20
+
+// zipFlateLevel matches the compression level of archive/zip, so that
+// parallel and serial archives compress alike
+const zipFlateLevel = 5
+
+// flateWriters reuses compressors, whose state is expensive to allocate
+var flateWriters = sync.Pool{
+	New: func() any {
+		fw, _ := flate.NewWriter(nil, zipFlateLevel)
+		return fw
+	},
+}
+
+// zipBuffers reuses the buffers holding file contents while they are
+// compressed and written
+var zipBuffers = sync.Pool{
+	New: func() any { return new(bytes.Buffer) },
+}
+
+// zipSink receives the entries of an archive in their final order
+type zipSink interface {
+	addDir(header *zip.FileHeader) error
+	addFile(fullPath, zipPath string) error
+}
+
+// serialZip writes every entry as soon as it is added
+type serialZip struct {
+	m       *Manager
+	zw      *zip.Writer
+	opts    ZipOptions
+	entries *zipEntries
+}
+
+func (s *serialZip) addDir(header *zip.FileHeader) error {
+	_, err := s.zw.CreateHeader(header)
+	return err
+}
+
+func (s *serialZip) addFile(fullPath, zipPath string) error {
+	return s.m.addFileToZip(s.zw, fullPath, zipPath, s.opts, s.entries)
+}
+
+// parallelZip reads and compresses small files on several workers while a
+// single goroutine writes all entries in the order they were added, as the
+// ZIP writer is not safe for concurrent use
+type parallelZip struct {
+	serialZip
+	workers *boundedGroup
+	// queue holds the pending writes in archive order
+	queue chan func() error
+	// failed is closed when a write failed, done when the writer returned
+	failed chan struct{}
+	done   chan struct{}
+	err    error
+}
+
+func newParallelZip(serial serialZip, workers int) *parallelZip {
+	p := &parallelZip{
+		serialZip: serial,
+		workers:   newBoundedGroup(workers),
+		queue:     make(chan func() error, 2*workers),
+		failed:    make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	go func() {
+		defer close(p.done)
+		// Writes queued after a failure are dropped; prepared files are
+		// buffered, so their workers still finish
+		for write := range p.queue {
+			if p.err != nil {
+				continue
+			}
+			if p.err = write(); p.err != nil {
+				close(p.failed)
+			}
+		}
+	}()
+	return p
+}
+
+func (p *parallelZip) addDir(header *zip.FileHeader) error {
+	return p.enqueue(func() error {
+		return p.serialZip.addDir(header)
+	})
+}
+
+func (p *parallelZip) addFile(fullPath, zipPath string) error {
+	info, err := os.Stat(fullPath)
+	if err != nil || info.Size() > zipPrefetchSize {
+		return p.enqueue(func() error {
+			return p.serialZip.addFile(fullPath, zipPath)
+		})
+	}
+
+	prepared := make(chan preparedZipFile, 1)
+	p.workers.Go(func() error {
+		prepared <- prepareZipFile(fullPath, zipPath, p.opts)
+		return nil
+	})
+	return p.enqueue(func() error {
+		return p.writePrepared(<-prepared)
+	})
+}
+
+// enqueue schedules a write and fails once an earlier write failed, which
+// stops the walk
+func (p *parallelZip) enqueue(write func() error) error {
+	select {
+	case <-p.failed:
+		return errWorkerFailed
+	case p.queue <- write:
+		return nil
+	}
+}
+
+// finish waits for all pending writes and returns the first error
+func (p *parallelZip) finish() error {
+	close(p.queue)
+	<-p.done
+	_ = p.workers.Wait()
+	return p.err
+}
+
+func (p *parallelZip) writePrepared(f preparedZipFile) error {
+	if f.err != nil {
+		return f.err
+	}
+	if f.skipped {
+		logSkippedFile(f.header.Name)
+		p.entries.skipped = append(p.entries.skipped,
+			SkippedPath{Path: filepath.ToSlash(f.header.Name), Reason: SkipReasonChanged})
+		return nil
+	}
+	defer zipBuffers.Put(f.data)
+	w, err := p.zw.CreateRaw(f.header)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(f.data.Bytes())
+	return err
+}
+
+// preparedZipFile is a compressed entry ready to be written
+type preparedZipFile struct {
+	header *zip.FileHeader
+	// data holds the compressed content; it returns to zipBuffers once written
+	data    *bytes.Buffer
+	skipped bool
+	err     error
+}
+
+// prepareZipFile reads and deflates a file, honoring the handling of changed
+// files like addFileToZip
+func prepareZipFile(fullPath, zipPath string, opts ZipOptions) preparedZipFile {
+	file, err := os.Open(fullPath) // #nosec G304 - path is validated by the caller
+	if err != nil {
+		return preparedZipFile{err: err}
+	}
+	defer func() {
+		if cerr := file.Close(); cerr != nil {
+			log.Printf("Error closing file %s: %v", fullPath, cerr)
+		}
+	}()
+
+	info, err := file.Stat()
+	if err != nil {
+		return preparedZipFile{err: err}
+	}
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return preparedZipFile{err: err}
+	}
+	header.Name = zipPath
+	header.Method = zip.Deflate
+	if opts.Deterministic {
+		header.Modified = zipEpoch
+	}
+
+	// Buffers are pooled and sized ahead, as allocating them dominates the
+	// cost for small files
+	content := zipBuffers.Get().(*bytes.Buffer)
+	defer zipBuffers.Put(content)
+	content.Reset()
+	content.Grow(int(info.Size()) + bytes.MinRead)
+	if opts.ChangedFiles == ZipChangedSkip || opts.ChangedFiles == ZipChangedFail {
+		err = copyUnchanged(content, file, info)
+	} else {
+		_, err = io.Copy(content, file)
+	}
+	switch {
+	case errors.Is(err, errFileChanged) && opts.ChangedFiles == ZipChangedSkip:
+		return preparedZipFile{header: header, skipped: true}
+	case errors.Is(err, errFileChanged):
+		return preparedZipFile{err: fmt.Errorf("%s: %w", zipPath, err)}
+	case err != nil:
+		return preparedZipFile{err: err}
+	}
+
+	compressed := zipBuffers.Get().(*bytes.Buffer)
+	compressed.Reset()
+	fw := flateWriters.Get().(*flate.Writer)
+	defer flateWriters.Put(fw)
+	fw.Reset(compressed)
+	if _, err := fw.Write(content.Bytes()); err != nil {
+		zipBuffers.Put(compressed)
+		return preparedZipFile{err: err}
+	}
+	if err := fw.Close(); err != nil {
+		zipBuffers.Put(compressed)
+		return preparedZipFile{err: err}
+	}
+
+	header.CRC32 = crc32.ChecksumIEEE(content.Bytes())
+	header.UncompressedSize64 = uint64(content.Len())  // #nosec G115 - lengths are never negative
+	header.CompressedSize64 = uint64(compressed.Len()) // #nosec G115 - lengths are never negative
+	return preparedZipFile{header: header, data: compressed}
+}
@@ -0,0 +1,139 @@
+package filesystem
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"dendrite/internal/config"
+)
+
+// createTree writes files of varying sizes, including one above the
+// prefetch size, below dir
+func createTree(t testing.TB, dir string, files int) {
+	t.Helper()
+	for i := 0; i < files; i++ {
+		sub := filepath.Join(dir, fmt.Sprintf("dir%d", i%4))
+		require.NoError(t, os.MkdirAll(sub, 0750))
+		content := bytes.Repeat([]byte(fmt.Sprintf("file %d ", i)), 100*(i+1))
+		require.NoError(t, os.WriteFile(filepath.Join(sub, fmt.Sprintf("file%03d.txt", i)), content, 0600))
+	}
+	large := bytes.Repeat([]byte("large "), zipPrefetchSize/3)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "large.bin"), large, 0600))
+}
+
+func readZipEntries(t *testing.T, data []byte) ([]string, map[string]string) {
+	t.Helper()
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+
+	var names []string
+	contents := make(map[string]string)
+	for _, f := range reader.File {
+		rc, err := f.Open()
+		require.NoError(t, err)
+		content, err := io.ReadAll(rc)
+		require.NoError(t, err)
+		require.NoError(t, rc.Close())
+		names = append(names, f.Name)
+		contents[f.Name] = string(content)
+	}
+	return names, contents
+}
+
+func TestRecursiveWorkers(t *testing.T) {
+	tempDir := t.TempDir()
+	createTree(t, filepath.Join(tempDir, "tree"), 40)
+
+	newManager := func(workers int) *Manager {
+		cfg := &config.Config{Directories: []config.DirMapping{{Source: tempDir, Virtual: "/test"}}}
+		cfg.Main.RecursiveWorkers = workers
+		return New(cfg)
+	}
+
+	t.Run("parallel zip matches serial zip", func(t *testing.T) {
+		for _, opts := range []ZipOptions{{}, {Deterministic: true}, {ChangedFiles: ZipChangedFail}} {
+			var serial, parallel bytes.Buffer
+			require.NoError(t, newManager(1).CreateZipWithOptions(&serial, []string{"/test/tree"}, opts))
+			require.NoError(t, newManager(8).CreateZipWithOptions(&parallel, []string{"/test/tree"}, opts))
+
+			serialNames, serialContents := readZipEntries(t, serial.Bytes())
+			parallelNames, parallelContents := readZipEntries(t, parallel.Bytes())
+			assert.Equal(t, serialNames, parallelNames)
+			assert.Equal(t, serialContents, parallelContents)
+			assert.Len(t, parallelNames, 46) // Root, four directories, 40 files and large.bin
+		}
+	})
+
+	t.Run("parallel zip skips changed files", func(t *testing.T) {
+		original := zipFileRead
+		zipFileRead = func(name string) {
+			if filepath.Base(name) != "file007.txt" {
+				return
+			}
+			f, err := os.OpenFile(name, os.O_APPEND|os.O_WRONLY, 0600)
+			require.NoError(t, err)
+			_, err = f.WriteString(" more")
+			require.NoError(t, err)
+			require.NoError(t, f.Close())
+		}
+		t.Cleanup(func() { zipFileRead = original })
+
+		var buf bytes.Buffer
+		opts := ZipOptions{ChangedFiles: ZipChangedSkip}
+		err := newManager(4).CreateZipWithOptions(&buf, []string{"/test/tree"}, opts)
+		require.NoError(t, err)
+		_, contents := readZipEntries(t, buf.Bytes())
+		assert.NotContains(t, contents, "/test/tree/dir3/file007.txt")
+		assert.Contains(t, contents[ZipSkippedManifest], "/test/tree/dir3/file007.txt")
+	})
+
+	t.Run("parallel copy copies every file", func(t *testing.T) {
+		mgr := newManager(8)
+		require.NoError(t, mgr.CopyFile("/test/tree", "/test/copy"))
+
+		var files int
+		err := filepath.WalkDir(filepath.Join(tempDir, "tree"), func(p string, d os.DirEntry, err error) error {
+			require.NoError(t, err)
+			if d.IsDir() {
+				return nil
+			}
+			files++
+			rel, err := filepath.Rel(filepath.Join(tempDir, "tree"), p)
+			require.NoError(t, err)
+			original, err := os.ReadFile(p)
+			require.NoError(t, err)
+			copied, err := os.ReadFile(filepath.Join(tempDir, "copy", rel))
+			require.NoError(t, err)
+			assert.Equal(t, original, copied, rel)
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 41, files)
+	})
+}
+
+func BenchmarkCreateZip(b *testing.B) {
+	tempDir := b.TempDir()
+	createTree(b, tempDir, 200)
+
+	for _, workers := range []int{1, 4} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			cfg := &config.Config{Directories: []config.DirMapping{{Source: tempDir, Virtual: "/test"}}}
+			cfg.Main.RecursiveWorkers = workers
+			mgr := New(cfg)
+			for i := 0; i < b.N; i++ {
+				if err := mgr.CreateZip(io.Discard, []string{"/test"}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
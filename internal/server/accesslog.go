@@ -0,0 +1,190 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"dendrite/internal/config"
+)
+
+// accessLogger writes one line per request, sampling successful requests
+type accessLogger struct {
+	sampleRate      float64
+	alwaysLogErrors bool
+	// json writes JSON lines to out instead of text lines through logf
+	json bool
+	// level is "error", "info" or "debug"
+	level string
+
+	// sample, logf and out are replaceable for tests
+	sample func() float64
+	logf   func(format string, args ...any)
+	out    io.Writer
+}
+
+func newAccessLogger(cfg config.LoggingConfig) *accessLogger {
+	level := cfg.Level
+	if level == "" {
+		level = "info"
+	}
+	return &accessLogger{
+		sampleRate:      cfg.SampleRate,
+		alwaysLogErrors: cfg.AlwaysLogErrors,
+		json:            cfg.Format == "json",
+		level:           level,
+		sample:          rand.Float64,
+		logf:            log.Printf,
+		out:             os.Stdout,
+	}
+}
+
+// shouldLog decides whether a request with the given status is logged
+func (l *accessLogger) shouldLog(status int) bool {
+	switch l.level {
+	case "error":
+		return status >= 400
+	case "debug":
+		return true
+	}
+	if l.alwaysLogErrors && status >= 400 {
+		return true
+	}
+	return l.sample() < l.sampleRate
+}
+
+// accessLogEntry is one line of the JSON access log
+type accessLogEntry struct {
+	Time   time.Time `json:"time"`
+	Method string    `json:"method"`
+	Path   string    `json:"path"`
+	Query  string    `json:"query,omitempty"`
+	Status int       `json:"status"`
+	Bytes  int64     `json:"bytes"`
+	// DurationMs is set from duration when the entry is written
+	DurationMs float64 `json:"durationMs"`
+	Client     string  `json:"client"`
+	// Subject identifies the token holder in JWT mode without revealing the
+	// subject, see recordLogSubject
+	Subject   string `json:"subject,omitempty"`
+	UserAgent string `json:"userAgent,omitempty"`
+
+	duration time.Duration
+}
+
+// write emits an entry in the configured format
+func (l *accessLogger) write(entry accessLogEntry) {
+	if !l.json {
+		line := "access method=%s path=%q status=%d duration=%s bytes=%d client=%s"
+		args := []any{entry.Method, entry.Path, entry.Status, entry.duration.Round(time.Microsecond), entry.Bytes,
+			entry.Client}
+		if entry.Subject != "" {
+			line += " subject=%s"
+			args = append(args, entry.Subject)
+		}
+		if l.level == "debug" {
+			line += " query=%q user_agent=%q"
+			args = append(args, entry.Query, entry.UserAgent)
+		}
+		l.logf(line, args...)
+		return
+	}
+
+	entry.DurationMs = float64(entry.duration.Microseconds()) / 1000
+	data, err := json.Marshal(entry)
+	if err != nil {
+		log.Printf("Failed to encode access log entry: %v", err)
+		return
+	}
+	if _, err := l.out.Write(append(data, '\n')); err != nil {
+		log.Printf("Failed to write access log entry: %v", err)
+	}
+}
+
+// redactQuery masks the values of token parameters, which carry a JWT for
+// clients that cannot set headers, and keeps the rest of a query string as
+// sent
+func redactQuery(rawQuery string) string {
+	params := strings.Split(rawQuery, "&")
+	for i, param := range params {
+		key, _, _ := strings.Cut(param, "=")
+		if name, err := url.QueryUnescape(key); err == nil && name == "token" {
+			params[i] = key + "=REDACTED"
+		}
+	}
+	return strings.Join(params, "&")
+}
+
+// logSubjectKey is the context key of the *logSubject of a logged request
+type logSubjectKey struct{}
+
+// logSubject receives the hashed token subject from recordLogSubject, which
+// runs behind the JWT middleware on a request copy the logger cannot see
+type logSubject struct {
+	hash string
+}
+
+// accessLog is a middleware logging method, path, status, duration, response
+// size and client address of every sampled request
+func (s *Server) accessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The original request is logged with the path the client sent
+		if r.Context().Value(reroutedKey{}) != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+		subject := &logSubject{}
+		tw := &responseTracker{ResponseWriter: w}
+		next.ServeHTTP(tw, r.WithContext(context.WithValue(r.Context(), logSubjectKey{}, subject)))
+
+		status := tw.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		if !s.accessLogger.shouldLog(status) {
+			return
+		}
+
+		entry := accessLogEntry{
+			Time:     start,
+			Method:   r.Method,
+			Path:     r.URL.Path,
+			Status:   status,
+			Bytes:    tw.written,
+			Client:   s.clientIP(r),
+			Subject:  subject.hash,
+			duration: time.Since(start),
+		}
+		if s.accessLogger.level == "debug" {
+			entry.Query = redactQuery(r.URL.RawQuery)
+			entry.UserAgent = r.UserAgent()
+		}
+		s.accessLogger.write(entry)
+	})
+}
+
+// recordLogSubject passes a hash of the token subject of an authenticated
+// request to the access log. Tokens without a subject are identified by
+// their directories, like the scope of recent uploads.
+func (s *Server) recordLogSubject(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subject, ok := r.Context().Value(logSubjectKey{}).(*logSubject); ok {
+			if scope := requestScope(r); scope != "" {
+				sum := sha256.Sum256([]byte(scope))
+				subject.hash = hex.EncodeToString(sum[:8])
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
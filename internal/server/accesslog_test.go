@@ -0,0 +1,201 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"dendrite/internal/auth"
+	"dendrite/internal/config"
+)
+
+func TestAccessLogSampling(t *testing.T) {
+	tempDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "file.txt"), []byte("hello"), 0600))
+
+	cfg := &config.Config{
+		Logging:     config.LoggingConfig{AccessLog: true, SampleRate: 0.5, AlwaysLogErrors: true},
+		Directories: []config.DirMapping{{Source: tempDir, Virtual: "/test"}},
+	}
+	srv := New(cfg)
+
+	var lines []string
+	srv.accessLogger.logf = func(format string, args ...any) {
+		lines = append(lines, fmt.Sprintf(format, args...))
+	}
+	// Every request falls outside the sample
+	srv.accessLogger.sample = func() float64 { return 0.9 }
+
+	serve := func(method, target string) int {
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, httptest.NewRequest(method, target, nil))
+		return rec.Code
+	}
+
+	t.Run("successes are sampled", func(t *testing.T) {
+		lines = nil
+		require.Equal(t, http.StatusOK, serve("GET", "/api/files/test/file.txt"))
+		assert.Empty(t, lines)
+
+		srv.accessLogger.sample = func() float64 { return 0.1 }
+		defer func() { srv.accessLogger.sample = func() float64 { return 0.9 } }()
+		require.Equal(t, http.StatusOK, serve("GET", "/api/files/test/file.txt"))
+		require.Len(t, lines, 1)
+		assert.Contains(t, lines[0], "method=GET")
+		assert.Contains(t, lines[0], `path="/api/files/test/file.txt"`)
+		assert.Contains(t, lines[0], "status=200")
+		assert.Contains(t, lines[0], "bytes=5")
+		assert.Contains(t, lines[0], "client=192.0.2.1")
+	})
+
+	t.Run("errors are always logged", func(t *testing.T) {
+		lines = nil
+		require.Equal(t, http.StatusNotFound, serve("GET", "/api/files/test/missing.txt"))
+		require.Len(t, lines, 1)
+		assert.Contains(t, lines[0], "status=404")
+	})
+
+	t.Run("errors are sampled when not forced", func(t *testing.T) {
+		lines = nil
+		srv.accessLogger.alwaysLogErrors = false
+		defer func() { srv.accessLogger.alwaysLogErrors = true }()
+		require.Equal(t, http.StatusNotFound, serve("GET", "/api/files/test/missing.txt"))
+		assert.Empty(t, lines)
+	})
+}
+
+func TestAccessLogJSON(t *testing.T) {
+	baseDir := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(baseDir, "docs"), 0750))
+	require.NoError(t, os.WriteFile(filepath.Join(baseDir, "docs", "file.txt"), []byte("hello"), 0600))
+
+	secret := "test-secret-that-is-at-least-32-characters-long"
+	srv := New(&config.Config{
+		Logging:   config.LoggingConfig{AccessLog: true, Format: "json", SampleRate: 1, AlwaysLogErrors: true},
+		JWTSecret: secret,
+		BaseDir:   baseDir,
+	})
+	var out bytes.Buffer
+	srv.accessLogger.out = &out
+	token := signedToken(t, secret, "alice", []auth.DirMapping{{Source: "docs", Virtual: "/docs"}})
+
+	serve := func(target string) map[string]any {
+		t.Helper()
+		out.Reset()
+		req := httptest.NewRequest("GET", target, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		srv.Router.ServeHTTP(httptest.NewRecorder(), req)
+
+		lines := bytes.Split(bytes.TrimSpace(out.Bytes()), []byte("\n"))
+		require.Len(t, lines, 1, out.String())
+		var entry map[string]any
+		require.NoError(t, json.Unmarshal(lines[0], &entry))
+		return entry
+	}
+
+	entry := serve("/api/files/docs/file.txt")
+	assert.Equal(t, "GET", entry["method"])
+	assert.Equal(t, "/api/files/docs/file.txt", entry["path"])
+	assert.Equal(t, float64(http.StatusOK), entry["status"])
+	assert.Equal(t, float64(5), entry["bytes"])
+	assert.Equal(t, "192.0.2.1", entry["client"])
+	assert.Contains(t, entry, "durationMs")
+	assert.Contains(t, entry, "time")
+	assert.NotContains(t, entry, "query")
+
+	// The subject is hashed, identical for every request of the token
+	subject, ok := entry["subject"].(string)
+	require.True(t, ok)
+	assert.Len(t, subject, 16)
+	assert.NotContains(t, subject, "alice")
+
+	entry = serve("/api/files/docs/missing.txt")
+	assert.Equal(t, "/api/files/docs/missing.txt", entry["path"])
+	assert.Equal(t, float64(http.StatusNotFound), entry["status"])
+	assert.Equal(t, subject, entry["subject"])
+
+	// Static assets are logged as well, without a subject
+	entry = serve("/css/styles.css")
+	assert.Equal(t, "/css/styles.css", entry["path"])
+	assert.Equal(t, float64(http.StatusOK), entry["status"])
+	assert.NotContains(t, entry, "subject")
+}
+
+func TestAccessLogLevels(t *testing.T) {
+	tempDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "file.txt"), []byte("hello"), 0600))
+
+	logged := func(level, target string) []string {
+		srv := New(&config.Config{
+			Logging:     config.LoggingConfig{AccessLog: true, Level: level, SampleRate: 0},
+			Directories: []config.DirMapping{{Source: tempDir, Virtual: "/test"}},
+		})
+		var lines []string
+		srv.accessLogger.logf = func(format string, args ...any) {
+			lines = append(lines, fmt.Sprintf(format, args...))
+		}
+		req := httptest.NewRequest("GET", target, nil)
+		req.Header.Set("User-Agent", "curl/8.0")
+		srv.Router.ServeHTTP(httptest.NewRecorder(), req)
+		return lines
+	}
+
+	assert.Empty(t, logged("info", "/api/files/test/file.txt"), "sampled out")
+	assert.Empty(t, logged("error", "/api/files/test/file.txt"))
+	assert.Len(t, logged("error", "/api/files/test/missing.txt"), 1)
+
+	lines := logged("debug", "/api/files/test/file.txt?inline=true")
+	require.Len(t, lines, 1)
+	assert.Contains(t, lines[0], `query="inline=true"`)
+	assert.Contains(t, lines[0], `user_agent="curl/8.0"`)
+
+	lines = logged("debug", "/api/files/test/file.txt?token=eyJhbGciOi.secret&inline=true&%74oken=again")
+	require.Len(t, lines, 1)
+	assert.Contains(t, lines[0], `query="token=REDACTED&inline=true&%74oken=REDACTED"`)
+	assert.NotContains(t, lines[0], "secret")
+}
+
+func TestAccessLogDisabled(t *testing.T) {
+	srv := New(&config.Config{Directories: []config.DirMapping{{Source: t.TempDir(), Virtual: "/test"}}})
+	assert.Nil(t, srv.accessLogger)
+}
+
+func TestClientIP(t *testing.T) {
+	srv := New(&config.Config{
+		Logging:     config.LoggingConfig{TrustedProxies: []string{"10.0.0.0/8", "192.0.2.1"}},
+		Directories: []config.DirMapping{{Source: t.TempDir(), Virtual: "/test"}},
+	})
+
+	testCases := []struct {
+		name       string
+		remoteAddr string
+		forwarded  string
+		want       string
+	}{
+		{"direct client", "203.0.113.5:1234", "", "203.0.113.5"},
+		{"untrusted peer ignores header", "203.0.113.5:1234", "198.51.100.7", "203.0.113.5"},
+		{"trusted proxy", "192.0.2.1:1234", "198.51.100.7", "198.51.100.7"},
+		{"proxy chain", "10.0.0.2:1234", "198.51.100.7, 10.1.1.1", "198.51.100.7"},
+		{"spoofed leftmost entry", "10.0.0.2:1234", "1.2.3.4, 198.51.100.7", "198.51.100.7"},
+		{"trusted proxy without header", "10.0.0.2:1234", "", "10.0.0.2"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = tc.remoteAddr
+			if tc.forwarded != "" {
+				req.Header.Set("X-Forwarded-For", tc.forwarded)
+			}
+			assert.Equal(t, tc.want, srv.clientIP(req))
+		})
+	}
+}
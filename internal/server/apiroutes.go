@@ -0,0 +1,43 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// reroutedKey marks requests dispatched a second time after their path was
+// normalized, so that they are only logged once
+type reroutedKey struct{}
+
+// hasAPITrailingSlash matches API requests whose path ends in a slash
+func (s *Server) hasAPITrailingSlash(r *http.Request, _ *mux.RouteMatch) bool {
+	p := s.trimBasePath(r.URL.Path)
+	return strings.HasPrefix(p, "/api/") && strings.HasSuffix(p, "/")
+}
+
+// trimAPITrailingSlash serves an API request with a trailing slash like the
+// same path without it. Redirecting instead would make clients drop the body
+// of POST and PUT requests.
+func (s *Server) trimAPITrailingSlash(w http.ResponseWriter, r *http.Request) {
+	rerouted := r.Clone(context.WithValue(r.Context(), reroutedKey{}, true))
+	rerouted.URL.Path = strings.TrimRight(r.URL.Path, "/")
+	if rerouted.URL.RawPath != "" {
+		rerouted.URL.RawPath = strings.TrimRight(r.URL.RawPath, "/")
+	}
+	s.Router.ServeHTTP(w, rerouted)
+}
+
+// apiNotFound answers unknown API routes with a JSON error instead of falling
+// through to the web interface
+func (s *Server) apiNotFound(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusNotFound)
+	if err := json.NewEncoder(w).Encode(map[string]string{"error": "not found"}); err != nil {
+		log.Printf("Failed to encode not found response: %v", err)
+	}
+}
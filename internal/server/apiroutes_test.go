@@ -0,0 +1,89 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"dendrite/internal/config"
+)
+
+func TestAPITrailingSlash(t *testing.T) {
+	tmpDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "a.txt"), []byte("alpha"), 0600))
+	require.NoError(t, os.Mkdir(filepath.Join(tmpDir, "sub"), 0750))
+
+	srv := New(&config.Config{
+		Directories: []config.DirMapping{
+			{Source: tmpDir, Virtual: "/test"},
+		},
+	})
+
+	serve := func(method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("read routes answer identically", func(t *testing.T) {
+		for _, target := range []string{
+			"/api/mode",
+			"/api/files",
+			"/api/files?path=/test",
+			"/api/files/test/a.txt/stat",
+			"/api/files/test/a.txt/text",
+			"/api/files/test/sub/tree-hash",
+			"/api/files/test/a.txt",
+			"/api/exists?path=/test/a.txt",
+			"/api/quota",
+			"/api/recent",
+			"/api/empty-dirs?path=/test",
+		} {
+			withSlash := target
+			if i := strings.Index(target, "?"); i >= 0 {
+				withSlash = target[:i] + "/" + target[i:]
+			} else {
+				withSlash += "/"
+			}
+
+			plain := serve("GET", target, "")
+			slashed := serve("GET", withSlash, "")
+			require.Equal(t, http.StatusOK, plain.Code, target)
+			assert.Equal(t, plain.Code, slashed.Code, withSlash)
+			assert.Equal(t, plain.Header().Get("Content-Type"), slashed.Header().Get("Content-Type"), withSlash)
+			assert.Equal(t, plain.Body.String(), slashed.Body.String(), withSlash)
+		}
+	})
+
+	t.Run("write routes keep their body", func(t *testing.T) {
+		rec := serve("POST", "/api/mkdir/", `{"path":"/test/created"}`)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.DirExists(t, filepath.Join(tmpDir, "created"))
+
+		rec = serve("POST", "/api/files/test/a.txt/copy/", `{"destPath":"/test/b.txt"}`)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.FileExists(t, filepath.Join(tmpDir, "b.txt"))
+	})
+
+	t.Run("unknown routes answer JSON instead of the web interface", func(t *testing.T) {
+		for _, req := range []struct{ method, target string }{
+			{"GET", "/api"},
+			{"GET", "/api/"},
+			{"GET", "/api/unknown"},
+			{"GET", "/api/unknown/"},
+			{"PUT", "/api/files"},
+		} {
+			rec := serve(req.method, req.target, "")
+			assert.Equal(t, http.StatusNotFound, rec.Code, req.target)
+			assert.Equal(t, "application/json", rec.Header().Get("Content-Type"), req.target)
+			assert.NotContains(t, rec.Body.String(), "<html", req.target)
+		}
+	})
+}
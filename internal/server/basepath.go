@@ -0,0 +1,47 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// redirectToBasePath sends requests for the root, or for the base path
+// without trailing slash, to the application below the base path
+func (s *Server) redirectToBasePath(w http.ResponseWriter, r *http.Request) {
+	target := s.basePath + "/"
+	if r.URL.RawQuery != "" {
+		target += "?" + r.URL.RawQuery
+	}
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+// trimBasePath returns the request path relative to the base path
+func (s *Server) trimBasePath(urlPath string) string {
+	rel := strings.TrimPrefix(urlPath, s.basePath)
+	if rel == "" {
+		return "/"
+	}
+	return rel
+}
+
+// renderPage prepares an embedded HTML page for delivery: asset URLs are
+// mounted below the base path and versioned, and the runtime configuration
+// for the scripts is injected into the head.
+func (s *Server) renderPage(page []byte) []byte {
+	page = versionAssetURLs(page, s.basePath, s.assetVersion)
+
+	// json.Marshal escapes <, > and &, so the values cannot end the script
+	runtimeConfig, err := json.Marshal(struct {
+		BasePath string `json:"basePath"`
+		// DisabledOperations lets the interface hide the controls of
+		// operations the server rejects
+		DisabledOperations []string `json:"disabledOperations,omitempty"`
+	}{s.basePath, s.disabledOperations()})
+	if err != nil {
+		return page
+	}
+	script := []byte("<script>window.DENDRITE_CONFIG = " + string(runtimeConfig) + ";</script>\n</head>")
+	return bytes.Replace(page, []byte("</head>"), script, 1)
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"dendrite/internal/filesystem"
+)
+
+// resolveFileID finds the current virtual path of the file with the id of the
+// request. It writes the error response and returns false when the id does not
+// resolve.
+func (s *Server) resolveFileID(w http.ResponseWriter, r *http.Request) (*filesystem.Manager, string, bool) {
+	fs, err := s.getFilesystemForRequest(r)
+	if err != nil {
+		handleFilesystemError(w, err)
+		return nil, "", false
+	}
+
+	virtualPath, err := fs.ResolveFileID(mux.Vars(r)["id"])
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "invalid file id"):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case strings.Contains(err.Error(), "not found"):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return nil, "", false
+	}
+
+	// Clients can update stored paths from the current location
+	w.Header().Set("X-File-Path", virtualPath)
+	return fs, virtualPath, true
+}
+
+// getFileByID downloads a file by its stable id
+func (s *Server) getFileByID(w http.ResponseWriter, r *http.Request) {
+	fs, virtualPath, ok := s.resolveFileID(w, r)
+	if !ok {
+		return
+	}
+
+	filePath, err := fs.GetFilePath(virtualPath)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	s.serveDownload(w, r, filePath)
+}
+
+// statFileByID returns the stat information of a file or directory by its
+// stable id, including its current path
+func (s *Server) statFileByID(w http.ResponseWriter, r *http.Request) {
+	fs, virtualPath, ok := s.resolveFileID(w, r)
+	if !ok {
+		return
+	}
+
+	opts, err := statOptions(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	stat, err := fs.StatFileWithOptions(virtualPath, opts)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(stat); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
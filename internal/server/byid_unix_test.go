@@ -0,0 +1,64 @@
+//go:build !windows
+
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"dendrite/internal/config"
+	"dendrite/internal/filesystem"
+)
+
+func TestFileByID(t *testing.T) {
+	tmpDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "report.txt"), []byte("quarterly"), 0600))
+
+	srv := New(&config.Config{
+		Directories: []config.DirMapping{
+			{Source: tmpDir, Virtual: "/test"},
+		},
+	})
+
+	serve := func(method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := serve("GET", "/api/files/test/report.txt/stat", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var stat filesystem.FileStatInfo
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &stat))
+	require.NotEmpty(t, stat.ID)
+
+	rec = serve("GET", "/api/by-id/"+stat.ID, "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "quarterly", rec.Body.String())
+	assert.Equal(t, "/test/report.txt", rec.Header().Get("X-File-Path"))
+
+	rec = serve("POST", "/api/files/test/report.txt/move", `{"destPath":"/test/archive/2024.txt"}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	rec = serve("GET", "/api/by-id/"+stat.ID, "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "quarterly", rec.Body.String())
+	assert.Equal(t, "/test/archive/2024.txt", rec.Header().Get("X-File-Path"))
+
+	rec = serve("GET", "/api/by-id/"+stat.ID+"/stat", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"path":"/test/archive/2024.txt"`)
+
+	require.NoError(t, os.Remove(filepath.Join(tmpDir, "archive", "2024.txt")))
+	assert.Equal(t, http.StatusNotFound, serve("GET", "/api/by-id/"+stat.ID, "").Code)
+	assert.Equal(t, http.StatusBadRequest, serve("GET", "/api/by-id/not-an-id", "").Code)
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+)
+
+// defaultStaticMaxAge is used when static.max_age is not configured (one year)
+const defaultStaticMaxAge = 365 * 24 * 60 * 60
+
+// assetURLPattern matches references to embedded assets in the HTML pages
+var assetURLPattern = regexp.MustCompile(`((?:href|src)=")(/(?:css|js|img|images)/[^"?#]+)(")`)
+
+// computeAssetVersion hashes all embedded files so that asset URLs change
+// whenever the bundled UI changes.
+func computeAssetVersion(fsys fs.FS) (string, error) {
+	h := sha256.New()
+	err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return err
+		}
+		h.Write([]byte(p))
+		h.Write(data)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil))[:12], nil
+}
+
+// fileETag returns a validator for a downloaded file derived from its size
+// and modification time, so that it is stable without reading the content.
+// http.ServeFile compares it against If-None-Match and If-Range.
+func fileETag(info os.FileInfo) string {
+	return `"` + strconv.FormatInt(info.ModTime().UnixNano(), 16) + "-" + strconv.FormatInt(info.Size(), 16) + `"`
+}
+
+// versionAssetURLs prefixes every asset URL in page with the base path and
+// appends the asset version so that browsers fetch fresh copies after an
+// upgrade despite long cache times.
+func versionAssetURLs(page []byte, basePath, version string) []byte {
+	if basePath == "" && version == "" {
+		return page
+	}
+	suffix := ""
+	if version != "" {
+		suffix = "?v=" + version
+	}
+	return assetURLPattern.ReplaceAll(page, []byte("${1}"+basePath+"${2}"+suffix+"${3}"))
+}
+
+// staticCacheControl returns the Cache-Control value for static assets
+func (s *Server) staticCacheControl() string {
+	maxAge := s.Config.Static.MaxAge
+	if maxAge == 0 {
+		maxAge = defaultStaticMaxAge
+	}
+	if maxAge < 0 {
+		return "no-cache"
+	}
+	return "public, max-age=" + strconv.Itoa(maxAge)
+}
+
+// withStaticCache wraps a static file handler with caching headers
+func (s *Server) withStaticCache(next http.Handler) http.Handler {
+	cacheControl := s.staticCacheControl()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", cacheControl)
+		next.ServeHTTP(w, r)
+	})
+}
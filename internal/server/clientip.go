@@ -0,0 +1,104 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// parseTrustedProxies parses IP addresses and CIDR ranges of reverse proxies
+// whose forwarding headers may be trusted
+func parseTrustedProxies(entries []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(entries))
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy: %s", entry)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy: %s", entry)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+// isTrustedProxy reports whether ip belongs to a trusted proxy
+func (s *Server) isTrustedProxy(ip net.IP) bool {
+	for _, ipNet := range s.trustedProxies {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// peerAddress returns the address of the direct peer of a request and
+// whether it is a trusted proxy
+func (s *Server) peerAddress(r *http.Request) (string, bool) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	return host, ip != nil && s.isTrustedProxy(ip)
+}
+
+// clientIP returns the address of the client that sent the request. The
+// X-Forwarded-For header is only honored when the direct peer is a trusted
+// proxy; the chain is then walked from the right, skipping trusted proxies.
+func (s *Server) clientIP(r *http.Request) string {
+	host, trusted := s.peerAddress(r)
+	if !trusted {
+		return host
+	}
+
+	forwarded := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		hop := net.ParseIP(strings.TrimSpace(forwarded[i]))
+		if hop == nil {
+			break
+		}
+		if !s.isTrustedProxy(hop) {
+			return hop.String()
+		}
+		host = hop.String()
+	}
+	return host
+}
+
+// requestBaseURL returns the scheme and host the client sent the request to.
+// Behind a trusted proxy they are taken from X-Forwarded-Proto and
+// X-Forwarded-Host, where the proxy that received the request comes first.
+func (s *Server) requestBaseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	host := r.Host
+
+	if _, trusted := s.peerAddress(r); trusted {
+		proto, _, _ := strings.Cut(r.Header.Get("X-Forwarded-Proto"), ",")
+		if proto = strings.ToLower(strings.TrimSpace(proto)); proto == "http" || proto == "https" {
+			scheme = proto
+		}
+		forwardedHost, _, _ := strings.Cut(r.Header.Get("X-Forwarded-Host"), ",")
+		forwardedHost = strings.TrimSpace(forwardedHost)
+		if forwardedHost != "" && !strings.ContainsAny(forwardedHost, "/?#@") {
+			host = forwardedHost
+		}
+	}
+	return scheme + "://" + host
+}
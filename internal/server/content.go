@@ -0,0 +1,143 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"dendrite/internal/filesystem"
+)
+
+// maxContentSize caps the request body of a content save, matching the
+// largest file the editor loads as text
+const maxContentSize = filesystem.MaxTextFileSize
+
+// getFileContent sends a file for display in the browser, e.g. by the
+// editor. Unlike the download route it is always inline and reports the MIME
+// type of listings and stat; scriptable content is sent as plain text so
+// that it is never rendered.
+func (s *Server) getFileContent(w http.ResponseWriter, r *http.Request) {
+	virtualPath := mux.Vars(r)["path"]
+
+	fs, err := s.getFilesystemForRequest(r)
+	if err != nil {
+		handleFilesystemError(w, err)
+		return
+	}
+
+	stat, err := fs.StatFile(virtualPath)
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "not found"):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case strings.Contains(err.Error(), "access denied"):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	if stat.IsDir {
+		http.Error(w, "Path is a directory", http.StatusBadRequest)
+		return
+	}
+
+	filePath, err := fs.GetFilePath(virtualPath)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	info, err := os.Stat(filePath)
+	if err != nil {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+
+	contentType := stat.MimeType
+	if isScriptable(contentType) {
+		contentType = "text/plain; charset=utf-8"
+	}
+	w.Header().Set("Content-Disposition", contentDisposition(dispositionInline, filepath.Base(filePath)))
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("ETag", fileETag(info))
+	http.ServeFile(w, r, filePath)
+}
+
+// putFileContent replaces the content of a file with the request body, up to
+// maxContentSize bytes, and creates the file when it does not exist
+func (s *Server) putFileContent(w http.ResponseWriter, r *http.Request) {
+	virtualPath := mux.Vars(r)["path"]
+
+	fs, err := s.getFilesystemForRequest(r)
+	if err != nil {
+		handleFilesystemError(w, err)
+		return
+	}
+
+	defer func() {
+		_ = r.Body.Close()
+	}()
+
+	content, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxContentSize))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "content too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "Error reading request", http.StatusBadRequest)
+		return
+	}
+
+	if exists, isDir, err := fs.Exists(virtualPath); err == nil && exists && isDir {
+		http.Error(w, "Path is a directory", http.StatusBadRequest)
+		return
+	}
+
+	s.saveFile(w, fs, virtualPath, content)
+}
+
+// saveFile writes content to a file and answers the result, 201 for a new
+// file and 200 when an existing file was overwritten
+func (s *Server) saveFile(w http.ResponseWriter, fs *filesystem.Manager, virtualPath string, content []byte) {
+	result, err := fs.WriteFile(virtualPath, content)
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "quota exceeded"):
+			http.Error(w, "Quota exceeded", http.StatusInsufficientStorage)
+		case strings.Contains(err.Error(), "insufficient disk space"):
+			http.Error(w, err.Error(), http.StatusInsufficientStorage)
+		case strings.Contains(err.Error(), "access denied"):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		case os.IsNotExist(err), strings.Contains(err.Error(), "not found"):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	s.invalidateListings(fs, virtualPath)
+	s.stats.record(opSave, int64(len(content)), 0)
+
+	status := http.StatusOK
+	if result.Created {
+		status = http.StatusCreated
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(map[string]any{
+		"message": "File saved successfully",
+		"created": result.Created,
+		"size":    result.Size,
+	}); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,100 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"dendrite/internal/config"
+)
+
+func TestFileContent(t *testing.T) {
+	tmpDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(tmpDir, "docs"), 0750))
+	for name, content := range map[string]string{
+		"notes.txt":  "first draft\n",
+		"page.html":  "<script>alert(1)</script>",
+		"image":      "\x89PNG\r\n\x1a\n",
+		"docs/a.txt": "a",
+	} {
+		require.NoError(t, os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0600))
+	}
+	cfg := &config.Config{Directories: []config.DirMapping{{Source: tmpDir, Virtual: "/test"}}}
+	srv := New(cfg)
+	serve := func(method, target, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return rec
+	}
+
+	t.Run("read", func(t *testing.T) {
+		rec := serve("GET", "/api/files/test/notes.txt/content", "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "first draft\n", rec.Body.String())
+		assert.Equal(t, "text/plain", rec.Header().Get("Content-Type"))
+		assert.Equal(t, `inline; filename=notes.txt`, rec.Header().Get("Content-Disposition"))
+		assert.Equal(t, "nosniff", rec.Header().Get("X-Content-Type-Options"))
+
+		rec = serve("GET", "/api/files/test/image/content", "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "image/png", rec.Header().Get("Content-Type"))
+	})
+
+	t.Run("scriptable content is sent as text", func(t *testing.T) {
+		rec := serve("GET", "/api/files/test/page.html/content", "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "text/plain; charset=utf-8", rec.Header().Get("Content-Type"))
+	})
+
+	t.Run("edit and save round trip", func(t *testing.T) {
+		rec := serve("GET", "/api/files/test/notes.txt/content", "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		edited := rec.Body.String() + "second paragraph\n"
+
+		rec = serve("PUT", "/api/files/test/notes.txt/content", edited)
+		require.Equal(t, http.StatusOK, rec.Code)
+		var resp struct {
+			Created bool  `json:"created"`
+			Size    int64 `json:"size"`
+		}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		assert.False(t, resp.Created)
+		assert.Equal(t, int64(len(edited)), resp.Size)
+
+		rec = serve("GET", "/api/files/test/notes.txt/content", "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, edited, rec.Body.String())
+
+		rec = serve("PUT", "/api/files/test/docs/new.txt/content", "new")
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"size":3`)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, serve("GET", "/api/files/test/missing.txt/content", "").Code)
+		assert.Equal(t, http.StatusBadRequest, serve("GET", "/api/files/test/docs/content", "").Code)
+		assert.Equal(t, http.StatusBadRequest, serve("PUT", "/api/files/test/docs/content", "x").Code)
+		assert.Equal(t, http.StatusNotFound, serve("PUT", "/api/files/test/missing/a.txt/content", "x").Code)
+		assert.Equal(t, http.StatusNotFound, serve("GET", "/api/files/other/a.txt/content", "").Code)
+
+		rec := serve("PUT", "/api/files/test/notes.txt/content", strings.Repeat("x", maxContentSize+1))
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	})
+
+	t.Run("quota", func(t *testing.T) {
+		srv.Config.QuotaBytes = 100
+		defer func() { srv.Config.QuotaBytes = 0 }()
+		rec := serve("PUT", "/api/files/test/notes.txt/content", strings.Repeat("x", 200))
+		assert.Equal(t, http.StatusInsufficientStorage, rec.Code)
+		content, err := os.ReadFile(filepath.Join(tmpDir, "notes.txt"))
+		require.NoError(t, err)
+		assert.NotEqual(t, strings.Repeat("x", 200), string(content))
+	})
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultCORSMaxAge is used when cors.max_age is not configured
+const defaultCORSMaxAge = 10 * time.Minute
+
+// corsAllowedMethods are the methods of the API routes
+const corsAllowedMethods = "GET, HEAD, POST, PUT, DELETE, OPTIONS"
+
+// corsAllowedHeaders are the request headers a cross-origin client may send:
+// the JWT, request bodies and conditional or partial downloads
+const corsAllowedHeaders = "Authorization, Content-Type, Content-Encoding, Range, If-None-Match, If-Modified-Since"
+
+// corsExposedHeaders are the response headers beyond the CORS-safelisted ones
+// that cross-origin scripts may read
+const corsExposedHeaders = "Content-Disposition, ETag, X-Total-Count, X-Listing-Truncated, X-Skipped-Paths, " +
+	"X-Detected-Encoding, X-File-Path"
+
+// allowedOrigin returns the value of Access-Control-Allow-Origin for an
+// origin, or an empty string when the origin is not allowed
+func (s *Server) allowedOrigin(origin string) string {
+	for _, allowed := range s.Config.CORS.AllowedOrigins {
+		if allowed == "*" {
+			return "*"
+		}
+		if strings.EqualFold(allowed, origin) {
+			return origin
+		}
+	}
+	return ""
+}
+
+// corsMiddleware answers preflight requests and marks responses to allowed
+// origins as readable. Preflights are answered before authentication, as
+// browsers never send credentials with them. Requests from other origins
+// pass through unchanged, so that browsers block them.
+func (s *Server) corsMiddleware(next http.Handler) http.Handler {
+	maxAge := defaultCORSMaxAge
+	if s.Config.CORS.MaxAge > 0 {
+		maxAge = time.Duration(s.Config.CORS.MaxAge) * time.Second
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Origin")
+		allowed := s.allowedOrigin(origin)
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+		if preflight {
+			w.Header().Add("Vary", "Access-Control-Request-Method")
+			w.Header().Add("Vary", "Access-Control-Request-Headers")
+			if allowed == "" {
+				http.Error(w, "CORS origin not allowed", http.StatusForbidden)
+				return
+			}
+			w.Header().Set("Access-Control-Allow-Origin", allowed)
+			w.Header().Set("Access-Control-Allow-Methods", corsAllowedMethods)
+			w.Header().Set("Access-Control-Allow-Headers", corsAllowedHeaders)
+			w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(maxAge.Seconds())))
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		if allowed != "" {
+			w.Header().Set("Access-Control-Allow-Origin", allowed)
+			w.Header().Set("Access-Control-Expose-Headers", corsExposedHeaders)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// corsEnabled reports whether any cross-origin requests are allowed
+func (s *Server) corsEnabled() bool {
+	return len(s.Config.CORS.AllowedOrigins) > 0
+}
@@ -0,0 +1,102 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"dendrite/internal/auth"
+	"dendrite/internal/config"
+)
+
+func TestCORS(t *testing.T) {
+	const secret = "test-secret-key-that-is-long-enough"
+	const allowed = "https://app.example.com"
+	const other = "https://evil.example.com"
+
+	baseDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(baseDir, "docs"), 0750))
+	require.NoError(t, os.WriteFile(filepath.Join(baseDir, "docs", "a.txt"), []byte("a"), 0600))
+	token := signedToken(t, secret, "alice", []auth.DirMapping{{Source: "docs", Virtual: "/docs"}})
+
+	cfg := &config.Config{JWTSecret: secret, BaseDir: baseDir}
+	cfg.CORS.AllowedOrigins = []string{allowed}
+	srv := New(cfg)
+
+	preflight := func(origin string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("OPTIONS", "/api/files?path=/docs", nil)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", "GET")
+		req.Header.Set("Access-Control-Request-Headers", "authorization")
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+		return rec
+	}
+	list := func(srv *Server, origin string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/files?path=/docs", nil)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("preflight from allowed origin", func(t *testing.T) {
+		rec := preflight(allowed)
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		assert.Equal(t, allowed, rec.Header().Get("Access-Control-Allow-Origin"))
+		assert.Contains(t, rec.Header().Get("Access-Control-Allow-Methods"), "DELETE")
+		assert.Contains(t, rec.Header().Get("Access-Control-Allow-Headers"), "Authorization")
+		assert.Equal(t, "600", rec.Header().Get("Access-Control-Max-Age"))
+		assert.Contains(t, rec.Header().Values("Vary"), "Origin")
+	})
+
+	t.Run("preflight from other origin", func(t *testing.T) {
+		rec := preflight(other)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"))
+	})
+
+	t.Run("request from allowed origin", func(t *testing.T) {
+		rec := list(srv, allowed)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, allowed, rec.Header().Get("Access-Control-Allow-Origin"))
+		assert.Contains(t, rec.Header().Get("Access-Control-Expose-Headers"), "X-Total-Count")
+	})
+
+	t.Run("request from other origin", func(t *testing.T) {
+		rec := list(srv, other)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"))
+		assert.Empty(t, rec.Header().Get("Access-Control-Expose-Headers"))
+	})
+
+	t.Run("unauthorized request keeps CORS headers", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/api/files?path=/docs", nil)
+		req.Header.Set("Origin", allowed)
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.Equal(t, allowed, rec.Header().Get("Access-Control-Allow-Origin"))
+	})
+
+	t.Run("any origin", func(t *testing.T) {
+		cfg := &config.Config{JWTSecret: secret, BaseDir: baseDir}
+		cfg.CORS.AllowedOrigins = []string{"*"}
+		rec := list(New(cfg), other)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "*", rec.Header().Get("Access-Control-Allow-Origin"))
+	})
+
+	t.Run("disabled by default", func(t *testing.T) {
+		rec := list(New(&config.Config{JWTSecret: secret, BaseDir: baseDir}), allowed)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"))
+		assert.Empty(t, rec.Header().Values("Vary"))
+	})
+}
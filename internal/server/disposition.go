@@ -0,0 +1,140 @@
+package server
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	dispositionInline     = "inline"
+	dispositionAttachment = "attachment"
+)
+
+// scriptableTypes can execute script in the browser when rendered inline and
+// are therefore always served as attachment
+var scriptableTypes = map[string]bool{
+	"text/html":              true,
+	"application/xhtml+xml":  true,
+	"image/svg+xml":          true,
+	"text/xml":               true,
+	"application/xml":        true,
+	"text/javascript":        true,
+	"application/javascript": true,
+}
+
+// defaultInlineTypes may be displayed inline unless download.inline_types
+// is configured
+var defaultInlineTypes = []string{"image/*", "audio/*", "video/*", "application/pdf", "text/plain"}
+
+// detectContentType determines the media type of a file by its extension and
+// falls back to sniffing the first bytes of its content
+func detectContentType(filePath string) string {
+	if ct := mime.TypeByExtension(filepath.Ext(filePath)); ct != "" {
+		return ct
+	}
+
+	f, err := os.Open(filePath) // #nosec G304 - path is validated by the caller
+	if err != nil {
+		return "application/octet-stream"
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	buf := make([]byte, 512)
+	n, _ := f.Read(buf)
+	return http.DetectContentType(buf[:n])
+}
+
+// isScriptable reports whether the content type may run script when inline
+func isScriptable(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return true
+	}
+	return scriptableTypes[mediaType]
+}
+
+// inlineAllowed reports whether a file matches the inline safelist. Entries
+// starting with a dot match the file extension, entries ending in "/*" a
+// whole media type family and all others the exact media type. Scriptable
+// content is never allowed.
+func inlineAllowed(safelist []string, filename, contentType string) bool {
+	if isScriptable(contentType) {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	ext := strings.ToLower(filepath.Ext(filename))
+
+	for _, entry := range safelist {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		switch {
+		case strings.HasPrefix(entry, "."):
+			if ext == entry {
+				return true
+			}
+		case strings.HasSuffix(entry, "/*"):
+			if strings.HasPrefix(mediaType, strings.TrimSuffix(entry, "*")) {
+				return true
+			}
+		case mediaType == entry:
+			return true
+		}
+	}
+	return false
+}
+
+// requestedDisposition returns the disposition asked for by a download
+// request. inline=true is a shorthand for disposition=inline, which media
+// players can append to a download link; an explicit disposition wins.
+func requestedDisposition(r *http.Request) string {
+	query := r.URL.Query()
+	if disposition := query.Get("disposition"); disposition != "" {
+		return disposition
+	}
+	if query.Get("inline") == "true" {
+		return dispositionInline
+	}
+	return ""
+}
+
+// resolveDisposition picks the disposition for a download. The requested
+// value wins over the configured default, but only content on the inline
+// safelist is served inline.
+func (s *Server) resolveDisposition(requested, filename, contentType string) (string, error) {
+	disposition := s.Config.Download.Disposition
+	if requested != "" {
+		disposition = requested
+	}
+	switch strings.ToLower(disposition) {
+	case "", dispositionAttachment:
+		return dispositionAttachment, nil
+	case dispositionInline:
+		safelist := s.Config.Download.InlineTypes
+		if len(safelist) == 0 {
+			safelist = defaultInlineTypes
+		}
+		if !inlineAllowed(safelist, filename, contentType) {
+			return dispositionAttachment, nil
+		}
+		return dispositionInline, nil
+	default:
+		return "", fmt.Errorf("invalid disposition: %s (expected inline or attachment)", disposition)
+	}
+}
+
+// contentDisposition formats a Content-Disposition header value with a
+// properly escaped file name
+func contentDisposition(disposition, filename string) string {
+	if value := mime.FormatMediaType(disposition, map[string]string{"filename": filename}); value != "" {
+		return value
+	}
+	return disposition
+}
@@ -0,0 +1,156 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"dendrite/internal/config"
+)
+
+func TestDownloadDisposition(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"doc.pdf":     "%PDF-1.4\n",
+		"photo.png":   "\x89PNG\r\n\x1a\n",
+		"data.json":   `{"a":1}`,
+		"page.html":   "<script>alert(1)</script>",
+		"logo.svg":    `<svg xmlns="http://www.w3.org/2000/svg"></svg>`,
+		"noext":       "plain text content",
+		"ünïcode.txt": "x",
+	}
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0600))
+	}
+
+	download := func(cfg *config.Config, target string) *httptest.ResponseRecorder {
+		cfg.Directories = []config.DirMapping{{Source: tmpDir, Virtual: "/data"}}
+		rec := httptest.NewRecorder()
+		New(cfg).Router.ServeHTTP(rec, httptest.NewRequest("GET", target, nil))
+		return rec
+	}
+
+	t.Run("default is attachment", func(t *testing.T) {
+		rec := download(&config.Config{}, "/api/files/data/doc.pdf")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, `attachment; filename=doc.pdf`, rec.Header().Get("Content-Disposition"))
+		assert.Equal(t, "application/pdf", rec.Header().Get("Content-Type"))
+		assert.Equal(t, "nosniff", rec.Header().Get("X-Content-Type-Options"))
+	})
+
+	t.Run("inline pdf", func(t *testing.T) {
+		rec := download(&config.Config{}, "/api/files/data/doc.pdf?disposition=inline")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, `inline; filename=doc.pdf`, rec.Header().Get("Content-Disposition"))
+		assert.Equal(t, "application/pdf", rec.Header().Get("Content-Type"))
+	})
+
+	t.Run("html is forced to attachment", func(t *testing.T) {
+		rec := download(&config.Config{}, "/api/files/data/page.html?disposition=inline")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, `attachment; filename=page.html`, rec.Header().Get("Content-Disposition"))
+	})
+
+	t.Run("svg is forced to attachment", func(t *testing.T) {
+		rec := download(&config.Config{Download: config.DownloadConfig{Disposition: "inline"}},
+			"/api/files/data/logo.svg")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, `attachment; filename=logo.svg`, rec.Header().Get("Content-Disposition"))
+	})
+
+	t.Run("configured default inline", func(t *testing.T) {
+		rec := download(&config.Config{Download: config.DownloadConfig{Disposition: "inline"}},
+			"/api/files/data/doc.pdf")
+		assert.Equal(t, `inline; filename=doc.pdf`, rec.Header().Get("Content-Disposition"))
+
+		rec = download(&config.Config{Download: config.DownloadConfig{Disposition: "inline"}},
+			"/api/files/data/doc.pdf?disposition=attachment")
+		assert.Equal(t, `attachment; filename=doc.pdf`, rec.Header().Get("Content-Disposition"))
+	})
+
+	t.Run("content type is sniffed without extension", func(t *testing.T) {
+		rec := download(&config.Config{}, "/api/files/data/noext?disposition=inline")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "text/plain; charset=utf-8", rec.Header().Get("Content-Type"))
+		assert.Equal(t, `inline; filename=noext`, rec.Header().Get("Content-Disposition"))
+	})
+
+	t.Run("non-ascii file name", func(t *testing.T) {
+		rec := download(&config.Config{}, "/api/files/data/ünïcode.txt")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, `attachment; filename*=utf-8''%C3%BCn%C3%AFcode.txt`, rec.Header().Get("Content-Disposition"))
+	})
+
+	t.Run("image is displayed inline", func(t *testing.T) {
+		rec := download(&config.Config{}, "/api/files/data/photo.png?disposition=inline")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, `inline; filename=photo.png`, rec.Header().Get("Content-Disposition"))
+	})
+
+	t.Run("types outside the safelist are forced to attachment", func(t *testing.T) {
+		rec := download(&config.Config{}, "/api/files/data/data.json?disposition=inline")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, `attachment; filename=data.json`, rec.Header().Get("Content-Disposition"))
+	})
+
+	t.Run("configured safelist", func(t *testing.T) {
+		cfg := func() *config.Config {
+			return &config.Config{Download: config.DownloadConfig{InlineTypes: []string{".json", "application/pdf"}}}
+		}
+
+		rec := download(cfg(), "/api/files/data/data.json?disposition=inline")
+		assert.Equal(t, `inline; filename=data.json`, rec.Header().Get("Content-Disposition"))
+		rec = download(cfg(), "/api/files/data/photo.png?disposition=inline")
+		assert.Equal(t, `attachment; filename=photo.png`, rec.Header().Get("Content-Disposition"))
+	})
+
+	t.Run("safelist cannot allow scriptable content", func(t *testing.T) {
+		cfg := &config.Config{Download: config.DownloadConfig{InlineTypes: []string{".html", "text/html", "image/*"}}}
+		rec := download(cfg, "/api/files/data/page.html?disposition=inline")
+		assert.Equal(t, `attachment; filename=page.html`, rec.Header().Get("Content-Disposition"))
+
+		cfg = &config.Config{Download: config.DownloadConfig{InlineTypes: []string{"image/*"}}}
+		rec = download(cfg, "/api/files/data/logo.svg?disposition=inline")
+		assert.Equal(t, `attachment; filename=logo.svg`, rec.Header().Get("Content-Disposition"))
+	})
+
+	t.Run("invalid disposition", func(t *testing.T) {
+		rec := download(&config.Config{}, "/api/files/data/doc.pdf?disposition=preview")
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
+
+func TestDownloadRangeInline(t *testing.T) {
+	tmpDir := t.TempDir()
+	content := make([]byte, 1000)
+	for i := range content {
+		content[i] = byte(i)
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "clip.mp4"), content, 0600))
+
+	srv := New(&config.Config{Directories: []config.DirMapping{{Source: tmpDir, Virtual: "/data"}}})
+	req := httptest.NewRequest("GET", "/api/files/data/clip.mp4?inline=true", nil)
+	req.Header.Set("Range", "bytes=0-99")
+	rec := httptest.NewRecorder()
+	srv.Router.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusPartialContent, rec.Code)
+	assert.Equal(t, "bytes 0-99/1000", rec.Header().Get("Content-Range"))
+	assert.Equal(t, "bytes", rec.Header().Get("Accept-Ranges"))
+	assert.Equal(t, "video/mp4", rec.Header().Get("Content-Type"))
+	assert.Equal(t, `inline; filename=clip.mp4`, rec.Header().Get("Content-Disposition"))
+	assert.Equal(t, content[:100], rec.Body.Bytes())
+
+	t.Run("explicit disposition wins", func(t *testing.T) {
+		target := "/api/files/data/clip.mp4?inline=true&disposition=attachment"
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, httptest.NewRequest("GET", target, nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, `attachment; filename=clip.mp4`, rec.Header().Get("Content-Disposition"))
+	})
+}
@@ -0,0 +1,55 @@
+package server
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"dendrite/internal/filesystem"
+)
+
+// decodeContentEncoding wraps body with a decompressor for the given
+// Content-Encoding that enforces limits. An empty or identity encoding
+// returns body unchanged.
+func decodeContentEncoding(body io.Reader, encoding string, limits filesystem.DecompressionLimits) (io.Reader, error) {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "", "identity":
+		return body, nil
+	case "gzip", "x-gzip":
+		zr, err := limits.Reader(body, func(r io.Reader) (io.Reader, error) {
+			return gzip.NewReader(r)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("invalid gzip content: %w", err)
+		}
+		return zr, nil
+	case "deflate":
+		// HTTP deflate is the zlib format (RFC 9110)
+		zr, err := limits.Reader(body, func(r io.Reader) (io.Reader, error) {
+			return zlib.NewReader(r)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("invalid deflate content: %w", err)
+		}
+		return zr, nil
+	default:
+		return nil, fmt.Errorf("unsupported content encoding: %s", encoding)
+	}
+}
+
+// isDecompressionLimit reports whether err was caused by content expanding
+// beyond the decompression limits; such requests are answered with 413
+func isDecompressionLimit(err error) bool {
+	return strings.Contains(err.Error(), "decompression limit exceeded")
+}
+
+// contentEncodingStatus returns the status code for errors from decodeContentEncoding
+func contentEncodingStatus(err error) int {
+	if strings.Contains(err.Error(), "unsupported content encoding") {
+		return http.StatusUnsupportedMediaType
+	}
+	return http.StatusBadRequest
+}
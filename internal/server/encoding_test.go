@@ -0,0 +1,201 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"dendrite/internal/config"
+)
+
+func gzipBytes(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, err := zw.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+func deflateBytes(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	_, err := zw.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+// gzipPartRequest builds a multipart upload whose file part is gzip encoded
+func gzipPartRequest(t *testing.T, targetPath, filename string, compressed []byte) *http.Request {
+	t.Helper()
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	require.NoError(t, writer.WriteField("path", targetPath))
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", `form-data; name="file"; filename="`+filename+`"`)
+	header.Set("Content-Encoding", "gzip")
+	part, err := writer.CreatePart(header)
+	require.NoError(t, err)
+	_, err = part.Write(compressed)
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	req := httptest.NewRequest("POST", "/api/files", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
+func TestCompressedUploads(t *testing.T) {
+	tmpDir := t.TempDir()
+	srv := New(&config.Config{
+		Directories: []config.DirMapping{
+			{Source: tmpDir, Virtual: "/data"},
+		},
+		QuotaBytes: 2048,
+	})
+	content := strings.Repeat("compressible ", 20)
+
+	serve := func(req *http.Request) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+		return rec
+	}
+	stored := func(name string) string {
+		data, err := os.ReadFile(filepath.Join(tmpDir, name))
+		require.NoError(t, err)
+		return string(data)
+	}
+
+	t.Run("raw upload with gzip", func(t *testing.T) {
+		req := httptest.NewRequest("PUT", "/api/raw/data/raw-gzip.txt", bytes.NewReader(gzipBytes(t, []byte(content))))
+		req.Header.Set("Content-Encoding", "gzip")
+		rec := serve(req)
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		assert.Equal(t, content, stored("raw-gzip.txt"))
+	})
+
+	t.Run("raw upload with deflate", func(t *testing.T) {
+		req := httptest.NewRequest("PUT", "/api/raw/data/raw-deflate.txt", bytes.NewReader(deflateBytes(t, []byte(content))))
+		req.Header.Set("Content-Encoding", "deflate")
+		rec := serve(req)
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		assert.Equal(t, content, stored("raw-deflate.txt"))
+	})
+
+	t.Run("gzip encoded multipart body", func(t *testing.T) {
+		plain := uploadRequest(t, "/data", "multipart.txt", content)
+		body, err := io.ReadAll(plain.Body)
+		require.NoError(t, err)
+
+		req := httptest.NewRequest("POST", "/api/files", bytes.NewReader(gzipBytes(t, body)))
+		req.Header.Set("Content-Type", plain.Header.Get("Content-Type"))
+		req.Header.Set("Content-Encoding", "gzip")
+		rec := serve(req)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Equal(t, content, stored("multipart.txt"))
+	})
+
+	t.Run("gzip encoded part", func(t *testing.T) {
+		rec := serve(gzipPartRequest(t, "/data", "part.txt", gzipBytes(t, []byte(content))))
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Equal(t, content, stored("part.txt"))
+	})
+
+	t.Run("quota applies to decompressed size", func(t *testing.T) {
+		// Compresses to a few bytes but expands beyond the quota
+		bomb := gzipBytes(t, bytes.Repeat([]byte{0}, 4096))
+		req := httptest.NewRequest("PUT", "/api/raw/data/bomb.bin", bytes.NewReader(bomb))
+		req.Header.Set("Content-Encoding", "gzip")
+		rec := serve(req)
+		assert.Equal(t, http.StatusInsufficientStorage, rec.Code)
+		assert.NoFileExists(t, filepath.Join(tmpDir, "bomb.bin"))
+
+		rec = serve(gzipPartRequest(t, "/data", "bomb.bin", bomb))
+		assert.Equal(t, http.StatusInsufficientStorage, rec.Code)
+		assert.NoFileExists(t, filepath.Join(tmpDir, "bomb.bin"))
+	})
+
+	t.Run("unknown encoding is rejected", func(t *testing.T) {
+		req := httptest.NewRequest("PUT", "/api/raw/data/br.txt", strings.NewReader("data"))
+		req.Header.Set("Content-Encoding", "br")
+		rec := serve(req)
+		assert.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+		assert.NoFileExists(t, filepath.Join(tmpDir, "br.txt"))
+	})
+
+	t.Run("invalid gzip data is rejected", func(t *testing.T) {
+		req := httptest.NewRequest("PUT", "/api/raw/data/bad.txt", strings.NewReader("not gzip"))
+		req.Header.Set("Content-Encoding", "gzip")
+		rec := serve(req)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
+
+func TestCompressedUploadLimits(t *testing.T) {
+	tmpDir := t.TempDir()
+	srv := New(&config.Config{
+		Directories: []config.DirMapping{
+			{Source: tmpDir, Virtual: "/data"},
+		},
+		Decompression: config.DecompressionConfig{MaxRatio: 100},
+	})
+	serve := func(req *http.Request) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Four megabytes of zeros compress at a ratio of about 1000:1
+	bomb := gzipBytes(t, make([]byte, 4<<20))
+
+	t.Run("raw upload", func(t *testing.T) {
+		req := httptest.NewRequest("PUT", "/api/raw/data/bomb.bin", bytes.NewReader(bomb))
+		req.Header.Set("Content-Encoding", "gzip")
+		rec := serve(req)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+		assert.Contains(t, rec.Body.String(), "decompression limit exceeded")
+		assert.NoFileExists(t, filepath.Join(tmpDir, "bomb.bin"))
+	})
+
+	t.Run("gzip encoded part", func(t *testing.T) {
+		rec := serve(gzipPartRequest(t, "/data", "bomb.bin", bomb))
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+		assert.NoFileExists(t, filepath.Join(tmpDir, "bomb.bin"))
+	})
+
+	t.Run("gzip encoded multipart body", func(t *testing.T) {
+		plain := uploadRequest(t, "/data", "bomb.bin", string(make([]byte, 4<<20)))
+		body, err := io.ReadAll(plain.Body)
+		require.NoError(t, err)
+
+		req := httptest.NewRequest("POST", "/api/files", bytes.NewReader(gzipBytes(t, body)))
+		req.Header.Set("Content-Type", plain.Header.Get("Content-Type"))
+		req.Header.Set("Content-Encoding", "gzip")
+		rec := serve(req)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+		assert.NoFileExists(t, filepath.Join(tmpDir, "bomb.bin"))
+	})
+
+	t.Run("small payloads are not checked", func(t *testing.T) {
+		req := httptest.NewRequest("PUT", "/api/raw/data/zeros.bin", bytes.NewReader(gzipBytes(t, make([]byte, 64<<10))))
+		req.Header.Set("Content-Encoding", "gzip")
+		rec := serve(req)
+		assert.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	})
+}
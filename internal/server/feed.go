@@ -0,0 +1,204 @@
+package server
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/mux"
+
+	"dendrite/internal/auth"
+	"dendrite/internal/filesystem"
+	"dendrite/internal/format"
+)
+
+// atomFeed is an Atom feed (RFC 4287) of the files in a directory
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  atomAuthor  `xml:"author"`
+	Link    atomLink    `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomLink struct {
+	Href   string `xml:"href,attr"`
+	Rel    string `xml:"rel,attr,omitempty"`
+	Type   string `xml:"type,attr,omitempty"`
+	Length int64  `xml:"length,attr,omitempty"`
+}
+
+type atomEntry struct {
+	ID      string   `xml:"id"`
+	Title   string   `xml:"title"`
+	Updated string   `xml:"updated"`
+	Link    atomLink `xml:"link"`
+	Summary string   `xml:"summary"`
+}
+
+// feedTokenLifetime is how long the download token in the links of a feed is
+// valid. Feed readers receive fresh links with every refresh of the feed.
+const feedTokenLifetime = time.Hour
+
+// listFilesFeed serves the files of a directory as an Atom feed, newest
+// first. Each entry links to the file's download; in JWT mode the link
+// carries a download token so that feed readers can fetch it.
+func (s *Server) listFilesFeed(w http.ResponseWriter, r *http.Request) {
+	dirPath := r.URL.Query().Get("path")
+	if dirPath == "" {
+		dirPath = "/"
+	}
+
+	// Get filesystem manager with JWT restrictions if applicable
+	fs, err := s.getFilesystemForRequest(r)
+	if err != nil {
+		handleFilesystemError(w, err)
+		return
+	}
+
+	listing, err := s.cachedListDirectory(r, fs, dirPath)
+	if err != nil {
+		writeListingError(w, err)
+		return
+	}
+
+	// The listing may be shared through the cache, so sort a copy
+	sorted := append([]filesystem.FileInfo(nil), listing.Files...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].ModTime.After(sorted[j].ModTime)
+	})
+
+	base := s.requestBaseURL(r) + s.basePath
+	token, err := s.feedDownloadToken(r, dirPath)
+	if err != nil {
+		http.Error(w, "Failed to create download token", http.StatusInternalServerError)
+		return
+	}
+
+	updated := time.Now()
+	entries := make([]atomEntry, 0, len(sorted))
+	for _, file := range sorted {
+		if file.IsDir {
+			continue
+		}
+		if len(entries) == 0 {
+			updated = file.ModTime
+		}
+
+		download := base + "/api/files" + escapePath(file.Path)
+		href := download + "?disposition=attachment"
+		if token != "" {
+			href += "&token=" + url.QueryEscape(token)
+		}
+		entries = append(entries, atomEntry{
+			ID:      download,
+			Title:   file.Name,
+			Updated: file.ModTime.UTC().Format(time.RFC3339),
+			Link:    atomLink{Href: href, Rel: "enclosure", Type: file.MimeType, Length: file.Size},
+			Summary: format.FileSize(file.Size),
+		})
+	}
+
+	feedURL := base + "/api/files.atom?path=" + url.QueryEscape(dirPath)
+	feed := atomFeed{
+		ID:      feedURL,
+		Title:   "Dendrite: " + dirPath,
+		Updated: updated.UTC().Format(time.RFC3339),
+		Author:  atomAuthor{Name: "Dendrite"},
+		Link:    atomLink{Href: feedURL, Rel: "self", Type: "application/atom+xml"},
+		Entries: entries,
+	}
+
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	if _, err := w.Write([]byte(xml.Header)); err != nil {
+		return
+	}
+	if err := xml.NewEncoder(w).Encode(feed); err != nil {
+		http.Error(w, "Failed to encode feed", http.StatusInternalServerError)
+	}
+}
+
+// feedDownloadToken returns the token for the links of a feed: it may only
+// download files of the feed's directory and expires after
+// feedTokenLifetime, or earlier with the caller's token. Requests without
+// claims, in directory mode or for public paths, need no token.
+func (s *Server) feedDownloadToken(r *http.Request, dirPath string) (string, error) {
+	claims, ok := auth.GetClaimsFromContext(r.Context())
+	if !ok {
+		return "", nil
+	}
+
+	dirPath = path.Clean("/" + dirPath)
+	var scoped []auth.DirMapping
+	for _, dir := range claims.Directories {
+		virtual := path.Clean("/" + dir.Virtual)
+		if rel, ok := strings.CutPrefix(dirPath, virtual); ok && (rel == "" || virtual == "/" || rel[0] == '/') {
+			scoped = append(scoped, auth.DirMapping{Source: path.Join(dir.Source, rel), Virtual: dirPath})
+			break
+		}
+	}
+	if len(scoped) == 0 {
+		return "", nil // The virtual root of several mappings holds no files
+	}
+
+	expires := time.Now().Add(feedTokenLifetime)
+	if callerExpires, err := time.Parse(time.RFC3339, claims.Expires); err == nil && callerExpires.Before(expires) {
+		expires = callerExpires
+	}
+	if claims.ExpiresAt != nil && claims.ExpiresAt.Before(expires) {
+		expires = claims.ExpiresAt.Time
+	}
+	return auth.SignToken(&auth.Claims{
+		Directories:      scoped,
+		Expires:          expires.UTC().Format(time.RFC3339),
+		DownloadRate:     claims.DownloadRate,
+		Scope:            auth.ScopeDownload,
+		RegisteredClaims: jwt.RegisteredClaims{Subject: claims.Subject},
+	}, s.Config.JWTSecret)
+}
+
+// isFileDownload reports whether a request downloads a single file
+func (s *Server) isFileDownload(r *http.Request) bool {
+	target, ok := mux.Vars(r)["path"]
+	return ok && r.Method == http.MethodGet && s.trimBasePath(r.URL.Path) == "/api/files/"+target
+}
+
+// allowsQueryToken reports whether a request may authenticate with a token
+// in the query string: only reading the feed and downloading files, as feed
+// readers cannot send an Authorization header
+func (s *Server) allowsQueryToken(r *http.Request) bool {
+	return (r.Method == http.MethodGet && s.trimBasePath(r.URL.Path) == "/api/files.atom") || s.isFileDownload(r)
+}
+
+// restrictTokenScope rejects requests made with a scoped token that the scope
+// does not cover
+func (s *Server) restrictTokenScope(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if claims, ok := auth.GetClaimsFromContext(r.Context()); ok && claims.Scope != "" &&
+			(claims.Scope != auth.ScopeDownload || !s.isFileDownload(r)) {
+			http.Error(w, "Access denied: token is limited to file downloads", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// escapePath escapes every segment of a virtual path for use in a URL
+func escapePath(virtualPath string) string {
+	segments := strings.Split(path.Clean("/"+virtualPath), "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
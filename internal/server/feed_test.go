@@ -0,0 +1,187 @@
+package server
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"dendrite/internal/auth"
+	"dendrite/internal/config"
+)
+
+// writeFeedFiles creates files with increasing modification times
+func writeFeedFiles(t *testing.T, dir string, names ...string) {
+	t.Helper()
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for i, name := range names {
+		p := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(p, []byte(name), 0600))
+		modTime := base.Add(time.Duration(i) * time.Hour)
+		require.NoError(t, os.Chtimes(p, modTime, modTime))
+	}
+}
+
+func TestListFilesFeed(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeFeedFiles(t, tmpDir, "old.txt", "middle file.pdf", "new.zip")
+	require.NoError(t, os.Mkdir(filepath.Join(tmpDir, "sub"), 0750))
+
+	srv := New(&config.Config{Directories: []config.DirMapping{{Source: tmpDir, Virtual: "/downloads"}}})
+
+	req := httptest.NewRequest("GET", "/api/files.atom?path=/downloads", nil)
+	rec := httptest.NewRecorder()
+	srv.Router.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "application/atom+xml; charset=utf-8", rec.Header().Get("Content-Type"))
+
+	var feed atomFeed
+	require.NoError(t, xml.Unmarshal(rec.Body.Bytes(), &feed))
+	assert.Equal(t, "http://www.w3.org/2005/Atom", feed.XMLName.Space)
+	assert.Equal(t, "2024-05-01T14:00:00Z", feed.Updated)
+
+	require.Len(t, feed.Entries, 3)
+	assert.Equal(t, "new.zip", feed.Entries[0].Title)
+	assert.Equal(t, "middle file.pdf", feed.Entries[1].Title)
+	assert.Equal(t, "old.txt", feed.Entries[2].Title)
+	assert.Equal(t, "2024-05-01T12:00:00Z", feed.Entries[2].Updated)
+	assert.Equal(t, "http://example.com/api/files/downloads/middle%20file.pdf?disposition=attachment",
+		feed.Entries[1].Link.Href)
+	assert.Equal(t, "enclosure", feed.Entries[1].Link.Rel)
+
+	t.Run("enclosures are downloads", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, httptest.NewRequest("GET",
+			strings.TrimPrefix(feed.Entries[1].Link.Href, "http://example.com"), nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "application/pdf", rec.Header().Get("Content-Type"))
+		assert.True(t, strings.HasPrefix(rec.Header().Get("Content-Disposition"), "attachment;"),
+			rec.Header().Get("Content-Disposition"))
+		assert.Equal(t, "middle file.pdf", rec.Body.String())
+	})
+
+	t.Run("links follow a trusted proxy", func(t *testing.T) {
+		srv := New(&config.Config{
+			Logging:     config.LoggingConfig{TrustedProxies: []string{"10.0.0.0/8"}},
+			Directories: []config.DirMapping{{Source: tmpDir, Virtual: "/downloads"}},
+		})
+		feedFrom := func(remoteAddr string) atomFeed {
+			req := httptest.NewRequest("GET", "/api/files.atom?path=/downloads", nil)
+			req.RemoteAddr = remoteAddr
+			req.Header.Set("X-Forwarded-Proto", "https")
+			req.Header.Set("X-Forwarded-Host", "files.example.org")
+			rec := httptest.NewRecorder()
+			srv.Router.ServeHTTP(rec, req)
+			require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+			var feed atomFeed
+			require.NoError(t, xml.Unmarshal(rec.Body.Bytes(), &feed))
+			return feed
+		}
+
+		feed := feedFrom("10.0.0.2:1234")
+		assert.Equal(t, "https://files.example.org/api/files/downloads/new.zip?disposition=attachment",
+			feed.Entries[0].Link.Href)
+		assert.Equal(t, "https://files.example.org/api/files.atom?path=%2Fdownloads", feed.Link.Href)
+
+		feed = feedFrom("203.0.113.5:1234")
+		assert.Equal(t, "http://example.com/api/files/downloads/new.zip?disposition=attachment",
+			feed.Entries[0].Link.Href)
+	})
+
+	t.Run("missing directory", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/api/files.atom?path=/downloads/missing", nil)
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
+
+func TestListFilesFeedJWT(t *testing.T) {
+	baseDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(baseDir, "user", "downloads"), 0750))
+	writeFeedFiles(t, filepath.Join(baseDir, "user", "downloads"), "report.txt")
+
+	cfg := &config.Config{
+		JWTSecret: "test-secret-that-is-at-least-32-characters-long",
+		BaseDir:   baseDir,
+	}
+	srv := New(cfg)
+
+	claims := &auth.Claims{Directories: []auth.DirMapping{{Source: "user/downloads", Virtual: "/downloads"}}}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(cfg.JWTSecret))
+	require.NoError(t, err)
+
+	get := func(target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", target, nil)
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := get("/api/files.atom?path=/downloads&token=" + token)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var feed atomFeed
+	require.NoError(t, xml.Unmarshal(rec.Body.Bytes(), &feed))
+	require.Len(t, feed.Entries, 1)
+
+	link, err := url.Parse(feed.Entries[0].Link.Href)
+	require.NoError(t, err)
+	downloadToken := link.Query().Get("token")
+
+	t.Run("entry links carry a download token", func(t *testing.T) {
+		require.NotEmpty(t, downloadToken)
+		assert.NotEqual(t, token, downloadToken)
+
+		downloadClaims, err := auth.ValidateJWTString(downloadToken, cfg.JWTSecret)
+		require.NoError(t, err)
+		assert.Equal(t, auth.ScopeDownload, downloadClaims.Scope)
+		assert.Equal(t, []auth.DirMapping{{Source: "user/downloads", Virtual: "/downloads"}},
+			downloadClaims.Directories)
+		expires, err := time.Parse(time.RFC3339, downloadClaims.Expires)
+		require.NoError(t, err)
+		assert.WithinDuration(t, time.Now().Add(feedTokenLifetime), expires, time.Minute)
+
+		rec := get(strings.TrimPrefix(feed.Entries[0].Link.Href, "http://example.com"))
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "report.txt", rec.Body.String())
+	})
+
+	t.Run("download tokens only download files", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, get("/api/files.atom?path=/downloads&token="+downloadToken).Code)
+
+		for _, req := range []*http.Request{
+			httptest.NewRequest("GET", "/api/files?path=/downloads", nil),
+			httptest.NewRequest("GET", "/api/files/downloads/report.txt/stat", nil),
+			httptest.NewRequest("DELETE", "/api/files/downloads/report.txt", nil),
+			httptest.NewRequest("PUT", "/api/files/downloads/report.txt/content", strings.NewReader("changed")),
+		} {
+			req.Header.Set("Authorization", "Bearer "+downloadToken)
+			rec := httptest.NewRecorder()
+			srv.Router.ServeHTTP(rec, req)
+			assert.Equal(t, http.StatusForbidden, rec.Code, req.Method+" "+req.URL.Path)
+		}
+		assert.FileExists(t, filepath.Join(baseDir, "user", "downloads", "report.txt"))
+	})
+
+	t.Run("query token is limited to feeds and downloads", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, get("/api/files?path=/downloads&token="+token).Code)
+	})
+
+	t.Run("restrictions apply", func(t *testing.T) {
+		assert.NotEqual(t, http.StatusOK, get("/api/files.atom?path=/user&token="+token).Code)
+	})
+
+	t.Run("token is required", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, get("/api/files.atom?path=/downloads").Code)
+	})
+}
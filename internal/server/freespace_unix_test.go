@@ -0,0 +1,61 @@
+//go:build !windows
+
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"dendrite/internal/config"
+	"dendrite/internal/filesystem"
+)
+
+func TestMinFreeSpaceRejectsWrites(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg := &config.Config{
+		Directories: []config.DirMapping{
+			{Source: tmpDir, Virtual: "/test"},
+		},
+	}
+	// More headroom than any disk provides
+	cfg.Main.MinFreeSpaceBytes = 1 << 62
+	srv := New(cfg)
+
+	req := httptest.NewRequest("PUT", "/api/raw/test/file.txt", strings.NewReader("content"))
+	rec := httptest.NewRecorder()
+	srv.Router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusInsufficientStorage, rec.Code)
+	assert.Contains(t, rec.Body.String(), "insufficient disk space")
+	assert.NoFileExists(t, tmpDir+"/file.txt")
+}
+
+func TestQuotaReportsDirectorySpace(t *testing.T) {
+	srv := New(&config.Config{
+		Directories: []config.DirMapping{
+			{Source: t.TempDir(), Virtual: "/a"},
+			{Source: t.TempDir(), Virtual: "/b"},
+		},
+	})
+
+	rec := httptest.NewRecorder()
+	srv.Router.ServeHTTP(rec, httptest.NewRequest("GET", "/api/quota", nil))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var info filesystem.QuotaInfo
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &info))
+	require.Len(t, info.Directories, 2)
+	for i, virtual := range []string{"/a", "/b"} {
+		dir := info.Directories[i]
+		assert.Equal(t, virtual, dir.Virtual)
+		assert.NotEmpty(t, dir.Device)
+		assert.Positive(t, dir.Total)
+		assert.NotEmpty(t, dir.TotalHuman)
+	}
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+)
+
+// writeHealth encodes the response of a probe
+func writeHealth(w http.ResponseWriter, status int, response map[string]string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Failed to encode health response: %v", err)
+	}
+}
+
+// checkReadableDir fails unless dir is a directory whose entries can be read
+func checkReadableDir(dir string) error {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("not a directory")
+	}
+	f, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	if _, err := f.Readdirnames(1); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+// healthz is the liveness probe. It only shows that the server answers
+// requests and needs no token.
+func (s *Server) healthz(w http.ResponseWriter, _ *http.Request) {
+	writeHealth(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// readyz is the readiness probe. It checks that the configured directories,
+// or base_dir in JWT mode, can still be read, and names the first one that
+// cannot.
+func (s *Server) readyz(w http.ResponseWriter, _ *http.Request) {
+	for _, dir := range s.tempRoots() {
+		if err := checkReadableDir(dir); err != nil {
+			log.Printf("Readiness check failed for %s: %v", dir, err)
+			writeHealth(w, http.StatusServiceUnavailable, map[string]string{
+				"status": "unavailable",
+				"path":   dir,
+			})
+			return
+		}
+	}
+	writeHealth(w, http.StatusOK, map[string]string{"status": "ok"})
+}
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"dendrite/internal/config"
+)
+
+func TestHealthEndpoints(t *testing.T) {
+	probe := func(srv *Server, target string) (int, map[string]string) {
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, httptest.NewRequest("GET", target, nil))
+		var body map[string]string
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body), rec.Body.String())
+		return rec.Code, body
+	}
+
+	t.Run("directory mode", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		removed := filepath.Join(tmpDir, "removed")
+		require.NoError(t, os.Mkdir(removed, 0750))
+		srv := New(&config.Config{Directories: []config.DirMapping{
+			{Source: tmpDir, Virtual: "/data"},
+			{Source: removed, Virtual: "/removed"},
+		}})
+
+		code, body := probe(srv, "/healthz")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "ok", body["status"])
+
+		code, body = probe(srv, "/readyz")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "ok", body["status"])
+
+		require.NoError(t, os.Remove(removed))
+
+		code, _ = probe(srv, "/healthz")
+		assert.Equal(t, http.StatusOK, code)
+
+		code, body = probe(srv, "/readyz")
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		assert.Equal(t, "unavailable", body["status"])
+		assert.Equal(t, removed, body["path"])
+	})
+
+	t.Run("JWT mode needs no token", func(t *testing.T) {
+		baseDir := filepath.Join(t.TempDir(), "base")
+		require.NoError(t, os.Mkdir(baseDir, 0750))
+		srv := New(&config.Config{JWTSecret: "secret", BaseDir: baseDir})
+
+		code, _ := probe(srv, "/healthz")
+		assert.Equal(t, http.StatusOK, code)
+		code, _ = probe(srv, "/readyz")
+		assert.Equal(t, http.StatusOK, code)
+
+		require.NoError(t, os.Remove(baseDir))
+
+		code, body := probe(srv, "/readyz")
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		assert.Equal(t, baseDir, body["path"])
+	})
+
+	t.Run("below the base path", func(t *testing.T) {
+		cfg := &config.Config{Directories: []config.DirMapping{{Source: t.TempDir(), Virtual: "/data"}}}
+		cfg.Main.BasePath = "/dendrite"
+		srv := New(cfg)
+
+		code, body := probe(srv, "/dendrite/healthz")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "ok", body["status"])
+	})
+}
@@ -0,0 +1,63 @@
+//go:build !windows
+
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"dendrite/internal/config"
+	"dendrite/internal/filesystem"
+)
+
+func TestListFilesHardlinkIdentity(t *testing.T) {
+	tmpDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "original.txt"), []byte("data"), 0600))
+	require.NoError(t, os.Link(filepath.Join(tmpDir, "original.txt"), filepath.Join(tmpDir, "link.txt")))
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "other.txt"), []byte("data"), 0600))
+
+	srv := New(&config.Config{
+		Directories: []config.DirMapping{{Source: tmpDir, Virtual: "/data"}},
+	})
+	list := func(query string) map[string]filesystem.FileInfo {
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, httptest.NewRequest("GET", "/api/files?path=/data"+query, nil))
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		var files []filesystem.FileInfo
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &files))
+		byName := make(map[string]filesystem.FileInfo, len(files))
+		for _, f := range files {
+			byName[f.Name] = f
+		}
+		return byName
+	}
+
+	files := list("&meta=true")
+	require.Len(t, files, 3)
+	assert.NotZero(t, files["original.txt"].Inode)
+	assert.Equal(t, files["original.txt"].Inode, files["link.txt"].Inode)
+	assert.Equal(t, files["original.txt"].Device, files["link.txt"].Device)
+	assert.NotEqual(t, files["original.txt"].Inode, files["other.txt"].Inode)
+
+	t.Run("recursive listing", func(t *testing.T) {
+		files := list("&meta=true&recursive=true")
+		assert.Equal(t, files["original.txt"].Inode, files["link.txt"].Inode)
+	})
+
+	t.Run("omitted without meta", func(t *testing.T) {
+		for _, query := range []string{"", "&recursive=true"} {
+			for name, f := range list(query) {
+				assert.Zero(t, f.Inode, name)
+				assert.Zero(t, f.Device, name)
+			}
+		}
+	})
+}
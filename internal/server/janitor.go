@@ -0,0 +1,60 @@
+package server
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"dendrite/internal/filesystem"
+)
+
+// defaultTempMaxAge is used when temp_cleanup.max_age is not configured
+const defaultTempMaxAge = 24 * time.Hour
+
+// tempRoots returns the directories searched for orphaned temporary files:
+// the configured mappings, or base_dir in JWT mode
+func (s *Server) tempRoots() []string {
+	if s.Config.JWTSecret != "" {
+		return []string{s.Config.BaseDir}
+	}
+	roots := make([]string, 0, len(s.Config.Directories))
+	for _, dir := range s.Config.Directories {
+		roots = append(roots, dir.Source)
+	}
+	return roots
+}
+
+// cleanTemp removes temporary files older than the configured age from the
+// managed directories and the multipart spool directory
+func (s *Server) cleanTemp() []string {
+	maxAge := defaultTempMaxAge
+	if s.Config.TempCleanup.MaxAge > 0 {
+		maxAge = time.Duration(s.Config.TempCleanup.MaxAge) * time.Second
+	}
+	return filesystem.RemoveStaleTemp(s.tempRoots(), os.TempDir(), maxAge)
+}
+
+// StartTempJanitor periodically removes orphaned temporary files until ctx is
+// done. It does nothing unless temp_cleanup.interval is configured.
+func (s *Server) StartTempJanitor(ctx context.Context) {
+	if s.Config.TempCleanup.Interval <= 0 {
+		return
+	}
+	interval := time.Duration(s.Config.TempCleanup.Interval) * time.Second
+
+	go func() {
+		// Files orphaned by a crash are found right after the restart
+		s.cleanTemp()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.cleanTemp()
+			}
+		}
+	}()
+}
@@ -1,10 +1,12 @@
 package server
 
 import (
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strconv"
 	"testing"
 	"time"
 
@@ -224,4 +226,207 @@ func TestJWTWhitespaceOnlyFields(t *testing.T) {
 			assert.Contains(t, rec.Body.String(), tc.errMsg)
 		})
 	}
-}
\ No newline at end of file
+}
+
+// TestJWTConflictingVirtualPaths tests that tokens with ambiguous virtual paths are rejected
+func TestJWTConflictingVirtualPaths(t *testing.T) {
+	baseDir := t.TempDir()
+	for _, dir := range []string{"a", "b"} {
+		require.NoError(t, os.Mkdir(filepath.Join(baseDir, dir), 0750))
+	}
+
+	cfg := &config.Config{
+		JWTSecret: "test-secret-that-is-at-least-32-characters-long",
+		BaseDir:   baseDir,
+	}
+	srv := New(cfg)
+
+	testCases := []struct {
+		name       string
+		dirs       []auth.DirMapping
+		wantStatus int
+		errMsg     string
+	}{
+		{
+			name:       "duplicate virtual paths",
+			dirs:       []auth.DirMapping{{Source: "a", Virtual: "/docs"}, {Source: "b", Virtual: "/docs"}},
+			wantStatus: http.StatusBadRequest,
+			errMsg:     "conflicting virtual paths in token: duplicate virtual path: /docs",
+		},
+		{
+			name:       "duplicate after normalization",
+			dirs:       []auth.DirMapping{{Source: "a", Virtual: "/docs/"}, {Source: "b", Virtual: "/docs"}},
+			wantStatus: http.StatusBadRequest,
+			errMsg:     "conflicting virtual paths in token: duplicate virtual path: /docs",
+		},
+		{
+			name:       "prefix overlap",
+			dirs:       []auth.DirMapping{{Source: "a", Virtual: "/docs"}, {Source: "b", Virtual: "/docs/sub"}},
+			wantStatus: http.StatusBadRequest,
+			errMsg:     "conflicting virtual paths in token: virtual path /docs overlaps /docs/sub",
+		},
+		{
+			name:       "root overlaps everything",
+			dirs:       []auth.DirMapping{{Source: "a", Virtual: "/docs"}, {Source: "b", Virtual: "/"}},
+			wantStatus: http.StatusBadRequest,
+			errMsg:     "conflicting virtual paths in token: virtual path /docs overlaps /",
+		},
+		{
+			name:       "shared name prefix is no overlap",
+			dirs:       []auth.DirMapping{{Source: "a", Virtual: "/docs"}, {Source: "b", Virtual: "/docs2"}},
+			wantStatus: http.StatusOK,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			claims := &auth.Claims{
+				Directories: tc.dirs,
+				Expires:     time.Now().Add(time.Hour).Format(time.RFC3339),
+			}
+
+			token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+			tokenString, err := token.SignedString([]byte(cfg.JWTSecret))
+			require.NoError(t, err)
+
+			req := httptest.NewRequest("GET", "/api/files", nil)
+			req.Header.Set("Authorization", "Bearer "+tokenString)
+
+			rec := httptest.NewRecorder()
+			srv.Router.ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.wantStatus, rec.Code)
+			if tc.errMsg != "" {
+				assert.Contains(t, rec.Body.String(), tc.errMsg)
+			}
+		})
+	}
+}
+
+// TestJWTTooManyDirectories tests that tokens with more directories than
+// allowed are rejected before any directory is checked
+func TestJWTTooManyDirectories(t *testing.T) {
+	baseDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(baseDir, "dir"), 0750))
+
+	signedToken := func(t *testing.T, secret string, count int) string {
+		dirs := make([]auth.DirMapping, count)
+		for i := range dirs {
+			dirs[i] = auth.DirMapping{Source: "dir", Virtual: "/d" + strconv.Itoa(i)}
+		}
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, &auth.Claims{
+			Directories: dirs,
+			Expires:     time.Now().Add(time.Hour).Format(time.RFC3339),
+		})
+		tokenString, err := token.SignedString([]byte(secret))
+		require.NoError(t, err)
+		return tokenString
+	}
+
+	tests := []struct {
+		name           string
+		maxDirectories int
+		count          int
+		expectedStatus int
+	}{
+		{name: "over configured limit", maxDirectories: 3, count: 4, expectedStatus: http.StatusBadRequest},
+		{name: "at configured limit", maxDirectories: 3, count: 3, expectedStatus: http.StatusOK},
+		{name: "over default limit", count: defaultMaxTokenDirectories + 1, expectedStatus: http.StatusBadRequest},
+		{name: "limit disabled", maxDirectories: -1, count: defaultMaxTokenDirectories + 1,
+			expectedStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{
+				JWTSecret: "test-secret-that-is-at-least-32-characters-long",
+				BaseDir:   baseDir,
+				JWTAuth:   config.JWTAuthConfig{MaxDirectories: tt.maxDirectories},
+			}
+			srv := New(cfg)
+
+			req := httptest.NewRequest("GET", "/api/files?path=/d0", nil)
+			req.Header.Set("Authorization", "Bearer "+signedToken(t, cfg.JWTSecret, tt.count))
+			rec := httptest.NewRecorder()
+			srv.Router.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.expectedStatus, rec.Code, rec.Body.String())
+			if tt.expectedStatus == http.StatusBadRequest {
+				assert.Contains(t, rec.Body.String(), "directories, at most")
+			}
+		})
+	}
+}
+
+// TestVerifyToken tests that a valid token reports its scope without
+// exposing source directories
+func TestVerifyToken(t *testing.T) {
+	cfg := &config.Config{
+		JWTSecret: "test-secret-that-is-at-least-32-characters-long",
+		BaseDir:   t.TempDir(),
+	}
+	srv := New(cfg)
+
+	sign := func(t *testing.T, claims *auth.Claims, secret string) string {
+		tokenString, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+		require.NoError(t, err)
+		return tokenString
+	}
+	verify := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/auth/verify", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	expires := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	valid := &auth.Claims{
+		Directories: []auth.DirMapping{
+			{Source: "user123/documents", Virtual: "/documents"},
+			{Source: "shared/public", Virtual: "/public"},
+		},
+		Quota:   "100MB",
+		Expires: expires,
+	}
+
+	t.Run("valid token", func(t *testing.T) {
+		rec := verify(sign(t, valid, cfg.JWTSecret))
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		var resp map[string]any
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		assert.Equal(t, true, resp["valid"])
+		assert.Equal(t, []any{"/documents", "/public"}, resp["directories"])
+		assert.Equal(t, "100MB", resp["quota"])
+		assert.Equal(t, expires, resp["expires"])
+		assert.NotContains(t, rec.Body.String(), "user123")
+		assert.NotContains(t, rec.Body.String(), cfg.BaseDir)
+	})
+
+	t.Run("expired token", func(t *testing.T) {
+		expired := *valid
+		expired.Expires = time.Now().Add(-time.Hour).Format(time.RFC3339)
+		assert.Equal(t, http.StatusUnauthorized, verify(sign(t, &expired, cfg.JWTSecret)).Code)
+	})
+
+	t.Run("wrong signature", func(t *testing.T) {
+		rec := verify(sign(t, valid, "another-secret-that-is-at-least-32-characters"))
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+
+	t.Run("missing token", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, verify("").Code)
+	})
+
+	t.Run("directory mode", func(t *testing.T) {
+		dirSrv := New(&config.Config{
+			Directories: []config.DirMapping{{Source: t.TempDir(), Virtual: "/test"}},
+		})
+		rec := httptest.NewRecorder()
+		dirSrv.Router.ServeHTTP(rec, httptest.NewRequest("GET", "/api/auth/verify", nil))
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
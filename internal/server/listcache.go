@@ -0,0 +1,168 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"dendrite/internal/auth"
+	"dendrite/internal/filesystem"
+)
+
+// listingCacheSize bounds the number of cached directory listings
+const listingCacheSize = 1024
+
+// listingCacheEntry is a cached listing of one physical directory
+type listingCacheEntry struct {
+	listing  *filesystem.Listing
+	physical string
+	expires  time.Time
+}
+
+// listingCache keeps directory listings for a short time. Entries are keyed
+// by scope and virtual path, but invalidated by physical path so that a change
+// made through one token also refreshes listings cached for other tokens.
+type listingCache struct {
+	mu      sync.Mutex
+	entries map[string]listingCacheEntry
+	ttl     time.Duration
+	size    int
+	now     func() time.Time
+}
+
+// newListingCache creates a listing cache; it returns nil when ttl is not positive
+func newListingCache(ttl time.Duration, size int) *listingCache {
+	if ttl <= 0 {
+		return nil
+	}
+	return &listingCache{
+		entries: make(map[string]listingCacheEntry),
+		ttl:     ttl,
+		size:    size,
+		now:     time.Now,
+	}
+}
+
+// get returns the cached listing for key if it has not expired
+func (c *listingCache) get(key string) (*filesystem.Listing, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if !c.now().Before(entry.expires) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return entry.listing, true
+}
+
+// put stores a listing of the physical directory under key
+func (c *listingCache) put(key, physical string, listing *filesystem.Listing) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.entries) >= c.size {
+		now := c.now()
+		for k, entry := range c.entries {
+			if !now.Before(entry.expires) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= c.size {
+			return
+		}
+	}
+
+	c.entries[key] = listingCacheEntry{
+		listing:  listing,
+		physical: physical,
+		expires:  c.now().Add(c.ttl),
+	}
+}
+
+// invalidate drops listings of the physical path, its parent directory and
+// everything below it
+func (c *listingCache) invalidate(physical string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	parent := filepath.Dir(physical)
+	prefix := physical + string(filepath.Separator)
+	for key, entry := range c.entries {
+		if entry.physical == physical || entry.physical == parent || strings.HasPrefix(entry.physical, prefix) {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// clear drops all cached listings
+func (c *listingCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	clear(c.entries)
+}
+
+// listingCacheKey identifies a listing by the caller's directory permissions
+// and the virtual path. The full set of directories is hashed because the same
+// subject may hold tokens with different mappings.
+func listingCacheKey(r *http.Request, virtualPath string) string {
+	scope := ""
+	if claims, ok := auth.GetClaimsFromContext(r.Context()); ok {
+		data, err := json.Marshal(claims.Directories)
+		if err == nil {
+			sum := sha256.Sum256(data)
+			scope = hex.EncodeToString(sum[:])
+		}
+	}
+	return scope + "\x00" + virtualPath
+}
+
+// cachedListDirectory lists a directory, serving and filling the listing cache
+// when it is enabled
+func (s *Server) cachedListDirectory(r *http.Request, fs *filesystem.Manager, virtualPath string) (
+	*filesystem.Listing, error) {
+	if s.listings == nil {
+		return fs.ListDirectory(virtualPath)
+	}
+
+	key := listingCacheKey(r, virtualPath)
+	if listing, ok := s.listings.get(key); ok {
+		return listing, nil
+	}
+
+	listing, err := fs.ListDirectory(virtualPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// The virtual root of several mappings has no physical directory
+	if physical, err := fs.GetFilePath(virtualPath); err == nil {
+		s.listings.put(key, physical, listing)
+	}
+	return listing, nil
+}
+
+// invalidateListings drops cached listings affected by a change to the given
+// virtual paths. Paths without a physical directory, such as the virtual root
+// of several mappings, clear the whole cache.
+func (s *Server) invalidateListings(fs *filesystem.Manager, virtualPaths ...string) {
+	if s.listings == nil {
+		return
+	}
+	for _, virtualPath := range virtualPaths {
+		physical, err := fs.GetFilePath(virtualPath)
+		if err != nil {
+			s.listings.clear()
+			return
+		}
+		s.listings.invalidate(physical)
+	}
+}
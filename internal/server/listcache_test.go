@@ -0,0 +1,100 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"dendrite/internal/config"
+	"dendrite/internal/filesystem"
+)
+
+func TestListingCache(t *testing.T) {
+	tmpDir := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(tmpDir, "sub"), 0750))
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "sub", "a.txt"), []byte("a"), 0600))
+
+	srv := New(&config.Config{
+		Listing:     config.ListingConfig{CacheTTL: 60},
+		Directories: []config.DirMapping{{Source: tmpDir, Virtual: "/data"}},
+	})
+	now := time.Now()
+	srv.listings.now = func() time.Time { return now }
+
+	names := func() []string {
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, httptest.NewRequest("GET", "/api/files?path=/data/sub", nil))
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		var files []filesystem.FileInfo
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &files))
+		result := make([]string, 0, len(files))
+		for _, file := range files {
+			result = append(result, file.Name)
+		}
+		return result
+	}
+
+	assert.Equal(t, []string{"a.txt"}, names())
+
+	t.Run("served from cache within TTL", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "sub", "external.txt"), []byte("x"), 0600))
+		assert.Equal(t, []string{"a.txt"}, names())
+	})
+
+	t.Run("upload invalidates the directory", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, uploadRequest(t, "/data/sub", "b.txt", "b"))
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.ElementsMatch(t, []string{"a.txt", "b.txt", "external.txt"}, names())
+	})
+
+	t.Run("deleting the parent invalidates", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "sub", "c.txt"), []byte("c"), 0600))
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, httptest.NewRequest("DELETE", "/api/files/data/sub/a.txt", nil))
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.ElementsMatch(t, []string{"b.txt", "c.txt", "external.txt"}, names())
+	})
+
+	t.Run("chmod invalidates the directory", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "sub", "d.txt"), []byte("d"), 0600))
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, httptest.NewRequest("POST", "/api/files/data/sub/b.txt/chmod",
+			strings.NewReader(`{"mode": "0644"}`)))
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.ElementsMatch(t, []string{"b.txt", "c.txt", "d.txt", "external.txt"}, names())
+	})
+
+	t.Run("expires after TTL", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "sub", "e.txt"), []byte("e"), 0600))
+		now = now.Add(61 * time.Second)
+		assert.ElementsMatch(t, []string{"b.txt", "c.txt", "d.txt", "e.txt", "external.txt"}, names())
+	})
+}
+
+func TestListingCacheInvalidate(t *testing.T) {
+	cache := newListingCache(time.Minute, 10)
+	for _, dir := range []string{"/srv/a", "/srv/a/b", "/srv/a/b/c", "/srv/ab", "/srv"} {
+		cache.put(dir, dir, &filesystem.Listing{})
+	}
+
+	// A change to /srv/a/b affects its parent and everything below it
+	cache.invalidate("/srv/a/b")
+	for dir, want := range map[string]bool{
+		"/srv/a": false, "/srv/a/b": false, "/srv/a/b/c": false, "/srv/ab": true, "/srv": true,
+	} {
+		_, ok := cache.get(dir)
+		assert.Equal(t, want, ok, dir)
+	}
+
+	assert.Nil(t, newListingCache(0, 10))
+}
@@ -0,0 +1,291 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"dendrite/internal/filesystem"
+)
+
+// canonicalListPath normalizes the path of a listing request, so that
+// "/docs/", "docs" and "/docs//./" all list, cache and report "/docs"
+func canonicalListPath(virtualPath string) string {
+	return path.Clean("/" + virtualPath)
+}
+
+// breadcrumbSegment is one directory on the way from the virtual root to a
+// listed directory
+type breadcrumbSegment struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
+
+// listingPath describes the position of a listed directory, so that clients
+// can navigate upwards without manipulating paths themselves
+type listingPath struct {
+	Current    string              `json:"current"`
+	Parent     string              `json:"parent,omitempty"`
+	Breadcrumb []breadcrumbSegment `json:"breadcrumb"`
+}
+
+// breadcrumbListing is the response of a listing requested with
+// breadcrumb=true
+type breadcrumbListing struct {
+	Path  listingPath           `json:"path"`
+	Files []filesystem.FileInfo `json:"files"`
+}
+
+// newListingPath builds the path metadata of a canonical listing path. The
+// breadcrumb starts with the virtual root, which has no parent.
+func newListingPath(virtualPath string) listingPath {
+	lp := listingPath{
+		Current:    virtualPath,
+		Breadcrumb: []breadcrumbSegment{{Name: "/", Path: "/"}},
+	}
+	if virtualPath == "/" {
+		return lp
+	}
+
+	lp.Parent = path.Dir(virtualPath)
+	current := "/"
+	for _, name := range strings.Split(strings.TrimPrefix(virtualPath, "/"), "/") {
+		current = path.Join(current, name)
+		lp.Breadcrumb = append(lp.Breadcrumb, breadcrumbSegment{Name: name, Path: current})
+	}
+	return lp
+}
+
+// pagedListing is the response of a listing requested with a limit. Path is
+// set when breadcrumb=true was requested as well.
+type pagedListing struct {
+	Path *listingPath `json:"path,omitempty"`
+	filesystem.PagedResult
+}
+
+// listingPage holds the paging parameters of a listing request
+type listingPage struct {
+	offset, limit int
+}
+
+// listingSorted reports whether a listing request asks the server to sort
+func listingSorted(r *http.Request) bool {
+	query := r.URL.Query()
+	return query.Has("sort") || query.Has("order") || query.Has("limit")
+}
+
+// listingOrder reads the sort, order, dirsFirst, offset and limit parameters
+// of a listing request. Sorted listings keep directories first unless
+// dirsFirst=false; unsorted ones only with dirsFirst=true. The page is nil
+// without a limit, which keeps the plain array response.
+func listingOrder(r *http.Request) (filesystem.SortSpec, *listingPage, error) {
+	query := r.URL.Query()
+	dirsFirst := query.Get("dirsFirst") == "true" || (listingSorted(r) && query.Get("dirsFirst") != "false")
+	spec, err := filesystem.ParseSortSpec(query.Get("sort"), query.Get("order"), dirsFirst)
+	if err != nil || query.Get("limit") == "" {
+		return spec, nil, err
+	}
+
+	page := &listingPage{}
+	if page.limit, err = strconv.Atoi(query.Get("limit")); err != nil || page.limit < 1 {
+		return spec, nil, fmt.Errorf("invalid limit: must be a positive integer")
+	}
+	if v := query.Get("offset"); v != "" {
+		if page.offset, err = strconv.Atoi(v); err != nil || page.offset < 0 {
+			return spec, nil, fmt.Errorf("invalid offset: must not be negative")
+		}
+	}
+	return spec, page, nil
+}
+
+// wantsTextListing reports whether a directory listing should be rendered as
+// plain text instead of JSON. An explicit format parameter wins over the
+// Accept header so that JSON stays the default for browsers.
+func wantsTextListing(r *http.Request) bool {
+	switch r.URL.Query().Get("format") {
+	case "text":
+		return true
+	case "json":
+		return false
+	}
+	accept := r.Header.Get("Accept")
+	return strings.Contains(accept, "text/plain") && !strings.Contains(accept, "application/json")
+}
+
+// writeTextListing writes one entry per line. Directories get a trailing
+// slash. The long variant mimics the columns of `ls -l`.
+func writeTextListing(w io.Writer, files []filesystem.FileInfo, long bool) error {
+	for _, f := range files {
+		if err := writeTextEntry(w, f, f.Name, long); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeTextEntry writes a single line of a text listing for the entry, using
+// name as the displayed name
+func writeTextEntry(w io.Writer, f filesystem.FileInfo, name string, long bool) error {
+	if f.IsDir {
+		name += "/"
+	}
+	var err error
+	if long {
+		_, err = fmt.Fprintf(w, "%s %12d %s %s\n", f.Mode, f.Size, f.ModTime.UTC().Format("2006-01-02 15:04"), name)
+	} else {
+		_, err = fmt.Fprintln(w, name)
+	}
+	return err
+}
+
+// writeListingError maps a listing error to an HTTP status
+func writeListingError(w http.ResponseWriter, err error) {
+	switch {
+	case strings.Contains(err.Error(), "not found"):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case strings.Contains(err.Error(), "access denied"):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case strings.Contains(err.Error(), "too many directory entries"):
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// wantsFileIdentity reports whether inode and device numbers were requested
+func wantsFileIdentity(r *http.Request) bool {
+	return r.URL.Query().Get("meta") == "true"
+}
+
+// withoutFileIdentity returns a copy of files without inode and device
+// numbers. The input is not modified, since it may be a cached listing.
+func withoutFileIdentity(files []filesystem.FileInfo) []filesystem.FileInfo {
+	stripped := make([]filesystem.FileInfo, len(files))
+	for i, f := range files {
+		f.Inode, f.Device = 0, 0
+		stripped[i] = f
+	}
+	return stripped
+}
+
+// wantsNDJSONListing reports whether a recursive listing should be streamed
+// as newline-delimited JSON
+func wantsNDJSONListing(r *http.Request) bool {
+	if format := r.URL.Query().Get("format"); format != "" {
+		return format == "ndjson"
+	}
+	return strings.Contains(r.Header.Get("Accept"), "application/x-ndjson")
+}
+
+// streamFlushInterval is the number of entries after which a streamed
+// listing is flushed to the client
+const streamFlushInterval = 100
+
+// errListingLimit stops a recursive JSON listing at the entry limit
+var errListingLimit = errors.New("listing limit reached")
+
+// listFilesRecursive lists all entries below path. The ndjson and text
+// formats are streamed while the tree is walked; the JSON array is bounded
+// by the configured listing limit. Only entries of the given kind are
+// returned, although the walk descends into every directory.
+func (s *Server) listFilesRecursive(w http.ResponseWriter, r *http.Request, fs *filesystem.Manager,
+	path, kind string) {
+	depth := 0
+	if v := r.URL.Query().Get("depth"); v != "" {
+		d, err := strconv.Atoi(v)
+		if err != nil || d < 1 {
+			http.Error(w, "Invalid depth", http.StatusBadRequest)
+			return
+		}
+		depth = d
+	}
+
+	meta := wantsFileIdentity(r)
+	if wantsNDJSONListing(r) || wantsTextListing(r) {
+		ndjson := wantsNDJSONListing(r)
+		long := r.URL.Query().Get("long") == "true"
+		enc := json.NewEncoder(w)
+		flusher, _ := w.(http.Flusher)
+		count := 0
+
+		// http.Error replaces the content type if the walk fails up front
+		if ndjson {
+			w.Header().Set("Content-Type", "application/x-ndjson")
+		} else {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		}
+
+		err := fs.WalkFiles(path, depth, func(f filesystem.FileInfo) error {
+			if !filesystem.MatchesType(f, kind) {
+				return nil
+			}
+			if !meta {
+				f.Inode, f.Device = 0, 0
+			}
+			var err error
+			if ndjson {
+				err = enc.Encode(f)
+			} else {
+				err = writeTextEntry(w, f, f.Path, long)
+			}
+			count++
+			if flusher != nil && count%streamFlushInterval == 0 {
+				flusher.Flush()
+			}
+			return err
+		})
+		if err != nil {
+			// Once entries are sent, the status can no longer be changed
+			if count > 0 {
+				log.Printf("Recursive listing of %s failed mid-stream: %v", path, err)
+				return
+			}
+			writeListingError(w, err)
+			return
+		}
+		s.stats.record(opList, 0, 0)
+		return
+	}
+
+	limit := fs.MaxEntries()
+	files := []filesystem.FileInfo{}
+	truncated := false
+	err := fs.WalkFiles(path, depth, func(f filesystem.FileInfo) error {
+		if !filesystem.MatchesType(f, kind) {
+			return nil
+		}
+		if !meta {
+			f.Inode, f.Device = 0, 0
+		}
+		if limit >= 0 && len(files) >= limit {
+			truncated = true
+			return errListingLimit
+		}
+		files = append(files, f)
+		return nil
+	})
+	if err != nil && !errors.Is(err, errListingLimit) {
+		writeListingError(w, err)
+		return
+	}
+	if truncated {
+		if s.Config.Listing.OnLimit == "error" {
+			http.Error(w, fmt.Sprintf("too many directory entries: more than %d entries below %s", limit, path),
+				http.StatusRequestEntityTooLarge)
+			return
+		}
+		w.Header().Set("X-Listing-Truncated", "true")
+	}
+
+	s.stats.record(opList, 0, 0)
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(files); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
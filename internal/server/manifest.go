@@ -0,0 +1,64 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"dendrite/internal/filesystem"
+)
+
+// getManifest streams one JSON line per file below a path for sync clients
+// to compare against their local copy. The walk is streamed, so memory use
+// does not depend on the number of files.
+func (s *Server) getManifest(w http.ResponseWriter, r *http.Request) {
+	path := canonicalListPath(r.URL.Query().Get("path"))
+	mode := r.URL.Query().Get("mode")
+	if mode == "" {
+		mode = filesystem.ManifestFast
+	}
+	if err := filesystem.ValidateManifestMode(mode); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	depth := 0
+	if v := r.URL.Query().Get("depth"); v != "" {
+		d, err := strconv.Atoi(v)
+		if err != nil || d < 1 {
+			http.Error(w, "Invalid depth", http.StatusBadRequest)
+			return
+		}
+		depth = d
+	}
+
+	fs, err := s.getFilesystemForRequest(r)
+	if err != nil {
+		handleFilesystemError(w, err)
+		return
+	}
+
+	// http.Error replaces the content type if the walk fails up front
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	enc := json.NewEncoder(w)
+	flusher, _ := w.(http.Flusher)
+	count := 0
+	err = fs.WalkManifest(path, mode, depth, func(entry filesystem.ManifestEntry) error {
+		err := enc.Encode(entry)
+		count++
+		if flusher != nil && count%streamFlushInterval == 0 {
+			flusher.Flush()
+		}
+		return err
+	})
+	if err != nil {
+		// Once entries are sent, the status can no longer be changed
+		if count > 0 {
+			log.Printf("Manifest of %s failed mid-stream: %v", path, err)
+			return
+		}
+		writeListingError(w, err)
+		return
+	}
+	s.stats.record(opList, 0, 0)
+}
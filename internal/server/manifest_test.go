@@ -0,0 +1,117 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"dendrite/internal/auth"
+	"dendrite/internal/config"
+	"dendrite/internal/filesystem"
+)
+
+func TestManifest(t *testing.T) {
+	docs, other := t.TempDir(), t.TempDir()
+	contents := make(map[string]string)
+	for i := range 150 {
+		name := fmt.Sprintf("dir%d/file%d.txt", i%3, i)
+		contents["/docs/"+name] = fmt.Sprintf("content %d", i)
+		require.NoError(t, os.MkdirAll(filepath.Join(docs, filepath.Dir(name)), 0750))
+		require.NoError(t, os.WriteFile(filepath.Join(docs, name), []byte(contents["/docs/"+name]), 0600))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(other, "secret.txt"), []byte("secret"), 0600))
+
+	srv := New(&config.Config{
+		Directories: []config.DirMapping{
+			{Source: docs, Virtual: "/docs"},
+			{Source: other, Virtual: "/other"},
+		},
+	})
+	manifest := func(query string) (*httptest.ResponseRecorder, map[string]filesystem.ManifestEntry) {
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, httptest.NewRequest("GET", "/api/manifest"+query, nil))
+		entries := make(map[string]filesystem.ManifestEntry)
+		if rec.Code != http.StatusOK {
+			return rec, entries
+		}
+		assert.Equal(t, "application/x-ndjson", rec.Header().Get("Content-Type"))
+		scanner := bufio.NewScanner(bytes.NewReader(rec.Body.Bytes()))
+		for scanner.Scan() {
+			var entry filesystem.ManifestEntry
+			require.NoError(t, json.Unmarshal(scanner.Bytes(), &entry))
+			entries[entry.Path] = entry
+		}
+		return rec, entries
+	}
+
+	t.Run("covers all files", func(t *testing.T) {
+		rec, entries := manifest("?path=/docs")
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		require.Len(t, entries, len(contents))
+		for p, content := range contents {
+			assert.Equal(t, int64(len(content)), entries[p].Size, p)
+			assert.Empty(t, entries[p].Hash, p)
+		}
+	})
+
+	t.Run("full mode hashes match", func(t *testing.T) {
+		rec, entries := manifest("?path=/docs&mode=full")
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		require.Len(t, entries, len(contents))
+		for p, content := range contents {
+			sum := sha256.Sum256([]byte(content))
+			assert.Equal(t, hex.EncodeToString(sum[:]), entries[p].Hash, p)
+		}
+	})
+
+	t.Run("root covers all mappings", func(t *testing.T) {
+		_, entries := manifest("")
+		assert.Len(t, entries, len(contents)+1)
+		assert.Contains(t, entries, "/other/secret.txt")
+	})
+
+	t.Run("depth", func(t *testing.T) {
+		_, entries := manifest("?path=/docs&depth=1")
+		assert.Empty(t, entries)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		rec, _ := manifest("?path=/docs&mode=slow")
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		rec, _ = manifest("?path=/docs&depth=0")
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		rec, _ = manifest("?path=/docs/missing")
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
+
+func TestManifestRespectsTokenScope(t *testing.T) {
+	baseDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(baseDir, "alice"), 0750))
+	require.NoError(t, os.MkdirAll(filepath.Join(baseDir, "bob"), 0750))
+	require.NoError(t, os.WriteFile(filepath.Join(baseDir, "alice", "a.txt"), []byte("a"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(baseDir, "bob", "b.txt"), []byte("b"), 0600))
+
+	secret := "test-secret-that-is-at-least-32-characters-long"
+	srv := New(&config.Config{JWTSecret: secret, BaseDir: baseDir})
+	token := signedToken(t, secret, "alice", []auth.DirMapping{{Source: "alice", Virtual: "/mine"}})
+
+	req := httptest.NewRequest("GET", "/api/manifest?path=/", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	srv.Router.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Contains(t, rec.Body.String(), `"path":"/mine/a.txt"`)
+	assert.NotContains(t, rec.Body.String(), "b.txt")
+}
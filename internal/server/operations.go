@@ -0,0 +1,51 @@
+package server
+
+import (
+	"net/http"
+	"slices"
+
+	"dendrite/internal/config"
+)
+
+// readOnlyRoutes lists the API routes that are requested with POST but do not
+// modify any files, so that they remain available in read-only mode
+var readOnlyRoutes = []string{
+	"/api/download/zip", "/api/download/tar", "/api/download/targz", "/api/admin/quota/recalc",
+}
+
+// allowOperation wraps the handler of a write operation so that it answers
+// 403 when the operation is listed in disabled_operations
+func (s *Server) allowOperation(operation string, next http.HandlerFunc) http.HandlerFunc {
+	if !slices.Contains(s.Config.Main.DisabledOperations, operation) {
+		return next
+	}
+	return func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, "operation disabled: "+operation, http.StatusForbidden)
+	}
+}
+
+// disabledOperations returns the operations the server rejects, which are all
+// of them in read-only mode
+func (s *Server) disabledOperations() []string {
+	if s.Config.Main.ReadOnly {
+		return config.Operations
+	}
+	return s.Config.Main.DisabledOperations
+}
+
+// readOnlyMiddleware answers 403 to every API request that could modify files
+// when read_only is set. It only runs for matched routes, so unknown routes
+// and methods keep their 404 and 405 responses.
+func (s *Server) readOnlyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+		default:
+			if !slices.Contains(readOnlyRoutes, s.trimBasePath(r.URL.Path)) {
+				http.Error(w, "read-only mode: modifications are disabled on this server", http.StatusForbidden)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
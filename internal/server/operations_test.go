@@ -0,0 +1,172 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"dendrite/internal/config"
+)
+
+func TestDisabledOperations(t *testing.T) {
+	tmpDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "file.txt"), []byte("content"), 0600))
+
+	cfg := &config.Config{
+		Directories: []config.DirMapping{{Source: tmpDir, Virtual: "/data"}},
+	}
+	cfg.Main.DisabledOperations = []string{"upload", "edit", "mkdir", "move", "delete"}
+	srv := New(cfg)
+	serve := func(req *http.Request) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	post := func(target, body string) *http.Request {
+		return httptest.NewRequest("POST", target, strings.NewReader(body))
+	}
+	rejected := map[string]*http.Request{
+		"upload":       uploadRequest(t, "/data", "new.txt", "data"),
+		"raw upload":   httptest.NewRequest("PUT", "/api/raw/data/new.txt", strings.NewReader("data")),
+		"resumable":    post("/api/uploads", `{"path": "/data/new.txt", "size": 4}`),
+		"edit":         httptest.NewRequest("PUT", "/api/files/data/file.txt/raw", strings.NewReader("changed")),
+		"content save": httptest.NewRequest("PUT", "/api/files/data/file.txt/content", strings.NewReader("changed")),
+		"mkdir":        post("/api/mkdir", `{"path": "/data/new"}`),
+		"rename":       post("/api/files/data/file.txt/move", `{"destPath": "/data/renamed.txt"}`),
+		"batch move":   post("/api/move-batch", `{"sources": ["/data/file.txt"], "dest": "/data/sub"}`),
+		"bulk move":    post("/api/files/move", `{"sources": ["/data/file.txt"], "dest": "/data/sub"}`),
+		"delete":       httptest.NewRequest("DELETE", "/api/files/data/file.txt", nil),
+		"batch delete": post("/api/files/delete", `{"paths": ["/data/file.txt"]}`),
+		"cleanup dirs": post("/api/cleanup/empty-dirs", `{"path": "/data"}`),
+		"restore":      post("/api/trash/restore", `{"id": "00"}`),
+		"empty trash":  httptest.NewRequest("DELETE", "/api/trash", nil),
+	}
+	for name, req := range rejected {
+		t.Run(name+" is rejected", func(t *testing.T) {
+			rec := serve(req)
+			assert.Equal(t, http.StatusForbidden, rec.Code)
+			assert.Contains(t, rec.Body.String(), "operation disabled")
+		})
+	}
+
+	entries, err := os.ReadDir(tmpDir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	content, err := os.ReadFile(filepath.Join(tmpDir, "file.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "content", string(content))
+
+	t.Run("reads work", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, serve(httptest.NewRequest("GET", "/api/files?path=/data", nil)).Code)
+		rec := serve(httptest.NewRequest("GET", "/api/files/data/file.txt", nil))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "content", rec.Body.String())
+	})
+
+	t.Run("enabled operations work", func(t *testing.T) {
+		rec := serve(post("/api/files/data/file.txt/copy", `{"destPath": "/data/copy.txt"}`))
+		assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	})
+
+	t.Run("the interface receives the disabled operations", func(t *testing.T) {
+		rec := serve(httptest.NewRequest("GET", "/", nil))
+		assert.Contains(t, rec.Body.String(),
+			`"disabledOperations":["upload","edit","mkdir","move","delete"]`)
+	})
+}
+
+func TestReadOnlyMode(t *testing.T) {
+	tmpDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "file.txt"), []byte("content"), 0600))
+	require.NoError(t, os.Mkdir(filepath.Join(tmpDir, "empty"), 0750))
+
+	cfg := &config.Config{
+		Directories: []config.DirMapping{{Source: tmpDir, Virtual: "/data"}},
+	}
+	cfg.Main.ReadOnly = true
+	srv := New(cfg)
+	serve := func(req *http.Request) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	post := func(target, body string) *http.Request {
+		return httptest.NewRequest("POST", target, strings.NewReader(body))
+	}
+	rejected := map[string]*http.Request{
+		"upload":           uploadRequest(t, "/data", "new.txt", "data"),
+		"raw upload":       httptest.NewRequest("PUT", "/api/raw/data/new.txt", strings.NewReader("data")),
+		"resumable start":  post("/api/uploads", `{"path": "/data/new.txt", "size": 4}`),
+		"resumable chunk":  httptest.NewRequest("PUT", "/api/uploads/abc?offset=0", strings.NewReader("data")),
+		"resumable finish": post("/api/uploads/abc/complete", ""),
+		"resumable abort":  httptest.NewRequest("DELETE", "/api/uploads/abc", nil),
+		"edit":             httptest.NewRequest("PUT", "/api/files/data/file.txt/raw", strings.NewReader("changed")),
+		"content save":     httptest.NewRequest("PUT", "/api/files/data/file.txt/content", strings.NewReader("changed")),
+		"mkdir":            post("/api/mkdir", `{"path": "/data/new"}`),
+		"move":             post("/api/files/data/file.txt/move", `{"destPath": "/data/moved.txt"}`),
+		"rename":           post("/api/files/data/file.txt/rename", `{"newName": "renamed.txt"}`),
+		"copy":             post("/api/files/data/file.txt/copy", `{"destPath": "/data/copy.txt"}`),
+		"chmod":            post("/api/files/data/file.txt/chmod", `{"mode": "0644"}`),
+		"replace":          post("/api/files/data/empty/replace", ""),
+		"extract":          post("/api/files/data/file.txt/extract", `{"dest": "/data"}`),
+		"batch move":       post("/api/move-batch", `{"sources": ["/data/file.txt"], "dest": "/data/empty"}`),
+		"delete":           httptest.NewRequest("DELETE", "/api/files/data/file.txt", nil),
+		"batch delete":     post("/api/files/delete", `{"paths": ["/data/file.txt"]}`),
+		"bulk move":        post("/api/files/move", `{"sources": ["/data/file.txt"], "dest": "/data/empty"}`),
+		"bulk copy":        post("/api/files/copy", `{"sources": ["/data/file.txt"], "dest": "/data/empty"}`),
+		"cleanup dirs":     post("/api/cleanup/empty-dirs", `{"path": "/data"}`),
+		"restore":          post("/api/trash/restore", `{"id": "00"}`),
+		"empty trash":      httptest.NewRequest("DELETE", "/api/trash", nil),
+	}
+	for name, req := range rejected {
+		t.Run(name+" is rejected", func(t *testing.T) {
+			rec := serve(req)
+			assert.Equal(t, http.StatusForbidden, rec.Code)
+			assert.Contains(t, rec.Body.String(), "read-only mode")
+		})
+	}
+
+	entries, err := os.ReadDir(tmpDir)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	content, err := os.ReadFile(filepath.Join(tmpDir, "file.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "content", string(content))
+
+	allowed := map[string]*http.Request{
+		"listing":      httptest.NewRequest("GET", "/api/files?path=/data", nil),
+		"download":     httptest.NewRequest("GET", "/api/files/data/file.txt", nil),
+		"stat":         httptest.NewRequest("GET", "/api/files/data/file.txt/stat", nil),
+		"text":         httptest.NewRequest("GET", "/api/files/data/file.txt/text", nil),
+		"exists":       httptest.NewRequest("GET", "/api/exists?path=/data/file.txt", nil),
+		"quota":        httptest.NewRequest("GET", "/api/quota", nil),
+		"stats":        httptest.NewRequest("GET", "/api/stats", nil),
+		"zip download": post("/api/download/zip", `{"paths": ["/data/file.txt"]}`),
+		"tar download": post("/api/download/tar", `{"paths": ["/data/file.txt"]}`),
+		"tgz download": post("/api/download/targz", `{"paths": ["/data/file.txt"]}`),
+	}
+	for name, req := range allowed {
+		t.Run(name+" works", func(t *testing.T) {
+			rec := serve(req)
+			assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		})
+	}
+
+	t.Run("unknown routes are not found", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, serve(post("/api/unknown", "")).Code)
+	})
+
+	t.Run("the interface hides all operations", func(t *testing.T) {
+		rec := serve(httptest.NewRequest("GET", "/", nil))
+		assert.Contains(t, rec.Body.String(),
+			`"disabledOperations":["upload","edit","mkdir","move","copy","delete","chmod","replace"]`)
+	})
+}
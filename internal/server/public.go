@@ -0,0 +1,59 @@
+package server
+
+import (
+	"net/http"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"dendrite/internal/config"
+	"dendrite/internal/filesystem"
+)
+
+// newPublicFS creates the filesystem manager for the public paths of JWT
+// mode. Each public path is served from the same path below the base
+// directory, and nothing else is reachable through it.
+func newPublicFS(cfg *config.Config) *filesystem.Manager {
+	dirs := make([]config.DirMapping, len(cfg.JWTAuth.PublicPaths))
+	for i, publicPath := range cfg.JWTAuth.PublicPaths {
+		virtual := path.Clean("/" + publicPath)
+		dirs[i] = config.DirMapping{
+			Source:  filepath.Join(cfg.BaseDir, filepath.FromSlash(virtual)),
+			Virtual: virtual,
+		}
+	}
+	return filesystem.NewWithRestriction(cfg, dirs)
+}
+
+// isPublicRequest reports whether a request may be served without a token:
+// reading requests for a path within one of the public paths, and listing
+// the root, which then shows only the public paths
+func (s *Server) isPublicRequest(r *http.Request) bool {
+	if s.publicFS == nil || (r.Method != http.MethodGet && r.Method != http.MethodHead) {
+		return false
+	}
+
+	route := s.trimBasePath(r.URL.Path)
+	target, ok := mux.Vars(r)["path"]
+	switch {
+	case ok:
+	case route == "/api/files" || route == "/api/files.atom" || route == "/api/exists":
+		target = r.URL.Query().Get("path")
+	default:
+		return false
+	}
+
+	target = path.Clean("/" + target)
+	if target == "/" {
+		return route == "/api/files"
+	}
+	for _, publicPath := range s.Config.JWTAuth.PublicPaths {
+		publicPath = path.Clean("/" + publicPath)
+		if target == publicPath || strings.HasPrefix(target, publicPath+"/") {
+			return true
+		}
+	}
+	return false
+}
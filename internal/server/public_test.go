@@ -0,0 +1,96 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"dendrite/internal/auth"
+	"dendrite/internal/config"
+)
+
+func TestPublicPaths(t *testing.T) {
+	baseDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(baseDir, "public"), 0750))
+	require.NoError(t, os.MkdirAll(filepath.Join(baseDir, "private"), 0750))
+	require.NoError(t, os.WriteFile(filepath.Join(baseDir, "public", "readme.txt"), []byte("hello"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(baseDir, "private", "secret.txt"), []byte("secret"), 0600))
+
+	cfg := &config.Config{
+		JWTSecret: "test-secret-that-is-at-least-32-characters-long",
+		BaseDir:   baseDir,
+		JWTAuth:   config.JWTAuthConfig{PublicPaths: []string{"/public"}},
+	}
+	srv := New(cfg)
+
+	serve := func(method, target, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("public path is readable without a token", func(t *testing.T) {
+		rec := serve("GET", "/api/files?path=/public", "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), "readme.txt")
+
+		rec = serve("GET", "/api/files/public/readme.txt/raw", "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "hello", rec.Body.String())
+	})
+
+	t.Run("root lists only public paths", func(t *testing.T) {
+		rec := serve("GET", "/api/files?path=/", "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), "/public")
+		assert.NotContains(t, rec.Body.String(), "private")
+	})
+
+	t.Run("other paths require a token", func(t *testing.T) {
+		for _, target := range []string{
+			"/api/files?path=/private",
+			"/api/files/private/secret.txt/raw",
+			"/api/files?path=/public/../private",
+			"/api/quota",
+		} {
+			rec := serve("GET", target, "")
+			assert.Equal(t, http.StatusUnauthorized, rec.Code, target)
+		}
+	})
+
+	t.Run("writes to public paths require a token", func(t *testing.T) {
+		rec := serve("DELETE", "/api/files/public/readme.txt", "")
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+		req := httptest.NewRequest("PUT", "/api/files/public/new.txt/raw", strings.NewReader("x"))
+		rec = httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.NoFileExists(t, filepath.Join(baseDir, "public", "new.txt"))
+	})
+
+	t.Run("token requests use the token directories", func(t *testing.T) {
+		claims := &auth.Claims{
+			Directories: []auth.DirMapping{{Source: "private", Virtual: "/private"}},
+			Expires:     time.Now().Add(time.Hour).Format(time.RFC3339),
+		}
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(cfg.JWTSecret))
+		require.NoError(t, err)
+
+		rec := serve("GET", "/api/files/private/secret.txt/raw", token)
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "secret", rec.Body.String())
+	})
+}
@@ -0,0 +1,148 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"dendrite/internal/auth"
+	"dendrite/internal/filesystem"
+)
+
+const (
+	// recentUploadsSize is the number of uploads remembered per scope
+	recentUploadsSize = 50
+	// recentUploadsTTL is how long an upload stays in the feed
+	recentUploadsTTL = time.Hour
+)
+
+// RecentUpload is an entry of the recent uploads feed
+type RecentUpload struct {
+	filesystem.UploadResult
+	UploadedAt time.Time `json:"uploadedAt"`
+}
+
+// recentUploads keeps a ring buffer of successful uploads per scope
+type recentUploads struct {
+	mu      sync.Mutex
+	entries map[string][]RecentUpload
+	size    int
+	ttl     time.Duration
+	now     func() time.Time
+
+	// lastSweep is when expired entries of all scopes were last removed
+	lastSweep time.Time
+}
+
+// newRecentUploads creates an empty recent uploads store
+func newRecentUploads(size int, ttl time.Duration) *recentUploads {
+	return &recentUploads{
+		entries: make(map[string][]RecentUpload),
+		size:    size,
+		ttl:     ttl,
+		now:     time.Now,
+	}
+}
+
+// add records an upload for a scope, dropping the oldest entry when full
+func (ru *recentUploads) add(scope string, result filesystem.UploadResult) {
+	ru.mu.Lock()
+	defer ru.mu.Unlock()
+
+	// Periodically drop scopes that have not been accessed within the TTL
+	if ru.now().Sub(ru.lastSweep) > ru.ttl {
+		for other := range ru.entries {
+			ru.evict(other)
+		}
+		ru.lastSweep = ru.now()
+	}
+
+	entries := ru.evict(scope)
+	entries = append(entries, RecentUpload{UploadResult: result, UploadedAt: ru.now()})
+	if len(entries) > ru.size {
+		entries = entries[len(entries)-ru.size:]
+	}
+	ru.entries[scope] = entries
+}
+
+// list returns up to limit uploads of a scope, newest first
+func (ru *recentUploads) list(scope string, limit int) []RecentUpload {
+	ru.mu.Lock()
+	defer ru.mu.Unlock()
+
+	entries := ru.evict(scope)
+	if limit <= 0 || limit > len(entries) {
+		limit = len(entries)
+	}
+
+	result := make([]RecentUpload, 0, limit)
+	for i := len(entries) - 1; i >= 0 && len(result) < limit; i-- {
+		result = append(result, entries[i])
+	}
+	return result
+}
+
+// evict removes expired entries of a scope and returns the remaining ones.
+// The caller must hold the lock.
+func (ru *recentUploads) evict(scope string) []RecentUpload {
+	entries := ru.entries[scope]
+	cutoff := ru.now().Add(-ru.ttl)
+	i := 0
+	for i < len(entries) && entries[i].UploadedAt.Before(cutoff) {
+		i++
+	}
+	entries = entries[i:]
+	if len(entries) == 0 {
+		delete(ru.entries, scope)
+		return nil
+	}
+	ru.entries[scope] = entries
+	return entries
+}
+
+// requestScope identifies the caller for per-user state. In JWT mode this is
+// the token subject or, without a subject, a hash of the granted directories.
+// Without JWT authentication all clients share one scope.
+func requestScope(r *http.Request) string {
+	claims, ok := auth.GetClaimsFromContext(r.Context())
+	if !ok {
+		return ""
+	}
+	if claims.Subject != "" {
+		return "sub:" + claims.Subject
+	}
+
+	data, err := json.Marshal(claims.Directories)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return "dirs:" + hex.EncodeToString(sum[:])
+}
+
+func (s *Server) listRecentUploads(w http.ResponseWriter, r *http.Request) {
+	// Ensure the caller has a valid filesystem scope
+	if _, err := s.getFilesystemForRequest(r); err != nil {
+		handleFilesystemError(w, err)
+		return
+	}
+
+	limit := 0
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = parsed
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(s.recent.list(requestScope(r), limit)); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,146 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"dendrite/internal/auth"
+	"dendrite/internal/config"
+	"dendrite/internal/filesystem"
+)
+
+// uploadRequest builds a multipart upload request for a single file
+func uploadRequest(t *testing.T, targetPath, filename, content string) *http.Request {
+	t.Helper()
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	require.NoError(t, writer.WriteField("path", targetPath))
+	part, err := writer.CreateFormFile("file", filename)
+	require.NoError(t, err)
+	_, err = part.Write([]byte(content))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	req := httptest.NewRequest("POST", "/api/files", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
+// signedToken creates a JWT for the subject granting access to the given directories
+func signedToken(t *testing.T, secret, subject string, dirs []auth.DirMapping) string {
+	t.Helper()
+
+	claims := &auth.Claims{
+		Directories: dirs,
+		Expires:     time.Now().Add(time.Hour).Format(time.RFC3339),
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject: subject,
+		},
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	require.NoError(t, err)
+	return token
+}
+
+func TestRecentUploads(t *testing.T) {
+	baseDir := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(baseDir, "shared"), 0750))
+
+	cfg := &config.Config{
+		JWTSecret: "test-secret-that-is-at-least-32-characters-long",
+		BaseDir:   baseDir,
+	}
+	srv := New(cfg)
+
+	dirs := []auth.DirMapping{{Source: "shared", Virtual: "/shared"}}
+	alice := signedToken(t, cfg.JWTSecret, "alice", dirs)
+	bob := signedToken(t, cfg.JWTSecret, "bob", dirs)
+
+	upload := func(token, name string) {
+		req := uploadRequest(t, "/shared", name, "content of "+name)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	}
+
+	recent := func(token, query string) []RecentUpload {
+		req := httptest.NewRequest("GET", "/api/recent"+query, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		var uploads []RecentUpload
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &uploads))
+		return uploads
+	}
+
+	upload(alice, "one.txt")
+	upload(bob, "bob.txt")
+	upload(alice, "two.txt")
+	upload(alice, "three.txt")
+
+	t.Run("newest first", func(t *testing.T) {
+		uploads := recent(alice, "")
+		require.Len(t, uploads, 3)
+		assert.Equal(t, "/shared/three.txt", uploads[0].Path)
+		assert.Equal(t, "/shared/two.txt", uploads[1].Path)
+		assert.Equal(t, "/shared/one.txt", uploads[2].Path)
+		assert.Equal(t, int64(len("content of three.txt")), uploads[0].Size)
+	})
+
+	t.Run("scoped per subject", func(t *testing.T) {
+		uploads := recent(bob, "")
+		require.Len(t, uploads, 1)
+		assert.Equal(t, "/shared/bob.txt", uploads[0].Path)
+	})
+
+	t.Run("limit", func(t *testing.T) {
+		uploads := recent(alice, "?limit=1")
+		require.Len(t, uploads, 1)
+		assert.Equal(t, "/shared/three.txt", uploads[0].Path)
+	})
+
+	t.Run("requires authentication", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/api/recent", nil)
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+}
+
+func TestRecentUploadsEviction(t *testing.T) {
+	now := time.Now()
+	ru := newRecentUploads(2, time.Minute)
+	ru.now = func() time.Time { return now }
+
+	ru.add("scope", filesystem.UploadResult{Path: "/old"})
+	now = now.Add(2 * time.Minute)
+	ru.add("scope", filesystem.UploadResult{Path: "/a"})
+	ru.add("scope", filesystem.UploadResult{Path: "/b"})
+	ru.add("scope", filesystem.UploadResult{Path: "/c"})
+
+	// Ring buffer keeps the two newest entries
+	uploads := ru.list("scope", 0)
+	require.Len(t, uploads, 2)
+	assert.Equal(t, "/c", uploads[0].Path)
+	assert.Equal(t, "/b", uploads[1].Path)
+
+	// Entries expire after the TTL
+	now = now.Add(2 * time.Minute)
+	assert.Empty(t, ru.list("scope", 0))
+	assert.Empty(t, ru.list("other", 0))
+}
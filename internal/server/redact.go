@@ -0,0 +1,144 @@
+package server
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"dendrite/internal/config"
+)
+
+// redactedPath replaces physical paths in error messages sent to clients
+const redactedPath = "[path]"
+
+// errorRedactor keeps the server's directory layout out of error responses
+type errorRedactor struct {
+	// paths matches physical paths below the managed directories and the
+	// temp directory
+	paths *regexp.Regexp
+
+	// logf is replaceable for tests
+	logf func(format string, args ...any)
+}
+
+// newErrorRedactor builds a redactor for the physical directories of cfg
+func newErrorRedactor(cfg *config.Config) *errorRedactor {
+	roots := []string{os.TempDir()}
+	if cfg.BaseDir != "" {
+		roots = append(roots, cfg.BaseDir)
+	}
+	for _, dir := range cfg.Directories {
+		roots = append(roots, dir.Source)
+	}
+
+	// Longer roots first, so that nested sources are replaced as a whole
+	sort.Slice(roots, func(i, j int) bool { return len(roots[i]) > len(roots[j]) })
+	patterns := make([]string, 0, len(roots))
+	for _, root := range roots {
+		if root = filepath.Clean(root); root != string(filepath.Separator) {
+			patterns = append(patterns, regexp.QuoteMeta(root))
+		}
+	}
+	return &errorRedactor{
+		paths: regexp.MustCompile(`(?:` + strings.Join(patterns, "|") + `)(?:[/\\][^\s:"']*)?`),
+		logf:  log.Printf,
+	}
+}
+
+// redact returns the message sent to the client for an error response.
+// Internal errors are replaced by a reference to the logged detail, other
+// errors keep their message with physical paths removed.
+func (e *errorRedactor) redact(r *http.Request, status int, body []byte) []byte {
+	if status != http.StatusInternalServerError {
+		return e.paths.ReplaceAll(body, []byte(redactedPath))
+	}
+
+	ref := make([]byte, 4)
+	_, _ = rand.Read(ref)
+	id := hex.EncodeToString(ref)
+	e.logf("Internal error ref=%s method=%s path=%q: %s", id, r.Method, r.URL.Path, bytes.TrimSpace(body))
+	return []byte("Internal server error (ref " + id + ")\n")
+}
+
+// redactErrors is a middleware that passes error responses through the
+// redactor. Successful responses are streamed unchanged.
+func (s *Server) redactErrors(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The original request redacts the response of the rerouted one
+		if r.Context().Value(reroutedKey{}) != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		rw := &redactingWriter{ResponseWriter: w}
+		next.ServeHTTP(rw, r)
+		if rw.status == 0 {
+			return
+		}
+
+		body := s.redactor.redact(r, rw.status, rw.body.Bytes())
+		w.Header().Del("Content-Length")
+		if rw.status == http.StatusInternalServerError {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		}
+		w.WriteHeader(rw.status)
+		if _, err := w.Write(body); err != nil {
+			log.Printf("Failed to write error response: %v", err)
+		}
+	})
+}
+
+// redactingWriter holds back the body of error responses until the handler
+// is done, so that it can be redacted as a whole
+type redactingWriter struct {
+	http.ResponseWriter
+	committed bool
+	// status is set when an error response is held back
+	status int
+	body   bytes.Buffer
+}
+
+// WriteHeader holds back error responses and passes all others through
+func (rw *redactingWriter) WriteHeader(code int) {
+	if rw.committed {
+		return
+	}
+	rw.committed = true
+	if code >= 400 {
+		rw.status = code
+		return
+	}
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+// Write buffers the body of error responses
+func (rw *redactingWriter) Write(b []byte) (int, error) {
+	rw.committed = true
+	if rw.status != 0 {
+		return rw.body.Write(b)
+	}
+	return rw.ResponseWriter.Write(b)
+}
+
+// Unwrap returns the underlying writer for http.ResponseController
+func (rw *redactingWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// Flush sends buffered data to the client unless an error response is held back
+func (rw *redactingWriter) Flush() {
+	if rw.status != 0 {
+		return
+	}
+	if f, ok := rw.ResponseWriter.(http.Flusher); ok {
+		rw.committed = true
+		f.Flush()
+	}
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"dendrite/internal/config"
+)
+
+func TestRedactErrors(t *testing.T) {
+	tmpDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "file.txt"), []byte("content"), 0600))
+
+	newServer := func(redact bool) (*Server, *[]string) {
+		cfg := &config.Config{Directories: []config.DirMapping{{Source: tmpDir, Virtual: "/data"}}}
+		cfg.Main.RedactErrors = redact
+		srv := New(cfg)
+		var logged []string
+		if srv.redactor != nil {
+			srv.redactor.logf = func(format string, args ...any) {
+				logged = append(logged, fmt.Sprintf(format, args...))
+			}
+		}
+		return srv, &logged
+	}
+	// File names longer than the filesystem allows fail with an OS error
+	// that carries the physical path
+	mkdirTooLong := func(srv *Server) *httptest.ResponseRecorder {
+		body := `{"path": "/data/` + strings.Repeat("x", 300) + `"}`
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, httptest.NewRequest("POST", "/api/mkdir", strings.NewReader(body)))
+		return rec
+	}
+
+	t.Run("disabled by default", func(t *testing.T) {
+		srv, _ := newServer(false)
+		rec := mkdirTooLong(srv)
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.Contains(t, rec.Body.String(), tmpDir)
+	})
+
+	t.Run("internal errors are replaced by a reference", func(t *testing.T) {
+		srv, logged := newServer(true)
+		rec := mkdirTooLong(srv)
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.Equal(t, "text/plain; charset=utf-8", rec.Header().Get("Content-Type"))
+		assert.NotContains(t, rec.Body.String(), tmpDir)
+		assert.Regexp(t, `^Internal server error \(ref [0-9a-f]{8}\)\n$`, rec.Body.String())
+
+		require.Len(t, *logged, 1)
+		ref := strings.TrimSuffix(strings.TrimPrefix(rec.Body.String(), "Internal server error (ref "), ")\n")
+		assert.Contains(t, (*logged)[0], "ref="+ref)
+		assert.Contains(t, (*logged)[0], "failed to create directory")
+		assert.Contains(t, (*logged)[0], tmpDir)
+	})
+
+	t.Run("physical paths are removed from other errors", func(t *testing.T) {
+		srv, logged := newServer(true)
+		body := []byte("open " + tmpDir + "/sub/file.txt: permission denied\n")
+		assert.Equal(t, "open [path]: permission denied\n",
+			string(srv.redactor.redact(httptest.NewRequest("GET", "/", nil), http.StatusForbidden, body)))
+		assert.Empty(t, *logged)
+	})
+
+	t.Run("client errors keep their message", func(t *testing.T) {
+		srv, _ := newServer(true)
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, httptest.NewRequest("GET", "/api/files?path=/data/missing", nil))
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Equal(t, "directory not found: /data/missing\n", rec.Body.String())
+	})
+
+	t.Run("successful responses are untouched", func(t *testing.T) {
+		srv, _ := newServer(true)
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, httptest.NewRequest("GET", "/api/files/data/file.txt", nil))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "content", rec.Body.String())
+	})
+}
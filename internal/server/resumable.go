@@ -0,0 +1,205 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"dendrite/internal/config"
+)
+
+// defaultUploadSessionTTL is used when uploads.session_ttl is not configured
+const defaultUploadSessionTTL = 24 * time.Hour
+
+// uploadExpiryInterval is the longest time between two sweeps for expired
+// upload sessions
+const uploadExpiryInterval = time.Minute
+
+// uploadSessionTTL returns the configured lifetime of an idle upload session
+func uploadSessionTTL(cfg *config.Config) time.Duration {
+	if cfg.Uploads.SessionTTL > 0 {
+		return time.Duration(cfg.Uploads.SessionTTL) * time.Second
+	}
+	return defaultUploadSessionTTL
+}
+
+// StartUploadExpiry periodically removes expired upload sessions and their
+// partial files until ctx is done. Sessions are also expired whenever one is
+// looked up.
+func (s *Server) StartUploadExpiry(ctx context.Context) {
+	interval := min(uploadSessionTTL(s.Config), uploadExpiryInterval)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				for _, id := range s.uploads.Expire(now) {
+					log.Printf("Upload session %s expired", id)
+				}
+			}
+		}
+	}()
+}
+
+// writeUploadSessionError maps an error of a resumable upload to an HTTP status
+func writeUploadSessionError(w http.ResponseWriter, err error) {
+	switch {
+	case strings.Contains(err.Error(), "quota exceeded"),
+		strings.Contains(err.Error(), "insufficient disk space"),
+		strings.Contains(err.Error(), "too many entries in directory"):
+		http.Error(w, err.Error(), http.StatusInsufficientStorage)
+	case strings.Contains(err.Error(), "access denied"):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case strings.Contains(err.Error(), "not found"):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case strings.Contains(err.Error(), "offset mismatch"),
+		strings.Contains(err.Error(), "already exists"):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case strings.Contains(err.Error(), "exceeds declared size"):
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+	case strings.Contains(err.Error(), "invalid upload size"),
+		strings.Contains(err.Error(), "incomplete upload"):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// writeUploadResponse encodes a session state or upload result
+func writeUploadResponse(w http.ResponseWriter, status int, response any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+	}
+}
+
+// startUpload opens a resumable upload session for a file of known size
+func (s *Server) startUpload(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Path string `json:"path"`
+		Size *int64 `json:"size"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Path == "" || req.Size == nil {
+		http.Error(w, "Path and size are required", http.StatusBadRequest)
+		return
+	}
+
+	fs, err := s.getFilesystemForRequest(r)
+	if err != nil {
+		handleFilesystemError(w, err)
+		return
+	}
+
+	session, err := fs.StartUpload(s.uploads, req.Path, *req.Size)
+	if err != nil {
+		writeUploadSessionError(w, err)
+		return
+	}
+	writeUploadResponse(w, http.StatusCreated, session)
+}
+
+// uploadStatus reports the offset at which an upload continues
+func (s *Server) uploadStatus(w http.ResponseWriter, r *http.Request) {
+	fs, err := s.getFilesystemForRequest(r)
+	if err != nil {
+		handleFilesystemError(w, err)
+		return
+	}
+
+	session, err := fs.UploadStatus(s.uploads, mux.Vars(r)["id"])
+	if err != nil {
+		writeUploadSessionError(w, err)
+		return
+	}
+	writeUploadResponse(w, http.StatusOK, session)
+}
+
+// appendUpload stores the request body as the chunk at the given offset
+func (s *Server) appendUpload(w http.ResponseWriter, r *http.Request) {
+	offset, err := strconv.ParseInt(r.URL.Query().Get("offset"), 10, 64)
+	if err != nil || offset < 0 {
+		http.Error(w, "Invalid offset", http.StatusBadRequest)
+		return
+	}
+
+	fs, err := s.getFilesystemForRequest(r)
+	if err != nil {
+		handleFilesystemError(w, err)
+		return
+	}
+
+	defer func() {
+		_ = r.Body.Close()
+	}()
+
+	session, err := fs.AppendUpload(s.uploads, mux.Vars(r)["id"], offset, r.Body)
+	if err != nil {
+		writeUploadSessionError(w, err)
+		return
+	}
+	writeUploadResponse(w, http.StatusOK, session)
+}
+
+// completeUpload moves a fully received upload into place
+func (s *Server) completeUpload(w http.ResponseWriter, r *http.Request) {
+	fs, err := s.getFilesystemForRequest(r)
+	if err != nil {
+		handleFilesystemError(w, err)
+		return
+	}
+
+	result, err := fs.CompleteUpload(s.uploads, mux.Vars(r)["id"])
+	if err != nil {
+		writeUploadSessionError(w, err)
+		return
+	}
+	s.invalidateListings(fs, result.Path)
+
+	s.recent.add(requestScope(r), *result)
+	s.stats.record(opUpload, result.Size, 0)
+
+	if !s.extractUpload(w, fs, result) {
+		return
+	}
+
+	status := http.StatusOK
+	if result.Created {
+		status = http.StatusCreated
+	}
+	writeUploadResponse(w, status, result)
+}
+
+// abortUpload ends an upload session and removes the partial file
+func (s *Server) abortUpload(w http.ResponseWriter, r *http.Request) {
+	fs, err := s.getFilesystemForRequest(r)
+	if err != nil {
+		handleFilesystemError(w, err)
+		return
+	}
+
+	if err := fs.AbortUpload(s.uploads, mux.Vars(r)["id"]); err != nil {
+		writeUploadSessionError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(map[string]string{"status": "aborted"}); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
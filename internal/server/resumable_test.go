@@ -0,0 +1,86 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"dendrite/internal/config"
+	"dendrite/internal/filesystem"
+)
+
+func TestResumableUploadEndpoints(t *testing.T) {
+	tmpDir := t.TempDir()
+	srv := New(&config.Config{
+		Directories: []config.DirMapping{{Source: tmpDir, Virtual: "/data"}},
+	})
+	serve := func(method, target, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return rec
+	}
+
+	rec := serve("POST", "/api/uploads", `{"path": "/data/video.mp4", "size": 12}`)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var session filesystem.UploadSession
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &session))
+	require.NotEmpty(t, session.ID)
+	target := "/api/uploads/" + session.ID
+
+	rec = serve("PUT", target+"?offset=0", "hello ")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Contains(t, rec.Body.String(), `"offset":6`)
+
+	// A retried chunk after a lost response is rejected with the expected offset
+	rec = serve("PUT", target+"?offset=0", "hello ")
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Contains(t, rec.Body.String(), "expected 6")
+
+	rec = serve("GET", target, "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"offset":6`)
+
+	rec = serve("POST", target+"/complete", "")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = serve("PUT", target+"?offset=6", "world!")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = serve("POST", target+"/complete", "")
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	assert.Contains(t, rec.Body.String(), `"path":"/data/video.mp4"`)
+
+	content, err := os.ReadFile(filepath.Join(tmpDir, "video.mp4"))
+	require.NoError(t, err)
+	assert.Equal(t, "hello world!", string(content))
+
+	t.Run("finished sessions are gone", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, serve("PUT", target+"?offset=12", "x").Code)
+	})
+
+	t.Run("invalid requests", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, serve("POST", "/api/uploads", `{"path": "/data/x"}`).Code)
+		assert.Equal(t, http.StatusBadRequest, serve("POST", "/api/uploads", `{"path": "/data/x", "size": -1}`).Code)
+		assert.Equal(t, http.StatusBadRequest, serve("PUT", "/api/uploads/abc?offset=x", "").Code)
+		assert.Equal(t, http.StatusNotFound, serve("GET", "/api/uploads/unknown", "").Code)
+	})
+
+	t.Run("aborting removes the partial file", func(t *testing.T) {
+		rec := serve("POST", "/api/uploads", `{"path": "/data/aborted.bin", "size": 4}`)
+		require.Equal(t, http.StatusCreated, rec.Code)
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &session))
+
+		assert.Equal(t, http.StatusOK, serve("DELETE", "/api/uploads/"+session.ID, "").Code)
+		entries, err := os.ReadDir(tmpDir)
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		assert.Equal(t, "video.mp4", entries[0].Name())
+	})
+}
@@ -6,10 +6,15 @@ import (
 	"fmt"
 	"io"
 	"io/fs"
+	"log"
+	"net"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 
@@ -25,6 +30,41 @@ type Server struct {
 	FS     *filesystem.Manager
 	Router *mux.Router
 	webFS  fs.FS
+
+	// assetVersion is appended to asset URLs in the HTML pages for cache busting
+	assetVersion string
+
+	// notFoundPage holds the custom 404 page for unknown non-SPA routes
+	notFoundPage []byte
+
+	// recent tracks recent uploads per user scope
+	recent *recentUploads
+
+	// stats aggregates transfer volumes and operation counts
+	stats *serverStats
+
+	// trustedProxies lists reverse proxies whose forwarding headers are honored
+	trustedProxies []*net.IPNet
+
+	// accessLogger writes sampled access logs; nil when disabled
+	accessLogger *accessLogger
+
+	// redactor removes physical paths and internal details from error
+	// responses; nil when disabled
+	redactor *errorRedactor
+
+	// listings caches directory listings; nil when disabled
+	listings *listingCache
+
+	// basePath is the prefix all routes are mounted below, empty for the root
+	basePath string
+
+	// publicFS serves the public paths to requests without a token in JWT
+	// mode; nil when no public paths are configured
+	publicFS *filesystem.Manager
+
+	// uploads holds the sessions of resumable uploads
+	uploads *filesystem.UploadSessions
 }
 
 // New creates a new server instance
@@ -50,47 +90,189 @@ func New(cfg *config.Config) *Server {
 		FS:     fs,
 		Router: mux.NewRouter(),
 		webFS:  webFS,
+		recent: newRecentUploads(recentUploadsSize, recentUploadsTTL),
+		stats:  newServerStats(),
+	}
+
+	proxies, err := parseTrustedProxies(cfg.Logging.TrustedProxies)
+	if err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	s.trustedProxies = proxies
+
+	basePath, err := config.NormalizeBasePath(cfg.Main.BasePath)
+	if err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	s.basePath = basePath
+
+	if cfg.JWTSecret != "" && len(cfg.JWTAuth.PublicPaths) > 0 {
+		s.publicFS = newPublicFS(cfg)
+	}
+
+	s.listings = newListingCache(time.Duration(cfg.Listing.CacheTTL)*time.Second, listingCacheSize)
+	s.uploads = filesystem.NewUploadSessions(uploadSessionTTL(cfg))
+
+	if cfg.Logging.AccessLog {
+		s.accessLogger = newAccessLogger(cfg.Logging)
+	}
+
+	if cfg.Main.RedactErrors {
+		s.redactor = newErrorRedactor(cfg)
+	}
+
+	if version, err := computeAssetVersion(webFS); err != nil {
+		log.Printf("Warning: failed to compute asset version: %v", err)
+	} else {
+		s.assetVersion = version
+	}
+
+	if cfg.SPA.NotFoundPage != "" {
+		page, err := os.ReadFile(cfg.SPA.NotFoundPage)
+		if err != nil {
+			log.Printf("Warning: failed to load not_found_page %s: %v", cfg.SPA.NotFoundPage, err)
+		} else {
+			s.notFoundPage = page
+		}
 	}
 
 	s.setupRoutes()
 	return s
 }
 
+// defaultMaxTokenDirectories is used when jwt_auth.max_directories is not configured
+const defaultMaxTokenDirectories = 100
+
 func (s *Server) setupRoutes() {
+	if s.accessLogger != nil {
+		s.Router.Use(s.accessLog)
+	}
+	if s.redactor != nil {
+		s.Router.Use(s.redactErrors)
+	}
+	if s.corsEnabled() {
+		s.Router.Use(s.corsMiddleware)
+	}
+
+	// All routes are mounted below the base path when one is configured
+	root := s.Router
+	if s.basePath != "" {
+		s.Router.Path("/").HandlerFunc(s.redirectToBasePath)
+		s.Router.Path(s.basePath).HandlerFunc(s.redirectToBasePath)
+		root = s.Router.PathPrefix(s.basePath).Subrouter()
+	}
+
+	// API paths with a trailing slash are served like the path without it
+	root.MatcherFunc(s.hasAPITrailingSlash).HandlerFunc(s.trimAPITrailingSlash)
+
+	// Public API routes, registered before the subrouter so that they bypass
+	// the JWT middleware
+	root.HandleFunc("/api/mode", s.getMode).Methods("GET")
+
+	// Probes for container orchestration, which must not need a token and
+	// must not be answered by the web interface
+	root.HandleFunc("/healthz", s.healthz).Methods("GET", "HEAD")
+	root.HandleFunc("/readyz", s.readyz).Methods("GET", "HEAD")
+
 	// API routes
-	api := s.Router.PathPrefix("/api").Subrouter()
+	api := root.PathPrefix("/api").Subrouter()
+
+	// Reject modifications before authentication in read-only mode
+	if s.Config.Main.ReadOnly {
+		api.Use(s.readOnlyMiddleware)
+	}
 
 	// Apply JWT middleware if JWT secret is configured
 	if s.Config.JWTSecret != "" {
-		api.Use(auth.JWTMiddleware(s.Config.JWTSecret))
+		api.Use(auth.JWTMiddlewareWithOptions(s.Config.JWTSecret, auth.MiddlewareOptions{
+			MaxDirectories: s.maxTokenDirectories(),
+			QueryToken:     s.allowsQueryToken,
+			Public:         s.isPublicRequest,
+		}))
+		api.Use(s.restrictTokenScope)
+	}
+	if s.accessLogger != nil && s.Config.JWTSecret != "" {
+		api.Use(s.recordLogSubject)
 	}
 
+	api.HandleFunc("/auth/verify", s.verifyToken).Methods("GET")
 	api.HandleFunc("/files", s.listFiles).Methods("GET")
-	api.HandleFunc("/files", s.uploadFile).Methods("POST")
+	api.HandleFunc("/files.atom", s.listFilesFeed).Methods("GET")
+	api.HandleFunc("/files", s.allowOperation("upload", s.uploadFile)).Methods("POST")
+	api.HandleFunc("/files/delete", s.allowOperation("delete", s.deleteBatch)).Methods("POST")
+	api.HandleFunc("/files/move", s.allowOperation("move", s.moveInto)).Methods("POST")
+	api.HandleFunc("/files/copy", s.allowOperation("copy", s.copyInto)).Methods("POST")
 	api.HandleFunc("/files/{path:.+}/stat", s.statFile).Methods("GET")
-	api.HandleFunc("/files/{path:.+}/move", s.moveFile).Methods("POST")
-	api.HandleFunc("/files/{path:.+}/copy", s.copyFile).Methods("POST")
+	api.HandleFunc("/files/{path:.+}/move", s.allowOperation("move", s.moveFile)).Methods("POST")
+	api.HandleFunc("/files/{path:.+}/rename", s.allowOperation("move", s.renameFile)).Methods("POST")
+	api.HandleFunc("/files/{path:.+}/copy", s.allowOperation("copy", s.copyFile)).Methods("POST")
+	api.HandleFunc("/files/{path:.+}/chmod", s.allowOperation("chmod", s.chmodFile)).Methods("POST")
+	api.HandleFunc("/files/{path:.+}/replace", s.allowOperation("replace", s.replaceDirectory)).Methods("POST")
+	api.HandleFunc("/files/{path:.+}/extract", s.allowOperation("upload", s.extractArchive)).Methods("POST")
 	api.HandleFunc("/files/{path:.+}/raw", s.getFileRaw).Methods("GET")
-	api.HandleFunc("/files/{path:.+}/raw", s.putFileRaw).Methods("PUT")
+	api.HandleFunc("/files/{path:.+}/raw", s.allowOperation("edit", s.putFileRaw)).Methods("PUT")
+	api.HandleFunc("/files/{path:.+}/text", s.getFileText).Methods("GET")
+	api.HandleFunc("/files/{path:.+}/content", s.getFileContent).Methods("GET")
+	api.HandleFunc("/files/{path:.+}/content", s.allowOperation("edit", s.putFileContent)).Methods("PUT")
+	api.HandleFunc("/files/{path:.+}/tree-hash", s.getTreeHash).Methods("GET")
 	api.HandleFunc("/files/{path:.+}", s.getFile).Methods("GET")
-	api.HandleFunc("/files/{path:.+}", s.deleteFile).Methods("DELETE")
-	api.HandleFunc("/mkdir", s.createFolder).Methods("POST")
+	api.HandleFunc("/files/{path:.+}", s.allowOperation("delete", s.deleteFile)).Methods("DELETE")
+	api.HandleFunc("/raw/{path:.+}", s.allowOperation("upload", s.uploadRaw)).Methods("PUT")
+	api.HandleFunc("/uploads", s.allowOperation("upload", s.startUpload)).Methods("POST")
+	api.HandleFunc("/uploads/{id}", s.allowOperation("upload", s.uploadStatus)).Methods("GET")
+	api.HandleFunc("/uploads/{id}", s.allowOperation("upload", s.appendUpload)).Methods("PUT")
+	api.HandleFunc("/uploads/{id}", s.allowOperation("upload", s.abortUpload)).Methods("DELETE")
+	api.HandleFunc("/uploads/{id}/complete", s.allowOperation("upload", s.completeUpload)).Methods("POST")
+	api.HandleFunc("/by-id/{id}", s.getFileByID).Methods("GET")
+	api.HandleFunc("/by-id/{id}/stat", s.statFileByID).Methods("GET")
+	api.HandleFunc("/move-batch", s.allowOperation("move", s.moveBatch)).Methods("POST")
+	api.HandleFunc("/mkdir", s.allowOperation("mkdir", s.createFolder)).Methods("POST")
+	api.HandleFunc("/exists", s.checkExists).Methods("GET")
+	api.HandleFunc("/manifest", s.getManifest).Methods("GET")
 	api.HandleFunc("/download/zip", s.downloadZip).Methods("POST")
+	api.HandleFunc("/download/tar", s.downloadTar).Methods("POST")
+	api.HandleFunc("/download/targz", s.downloadTarGz).Methods("POST")
 	api.HandleFunc("/quota", s.getQuotaInfo).Methods("GET")
+	api.HandleFunc("/admin/quota/recalc", s.recalculateQuota).Methods("POST")
+	api.HandleFunc("/stats", s.getStats).Methods("GET")
+	api.HandleFunc("/recent", s.listRecentUploads).Methods("GET")
+	api.HandleFunc("/empty-dirs", s.listEmptyDirs).Methods("GET")
+	api.HandleFunc("/cleanup/empty-dirs", s.allowOperation("delete", s.cleanupEmptyDirs)).Methods("POST")
+	api.HandleFunc("/trash", s.listTrash).Methods("GET")
+	api.HandleFunc("/trash", s.allowOperation("delete", s.emptyTrash)).Methods("DELETE")
+	api.HandleFunc("/trash/restore", s.allowOperation("delete", s.restoreTrash)).Methods("POST")
+
+	// Unknown API routes, and known ones requested with another method, must
+	// not fall through to the web interface
+	root.Path("/api").HandlerFunc(s.apiNotFound)
+	root.PathPrefix("/api/").HandlerFunc(s.apiNotFound)
 
 	// Static files (frontend)
 	// Serve static assets from embedded filesystem
-	fileServer := http.FileServer(http.FS(s.webFS))
-	s.Router.PathPrefix("/css/").Handler(fileServer)
-	s.Router.PathPrefix("/js/").Handler(fileServer)
-	s.Router.PathPrefix("/img/").Handler(fileServer)
-	s.Router.PathPrefix("/images/").Handler(fileServer)
+	fileServer := s.withStaticCache(http.StripPrefix(s.basePath, http.FileServer(http.FS(s.webFS))))
+	root.PathPrefix("/css/").Handler(fileServer)
+	root.PathPrefix("/js/").Handler(fileServer)
+	root.PathPrefix("/img/").Handler(fileServer)
+	root.PathPrefix("/images/").Handler(fileServer)
 
 	// Serve editor.html for the editor route
-	s.Router.Path("/editor.html").HandlerFunc(s.serveEditor)
+	root.Path("/editor.html").HandlerFunc(s.serveEditor)
 
 	// For all other routes, serve index.html to support client-side routing
-	s.Router.PathPrefix("/").HandlerFunc(s.serveIndex)
+	root.PathPrefix("/").HandlerFunc(s.serveIndex)
+}
+
+// maxTokenDirectories returns the configured limit of directory mappings per
+// token, or -1 when the limit is disabled
+func (s *Server) maxTokenDirectories() int {
+	limit := s.Config.JWTAuth.MaxDirectories
+	if limit == 0 {
+		return defaultMaxTokenDirectories
+	}
+	if limit < 0 {
+		return -1
+	}
+	return limit
 }
 
 // getFilesystemForRequest returns a filesystem manager with JWT restrictions if applicable
@@ -103,6 +285,9 @@ func (s *Server) getFilesystemForRequest(r *http.Request) (*filesystem.Manager,
 
 	// JWT is enabled - NEVER fall back to default filesystem
 	claims, ok := auth.GetClaimsFromContext(r.Context())
+	if !ok && s.isPublicRequest(r) {
+		return s.publicFS, nil
+	}
 	if !ok {
 		return nil, fmt.Errorf("no valid JWT claims found")
 	}
@@ -111,6 +296,15 @@ func (s *Server) getFilesystemForRequest(r *http.Request) (*filesystem.Manager,
 		return nil, fmt.Errorf("JWT token contains no directory permissions")
 	}
 
+	// Reject ambiguous tokens before doing any per-directory work
+	virtualPaths := make([]string, len(claims.Directories))
+	for i, dir := range claims.Directories {
+		virtualPaths[i] = dir.Virtual
+	}
+	if err := config.ValidateVirtualPaths(virtualPaths, false); err != nil {
+		return nil, fmt.Errorf("conflicting virtual paths in token: %w", err)
+	}
+
 	// In JWT mode, directories are relative to base_dir
 	jwtDirs := make([]config.DirMapping, len(claims.Directories))
 	for i, dir := range claims.Directories {
@@ -156,10 +350,83 @@ func (s *Server) getFilesystemForRequest(r *http.Request) (*filesystem.Manager,
 	}
 
 	// Create a new filesystem manager with JWT directory restrictions
-	return filesystem.NewWithRestriction(s.Config, jwtDirs), nil
+	fs := filesystem.NewWithRestriction(s.Config, jwtDirs)
+
+	// The token's quota replaces the server quota; usage is counted across
+	// the token's directories
+	if claims.Quota != "" {
+		quota, err := config.ParseQuotaValue(claims.Quota)
+		if err != nil || quota <= 0 {
+			return nil, fmt.Errorf("invalid quota in token: %s", claims.Quota)
+		}
+		fs.QuotaBytes = quota
+	}
+	return fs, nil
+}
+
+// handleFilesystemError writes the HTTP error for a failed getFilesystemForRequest call
+func handleFilesystemError(w http.ResponseWriter, err error) {
+	if strings.Contains(err.Error(), "no valid JWT claims") {
+		http.Error(w, "Authentication required", http.StatusUnauthorized)
+	} else if strings.Contains(err.Error(), "not found") {
+		http.Error(w, err.Error(), http.StatusNotFound)
+	} else if strings.Contains(err.Error(), "empty") && strings.Contains(err.Error(), "field") {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	} else if strings.Contains(err.Error(), "conflicting virtual paths") ||
+		strings.Contains(err.Error(), "invalid quota in token") {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	} else {
+		http.Error(w, err.Error(), http.StatusForbidden)
+	}
+}
+
+// isSPARoute checks if the path is handled by client-side routing
+func (s *Server) isSPARoute(urlPath string) bool {
+	// Without an allowlist every path is treated as a client-side route
+	if len(s.Config.SPA.Routes) == 0 || urlPath == "/" {
+		return true
+	}
+
+	for _, route := range s.Config.SPA.Routes {
+		prefix := strings.TrimSuffix(route, "/")
+		if prefix == "" || urlPath == prefix || strings.HasPrefix(urlPath, prefix+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// serveNotFound answers unknown paths with a 404 in the format the client accepts
+func (s *Server) serveNotFound(w http.ResponseWriter, r *http.Request) {
+	if strings.Contains(r.Header.Get("Accept"), "application/json") {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		if err := json.NewEncoder(w).Encode(map[string]string{"error": "not found"}); err != nil {
+			log.Printf("Failed to encode not found response: %v", err)
+		}
+		return
+	}
+
+	page := s.notFoundPage
+	if page == nil {
+		page = []byte("<!DOCTYPE html>\n<html><head><title>404 Not Found</title></head>" +
+			"<body><h1>404 Not Found</h1></body></html>\n")
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusNotFound)
+	if _, err := w.Write(page); err != nil {
+		log.Printf("Failed to write not found page: %v", err)
+	}
 }
 
-func (s *Server) serveIndex(w http.ResponseWriter, _ *http.Request) {
+func (s *Server) serveIndex(w http.ResponseWriter, r *http.Request) {
+	if !s.isSPARoute(s.trimBasePath(r.URL.Path)) {
+		s.serveNotFound(w, r)
+		return
+	}
+
 	// Serve index.html from embedded filesystem
 	indexContent, err := fs.ReadFile(s.webFS, "index.html")
 	if err != nil {
@@ -167,8 +434,10 @@ func (s *Server) serveIndex(w http.ResponseWriter, _ *http.Request) {
 		return
 	}
 
+	// Always revalidate the page so that new asset versions are picked up
+	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	if _, err := w.Write(indexContent); err != nil {
+	if _, err := w.Write(s.renderPage(indexContent)); err != nil {
 		http.Error(w, "Failed to write response", http.StatusInternalServerError)
 	}
 }
@@ -181,31 +450,20 @@ func (s *Server) serveEditor(w http.ResponseWriter, _ *http.Request) {
 		return
 	}
 
+	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	if _, err := w.Write(editorContent); err != nil {
+	if _, err := w.Write(s.renderPage(editorContent)); err != nil {
 		http.Error(w, "Failed to write response", http.StatusInternalServerError)
 	}
 }
 
 func (s *Server) listFiles(w http.ResponseWriter, r *http.Request) {
-	path := r.URL.Query().Get("path")
-	if path == "" {
-		path = "/"
-	}
+	path := canonicalListPath(r.URL.Query().Get("path"))
 
 	// Get filesystem manager with JWT restrictions if applicable
 	fs, err := s.getFilesystemForRequest(r)
 	if err != nil {
-		// More specific error handling
-		if strings.Contains(err.Error(), "no valid JWT claims") {
-			http.Error(w, "Authentication required", http.StatusUnauthorized)
-		} else if strings.Contains(err.Error(), "not found") {
-			http.Error(w, err.Error(), http.StatusNotFound)
-		} else if strings.Contains(err.Error(), "empty") && strings.Contains(err.Error(), "field") {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-		} else {
-			http.Error(w, err.Error(), http.StatusForbidden)
-		}
+		handleFilesystemError(w, err)
 		return
 	}
 
@@ -215,24 +473,79 @@ func (s *Server) listFiles(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	files, err := fs.ListFiles(path)
+	kind := r.URL.Query().Get("type")
+	if err := filesystem.ValidateFileType(kind); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if r.URL.Query().Get("recursive") == "true" {
+		s.listFilesRecursive(w, r, fs, path, kind)
+		return
+	}
+
+	spec, page, err := listingOrder(r)
 	if err != nil {
-		// Check if it's a "not found" error
-		if strings.Contains(err.Error(), "not found") {
-			http.Error(w, err.Error(), http.StatusNotFound)
-			return
-		}
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	listing, err := s.cachedListDirectory(r, fs, path)
+	if err != nil {
+		writeListingError(w, err)
 		return
 	}
 
+	files := filesystem.FilterByType(listing.Files, kind)
+	var paged *filesystem.PagedResult
+	switch {
+	case page != nil:
+		result := filesystem.PageFiles(files, page.offset, page.limit, spec)
+		paged, files = &result, result.Files
+		w.Header().Set("X-Total-Count", strconv.Itoa(result.Total))
+	case listingSorted(r):
+		files = filesystem.SortFiles(files, spec)
+	case spec.DirsFirst:
+		files = filesystem.DirsFirst(files)
+	}
+	if !wantsFileIdentity(r) {
+		files = withoutFileIdentity(files)
+	}
+	if listing.Truncated {
+		w.Header().Set("X-Listing-Truncated", "true")
+	}
+
 	// Ensure we always return an array, never null
 	if files == nil {
 		files = []filesystem.FileInfo{}
 	}
 
+	s.stats.record(opList, 0, 0)
+
+	if wantsTextListing(r) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if err := writeTextListing(w, files, r.URL.Query().Get("long") == "true"); err != nil {
+			log.Printf("Failed to write text listing: %v", err)
+		}
+		return
+	}
+
+	var response any = files
+	switch {
+	case paged != nil:
+		paged.Files = files
+		pagedResponse := pagedListing{PagedResult: *paged}
+		if r.URL.Query().Get("breadcrumb") == "true" {
+			lp := newListingPath(path)
+			pagedResponse.Path = &lp
+		}
+		response = pagedResponse
+	case r.URL.Query().Get("breadcrumb") == "true":
+		response = breadcrumbListing{Path: newListingPath(path), Files: files}
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(files); err != nil {
+	if err := json.NewEncoder(w).Encode(response); err != nil {
 		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
 	}
 }
@@ -243,9 +556,27 @@ func (s *Server) uploadFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Parse multipart form
+	// A compressed request body contains the whole multipart message
+	if encoding := r.Header.Get("Content-Encoding"); encoding != "" {
+		body, err := decodeContentEncoding(r.Body, encoding, filesystem.DecompressionLimitsFor(s.Config))
+		if err != nil {
+			http.Error(w, err.Error(), contentEncodingStatus(err))
+			return
+		}
+		r.Body = io.NopCloser(body)
+		r.Header.Del("Content-Encoding")
+		r.ContentLength = -1
+	}
+
+	// Parse multipart form; parts beyond the file limit are never spooled, and
+	// the parser removes the ones already spooled when it fails
+	r.Body = io.NopCloser(limitUploadParts(r.Body, r.Header.Get("Content-Type"), s.maxFilesPerUpload()))
 	err := r.ParseMultipartForm(32 << 20) // 32 MB max memory
 	if err != nil {
+		if isDecompressionLimit(err) || isTooManyFiles(err) {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
 		http.Error(w, "Error parsing form: "+err.Error(), http.StatusBadRequest)
 		return
 	}
@@ -269,27 +600,205 @@ func (s *Server) uploadFile(w http.ResponseWriter, r *http.Request) {
 	// Get filesystem manager with JWT restrictions if applicable
 	fs, err := s.getFilesystemForRequest(r)
 	if err != nil {
-		// More specific error handling
-		if strings.Contains(err.Error(), "no valid JWT claims") {
-			http.Error(w, "Authentication required", http.StatusUnauthorized)
-		} else if strings.Contains(err.Error(), "not found") {
+		handleFilesystemError(w, err)
+		return
+	}
+
+	// Individual parts may be compressed as well; their decompressed size is
+	// unknown, so the quota is enforced while writing
+	var content io.Reader = file
+	size := header.Size
+	if encoding := header.Header.Get("Content-Encoding"); encoding != "" {
+		content, err = decodeContentEncoding(file, encoding, filesystem.DecompressionLimitsFor(s.Config))
+		if err != nil {
+			http.Error(w, err.Error(), contentEncodingStatus(err))
+			return
+		}
+		size = -1
+	}
+
+	result, err := fs.UploadFile(targetPath, header.Filename, content, size)
+	if err != nil {
+		if strings.Contains(err.Error(), "quota exceeded") ||
+			strings.Contains(err.Error(), "would exceed quota") ||
+			strings.Contains(err.Error(), "insufficient disk space") ||
+			strings.Contains(err.Error(), "too many entries in directory") {
+			http.Error(w, err.Error(), http.StatusInsufficientStorage)
+			return
+		}
+		if strings.Contains(err.Error(), "already exists") {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if isDecompressionLimit(err) {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.invalidateListings(fs, result.Path)
+
+	s.recent.add(requestScope(r), *result)
+	s.stats.record(opUpload, result.Size, 0)
+
+	if !s.extractUpload(w, fs, result) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// uploadRaw stores the request body as the file at the given path. Unlike the
+// multipart endpoint the body is streamed straight to disk.
+func (s *Server) uploadRaw(w http.ResponseWriter, r *http.Request) {
+	path := "/" + strings.TrimPrefix(mux.Vars(r)["path"], "/")
+
+	fs, err := s.getFilesystemForRequest(r)
+	if err != nil {
+		handleFilesystemError(w, err)
+		return
+	}
+
+	defer func() {
+		_ = r.Body.Close()
+	}()
+
+	var body io.Reader = r.Body
+	size := r.ContentLength
+	if encoding := r.Header.Get("Content-Encoding"); encoding != "" {
+		body, err = decodeContentEncoding(r.Body, encoding, filesystem.DecompressionLimitsFor(s.Config))
+		if err != nil {
+			http.Error(w, err.Error(), contentEncodingStatus(err))
+			return
+		}
+		// The decompressed size is only known once the stream is consumed
+		size = -1
+	}
+
+	// ContentLength is -1 for chunked requests, which StreamFile treats as unknown
+	result, err := fs.StreamFile(path, body, size)
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "quota exceeded"),
+			strings.Contains(err.Error(), "insufficient disk space"),
+			strings.Contains(err.Error(), "too many entries in directory"):
+			http.Error(w, err.Error(), http.StatusInsufficientStorage)
+		case strings.Contains(err.Error(), "access denied"):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		case strings.Contains(err.Error(), "not found"):
 			http.Error(w, err.Error(), http.StatusNotFound)
-		} else if strings.Contains(err.Error(), "empty") && strings.Contains(err.Error(), "field") {
+		case strings.Contains(err.Error(), "cannot overwrite directory"), strings.Contains(err.Error(), "already exists"):
+			http.Error(w, err.Error(), http.StatusConflict)
+		case strings.Contains(err.Error(), "incomplete upload"):
 			http.Error(w, err.Error(), http.StatusBadRequest)
-		} else {
+		case isDecompressionLimit(err):
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	s.invalidateListings(fs, result.Path)
+
+	s.recent.add(requestScope(r), *result)
+	s.stats.record(opUpload, result.Size, 0)
+
+	if !s.extractUpload(w, fs, result) {
+		return
+	}
+
+	status := http.StatusOK
+	if result.Created {
+		status = http.StatusCreated
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+	}
+}
+
+// extractUpload runs auto_extract for an uploaded file and stores the
+// extracted folder in the result. It writes an error response and returns
+// false when extraction fails; the uploaded archive is kept in that case.
+func (s *Server) extractUpload(w http.ResponseWriter, fs *filesystem.Manager, result *filesystem.UploadResult) bool {
+	extracted, err := fs.AutoExtract(result.Path)
+	if extracted != "" {
+		s.invalidateListings(fs, extracted)
+	}
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "quota exceeded"):
+			http.Error(w, err.Error(), http.StatusInsufficientStorage)
+		case strings.Contains(err.Error(), "already exists"):
+			http.Error(w, err.Error(), http.StatusConflict)
+		case strings.Contains(err.Error(), "access denied"):
 			http.Error(w, err.Error(), http.StatusForbidden)
+		case isDecompressionLimit(err):
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		default:
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
 		}
+		return false
+	}
+	if extracted != "" {
+		result.Extracted = extracted
+		result.Message = "File uploaded and extracted successfully"
+	}
+	return true
+}
+
+// extractArchive unpacks an archive stored on the server into a directory
+func (s *Server) extractArchive(w http.ResponseWriter, r *http.Request) {
+	archivePath := mux.Vars(r)["path"]
+
+	var req struct {
+		Dest string `json:"dest"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Dest == "" {
+		http.Error(w, "Destination path is required", http.StatusBadRequest)
 		return
 	}
 
-	result, err := fs.UploadFile(targetPath, header.Filename, file, header.Size)
+	fs, err := s.getFilesystemForRequest(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		handleFilesystemError(w, err)
+		return
+	}
+
+	if err := fs.ExtractArchive(archivePath, req.Dest); err != nil {
+		switch {
+		case strings.Contains(err.Error(), "not found"):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case strings.Contains(err.Error(), "access denied"):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		case strings.Contains(err.Error(), "already exists"):
+			http.Error(w, err.Error(), http.StatusConflict)
+		case strings.Contains(err.Error(), "quota exceeded"):
+			http.Error(w, err.Error(), http.StatusInsufficientStorage)
+		case isDecompressionLimit(err):
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		case strings.Contains(err.Error(), "unsupported archive type"),
+			strings.Contains(err.Error(), "not a directory"):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		}
 		return
 	}
+	s.invalidateListings(fs, req.Dest)
 
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(result); err != nil {
+	if err := json.NewEncoder(w).Encode(map[string]string{"status": "extracted", "path": req.Dest}); err != nil {
 		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
 	}
 }
@@ -301,16 +810,7 @@ func (s *Server) getFile(w http.ResponseWriter, r *http.Request) {
 	// Get filesystem manager with JWT restrictions if applicable
 	fs, err := s.getFilesystemForRequest(r)
 	if err != nil {
-		// More specific error handling
-		if strings.Contains(err.Error(), "no valid JWT claims") {
-			http.Error(w, "Authentication required", http.StatusUnauthorized)
-		} else if strings.Contains(err.Error(), "not found") {
-			http.Error(w, err.Error(), http.StatusNotFound)
-		} else if strings.Contains(err.Error(), "empty") && strings.Contains(err.Error(), "field") {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-		} else {
-			http.Error(w, err.Error(), http.StatusForbidden)
-		}
+		handleFilesystemError(w, err)
 		return
 	}
 
@@ -320,6 +820,12 @@ func (s *Server) getFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	s.serveDownload(w, r, filePath)
+}
+
+// serveDownload sends a file as a download, honoring the disposition and
+// rate limit settings
+func (s *Server) serveDownload(w http.ResponseWriter, r *http.Request, filePath string) {
 	// Check if it's a directory
 	info, err := os.Stat(filePath)
 	if err != nil {
@@ -332,11 +838,24 @@ func (s *Server) getFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	contentType := detectContentType(filePath)
+	disposition, err := s.resolveDisposition(requestedDisposition(r), filepath.Base(filePath), contentType)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	// Set appropriate headers for file download
-	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filepath.Base(filePath)))
-	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", contentDisposition(disposition, filepath.Base(filePath)))
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("ETag", fileETag(info))
 
-	http.ServeFile(w, r, filePath)
+	tw := &responseTracker{ResponseWriter: s.throttle(w, r)}
+	http.ServeFile(tw, r, filePath)
+	if tw.succeeded() {
+		s.stats.record(opDownload, 0, tw.written)
+	}
 }
 
 func (s *Server) deleteFile(w http.ResponseWriter, r *http.Request) {
@@ -346,24 +865,21 @@ func (s *Server) deleteFile(w http.ResponseWriter, r *http.Request) {
 	// Get filesystem manager with JWT restrictions if applicable
 	fs, err := s.getFilesystemForRequest(r)
 	if err != nil {
-		// More specific error handling
-		if strings.Contains(err.Error(), "no valid JWT claims") {
-			http.Error(w, "Authentication required", http.StatusUnauthorized)
-		} else if strings.Contains(err.Error(), "not found") {
-			http.Error(w, err.Error(), http.StatusNotFound)
-		} else if strings.Contains(err.Error(), "empty") && strings.Contains(err.Error(), "field") {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-		} else {
-			http.Error(w, err.Error(), http.StatusForbidden)
-		}
+		handleFilesystemError(w, err)
 		return
 	}
 
 	err = fs.DeleteFile(path)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		if strings.Contains(err.Error(), "access denied") {
+			http.Error(w, err.Error(), http.StatusForbidden)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
 		return
 	}
+	s.invalidateListings(fs, path)
+	s.stats.record(opDelete, 0, 0)
 
 	w.WriteHeader(http.StatusOK)
 	if err := json.NewEncoder(w).Encode(map[string]string{"status": "deleted"}); err != nil {
@@ -371,110 +887,501 @@ func (s *Server) deleteFile(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (s *Server) moveFile(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	sourcePath := vars["path"]
-
+// deleteBatch deletes several files or directories. Every path is deleted on
+// its own; the response lists the outcome per path and answers 207 when any
+// of them failed.
+func (s *Server) deleteBatch(w http.ResponseWriter, r *http.Request) {
 	var req struct {
-		DestPath string `json:"destPath"`
+		Paths []string `json:"paths"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
+	if len(req.Paths) == 0 {
+		http.Error(w, "paths are required", http.StatusBadRequest)
+		return
+	}
 
 	// Get filesystem manager with JWT restrictions if applicable
 	fs, err := s.getFilesystemForRequest(r)
 	if err != nil {
-		// More specific error handling
-		if strings.Contains(err.Error(), "no valid JWT claims") {
-			http.Error(w, "Authentication required", http.StatusUnauthorized)
-		} else if strings.Contains(err.Error(), "not found") {
-			http.Error(w, err.Error(), http.StatusNotFound)
-		} else if strings.Contains(err.Error(), "empty") && strings.Contains(err.Error(), "field") {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-		} else {
-			http.Error(w, err.Error(), http.StatusForbidden)
-		}
+		handleFilesystemError(w, err)
 		return
 	}
 
-	err = fs.MoveFile(sourcePath, req.DestPath)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+	results := fs.DeleteBatch(req.Paths)
+	deleted := 0
+	for _, result := range results {
+		if result.Error == "" {
+			s.invalidateListings(fs, result.Path)
+			s.stats.record(opDelete, 0, 0)
+			deleted++
+		}
 	}
 
-	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(map[string]string{"status": "moved"}); err != nil {
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	status := http.StatusOK
+	if deleted < len(results) {
+		status = http.StatusMultiStatus
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	response := map[string]any{
+		"deleted": deleted,
+		"failed":  len(results) - deleted,
+		"results": results,
+	}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Failed to encode response: %v", err)
 	}
 }
 
-func (s *Server) copyFile(w http.ResponseWriter, r *http.Request) {
+// moveFile moves a file or directory. An existing destination is handled by
+// the overwrite policy of the request, "error" (409) unless given. Moves used
+// to replace an existing destination silently; clients relying on that must
+// now send "overwrite": "replace".
+func (s *Server) moveFile(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	sourcePath := vars["path"]
 
 	var req struct {
-		DestPath string `json:"destPath"`
+		DestPath  string `json:"destPath"`
+		Overwrite string `json:"overwrite"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
+	policy, err := filesystem.ParseOverwritePolicy(req.Overwrite)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	// Get filesystem manager with JWT restrictions if applicable
 	fs, err := s.getFilesystemForRequest(r)
 	if err != nil {
-		// More specific error handling
-		if strings.Contains(err.Error(), "no valid JWT claims") {
-			http.Error(w, "Authentication required", http.StatusUnauthorized)
-		} else if strings.Contains(err.Error(), "not found") {
+		handleFilesystemError(w, err)
+		return
+	}
+
+	destPath, err := fs.MoveFileWithOptions(sourcePath, req.DestPath, filesystem.TransferOptions{Overwrite: policy})
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "destination directory not found"):
 			http.Error(w, err.Error(), http.StatusNotFound)
-		} else if strings.Contains(err.Error(), "empty") && strings.Contains(err.Error(), "field") {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-		} else {
+		case strings.Contains(err.Error(), "access denied"):
 			http.Error(w, err.Error(), http.StatusForbidden)
+		case strings.Contains(err.Error(), "already exists"):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
 		}
 		return
 	}
+	s.invalidateListings(fs, sourcePath, destPath)
+	s.stats.record(opMove, 0, 0)
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(map[string]string{"status": "moved", "path": destPath}); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// renameFile renames a file or directory within its parent directory
+func (s *Server) renameFile(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	sourcePath := vars["path"]
+
+	var req struct {
+		NewName string `json:"newName"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
 
-	err = fs.CopyFile(sourcePath, req.DestPath)
+	// Get filesystem manager with JWT restrictions if applicable
+	fs, err := s.getFilesystemForRequest(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		handleFilesystemError(w, err)
 		return
 	}
 
+	err = fs.RenameFile(sourcePath, req.NewName)
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "invalid name"):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case strings.Contains(err.Error(), "not found"):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case strings.Contains(err.Error(), "access denied"):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		case strings.Contains(err.Error(), "already exists"):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	destPath := path.Join(path.Dir(path.Clean("/"+sourcePath)), req.NewName)
+	s.invalidateListings(fs, sourcePath, destPath)
+	s.stats.record(opMove, 0, 0)
+
 	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(map[string]string{"status": "copied"}); err != nil {
+	if err := json.NewEncoder(w).Encode(map[string]string{"status": "renamed", "path": destPath}); err != nil {
 		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
 	}
 }
 
-func (s *Server) statFile(w http.ResponseWriter, r *http.Request) {
+// moveBatch moves several files into one directory. With atomic set, either
+// all sources are moved or none.
+func (s *Server) moveBatch(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Sources []string `json:"sources"`
+		Dest    string   `json:"dest"`
+		Atomic  bool     `json:"atomic"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(req.Sources) == 0 || req.Dest == "" {
+		http.Error(w, "sources and dest are required", http.StatusBadRequest)
+		return
+	}
+
+	// Get filesystem manager with JWT restrictions if applicable
+	fs, err := s.getFilesystemForRequest(r)
+	if err != nil {
+		handleFilesystemError(w, err)
+		return
+	}
+
+	results, err := fs.MoveBatch(req.Sources, req.Dest, req.Atomic)
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "not found"):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case strings.Contains(err.Error(), "access denied"):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		case strings.Contains(err.Error(), "already exists"), strings.Contains(err.Error(), "into itself"),
+			strings.Contains(err.Error(), "not a directory"), strings.Contains(err.Error(), "overlapping sources"):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	moved := 0
+	for _, result := range results {
+		if result.Error == "" {
+			s.invalidateListings(fs, result.Source, result.Dest)
+			s.stats.record(opMove, 0, 0)
+			moved++
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	response := map[string]any{
+		"moved":   moved,
+		"failed":  len(results) - moved,
+		"results": results,
+	}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// moveInto moves several files or directories into one directory, each on
+// its own
+func (s *Server) moveInto(w http.ResponseWriter, r *http.Request) {
+	s.transferInto(w, r, opMove, "moved")
+}
+
+// copyInto copies several files or directories into one directory, each on
+// its own after checking the quota for all of them
+func (s *Server) copyInto(w http.ResponseWriter, r *http.Request) {
+	s.transferInto(w, r, opCopy, "copied")
+}
+
+// transferInto serves moveInto and copyInto. The response lists the outcome
+// per source and answers 207 when any of them failed.
+func (s *Server) transferInto(w http.ResponseWriter, r *http.Request, op string, done string) {
+	var req struct {
+		Sources []string `json:"sources"`
+		Dest    string   `json:"dest"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(req.Sources) == 0 || req.Dest == "" {
+		http.Error(w, "sources and dest are required", http.StatusBadRequest)
+		return
+	}
+
+	// Get filesystem manager with JWT restrictions if applicable
+	fs, err := s.getFilesystemForRequest(r)
+	if err != nil {
+		handleFilesystemError(w, err)
+		return
+	}
+
+	var results []filesystem.BatchMoveResult
+	if op == opMove {
+		results, err = fs.MoveInto(req.Sources, req.Dest)
+	} else {
+		results, err = fs.CopyInto(req.Sources, req.Dest)
+	}
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "would exceed quota"):
+			http.Error(w, err.Error(), http.StatusInsufficientStorage)
+		case strings.Contains(err.Error(), "not found"):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case strings.Contains(err.Error(), "access denied"):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		case strings.Contains(err.Error(), "not a directory"):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	succeeded := 0
+	for _, result := range results {
+		if result.Error == "" {
+			if op == opMove {
+				s.invalidateListings(fs, result.Source, result.Dest)
+			} else {
+				s.invalidateListings(fs, result.Dest)
+			}
+			s.stats.record(op, 0, 0)
+			succeeded++
+		}
+	}
+
+	status := http.StatusOK
+	if succeeded < len(results) {
+		status = http.StatusMultiStatus
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	response := map[string]any{
+		done:      succeeded,
+		"failed":  len(results) - succeeded,
+		"results": results,
+	}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+	}
+}
+
+// copyFile copies a file or directory. An existing destination is handled by
+// the overwrite policy of the request, "error" (409) unless given. Copies
+// used to overwrite existing files silently; clients relying on that must
+// now send "overwrite": "replace".
+func (s *Server) copyFile(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	path := vars["path"]
+	sourcePath := vars["path"]
+
+	var req struct {
+		DestPath  string `json:"destPath"`
+		Overwrite string `json:"overwrite"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	policy, err := filesystem.ParseOverwritePolicy(req.Overwrite)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	// Get filesystem manager with JWT restrictions if applicable
 	fs, err := s.getFilesystemForRequest(r)
 	if err != nil {
-		// More specific error handling
-		if strings.Contains(err.Error(), "no valid JWT claims") {
-			http.Error(w, "Authentication required", http.StatusUnauthorized)
-		} else if strings.Contains(err.Error(), "not found") {
+		handleFilesystemError(w, err)
+		return
+	}
+
+	destPath, err := fs.CopyFileWithOptions(sourcePath, req.DestPath, filesystem.TransferOptions{Overwrite: policy})
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "would exceed quota"),
+			strings.Contains(err.Error(), "insufficient disk space"),
+			strings.Contains(err.Error(), "too many entries in directory"):
+			http.Error(w, err.Error(), http.StatusInsufficientStorage)
+		case strings.Contains(err.Error(), "destination directory not found"):
 			http.Error(w, err.Error(), http.StatusNotFound)
-		} else if strings.Contains(err.Error(), "empty") && strings.Contains(err.Error(), "field") {
+		case strings.Contains(err.Error(), "access denied"):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		case strings.Contains(err.Error(), "already exists"):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	s.invalidateListings(fs, destPath)
+	s.stats.record(opCopy, 0, 0)
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(map[string]string{"status": "copied", "path": destPath}); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+func (s *Server) chmodFile(w http.ResponseWriter, r *http.Request) {
+	path := mux.Vars(r)["path"]
+
+	var req struct {
+		Mode      string `json:"mode"`
+		DirMode   string `json:"dirMode"`
+		Recursive bool   `json:"recursive"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if r.URL.Query().Get("recursive") == "true" {
+		req.Recursive = true
+	}
+
+	fileMode, err := filesystem.ParseMode(req.Mode)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// Directories need execute bits to be entered
+	dirMode := filesystem.DirModeFor(fileMode)
+	if req.DirMode != "" {
+		if dirMode, err = filesystem.ParseMode(req.DirMode); err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
-		} else {
+			return
+		}
+	}
+
+	fs, err := s.getFilesystemForRequest(r)
+	if err != nil {
+		handleFilesystemError(w, err)
+		return
+	}
+
+	changed, err := fs.Chmod(path, fileMode, dirMode, req.Recursive)
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "not found"):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case strings.Contains(err.Error(), "access denied"):
 			http.Error(w, err.Error(), http.StatusForbidden)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
 		}
 		return
 	}
+	s.invalidateListings(fs, path)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]any{"status": "changed", "changed": changed}); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// replaceDirectory swaps the contents of a directory for a ZIP archive sent
+// as the request body, or for a staging directory named in a JSON body
+func (s *Server) replaceDirectory(w http.ResponseWriter, r *http.Request) {
+	path := "/" + strings.TrimPrefix(mux.Vars(r)["path"], "/")
 
-	stat, err := fs.StatFile(path)
+	fs, err := s.getFilesystemForRequest(r)
 	if err != nil {
+		handleFilesystemError(w, err)
+		return
+	}
+
+	defer func() {
+		_ = r.Body.Close()
+	}()
+
+	var staging string
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		var req struct {
+			Staging string `json:"staging"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Staging == "" {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		staging = req.Staging
+		err = fs.ReplaceDirectoryFromStaging(path, staging)
+	} else {
+		err = fs.ReplaceDirectoryFromZip(path, r.Body)
+	}
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "quota exceeded"):
+			http.Error(w, err.Error(), http.StatusInsufficientStorage)
+		case strings.Contains(err.Error(), "access denied"):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		case strings.Contains(err.Error(), "invalid staging path"):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case strings.Contains(err.Error(), "not found"):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case strings.Contains(err.Error(), "not a directory"):
+			http.Error(w, err.Error(), http.StatusConflict)
+		case isDecompressionLimit(err):
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		case strings.Contains(err.Error(), "failed to extract"):
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	s.invalidateListings(fs, path)
+	if staging != "" {
+		s.invalidateListings(fs, staging)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]string{"status": "replaced", "path": path}); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+func (s *Server) statFile(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	path := vars["path"]
+
+	// Get filesystem manager with JWT restrictions if applicable
+	fs, err := s.getFilesystemForRequest(r)
+	if err != nil {
+		handleFilesystemError(w, err)
+		return
+	}
+
+	opts, err := statOptions(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	stat, err := fs.StatFileWithOptions(path, opts)
+	if err != nil {
+		if strings.Contains(err.Error(), "failed to read") {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
 		http.Error(w, err.Error(), http.StatusNotFound)
 		return
 	}
@@ -485,19 +1392,231 @@ func (s *Server) statFile(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (s *Server) downloadZip(w http.ResponseWriter, r *http.Request) {
-	var req struct {
-		Paths []string `json:"paths"`
-		Name  string   `json:"name"`
+const (
+	// defaultStatEmbed is the largest file embedded by ?embed=true without maxEmbed
+	defaultStatEmbed = 4096
+	// maxStatEmbed bounds maxEmbed, as embedded content is held in memory
+	maxStatEmbed = 1024 * 1024
+)
+
+// statOptions reads the embedding and checksum parameters of a stat request
+func statOptions(r *http.Request) (filesystem.StatOptions, error) {
+	var opts filesystem.StatOptions
+	if checksum := r.URL.Query().Get("checksum"); checksum != "" {
+		if err := filesystem.ValidateChecksumAlgorithm(checksum); err != nil {
+			return opts, err
+		}
+		opts.Checksum = checksum
+	}
+
+	if r.URL.Query().Get("embed") != "true" {
+		return opts, nil
+	}
+
+	opts.MaxEmbed = defaultStatEmbed
+	if value := r.URL.Query().Get("maxEmbed"); value != "" {
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || parsed < 1 || parsed > maxStatEmbed {
+			return opts, fmt.Errorf("invalid maxEmbed: must be between 1 and %d", maxStatEmbed)
+		}
+		opts.MaxEmbed = parsed
+	}
+	return opts, nil
+}
+
+// getTreeHash returns a fingerprint of a directory's entire contents
+func (s *Server) getTreeHash(w http.ResponseWriter, r *http.Request) {
+	path := "/" + strings.TrimPrefix(mux.Vars(r)["path"], "/")
+	mode := r.URL.Query().Get("mode")
+	if mode == "" {
+		mode = filesystem.TreeHashContent
+	}
+
+	fs, err := s.getFilesystemForRequest(r)
+	if err != nil {
+		handleFilesystemError(w, err)
+		return
+	}
+
+	hash, err := fs.TreeHash(path, mode)
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "not found"):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case strings.Contains(err.Error(), "access denied"):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		case strings.Contains(err.Error(), "invalid hash mode"), strings.Contains(err.Error(), "not a directory"):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]string{
+		"path":      path,
+		"algorithm": "sha256",
+		"mode":      mode,
+		"hash":      hash,
+	}); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// checkExists reports whether a path exists. The answer is conveyed in the
+// body so that clients do not have to interpret 404 responses.
+func (s *Server) checkExists(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Query().Get("path")
+	if path == "" {
+		http.Error(w, "No path specified", http.StatusBadRequest)
+		return
+	}
+
+	fs, err := s.getFilesystemForRequest(r)
+	if err != nil {
+		handleFilesystemError(w, err)
+		return
+	}
+
+	exists, isDir, err := fs.Exists(path)
+	if err != nil {
+		if strings.Contains(err.Error(), "access denied") {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]bool{
+		"exists": exists,
+		"isDir":  isDir,
+	}); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
 	}
+}
 
+// archiveRequest is the body of ZIP and tar download requests
+type archiveRequest struct {
+	Paths         []string `json:"paths"`
+	Name          string   `json:"name"`
+	Deterministic bool     `json:"deterministic"`
+	Exclude       []string `json:"exclude"`
+	Layout        string   `json:"layout"`
+	ChangedFiles  string   `json:"changedFiles"`
+	// SkipInvalid leaves out missing and inaccessible paths instead of
+	// rejecting the request; they are listed in the X-Skipped-Paths header
+	SkipInvalid bool `json:"skipInvalid"`
+}
+
+// options returns the archive options selected by the request
+func (req *archiveRequest) options() filesystem.ZipOptions {
+	return filesystem.ZipOptions{
+		Deterministic: req.Deterministic,
+		Exclude:       req.Exclude,
+		Layout:        req.Layout,
+		ChangedFiles:  req.ChangedFiles,
+	}
+}
+
+// prepareArchive decodes and validates an archive download request and
+// checks all paths before any archive bytes are written, so that errors can
+// still be reported with a proper status code. It writes the error response
+// and returns false when the request cannot be served.
+func (s *Server) prepareArchive(w http.ResponseWriter, r *http.Request) (*archiveRequest, *filesystem.Manager, bool) {
+	var req archiveRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
-		return
+		return nil, nil, false
 	}
 
 	if len(req.Paths) == 0 {
 		http.Error(w, "No paths specified", http.StatusBadRequest)
+		return nil, nil, false
+	}
+
+	if err := filesystem.ValidateExcludePatterns(req.Exclude); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, nil, false
+	}
+
+	if err := filesystem.ValidateZipLayout(req.Layout); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, nil, false
+	}
+
+	// Get filesystem manager with JWT restrictions if applicable
+	fs, err := s.getFilesystemForRequest(r)
+	if err != nil {
+		handleFilesystemError(w, err)
+		return nil, nil, false
+	}
+
+	if req.SkipInvalid {
+		valid, skipped := fs.CheckPaths(req.Paths)
+		if len(valid) == 0 {
+			http.Error(w, "None of the paths can be archived", http.StatusNotFound)
+			return nil, nil, false
+		}
+		if len(skipped) > 0 {
+			w.Header().Set("X-Skipped-Paths", skippedPathsHeader(skipped))
+		}
+		return &req, fs, true
+	}
+
+	if err := fs.ValidatePaths(req.Paths); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, err.Error(), http.StatusNotFound)
+		} else {
+			http.Error(w, err.Error(), http.StatusForbidden)
+		}
+		return nil, nil, false
+	}
+
+	return &req, fs, true
+}
+
+// skippedPathsHeader lists skipped paths for the X-Skipped-Paths header,
+// escaped like URL paths and separated by commas
+func skippedPathsHeader(skipped []filesystem.SkippedPath) string {
+	escaped := make([]string, len(skipped))
+	for i, s := range skipped {
+		escaped[i] = escapePath(s.Path)
+	}
+	return strings.Join(escaped, ",")
+}
+
+// streamArchive writes an archive through the download throttle and counts it
+// as op. Once archive bytes are sent, an error message would corrupt the
+// archive further, so later errors are only logged.
+func (s *Server) streamArchive(w http.ResponseWriter, r *http.Request, kind, op string,
+	create func(io.Writer) error) {
+	tw := &responseTracker{ResponseWriter: s.throttle(w, r)}
+	if err := create(tw); err != nil {
+		if tw.committed {
+			log.Printf("%s download failed mid-stream: %v", kind, err)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	s.stats.record(op, 0, tw.written)
+}
+
+func (s *Server) downloadZip(w http.ResponseWriter, r *http.Request) {
+	req, fs, ok := s.prepareArchive(w, r)
+	if !ok {
+		return
+	}
+
+	if req.ChangedFiles == "" {
+		req.ChangedFiles = s.Config.Download.ZipChangedFiles
+	}
+	if err := filesystem.ValidateZipChangedFiles(req.ChangedFiles); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
@@ -510,61 +1629,214 @@ func (s *Server) downloadZip(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/zip")
 	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", zipName))
 
+	s.streamArchive(w, r, "ZIP", opZip, func(out io.Writer) error {
+		return fs.CreateZipWithOptions(out, req.Paths, req.options())
+	})
+}
+
+// downloadTar streams the selected paths as an uncompressed tar archive,
+// which Unix clients can pipe straight into tar
+func (s *Server) downloadTar(w http.ResponseWriter, r *http.Request) {
+	req, fs, ok := s.prepareArchive(w, r)
+	if !ok {
+		return
+	}
+
+	tarName := req.Name
+	if tarName == "" {
+		tarName = "download.tar"
+	}
+
+	w.Header().Set("Content-Type", "application/x-tar")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", tarName))
+
+	s.streamArchive(w, r, "Tar", opTar, func(out io.Writer) error {
+		return fs.CreateTar(out, req.Paths, req.options())
+	})
+}
+
+func (s *Server) downloadTarGz(w http.ResponseWriter, r *http.Request) {
+	req, fs, ok := s.prepareArchive(w, r)
+	if !ok {
+		return
+	}
+
+	tarName := req.Name
+	if tarName == "" {
+		tarName = "download.tar.gz"
+	}
+
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", tarName))
+
+	s.streamArchive(w, r, "Tar.gz", opTarGz, func(out io.Writer) error {
+		return fs.CreateTarGzWithOptions(out, req.Paths, req.options())
+	})
+}
+
+// responseTracker records whether a response has been committed to the
+// client, with which status and how many body bytes were written
+type responseTracker struct {
+	http.ResponseWriter
+	committed bool
+	status    int
+	written   int64
+}
+
+// WriteHeader marks the response as committed
+func (t *responseTracker) WriteHeader(code int) {
+	if !t.committed {
+		t.status = code
+	}
+	t.committed = true
+	t.ResponseWriter.WriteHeader(code)
+}
+
+// Write marks the response as committed
+func (t *responseTracker) Write(b []byte) (int, error) {
+	if !t.committed {
+		t.status = http.StatusOK
+	}
+	t.committed = true
+	n, err := t.ResponseWriter.Write(b)
+	t.written += int64(n)
+	return n, err
+}
+
+// Flush sends buffered data to the client if the writer supports it
+func (t *responseTracker) Flush() {
+	if f, ok := t.ResponseWriter.(http.Flusher); ok {
+		if !t.committed {
+			t.status = http.StatusOK
+		}
+		t.committed = true
+		f.Flush()
+	}
+}
+
+// Unwrap returns the underlying writer for http.ResponseController
+func (t *responseTracker) Unwrap() http.ResponseWriter {
+	return t.ResponseWriter
+}
+
+// succeeded reports whether a successful status was sent
+func (t *responseTracker) succeeded() bool {
+	return t.status >= 200 && t.status < 300
+}
+
+// getMode tells clients whether a token is required. It is served without
+// authentication and must not reveal anything beyond the mode.
+func (s *Server) getMode(w http.ResponseWriter, _ *http.Request) {
+	mode := "directory"
+	if s.Config.JWTSecret != "" {
+		mode = "jwt"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]any{
+		"mode":          mode,
+		"requiresToken": mode == "jwt",
+	}); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// verifyToken reports the scope granted by a valid token. The JWT middleware
+// has already rejected missing, invalid and expired tokens with 401. Only
+// virtual paths are returned, never the source directories.
+func (s *Server) verifyToken(w http.ResponseWriter, r *http.Request) {
+	if s.Config.JWTSecret == "" {
+		http.Error(w, "JWT authentication is not enabled", http.StatusNotFound)
+		return
+	}
+
+	claims, ok := auth.GetClaimsFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	directories := make([]string, 0, len(claims.Directories))
+	for _, dir := range claims.Directories {
+		directories = append(directories, dir.Virtual)
+	}
+
+	expires := claims.Expires
+	if expires == "" && claims.ExpiresAt != nil {
+		expires = claims.ExpiresAt.UTC().Format(time.RFC3339)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(struct {
+		Valid        bool     `json:"valid"`
+		Directories  []string `json:"directories"`
+		Quota        string   `json:"quota,omitempty"`
+		DownloadRate string   `json:"downloadRate,omitempty"`
+		Expires      string   `json:"expires,omitempty"`
+	}{
+		Valid:        true,
+		Directories:  directories,
+		Quota:        claims.Quota,
+		DownloadRate: claims.DownloadRate,
+		Expires:      expires,
+	}); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+func (s *Server) getQuotaInfo(w http.ResponseWriter, r *http.Request) {
 	// Get filesystem manager with JWT restrictions if applicable
 	fs, err := s.getFilesystemForRequest(r)
 	if err != nil {
-		// More specific error handling
-		if strings.Contains(err.Error(), "no valid JWT claims") {
-			http.Error(w, "Authentication required", http.StatusUnauthorized)
-		} else if strings.Contains(err.Error(), "not found") {
-			http.Error(w, err.Error(), http.StatusNotFound)
-		} else if strings.Contains(err.Error(), "empty") && strings.Contains(err.Error(), "field") {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-		} else {
-			http.Error(w, err.Error(), http.StatusForbidden)
-		}
+		handleFilesystemError(w, err)
 		return
 	}
 
-	err = fs.CreateZip(w, req.Paths)
+	info, err := fs.GetQuotaInfo()
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	info.Directories = fs.DirectorySpace()
+	if r.URL.Query().Get("detail") == "true" {
+		if info.PerDirectory, err = fs.GetQuotaInfoPerDir(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(info); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	}
 }
 
-func (s *Server) getQuotaInfo(w http.ResponseWriter, r *http.Request) {
+// recalculateQuota walks the caller's directories again instead of using the
+// tracked usage and reports how far the tracked usage had drifted
+func (s *Server) recalculateQuota(w http.ResponseWriter, r *http.Request) {
 	// Get filesystem manager with JWT restrictions if applicable
 	fs, err := s.getFilesystemForRequest(r)
 	if err != nil {
-		// More specific error handling
-		if strings.Contains(err.Error(), "no valid JWT claims") {
-			http.Error(w, "Authentication required", http.StatusUnauthorized)
-		} else if strings.Contains(err.Error(), "not found") {
-			http.Error(w, err.Error(), http.StatusNotFound)
-		} else if strings.Contains(err.Error(), "empty") && strings.Contains(err.Error(), "field") {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-		} else {
-			http.Error(w, err.Error(), http.StatusForbidden)
-		}
+		handleFilesystemError(w, err)
 		return
 	}
 
-	info, err := fs.GetQuotaInfo()
+	info, drift, err := fs.RecalculateUsage()
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(info); err != nil {
+	if err := json.NewEncoder(w).Encode(map[string]any{"quota": info, "drift": drift}); err != nil {
 		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
 	}
 }
 
 func (s *Server) createFolder(w http.ResponseWriter, r *http.Request) {
 	var req struct {
-		Path string `json:"path"`
+		Path    string `json:"path"`
+		ExistOk bool   `json:"existOk"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -577,30 +1849,50 @@ func (s *Server) createFolder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// existOk may be given in the body or as a query parameter
+	if r.URL.Query().Get("existOk") == "true" {
+		req.ExistOk = true
+	}
+
 	// Get filesystem manager with JWT restrictions if applicable
 	fs, err := s.getFilesystemForRequest(r)
 	if err != nil {
-		// More specific error handling
-		if strings.Contains(err.Error(), "no valid JWT claims") {
-			http.Error(w, "Authentication required", http.StatusUnauthorized)
-		} else if strings.Contains(err.Error(), "not found") {
-			http.Error(w, err.Error(), http.StatusNotFound)
-		} else if strings.Contains(err.Error(), "empty") && strings.Contains(err.Error(), "field") {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-		} else {
-			http.Error(w, err.Error(), http.StatusForbidden)
-		}
+		handleFilesystemError(w, err)
 		return
 	}
 
-	err = fs.CreateFolder(req.Path)
+	status := "created"
+	if req.ExistOk {
+		var created bool
+		created, err = fs.EnsureFolder(req.Path)
+		if !created {
+			status = "exists"
+		}
+	} else {
+		err = fs.CreateFolder(req.Path)
+	}
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		switch {
+		case strings.Contains(err.Error(), "already exists"):
+			http.Error(w, err.Error(), http.StatusConflict)
+		case strings.Contains(err.Error(), "too many entries in directory"):
+			http.Error(w, err.Error(), http.StatusInsufficientStorage)
+		case strings.Contains(err.Error(), "access denied"):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		case strings.Contains(err.Error(), "not found"):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
 		return
 	}
+	if status == "created" {
+		s.invalidateListings(fs, req.Path)
+		s.stats.record(opMkdir, 0, 0)
+	}
 
 	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(map[string]string{"status": "created", "path": req.Path}); err != nil {
+	if err := json.NewEncoder(w).Encode(map[string]string{"status": status, "path": req.Path}); err != nil {
 		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
 	}
 }
@@ -641,6 +1933,42 @@ func (s *Server) getFileRaw(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (s *Server) getFileText(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	filePath := vars["path"]
+
+	fs, err := s.getFilesystemForRequest(r)
+	if err != nil {
+		handleFilesystemError(w, err)
+		return
+	}
+
+	text, encoding, err := fs.ReadFileText(filePath, r.URL.Query().Get("encoding"))
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "not found"):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case strings.Contains(err.Error(), "access denied"):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		case strings.Contains(err.Error(), "too large"):
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		case strings.Contains(err.Error(), "binary file"):
+			http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+		case strings.Contains(err.Error(), "failed to read"):
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		default:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Detected-Encoding", encoding)
+	if _, err := io.WriteString(w, text); err != nil {
+		log.Printf("Failed to write text response: %v", err)
+	}
+}
+
 func (s *Server) putFileRaw(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	filePath := vars["path"]
@@ -662,20 +1990,79 @@ func (s *Server) putFileRaw(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Write file
-	err = fs.WriteFile(filePath, content)
+	s.saveFile(w, fs, filePath, content)
+}
+
+func (s *Server) listEmptyDirs(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Query().Get("path")
+	if path == "" {
+		path = "/"
+	}
+
+	fs, err := s.getFilesystemForRequest(r)
+	if err != nil {
+		handleFilesystemError(w, err)
+		return
+	}
+
+	dirs, err := fs.FindEmptyDirs(path)
 	if err != nil {
-		if strings.Contains(err.Error(), "quota exceeded") {
-			http.Error(w, "Quota exceeded", http.StatusInsufficientStorage)
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, err.Error(), http.StatusNotFound)
+		} else if strings.Contains(err.Error(), "access denied") {
+			http.Error(w, err.Error(), http.StatusForbidden)
 		} else {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			http.Error(w, err.Error(), http.StatusBadRequest)
 		}
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(map[string]string{
-		"message": "File saved successfully",
+	if err := json.NewEncoder(w).Encode(dirs); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+func (s *Server) cleanupEmptyDirs(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Path   string `json:"path"`
+		DryRun bool   `json:"dryRun"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.Path == "" {
+		req.Path = "/"
+	}
+
+	fs, err := s.getFilesystemForRequest(r)
+	if err != nil {
+		handleFilesystemError(w, err)
+		return
+	}
+
+	removed, err := fs.RemoveEmptyDirs(req.Path, req.DryRun)
+	if !req.DryRun {
+		s.invalidateListings(fs, req.Path)
+	}
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, err.Error(), http.StatusNotFound)
+		} else if strings.Contains(err.Error(), "access denied") {
+			http.Error(w, err.Error(), http.StatusForbidden)
+		} else {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"removed": removed,
+		"dryRun":  req.DryRun,
 	}); err != nil {
 		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
 	}
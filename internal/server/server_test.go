@@ -1,11 +1,21 @@
 package server
 
 import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
 	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -161,4 +171,1842 @@ func TestJWTWithInvalidDirectory(t *testing.T) {
 	// This prevents the security vulnerability where invalid JWT would grant access to all configured directories
 	assert.Equal(t, http.StatusNotFound, rec.Code)
 	assert.Contains(t, rec.Body.String(), "directory not found")
-}
\ No newline at end of file
+}
+
+func TestSPAFallback(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	notFoundPage := filepath.Join(t.TempDir(), "404.html")
+	require.NoError(t, os.WriteFile(notFoundPage, []byte("<h1>Custom not found</h1>"), 0600))
+
+	cfg := &config.Config{
+		Directories: []config.DirMapping{
+			{Source: tmpDir, Virtual: "/docs"},
+		},
+		SPA: config.SPAConfig{
+			Routes:       []string{"/docs"},
+			NotFoundPage: notFoundPage,
+		},
+	}
+	srv := New(cfg)
+
+	t.Run("SPA route serves index", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/docs/sub/folder", nil)
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), "Dendrite File Manager")
+	})
+
+	t.Run("root serves index", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/", nil)
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("unknown path serves custom 404 page", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/unknown/page", nil)
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Equal(t, "text/html; charset=utf-8", rec.Header().Get("Content-Type"))
+		assert.Equal(t, "<h1>Custom not found</h1>", rec.Body.String())
+	})
+
+	t.Run("unknown path returns JSON when accepted", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/docsextra", nil)
+		req.Header.Set("Accept", "application/json")
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+		assert.JSONEq(t, `{"error":"not found"}`, rec.Body.String())
+	})
+
+	t.Run("without allowlist every path serves index", func(t *testing.T) {
+		srv := New(&config.Config{
+			Directories: []config.DirMapping{{Source: tmpDir, Virtual: "/docs"}},
+		})
+		req := httptest.NewRequest("GET", "/unknown/page", nil)
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+}
+
+// failingResponseWriter simulates a client connection that breaks after a number of bytes
+type failingResponseWriter struct {
+	header http.Header
+	body   bytes.Buffer
+	status int
+	limit  int
+}
+
+func (f *failingResponseWriter) Header() http.Header {
+	return f.header
+}
+
+func (f *failingResponseWriter) WriteHeader(code int) {
+	if f.status == 0 {
+		f.status = code
+	}
+}
+
+func (f *failingResponseWriter) Write(b []byte) (int, error) {
+	if f.status == 0 {
+		f.status = http.StatusOK
+	}
+	if f.body.Len()+len(b) > f.limit {
+		return 0, errors.New("connection reset")
+	}
+	return f.body.Write(b)
+}
+
+func TestDownloadZipErrors(t *testing.T) {
+	tmpDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "small.txt"), []byte("small"), 0600))
+
+	// Random data does not compress, so the archive exceeds the writer limit
+	large := make([]byte, 256*1024)
+	_, err := rand.Read(large)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "large.bin"), large, 0600))
+
+	cfg := &config.Config{
+		Directories: []config.DirMapping{
+			{Source: tmpDir, Virtual: "/test"},
+		},
+	}
+	srv := New(cfg)
+
+	t.Run("missing path is rejected before streaming", func(t *testing.T) {
+		body := strings.NewReader(`{"paths":["/test/small.txt","/test/missing.txt"]}`)
+		req := httptest.NewRequest("POST", "/api/download/zip", body)
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.NotEqual(t, "application/zip", rec.Header().Get("Content-Type"))
+		assert.Empty(t, rec.Header().Get("Content-Disposition"))
+		assert.Contains(t, rec.Body.String(), "file not found: /test/missing.txt")
+	})
+
+	t.Run("unknown virtual path is rejected before streaming", func(t *testing.T) {
+		body := strings.NewReader(`{"paths":["/other/file.txt"]}`)
+		req := httptest.NewRequest("POST", "/api/download/zip", body)
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Contains(t, rec.Body.String(), "virtual path not found")
+	})
+
+	t.Run("invalid exclude pattern is rejected", func(t *testing.T) {
+		body := strings.NewReader(`{"paths":["/test/small.txt"],"exclude":["[a-"]}`)
+		req := httptest.NewRequest("POST", "/api/download/zip", body)
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), "invalid exclude pattern")
+	})
+
+	t.Run("invalid layout is rejected", func(t *testing.T) {
+		body := strings.NewReader(`{"paths":["/test/small.txt"],"layout":"tree"}`)
+		req := httptest.NewRequest("POST", "/api/download/zip", body)
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), "invalid zip layout")
+	})
+
+	t.Run("skipInvalid reports skipped paths", func(t *testing.T) {
+		body := strings.NewReader(
+			`{"paths":["/test/small.txt","/test/missing file.txt","/other/x"],"skipInvalid":true}`)
+		req := httptest.NewRequest("POST", "/api/download/zip", body)
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "/test/missing%20file.txt,/other/x", rec.Header().Get("X-Skipped-Paths"))
+
+		reader, err := zip.NewReader(bytes.NewReader(rec.Body.Bytes()), int64(rec.Body.Len()))
+		require.NoError(t, err)
+		var names []string
+		for _, f := range reader.File {
+			names = append(names, f.Name)
+		}
+		assert.Equal(t, []string{"/test/small.txt", filesystem.ZipSkippedManifest}, names)
+	})
+
+	t.Run("skipInvalid without valid paths is rejected", func(t *testing.T) {
+		body := strings.NewReader(`{"paths":["/test/missing.txt"],"skipInvalid":true}`)
+		req := httptest.NewRequest("POST", "/api/download/zip", body)
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("mid-stream failure does not append an error", func(t *testing.T) {
+		body := strings.NewReader(`{"paths":["/test/small.txt","/test/large.bin"]}`)
+		req := httptest.NewRequest("POST", "/api/download/zip", body)
+		w := &failingResponseWriter{header: http.Header{}, limit: 8192}
+		srv.Router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.status)
+		assert.Equal(t, "application/zip", w.header.Get("Content-Type"))
+		assert.NotContains(t, w.body.String(), "failed to add")
+	})
+}
+
+func TestDownloadTar(t *testing.T) {
+	tmpDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "script.sh"), []byte("echo hi"), 0750))
+
+	srv := New(&config.Config{
+		Directories: []config.DirMapping{
+			{Source: tmpDir, Virtual: "/test"},
+		},
+	})
+
+	t.Run("streams tar archive", func(t *testing.T) {
+		body := strings.NewReader(`{"paths":["/test/script.sh"],"name":"scripts.tar"}`)
+		req := httptest.NewRequest("POST", "/api/download/tar", body)
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "application/x-tar", rec.Header().Get("Content-Type"))
+		assert.Equal(t, `attachment; filename="scripts.tar"`, rec.Header().Get("Content-Disposition"))
+
+		tr := tar.NewReader(rec.Body)
+		header, err := tr.Next()
+		require.NoError(t, err)
+		assert.Equal(t, "test/script.sh", header.Name)
+		if runtime.GOOS != "windows" {
+			assert.Equal(t, int64(0750), header.Mode&0777)
+		}
+		content, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		assert.Equal(t, "echo hi", string(content))
+		_, err = tr.Next()
+		assert.Equal(t, io.EOF, err)
+	})
+
+	t.Run("missing path is rejected before streaming", func(t *testing.T) {
+		body := strings.NewReader(`{"paths":["/test/missing.sh"]}`)
+		req := httptest.NewRequest("POST", "/api/download/tar", body)
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Empty(t, rec.Header().Get("Content-Disposition"))
+	})
+
+	t.Run("streams gzip-compressed tar archive", func(t *testing.T) {
+		body := strings.NewReader(`{"paths":["/test/script.sh"]}`)
+		req := httptest.NewRequest("POST", "/api/download/targz", body)
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "application/gzip", rec.Header().Get("Content-Type"))
+		assert.Equal(t, `attachment; filename="download.tar.gz"`, rec.Header().Get("Content-Disposition"))
+
+		gr, err := gzip.NewReader(rec.Body)
+		require.NoError(t, err)
+		tr := tar.NewReader(gr)
+		header, err := tr.Next()
+		require.NoError(t, err)
+		assert.Equal(t, "test/script.sh", header.Name)
+		content, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		assert.Equal(t, "echo hi", string(content))
+	})
+}
+
+func TestStatEmbed(t *testing.T) {
+	tmpDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "note.txt"), []byte("short note"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "large.txt"), bytes.Repeat([]byte("x"), 5000), 0600))
+	require.NoError(t, os.Mkdir(filepath.Join(tmpDir, "dir"), 0750))
+
+	srv := New(&config.Config{
+		Directories: []config.DirMapping{
+			{Source: tmpDir, Virtual: "/test"},
+		},
+	})
+
+	stat := func(t *testing.T, target string) filesystem.FileStatInfo {
+		t.Helper()
+		req := httptest.NewRequest("GET", target, nil)
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		var info filesystem.FileStatInfo
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &info))
+		return info
+	}
+
+	t.Run("small file is embedded", func(t *testing.T) {
+		info := stat(t, "/api/files/test/note.txt/stat?embed=true")
+		assert.Equal(t, "short note", string(info.Content))
+		assert.Contains(t, info.MimeType, "text/plain")
+	})
+
+	t.Run("content is base64 encoded", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/api/files/test/note.txt/stat?embed=true", nil)
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+		assert.Contains(t, rec.Body.String(), `"content":"c2hvcnQgbm90ZQ=="`)
+	})
+
+	t.Run("file above the limit is not embedded", func(t *testing.T) {
+		assert.Empty(t, stat(t, "/api/files/test/large.txt/stat?embed=true").Content)
+		assert.Empty(t, stat(t, "/api/files/test/note.txt/stat?embed=true&maxEmbed=4").Content)
+		assert.Len(t, stat(t, "/api/files/test/large.txt/stat?embed=true&maxEmbed=8192").Content, 5000)
+	})
+
+	t.Run("directories and plain stat carry no content", func(t *testing.T) {
+		assert.Empty(t, stat(t, "/api/files/test/dir/stat?embed=true").Content)
+		assert.Empty(t, stat(t, "/api/files/test/note.txt/stat").Content)
+	})
+
+	t.Run("checksum is computed on request", func(t *testing.T) {
+		info := stat(t, "/api/files/test/note.txt/stat?checksum=sha256")
+		assert.Equal(t, "92c397cbcd1b93c6da150d5a212aadc4490a913196159f788b3a61eecfe1b79f", info.SHA256)
+		assert.Equal(t, "dbda374a8b38ff113618279d14cbbf1b", stat(t, "/api/files/test/note.txt/stat?checksum=md5").MD5)
+		assert.Empty(t, stat(t, "/api/files/test/note.txt/stat").SHA256)
+		assert.Empty(t, stat(t, "/api/files/test/dir/stat?checksum=sha256").SHA256)
+
+		req := httptest.NewRequest("GET", "/api/files/test/note.txt/stat?checksum=crc32", nil)
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("invalid maxEmbed is rejected", func(t *testing.T) {
+		for _, value := range []string{"0", "-1", "abc", "2000000"} {
+			req := httptest.NewRequest("GET", "/api/files/test/note.txt/stat?embed=true&maxEmbed="+value, nil)
+			rec := httptest.NewRecorder()
+			srv.Router.ServeHTTP(rec, req)
+			assert.Equal(t, http.StatusBadRequest, rec.Code, value)
+		}
+	})
+}
+
+func TestEmptyDirsEndpoints(t *testing.T) {
+	tmpDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(tmpDir, "empty", "nested"), 0750))
+	require.NoError(t, os.MkdirAll(filepath.Join(tmpDir, "full"), 0750))
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "full", "file.txt"), []byte("x"), 0600))
+
+	srv := New(&config.Config{
+		Directories: []config.DirMapping{
+			{Source: tmpDir, Virtual: "/test"},
+		},
+	})
+
+	t.Run("list empty directories", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/api/empty-dirs?path=/test", nil)
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `["/test/empty","/test/empty/nested"]`, rec.Body.String())
+	})
+
+	t.Run("dry run cleanup keeps directories", func(t *testing.T) {
+		body := strings.NewReader(`{"path":"/test","dryRun":true}`)
+		req := httptest.NewRequest("POST", "/api/cleanup/empty-dirs", body)
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"removed":["/test/empty","/test/empty/nested"],"dryRun":true}`, rec.Body.String())
+		assert.DirExists(t, filepath.Join(tmpDir, "empty", "nested"))
+	})
+
+	t.Run("cleanup removes empty directories", func(t *testing.T) {
+		body := strings.NewReader(`{"path":"/test"}`)
+		req := httptest.NewRequest("POST", "/api/cleanup/empty-dirs", body)
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.NoDirExists(t, filepath.Join(tmpDir, "empty"))
+		assert.DirExists(t, filepath.Join(tmpDir, "full"))
+	})
+
+	t.Run("unknown path", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/api/empty-dirs?path=/other", nil)
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
+
+func TestGetFileText(t *testing.T) {
+	tmpDir := t.TempDir()
+	// "Grüße" in UTF-16LE with byte order mark
+	utf16 := []byte{0xFF, 0xFE, 'G', 0, 'r', 0, 0xFC, 0, 0xDF, 0, 'e', 0}
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "utf16.txt"), utf16, 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "latin1.txt"), []byte{'G', 'r', 0xFC, 0xDF, 'e'}, 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "image.bin"), []byte{0x89, 'P', 'N', 'G', 0}, 0600))
+
+	srv := New(&config.Config{
+		Directories: []config.DirMapping{
+			{Source: tmpDir, Virtual: "/test"},
+		},
+	})
+
+	for file, encoding := range map[string]string{"utf16.txt": "utf-16le", "latin1.txt": "iso-8859-1"} {
+		t.Run(file, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/files/test/"+file+"/text?encoding=auto", nil)
+			rec := httptest.NewRecorder()
+			srv.Router.ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, "text/plain; charset=utf-8", rec.Header().Get("Content-Type"))
+			assert.Equal(t, encoding, rec.Header().Get("X-Detected-Encoding"))
+			assert.Equal(t, "Grüße", rec.Body.String())
+		})
+	}
+
+	t.Run("binary file", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/api/files/test/image.bin/text", nil)
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+	})
+}
+
+func TestListFilesTextFormat(t *testing.T) {
+	tmpDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "notes.txt"), []byte("hello"), 0600))
+	require.NoError(t, os.Mkdir(filepath.Join(tmpDir, "sub"), 0750))
+
+	cfg := &config.Config{
+		Directories: []config.DirMapping{
+			{Source: tmpDir, Virtual: "/data"},
+		},
+	}
+	srv := New(cfg)
+
+	get := func(target, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", target, nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+		return rec
+	}
+	lines := func(rec *httptest.ResponseRecorder) []string {
+		return strings.Split(strings.TrimSuffix(rec.Body.String(), "\n"), "\n")
+	}
+
+	t.Run("format parameter", func(t *testing.T) {
+		rec := get("/api/files?path=/data&format=text", "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "text/plain; charset=utf-8", rec.Header().Get("Content-Type"))
+		assert.ElementsMatch(t, []string{"notes.txt", "sub/"}, lines(rec))
+	})
+
+	t.Run("accept header", func(t *testing.T) {
+		rec := get("/api/files?path=/data", "text/plain")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.ElementsMatch(t, []string{"notes.txt", "sub/"}, lines(rec))
+	})
+
+	t.Run("json stays default", func(t *testing.T) {
+		rec := get("/api/files?path=/data", "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+		rec = get("/api/files?path=/data&format=json", "text/plain")
+		assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	})
+
+	t.Run("detailed variant", func(t *testing.T) {
+		rec := get("/api/files?path=/data&format=text&long=true", "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		got := lines(rec)
+		require.Len(t, got, 2)
+		for _, line := range got {
+			fields := strings.Fields(line)
+			require.Len(t, fields, 5, line)
+			switch fields[4] {
+			case "notes.txt":
+				assert.Equal(t, "-rw-------", fields[0])
+				assert.Equal(t, "5", fields[1])
+			case "sub/":
+				assert.True(t, strings.HasPrefix(fields[0], "d"))
+			default:
+				t.Fatalf("unexpected entry %q", line)
+			}
+		}
+	})
+
+	t.Run("missing directory", func(t *testing.T) {
+		rec := get("/api/files?path=/data/missing&format=text", "")
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
+
+func TestStaticCacheHeaders(t *testing.T) {
+	get := func(srv *Server, target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", target, nil)
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	srv := New(&config.Config{})
+
+	t.Run("asset is cached for a long time", func(t *testing.T) {
+		rec := get(srv, "/css/styles.css")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "public, max-age=31536000", rec.Header().Get("Cache-Control"))
+	})
+
+	t.Run("index is revalidated", func(t *testing.T) {
+		rec := get(srv, "/")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "no-cache", rec.Header().Get("Cache-Control"))
+		require.NotEmpty(t, srv.assetVersion)
+		assert.Contains(t, rec.Body.String(), `href="/css/styles.css?v=`+srv.assetVersion+`"`)
+		assert.Contains(t, rec.Body.String(), `src="/js/app.js?v=`+srv.assetVersion+`"`)
+	})
+
+	t.Run("versioned asset URL is served", func(t *testing.T) {
+		rec := get(srv, "/js/app.js?v="+srv.assetVersion)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("configured max age", func(t *testing.T) {
+		srv := New(&config.Config{Static: config.StaticConfig{MaxAge: 600}})
+		assert.Equal(t, "public, max-age=600", get(srv, "/images/favicon.svg").Header().Get("Cache-Control"))
+	})
+
+	t.Run("caching disabled", func(t *testing.T) {
+		srv := New(&config.Config{Static: config.StaticConfig{MaxAge: -1}})
+		assert.Equal(t, "no-cache", get(srv, "/js/app.js").Header().Get("Cache-Control"))
+	})
+}
+
+func TestDownloadETag(t *testing.T) {
+	tmpDir := t.TempDir()
+	filePath := filepath.Join(tmpDir, "report.txt")
+	require.NoError(t, os.WriteFile(filePath, []byte("version one"), 0600))
+
+	srv := New(&config.Config{
+		Directories: []config.DirMapping{{Source: tmpDir, Virtual: "/data"}},
+	})
+	download := func(header, value string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/files/data/report.txt", nil)
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := download("", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	etag := rec.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+	assert.True(t, strings.HasPrefix(etag, `"`) && strings.HasSuffix(etag, `"`), etag)
+	lastModified := rec.Header().Get("Last-Modified")
+	require.NotEmpty(t, lastModified)
+	assert.Equal(t, etag, download("", "").Header().Get("ETag"), "the ETag is stable")
+
+	rec = download("If-None-Match", etag)
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+	assert.Empty(t, rec.Body.String())
+
+	rec = download("If-Modified-Since", lastModified)
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+	assert.Empty(t, rec.Body.String())
+
+	// A changed file gets a new ETag and is transferred again
+	require.NoError(t, os.WriteFile(filePath, []byte("version two!"), 0600))
+	later := time.Now().Add(time.Hour)
+	require.NoError(t, os.Chtimes(filePath, later, later))
+	rec = download("If-None-Match", etag)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "version two!", rec.Body.String())
+	assert.NotEqual(t, etag, rec.Header().Get("ETag"))
+}
+
+func TestBasePath(t *testing.T) {
+	tmpDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "a.txt"), []byte("a"), 0644))
+	srv := New(&config.Config{
+		Main:        config.MainConfig{BasePath: "/dendrite"},
+		Directories: []config.DirMapping{{Source: tmpDir, Virtual: "/data"}},
+	})
+
+	get := func(target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", target, nil)
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("root redirects to the base path", func(t *testing.T) {
+		for _, target := range []string{"/", "/dendrite"} {
+			rec := get(target)
+			assert.Equal(t, http.StatusFound, rec.Code, target)
+			assert.Equal(t, "/dendrite/", rec.Header().Get("Location"), target)
+		}
+	})
+
+	t.Run("index uses the base path", func(t *testing.T) {
+		rec := get("/dendrite/data")
+		require.Equal(t, http.StatusOK, rec.Code)
+		body := rec.Body.String()
+		assert.Contains(t, body, `src="/dendrite/js/app.js?v=`+srv.assetVersion+`"`)
+		assert.Contains(t, body, `window.DENDRITE_CONFIG = {"basePath":"/dendrite"};`)
+	})
+
+	t.Run("assets and API are served below the base path", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, get("/dendrite/css/styles.css").Code)
+		assert.Equal(t, http.StatusOK, get("/dendrite/api/mode").Code)
+		rec := get("/dendrite/api/files?path=/data")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), "a.txt")
+	})
+
+	t.Run("paths outside the base path are not found", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, get("/api/mode").Code)
+		assert.Equal(t, http.StatusNotFound, get("/css/styles.css").Code)
+	})
+}
+
+func TestUploadRaw(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg := &config.Config{
+		Directories: []config.DirMapping{
+			{Source: tmpDir, Virtual: "/data"},
+		},
+		QuotaBytes: 16,
+	}
+	srv := New(cfg)
+
+	put := func(target string, body string, chunked bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("PUT", target, strings.NewReader(body))
+		if chunked {
+			req.ContentLength = -1
+		}
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("stores the body", func(t *testing.T) {
+		rec := put("/api/raw/data/dir/hello.txt", "hello", false)
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+		var result filesystem.UploadResult
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+		assert.Equal(t, "/data/dir/hello.txt", result.Path)
+		assert.Equal(t, int64(5), result.Size)
+		assert.True(t, result.Created)
+
+		content, err := os.ReadFile(filepath.Join(tmpDir, "dir", "hello.txt"))
+		require.NoError(t, err)
+		assert.Equal(t, "hello", string(content))
+	})
+
+	t.Run("overwriting answers 200", func(t *testing.T) {
+		rec := put("/api/raw/data/dir/hello.txt", "hi", false)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		var result filesystem.UploadResult
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+		assert.False(t, result.Created)
+		assert.Equal(t, int64(2), result.Size)
+	})
+
+	t.Run("content length over quota", func(t *testing.T) {
+		rec := put("/api/raw/data/big.bin", strings.Repeat("x", 32), false)
+		assert.Equal(t, http.StatusInsufficientStorage, rec.Code)
+		assert.NoFileExists(t, filepath.Join(tmpDir, "big.bin"))
+	})
+
+	t.Run("chunked body over quota", func(t *testing.T) {
+		rec := put("/api/raw/data/big.bin", strings.Repeat("x", 32), true)
+		assert.Equal(t, http.StatusInsufficientStorage, rec.Code)
+		assert.NoFileExists(t, filepath.Join(tmpDir, "big.bin"))
+	})
+
+	t.Run("directory target", func(t *testing.T) {
+		rec := put("/api/raw/data/dir", "x", false)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+	})
+}
+
+func TestPutFileRawCreated(t *testing.T) {
+	tmpDir := t.TempDir()
+	srv := New(&config.Config{
+		Directories: []config.DirMapping{
+			{Source: tmpDir, Virtual: "/data"},
+		},
+	})
+
+	save := func(content string) (int, filesystem.WriteResult) {
+		req := httptest.NewRequest("PUT", "/api/files/data/notes.txt/raw", strings.NewReader(content))
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+
+		var result filesystem.WriteResult
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result), rec.Body.String())
+		return rec.Code, result
+	}
+
+	status, result := save("first")
+	assert.Equal(t, http.StatusCreated, status)
+	assert.True(t, result.Created)
+	assert.Equal(t, int64(5), result.Size)
+
+	status, result = save("second write")
+	assert.Equal(t, http.StatusOK, status)
+	assert.False(t, result.Created)
+	assert.Equal(t, int64(12), result.Size)
+
+	content, err := os.ReadFile(filepath.Join(tmpDir, "notes.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "second write", string(content))
+}
+
+func TestCheckExists(t *testing.T) {
+	tmpDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "file.txt"), []byte("x"), 0600))
+	require.NoError(t, os.Mkdir(filepath.Join(tmpDir, "sub"), 0750))
+
+	srv := New(&config.Config{
+		Directories: []config.DirMapping{
+			{Source: tmpDir, Virtual: "/data"},
+		},
+	})
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+		wantExists bool
+		wantIsDir  bool
+	}{
+		{"existing file", "/data/file.txt", http.StatusOK, true, false},
+		{"existing directory", "/data/sub", http.StatusOK, true, true},
+		{"missing path", "/data/missing.txt", http.StatusOK, false, false},
+		{"unmapped path", "/other/file.txt", http.StatusOK, false, false},
+		{"virtual root", "/", http.StatusOK, true, true},
+		{"path escaping the mapping", "/data/../../etc/passwd", http.StatusOK, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/exists?path="+tt.path, nil)
+			rec := httptest.NewRecorder()
+			srv.Router.ServeHTTP(rec, req)
+
+			require.Equal(t, tt.wantStatus, rec.Code, rec.Body.String())
+			var body map[string]bool
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+			assert.Equal(t, map[string]bool{"exists": tt.wantExists, "isDir": tt.wantIsDir}, body)
+		})
+	}
+
+	t.Run("missing parameter", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/api/exists", nil)
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
+
+func TestListFilesPermissionDenied(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("directory read permissions cannot be simulated with chmod on Windows")
+	}
+	if os.Geteuid() == 0 {
+		t.Skip("root can read directories regardless of permissions")
+	}
+
+	tmpDir := t.TempDir()
+	locked := filepath.Join(tmpDir, "locked")
+	require.NoError(t, os.Mkdir(locked, 0750))
+	require.NoError(t, os.Chmod(locked, 0))
+	t.Cleanup(func() {
+		_ = os.Chmod(locked, 0750) // Allow TempDir cleanup
+	})
+
+	srv := New(&config.Config{
+		Directories: []config.DirMapping{
+			{Source: tmpDir, Virtual: "/data"},
+		},
+	})
+
+	list := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/files?path="+path, nil)
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := list("/data/locked")
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Contains(t, rec.Body.String(), "no permission to read directory /data/locked")
+
+	rec = list("/data/missing")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestCreateFolderExistOk(t *testing.T) {
+	tmpDir := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(tmpDir, "existing"), 0750))
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "file.txt"), []byte("x"), 0600))
+
+	srv := New(&config.Config{
+		Directories: []config.DirMapping{
+			{Source: tmpDir, Virtual: "/data"},
+		},
+	})
+
+	mkdir := func(target, body string) (int, map[string]string) {
+		req := httptest.NewRequest("POST", target, strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+		var resp map[string]string
+		if rec.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		}
+		return rec.Code, resp
+	}
+
+	t.Run("missing directory is created", func(t *testing.T) {
+		code, resp := mkdir("/api/mkdir", `{"path": "/data/new", "existOk": true}`)
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "created", resp["status"])
+		assert.DirExists(t, filepath.Join(tmpDir, "new"))
+	})
+
+	t.Run("existing directory succeeds", func(t *testing.T) {
+		code, resp := mkdir("/api/mkdir", `{"path": "/data/existing", "existOk": true}`)
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "exists", resp["status"])
+
+		code, resp = mkdir("/api/mkdir?existOk=true", `{"path": "/data/existing"}`)
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "exists", resp["status"])
+	})
+
+	t.Run("existing file conflicts", func(t *testing.T) {
+		code, _ := mkdir("/api/mkdir", `{"path": "/data/file.txt", "existOk": true}`)
+		assert.Equal(t, http.StatusConflict, code)
+	})
+
+	t.Run("strict by default", func(t *testing.T) {
+		code, _ := mkdir("/api/mkdir", `{"path": "/data/existing"}`)
+		assert.Equal(t, http.StatusConflict, code)
+	})
+}
+
+func TestUploadAutoExtract(t *testing.T) {
+	tmpDir := t.TempDir()
+	srv := New(&config.Config{
+		Directories: []config.DirMapping{
+			{Source: tmpDir, Virtual: "/inbox", AutoExtract: true, DeleteArchive: true},
+		},
+	})
+
+	var archive bytes.Buffer
+	zw := zip.NewWriter(&archive)
+	w, err := zw.Create("docs/readme.txt")
+	require.NoError(t, err)
+	_, err = w.Write([]byte("read me"))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	rec := httptest.NewRecorder()
+	srv.Router.ServeHTTP(rec, uploadRequest(t, "/inbox", "release.zip", archive.String()))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var result filesystem.UploadResult
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+	assert.Equal(t, "/inbox/release", result.Extracted)
+
+	content, err := os.ReadFile(filepath.Join(tmpDir, "release", "docs", "readme.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "read me", string(content))
+	assert.NoFileExists(t, filepath.Join(tmpDir, "release.zip"))
+
+	// Raw uploads are extracted as well
+	req := httptest.NewRequest("PUT", "/api/raw/inbox/second.zip", bytes.NewReader(archive.Bytes()))
+	rec = httptest.NewRecorder()
+	srv.Router.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	assert.FileExists(t, filepath.Join(tmpDir, "second", "docs", "readme.txt"))
+}
+
+func TestExtractArchiveEndpoint(t *testing.T) {
+	tmpDir := t.TempDir()
+	srv := New(&config.Config{
+		Directories: []config.DirMapping{{Source: tmpDir, Virtual: "/docs"}},
+	})
+
+	writeZip := func(name string, entries ...string) {
+		var archive bytes.Buffer
+		zw := zip.NewWriter(&archive)
+		for _, entry := range entries {
+			w, err := zw.Create(entry)
+			require.NoError(t, err)
+			_, err = w.Write([]byte("content of " + entry))
+			require.NoError(t, err)
+		}
+		require.NoError(t, zw.Close())
+		require.NoError(t, os.WriteFile(filepath.Join(tmpDir, name), archive.Bytes(), 0600))
+	}
+	extract := func(target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", target, strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	writeZip("good.zip", "readme.txt", "sub/notes.txt")
+	writeZip("evil.zip", "../escaped.txt")
+
+	rec := extract("/api/files/docs/good.zip/extract", `{"dest": "/docs/unpacked"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.JSONEq(t, `{"status": "extracted", "path": "/docs/unpacked"}`, rec.Body.String())
+	assert.FileExists(t, filepath.Join(tmpDir, "unpacked", "sub", "notes.txt"))
+
+	rec = extract("/api/files/docs/good.zip/extract", `{"dest": "/docs/unpacked"}`)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	rec = extract("/api/files/docs/evil.zip/extract", `{"dest": "/docs/other"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Contains(t, rec.Body.String(), "invalid archive entry: ../escaped.txt")
+	assert.NoFileExists(t, filepath.Join(filepath.Dir(tmpDir), "escaped.txt"))
+	assert.NoDirExists(t, filepath.Join(tmpDir, "other"))
+
+	assert.Equal(t, http.StatusBadRequest, extract("/api/files/docs/good.zip/extract", `{}`).Code)
+	assert.Equal(t, http.StatusNotFound, extract("/api/files/docs/none.zip/extract", `{"dest": "/docs"}`).Code)
+}
+
+func TestListFilesMaxEntries(t *testing.T) {
+	tmpDir := t.TempDir()
+	for _, name := range []string{"a", "b", "c"} {
+		require.NoError(t, os.WriteFile(filepath.Join(tmpDir, name), []byte("x"), 0600))
+	}
+
+	list := func(listing config.ListingConfig) *httptest.ResponseRecorder {
+		srv := New(&config.Config{
+			Directories: []config.DirMapping{{Source: tmpDir, Virtual: "/data"}},
+			Listing:     listing,
+		})
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, httptest.NewRequest("GET", "/api/files?path=/data", nil))
+		return rec
+	}
+
+	t.Run("truncate", func(t *testing.T) {
+		rec := list(config.ListingConfig{MaxEntries: 2})
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "true", rec.Header().Get("X-Listing-Truncated"))
+		var files []filesystem.FileInfo
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &files))
+		assert.Len(t, files, 2)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		rec := list(config.ListingConfig{MaxEntries: 2, OnLimit: "error"})
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	})
+
+	t.Run("within limit", func(t *testing.T) {
+		rec := list(config.ListingConfig{MaxEntries: 3})
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Empty(t, rec.Header().Get("X-Listing-Truncated"))
+	})
+}
+
+func TestListFilesRecursive(t *testing.T) {
+	tmpDir := t.TempDir()
+	for _, dir := range []string{"a/b/c", "d"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(tmpDir, dir), 0750))
+	}
+	for _, file := range []string{"root.txt", "a/one.txt", "a/b/two.txt", "a/b/c/three.txt"} {
+		require.NoError(t, os.WriteFile(filepath.Join(tmpDir, file), []byte(file), 0600))
+	}
+	// Symlinked directories are not followed
+	require.NoError(t, os.Symlink(filepath.Join(tmpDir, "a"), filepath.Join(tmpDir, "d", "loop")))
+
+	srv := New(&config.Config{
+		Directories: []config.DirMapping{{Source: tmpDir, Virtual: "/data"}},
+	})
+	get := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, httptest.NewRequest("GET", target, nil))
+		return rec
+	}
+	paths := func(files []filesystem.FileInfo) []string {
+		result := make([]string, 0, len(files))
+		for _, f := range files {
+			result = append(result, f.Path)
+		}
+		return result
+	}
+
+	rec := get("/api/files?path=/data&recursive=true")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var arrayFiles []filesystem.FileInfo
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &arrayFiles))
+	assert.ElementsMatch(t, []string{
+		"/data/root.txt", "/data/a", "/data/a/one.txt", "/data/a/b", "/data/a/b/two.txt",
+		"/data/a/b/c", "/data/a/b/c/three.txt", "/data/d", "/data/d/loop",
+	}, paths(arrayFiles))
+
+	t.Run("ndjson stream matches array", func(t *testing.T) {
+		rec := get("/api/files?path=/data&recursive=true&format=ndjson")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "application/x-ndjson", rec.Header().Get("Content-Type"))
+
+		var streamed []filesystem.FileInfo
+		for _, line := range strings.Split(strings.TrimSuffix(rec.Body.String(), "\n"), "\n") {
+			var f filesystem.FileInfo
+			require.NoError(t, json.Unmarshal([]byte(line), &f), line)
+			streamed = append(streamed, f)
+		}
+		assert.ElementsMatch(t, arrayFiles, streamed)
+	})
+
+	t.Run("depth cap", func(t *testing.T) {
+		rec := get("/api/files?path=/data&recursive=true&format=ndjson&depth=2")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"path":"/data/a/b"`)
+		assert.NotContains(t, rec.Body.String(), "two.txt")
+
+		rec = get("/api/files?path=/data&recursive=true&depth=0")
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("text format prints paths", func(t *testing.T) {
+		rec := get("/api/files?path=/data/a&recursive=true&format=text")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.ElementsMatch(t, []string{
+			"/data/a/one.txt", "/data/a/b/", "/data/a/b/two.txt", "/data/a/b/c/", "/data/a/b/c/three.txt",
+		}, strings.Split(strings.TrimSuffix(rec.Body.String(), "\n"), "\n"))
+	})
+
+	t.Run("missing directory", func(t *testing.T) {
+		rec := get("/api/files?path=/data/missing&recursive=true&format=ndjson")
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
+
+func TestListFilesTypeFilter(t *testing.T) {
+	docsDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(docsDir, "sub", "deeper"), 0750))
+	require.NoError(t, os.WriteFile(filepath.Join(docsDir, "a.txt"), []byte("a"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(docsDir, "sub", "b.txt"), []byte("b"), 0600))
+
+	srv := New(&config.Config{
+		Directories: []config.DirMapping{
+			{Source: docsDir, Virtual: "/docs"},
+			{Source: t.TempDir(), Virtual: "/other"},
+		},
+	})
+	list := func(query string) []string {
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, httptest.NewRequest("GET", "/api/files?"+query, nil))
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		var files []filesystem.FileInfo
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &files))
+		paths := make([]string, 0, len(files))
+		for _, f := range files {
+			paths = append(paths, f.Path)
+		}
+		return paths
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{"directories only", "path=/docs&type=dir", []string{"/docs/sub"}},
+		{"files only", "path=/docs&type=file", []string{"/docs/a.txt"}},
+		{"no filter", "path=/docs", []string{"/docs/a.txt", "/docs/sub"}},
+		{"virtual root directories", "path=/&type=dir", []string{"/docs", "/other"}},
+		{"virtual root files", "path=/&type=file", []string{}},
+		{"recursive directories", "path=/docs&recursive=true&type=dir", []string{"/docs/sub", "/docs/sub/deeper"}},
+		{"recursive files", "path=/docs&recursive=true&type=file", []string{"/docs/a.txt", "/docs/sub/b.txt"}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.ElementsMatch(t, tc.want, list(tc.query))
+		})
+	}
+
+	t.Run("invalid type", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, httptest.NewRequest("GET", "/api/files?path=/docs&type=link", nil))
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
+
+func TestListFilesDirsFirst(t *testing.T) {
+	tmpDir := t.TempDir()
+	for _, name := range []string{"b-dir", "d-dir"} {
+		require.NoError(t, os.Mkdir(filepath.Join(tmpDir, name), 0750))
+	}
+	for _, name := range []string{"a.txt", "c.txt", "e.txt"} {
+		require.NoError(t, os.WriteFile(filepath.Join(tmpDir, name), []byte(name), 0600))
+	}
+
+	srv := New(&config.Config{
+		Directories: []config.DirMapping{{Source: tmpDir, Virtual: "/test"}},
+	})
+	list := func(query string) []string {
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, httptest.NewRequest("GET", "/api/files?"+query, nil))
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		var files []filesystem.FileInfo
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &files))
+		names := make([]string, 0, len(files))
+		for _, f := range files {
+			names = append(names, f.Name)
+		}
+		return names
+	}
+
+	assert.Equal(t, []string{"a.txt", "b-dir", "c.txt", "d-dir", "e.txt"}, list("path=/test"))
+	assert.Equal(t, []string{"b-dir", "d-dir", "a.txt", "c.txt", "e.txt"}, list("path=/test&dirsFirst=true"))
+}
+
+func TestListFilesPaged(t *testing.T) {
+	tmpDir := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(tmpDir, "dir"), 0750))
+	for i, name := range []string{"a.txt", "b.txt", "c.txt", "d.txt"} {
+		require.NoError(t, os.WriteFile(filepath.Join(tmpDir, name), bytes.Repeat([]byte("x"), 10-i), 0600))
+	}
+
+	srv := New(&config.Config{
+		Directories: []config.DirMapping{{Source: tmpDir, Virtual: "/test"}},
+	})
+	get := func(query string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, httptest.NewRequest("GET", "/api/files?"+query, nil))
+		return rec
+	}
+	page := func(query string) (names []string, total int) {
+		rec := get(query)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		var result struct {
+			Files  []filesystem.FileInfo `json:"files"`
+			Total  int                   `json:"total"`
+			Offset int                   `json:"offset"`
+			Limit  int                   `json:"limit"`
+		}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+		assert.Equal(t, strconv.Itoa(result.Total), rec.Header().Get("X-Total-Count"))
+		for _, f := range result.Files {
+			names = append(names, f.Name)
+		}
+		return names, result.Total
+	}
+
+	names, total := page("path=/test&limit=2")
+	assert.Equal(t, []string{"dir", "a.txt"}, names)
+	assert.Equal(t, 5, total)
+
+	names, _ = page("path=/test&offset=2&limit=2")
+	assert.Equal(t, []string{"b.txt", "c.txt"}, names)
+
+	names, _ = page("path=/test&offset=0&limit=3&sort=size&order=asc")
+	assert.Equal(t, []string{"dir", "d.txt", "c.txt"}, names)
+
+	names, _ = page("path=/test&limit=2&dirsFirst=false")
+	assert.Equal(t, []string{"a.txt", "b.txt"}, names)
+
+	names, total = page("path=/test&offset=10&limit=3")
+	assert.Empty(t, names)
+	assert.Equal(t, 5, total)
+
+	names, total = page("path=/test&limit=10&type=file&sort=name&order=desc")
+	assert.Equal(t, []string{"d.txt", "c.txt", "b.txt", "a.txt"}, names)
+	assert.Equal(t, 4, total)
+
+	t.Run("breadcrumb is combined with the page", func(t *testing.T) {
+		rec := get("path=/test&limit=1&breadcrumb=true")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"path":{"current":"/test"`)
+		assert.Contains(t, rec.Body.String(), `"total":5`)
+	})
+
+	t.Run("without a limit the plain array is sorted on request", func(t *testing.T) {
+		rec := get("path=/test&sort=size&order=desc")
+		require.Equal(t, http.StatusOK, rec.Code)
+		var files []filesystem.FileInfo
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &files))
+		require.Len(t, files, 5)
+		assert.Equal(t, "a.txt", files[1].Name)
+		assert.Equal(t, "d.txt", files[4].Name)
+		assert.Empty(t, rec.Header().Get("X-Total-Count"))
+	})
+
+	t.Run("invalid parameters are rejected", func(t *testing.T) {
+		for _, query := range []string{"limit=0", "limit=abc", "limit=5&offset=-1", "sort=owner", "order=up"} {
+			assert.Equal(t, http.StatusBadRequest, get("path=/test&"+query).Code, query)
+		}
+	})
+}
+
+func TestGetMode(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  *config.Config
+		want string
+	}{
+		{
+			name: "directory mode",
+			cfg:  &config.Config{Directories: []config.DirMapping{{Source: t.TempDir(), Virtual: "/secret-name"}}},
+			want: `{"mode":"directory","requiresToken":false}`,
+		},
+		{
+			name: "jwt mode",
+			cfg:  &config.Config{JWTSecret: "test-secret-that-is-at-least-32-characters-long", BaseDir: t.TempDir()},
+			want: `{"mode":"jwt","requiresToken":true}`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := New(tc.cfg)
+			rec := httptest.NewRecorder()
+			srv.Router.ServeHTTP(rec, httptest.NewRequest("GET", "/api/mode", nil))
+
+			require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+			assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+			assert.JSONEq(t, tc.want, rec.Body.String())
+		})
+	}
+
+	t.Run("other endpoints still require a token", func(t *testing.T) {
+		srv := New(tests[1].cfg)
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, httptest.NewRequest("GET", "/api/quota", nil))
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+}
+
+func TestChmodFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(tmpDir, "tree", "sub"), 0700))
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "tree", "sub", "run.sh"), []byte("#!/bin/sh"), 0600))
+
+	srv := New(&config.Config{
+		Directories: []config.DirMapping{{Source: tmpDir, Virtual: "/data"}},
+	})
+	chmod := func(target, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, httptest.NewRequest("POST", target, strings.NewReader(body)))
+		return rec
+	}
+
+	rec := chmod("/api/files/data/tree/chmod?recursive=true", `{"mode": "0644"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.JSONEq(t, `{"status": "changed", "changed": 3}`, rec.Body.String())
+
+	// The directory mode is derived from the file mode
+	info, err := os.Stat(filepath.Join(tmpDir, "tree", "sub"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o755), info.Mode().Perm())
+	info, err = os.Stat(filepath.Join(tmpDir, "tree", "sub", "run.sh"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o644), info.Mode().Perm())
+
+	rec = chmod("/api/files/data/tree/sub/run.sh/chmod", `{"mode": "0755"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.JSONEq(t, `{"status": "changed", "changed": 1}`, rec.Body.String())
+
+	assert.Equal(t, http.StatusBadRequest, chmod("/api/files/data/tree/chmod", `{"mode": "6755"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, chmod("/api/files/data/tree/chmod", `{"mode": "0644", "dirMode": "x"}`).Code)
+	assert.Equal(t, http.StatusNotFound, chmod("/api/files/data/missing/chmod", `{"mode": "0644"}`).Code)
+}
+
+func TestReplaceDirectory(t *testing.T) {
+	tmpDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(tmpDir, "site"), 0750))
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "site", "old.html"), []byte("old"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "file.txt"), []byte("x"), 0600))
+
+	srv := New(&config.Config{
+		Directories: []config.DirMapping{{Source: tmpDir, Virtual: "/data"}},
+	})
+	replace := func(target string, body []byte, contentType string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", target, bytes.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	var archive bytes.Buffer
+	zw := zip.NewWriter(&archive)
+	w, err := zw.Create("index.html")
+	require.NoError(t, err)
+	_, err = w.Write([]byte("new"))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	rec := replace("/api/files/data/site/replace", archive.Bytes(), "application/zip")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.JSONEq(t, `{"status": "replaced", "path": "/data/site"}`, rec.Body.String())
+	assert.FileExists(t, filepath.Join(tmpDir, "site", "index.html"))
+	assert.NoFileExists(t, filepath.Join(tmpDir, "site", "old.html"))
+
+	require.NoError(t, os.MkdirAll(filepath.Join(tmpDir, "staging"), 0750))
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "staging", "v2.html"), []byte("v2"), 0600))
+	rec = replace("/api/files/data/site/replace", []byte(`{"staging": "/data/staging"}`), "application/json")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.FileExists(t, filepath.Join(tmpDir, "site", "v2.html"))
+
+	assert.Equal(t, http.StatusUnprocessableEntity,
+		replace("/api/files/data/site/replace", []byte("garbage"), "application/zip").Code)
+	assert.Equal(t, http.StatusConflict,
+		replace("/api/files/data/file.txt/replace", archive.Bytes(), "application/zip").Code)
+	assert.Equal(t, http.StatusNotFound,
+		replace("/api/files/data/site/replace", []byte(`{"staging": "/data/none"}`), "application/json").Code)
+}
+
+func TestMoveCaseCollision(t *testing.T) {
+	tmpDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "File.txt"), []byte("original"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "other.txt"), []byte("other"), 0600))
+
+	srv := New(&config.Config{
+		Main:        config.MainConfig{CaseCollisions: "always"},
+		Directories: []config.DirMapping{{Source: tmpDir, Virtual: "/test"}},
+	})
+
+	body := strings.NewReader(`{"destPath":"/test/file.txt"}`)
+	req := httptest.NewRequest("POST", "/api/files/test/other.txt/move", body)
+	rec := httptest.NewRecorder()
+	srv.Router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Contains(t, rec.Body.String(), "already exists with different case")
+}
+
+func TestDeleteBatch(t *testing.T) {
+	const secret = "test-secret-key-that-is-long-enough"
+	baseDir := t.TempDir()
+	for _, name := range []string{"alice/a.txt", "alice/b.txt", "alice/sub/c.txt", "bob/secret.txt"} {
+		physical := filepath.Join(baseDir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(physical), 0750))
+		require.NoError(t, os.WriteFile(physical, []byte(name), 0600))
+	}
+	srv := New(&config.Config{JWTSecret: secret, BaseDir: baseDir})
+	token := signedToken(t, secret, "alice", []auth.DirMapping{{Source: "alice", Virtual: "/files"}})
+
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/files/delete", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("mixed results", func(t *testing.T) {
+		rec := post(`{"paths":["/files/a.txt","/files/missing.txt","/files/sub/","/secret.txt",` +
+			`"/files/../../bob/secret.txt"]}`)
+		require.Equal(t, http.StatusMultiStatus, rec.Code)
+
+		var resp struct {
+			Deleted int                            `json:"deleted"`
+			Failed  int                            `json:"failed"`
+			Results []filesystem.BatchDeleteResult `json:"results"`
+		}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		assert.Equal(t, 2, resp.Deleted)
+		assert.Equal(t, 3, resp.Failed)
+		require.Len(t, resp.Results, 5)
+		assert.Equal(t, "deleted", resp.Results[0].Status)
+		assert.Empty(t, resp.Results[0].Error)
+		assert.Equal(t, "error", resp.Results[1].Status)
+		assert.Contains(t, resp.Results[1].Error, "not found")
+		assert.Equal(t, "deleted", resp.Results[2].Status)
+		for _, result := range resp.Results[3:] {
+			assert.Equal(t, "error", result.Status, result.Path)
+			assert.NotEmpty(t, result.Error, result.Path)
+		}
+
+		assert.NoFileExists(t, filepath.Join(baseDir, "alice", "a.txt"))
+		assert.NoDirExists(t, filepath.Join(baseDir, "alice", "sub"))
+		assert.FileExists(t, filepath.Join(baseDir, "alice", "b.txt"))
+		assert.FileExists(t, filepath.Join(baseDir, "bob", "secret.txt"))
+	})
+
+	t.Run("all deleted", func(t *testing.T) {
+		rec := post(`{"paths":["/files/b.txt"]}`)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"deleted":1`)
+		assert.NoFileExists(t, filepath.Join(baseDir, "alice", "b.txt"))
+	})
+
+	t.Run("missing paths", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, post(`{"paths":[]}`).Code)
+		assert.Equal(t, http.StatusBadRequest, post(`not json`).Code)
+	})
+}
+
+func TestTransferInto(t *testing.T) {
+	setup := func(t *testing.T) (*Server, string) {
+		tmpDir := t.TempDir()
+		require.NoError(t, os.MkdirAll(filepath.Join(tmpDir, "target"), 0750))
+		for _, name := range []string{"a.txt", "b.txt", "target/b.txt"} {
+			require.NoError(t, os.WriteFile(filepath.Join(tmpDir, filepath.FromSlash(name)), []byte(name), 0600))
+		}
+		return New(&config.Config{Directories: []config.DirMapping{{Source: tmpDir, Virtual: "/test"}}}), tmpDir
+	}
+	post := func(srv *Server, target, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, httptest.NewRequest("POST", target, strings.NewReader(body)))
+		return rec
+	}
+	type response struct {
+		Moved   int                          `json:"moved"`
+		Copied  int                          `json:"copied"`
+		Failed  int                          `json:"failed"`
+		Results []filesystem.BatchMoveResult `json:"results"`
+	}
+	const body = `{"sources":["/test/a.txt","/test/b.txt","/test/missing.txt"],"dest":"/test/target"}`
+
+	t.Run("move with collision and partial failure", func(t *testing.T) {
+		srv, tmpDir := setup(t)
+		rec := post(srv, "/api/files/move", body)
+		require.Equal(t, http.StatusMultiStatus, rec.Code)
+
+		var resp response
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		assert.Equal(t, 1, resp.Moved)
+		assert.Equal(t, 2, resp.Failed)
+		require.Len(t, resp.Results, 3)
+		assert.Empty(t, resp.Results[0].Error)
+		assert.Contains(t, resp.Results[1].Error, "already exists")
+		assert.Contains(t, resp.Results[2].Error, "not found")
+
+		assert.NoFileExists(t, filepath.Join(tmpDir, "a.txt"))
+		assert.FileExists(t, filepath.Join(tmpDir, "target", "a.txt"))
+		assert.FileExists(t, filepath.Join(tmpDir, "b.txt"))
+	})
+
+	t.Run("copy with collision and partial failure", func(t *testing.T) {
+		srv, tmpDir := setup(t)
+		rec := post(srv, "/api/files/copy", body)
+		require.Equal(t, http.StatusMultiStatus, rec.Code)
+
+		var resp response
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		assert.Equal(t, 1, resp.Copied)
+		assert.Equal(t, 2, resp.Failed)
+		assert.FileExists(t, filepath.Join(tmpDir, "a.txt"))
+		assert.FileExists(t, filepath.Join(tmpDir, "target", "a.txt"))
+		content, err := os.ReadFile(filepath.Join(tmpDir, "target", "b.txt"))
+		require.NoError(t, err)
+		assert.Equal(t, "target/b.txt", string(content))
+	})
+
+	t.Run("all transferred", func(t *testing.T) {
+		srv, _ := setup(t)
+		rec := post(srv, "/api/files/copy", `{"sources":["/test/a.txt","/test/b.txt"],"dest":"/test/new"}`)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"copied":2`)
+	})
+
+	t.Run("copy exceeding the quota", func(t *testing.T) {
+		srv, tmpDir := setup(t)
+		srv.Config.QuotaBytes = 20
+		rec := post(srv, "/api/files/copy", `{"sources":["/test/a.txt","/test/b.txt"],"dest":"/test/new"}`)
+		assert.Equal(t, http.StatusInsufficientStorage, rec.Code)
+		assert.NoDirExists(t, filepath.Join(tmpDir, "new"))
+	})
+
+	t.Run("invalid requests", func(t *testing.T) {
+		srv, _ := setup(t)
+		assert.Equal(t, http.StatusBadRequest, post(srv, "/api/files/move", `{"dest":"/test/target"}`).Code)
+		assert.Equal(t, http.StatusBadRequest, post(srv, "/api/files/copy", `{"sources":["/test/a.txt"]}`).Code)
+		assert.Equal(t, http.StatusConflict,
+			post(srv, "/api/files/move", `{"sources":["/test/a.txt"],"dest":"/test/b.txt"}`).Code)
+	})
+}
+
+func TestMoveCopyOverwrite(t *testing.T) {
+	setup := func(t *testing.T) (*Server, string) {
+		tmpDir := t.TempDir()
+		for _, name := range []string{"a.txt", "b.txt"} {
+			require.NoError(t, os.WriteFile(filepath.Join(tmpDir, name), []byte(name), 0600))
+		}
+		return New(&config.Config{Directories: []config.DirMapping{{Source: tmpDir, Virtual: "/test"}}}), tmpDir
+	}
+	post := func(srv *Server, target, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, httptest.NewRequest("POST", target, strings.NewReader(body)))
+		return rec
+	}
+	read := func(t *testing.T, p string) string {
+		content, err := os.ReadFile(p)
+		require.NoError(t, err)
+		return string(content)
+	}
+
+	for _, op := range []string{"move", "copy"} {
+		target := "/api/files/test/a.txt/" + op
+
+		t.Run(op+" fails by default", func(t *testing.T) {
+			srv, tmpDir := setup(t)
+			rec := post(srv, target, `{"destPath":"/test/b.txt"}`)
+			assert.Equal(t, http.StatusConflict, rec.Code)
+			assert.Equal(t, "b.txt", read(t, filepath.Join(tmpDir, "b.txt")))
+			assert.FileExists(t, filepath.Join(tmpDir, "a.txt"))
+
+			rec = post(srv, target, `{"destPath":"/test/b.txt","overwrite":"error"}`)
+			assert.Equal(t, http.StatusConflict, rec.Code)
+		})
+
+		t.Run(op+" replace", func(t *testing.T) {
+			srv, tmpDir := setup(t)
+			rec := post(srv, target, `{"destPath":"/test/b.txt","overwrite":"replace"}`)
+			require.Equal(t, http.StatusOK, rec.Code)
+			assert.Contains(t, rec.Body.String(), `"path":"/test/b.txt"`)
+			assert.Equal(t, "a.txt", read(t, filepath.Join(tmpDir, "b.txt")))
+		})
+
+		t.Run(op+" rename", func(t *testing.T) {
+			srv, tmpDir := setup(t)
+			rec := post(srv, target, `{"destPath":"/test/b.txt","overwrite":"rename"}`)
+			require.Equal(t, http.StatusOK, rec.Code)
+			assert.Contains(t, rec.Body.String(), `"path":"/test/b (1).txt"`)
+			assert.Equal(t, "a.txt", read(t, filepath.Join(tmpDir, "b (1).txt")))
+			assert.Equal(t, "b.txt", read(t, filepath.Join(tmpDir, "b.txt")))
+		})
+
+		t.Run(op+" invalid policy", func(t *testing.T) {
+			srv, tmpDir := setup(t)
+			rec := post(srv, target, `{"destPath":"/test/b.txt","overwrite":"merge"}`)
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.Equal(t, "b.txt", read(t, filepath.Join(tmpDir, "b.txt")))
+		})
+	}
+}
+
+func TestMoveBatch(t *testing.T) {
+	tmpDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(tmpDir, "target"), 0750))
+	for _, name := range []string{"a.txt", "b.txt", "target/b.txt"} {
+		require.NoError(t, os.WriteFile(filepath.Join(tmpDir, filepath.FromSlash(name)), []byte(name), 0600))
+	}
+	srv := New(&config.Config{Directories: []config.DirMapping{{Source: tmpDir, Virtual: "/test"}}})
+
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/move-batch", strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("atomic collision moves nothing", func(t *testing.T) {
+		rec := post(`{"sources":["/test/a.txt","/test/b.txt"],"dest":"/test/target","atomic":true}`)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		assert.FileExists(t, filepath.Join(tmpDir, "a.txt"))
+		assert.FileExists(t, filepath.Join(tmpDir, "b.txt"))
+		assert.NoFileExists(t, filepath.Join(tmpDir, "target", "a.txt"))
+	})
+
+	t.Run("missing fields", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, post(`{"dest":"/test/target"}`).Code)
+	})
+
+	t.Run("atomic move", func(t *testing.T) {
+		rec := post(`{"sources":["/test/a.txt"],"dest":"/test/target","atomic":true}`)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var resp struct {
+			Moved   int                          `json:"moved"`
+			Failed  int                          `json:"failed"`
+			Results []filesystem.BatchMoveResult `json:"results"`
+		}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		assert.Equal(t, 1, resp.Moved)
+		assert.Equal(t, 0, resp.Failed)
+		assert.Equal(t, "/test/target/a.txt", resp.Results[0].Dest)
+		assert.FileExists(t, filepath.Join(tmpDir, "target", "a.txt"))
+	})
+}
+
+func TestRecalculateQuota(t *testing.T) {
+	tmpDir := t.TempDir()
+	srv := New(&config.Config{
+		Main:        config.MainConfig{QuotaReconcileInterval: 3600},
+		Directories: []config.DirMapping{{Source: tmpDir, Virtual: "/data"}},
+		QuotaBytes:  1 << 20,
+	})
+
+	do := func(method, target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	require.Equal(t, http.StatusOK, do("GET", "/api/quota").Code)
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "outside.bin"), make([]byte, 300), 0600))
+
+	rec := do("POST", "/api/admin/quota/recalc")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var resp struct {
+		Quota filesystem.QuotaInfo `json:"quota"`
+		Drift int64                `json:"drift"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, int64(300), resp.Quota.Used)
+	assert.Equal(t, int64(300), resp.Drift)
+}
+
+func TestListFilesCanonicalPaths(t *testing.T) {
+	tmpDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(tmpDir, "sub", "deep"), 0750))
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "sub", "a.txt"), []byte("a"), 0600))
+
+	srv := New(&config.Config{
+		Listing:     config.ListingConfig{CacheTTL: 60},
+		Directories: []config.DirMapping{{Source: tmpDir, Virtual: "/docs"}},
+	})
+	list := func(query string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, httptest.NewRequest("GET", "/api/files?path="+query, nil))
+		return rec
+	}
+	paths := func(query string) []string {
+		rec := list(query)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var files []filesystem.FileInfo
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &files))
+		result := make([]string, 0, len(files))
+		for _, file := range files {
+			result = append(result, file.Path)
+		}
+		return result
+	}
+
+	queries := []string{"/docs/sub", "/docs/sub/", "/docs//sub", "docs/sub", "/docs/./sub/", "/docs/sub/deep/.."}
+	for _, query := range queries {
+		t.Run(query, func(t *testing.T) {
+			assert.ElementsMatch(t, []string{"/docs/sub/a.txt", "/docs/sub/deep"}, paths(query))
+			assert.ElementsMatch(t, []string{"/docs/sub/a.txt", "/docs/sub/deep"}, paths(query+"&recursive=true"))
+		})
+	}
+
+	t.Run("errors report the canonical path", func(t *testing.T) {
+		rec := list("/docs//missing/")
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Contains(t, rec.Body.String(), "directory not found: /docs/missing\n")
+	})
+
+	t.Run("messy paths share the cached listing", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, uploadRequest(t, "/docs/sub", "b.txt", "b"))
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Contains(t, paths("/docs//sub/"), "/docs/sub/b.txt")
+	})
+}
+
+func TestListFilesBreadcrumb(t *testing.T) {
+	tmpDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(tmpDir, "sub", "deep"), 0750))
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "sub", "deep", "a.txt"), []byte("a"), 0600))
+
+	srv := New(&config.Config{
+		Directories: []config.DirMapping{{Source: tmpDir, Virtual: "/docs"}},
+	})
+	list := func(query string) breadcrumbListing {
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, httptest.NewRequest("GET", "/api/files?breadcrumb=true&path="+query, nil))
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var listing breadcrumbListing
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &listing))
+		return listing
+	}
+
+	t.Run("nested path", func(t *testing.T) {
+		listing := list("/docs/sub/deep/")
+		assert.Equal(t, "/docs/sub/deep", listing.Path.Current)
+		assert.Equal(t, "/docs/sub", listing.Path.Parent)
+		assert.Equal(t, []breadcrumbSegment{
+			{Name: "/", Path: "/"},
+			{Name: "docs", Path: "/docs"},
+			{Name: "sub", Path: "/docs/sub"},
+			{Name: "deep", Path: "/docs/sub/deep"},
+		}, listing.Path.Breadcrumb)
+		require.Len(t, listing.Files, 1)
+		assert.Equal(t, "/docs/sub/deep/a.txt", listing.Files[0].Path)
+	})
+
+	t.Run("virtual root", func(t *testing.T) {
+		listing := list("/")
+		assert.Equal(t, "/", listing.Path.Current)
+		assert.Empty(t, listing.Path.Parent)
+		assert.Equal(t, []breadcrumbSegment{{Name: "/", Path: "/"}}, listing.Path.Breadcrumb)
+		require.Len(t, listing.Files, 1)
+		assert.Equal(t, "/docs", listing.Files[0].Path)
+	})
+
+	t.Run("plain array without the parameter", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, httptest.NewRequest("GET", "/api/files?path=/docs", nil))
+		assert.True(t, strings.HasPrefix(rec.Body.String(), "["))
+	})
+}
+
+func TestFileDirectoryCollisions(t *testing.T) {
+	tmpDir := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(tmpDir, "reports"), 0750))
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "notes.txt"), []byte("notes"), 0600))
+
+	srv := New(&config.Config{
+		Directories: []config.DirMapping{{Source: tmpDir, Virtual: "/data"}},
+	})
+	serve := func(req *http.Request) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := serve(uploadRequest(t, "/data", "reports", "data"))
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Equal(t, "a directory already exists with that name: /data/reports\n", rec.Body.String())
+
+	rec = serve(httptest.NewRequest("PUT", "/api/raw/data/notes.txt/file.txt", strings.NewReader("data")))
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Equal(t, "a file already exists with that name: /data/notes.txt\n", rec.Body.String())
+
+	for _, body := range []string{`{"path": "/data/notes.txt"}`, `{"path": "/data/notes.txt", "existOk": true}`} {
+		rec = serve(httptest.NewRequest("POST", "/api/mkdir", strings.NewReader(body)))
+		assert.Equal(t, http.StatusConflict, rec.Code, body)
+		assert.Equal(t, "a file already exists with that name: /data/notes.txt\n", rec.Body.String(), body)
+	}
+}
+
+func TestDirectoryEntryLimitStatus(t *testing.T) {
+	tmpDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "existing.txt"), []byte("data"), 0600))
+
+	cfg := &config.Config{
+		Directories: []config.DirMapping{{Source: tmpDir, Virtual: "/data"}},
+	}
+	cfg.Main.MaxFilesPerDirectory = 1
+	srv := New(cfg)
+	serve := func(req *http.Request) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	copyBody := strings.NewReader(`{"destPath": "/data/copy.txt"}`)
+	requests := []*http.Request{
+		uploadRequest(t, "/data", "new.txt", "data"),
+		httptest.NewRequest("PUT", "/api/raw/data/new.txt", strings.NewReader("data")),
+		httptest.NewRequest("POST", "/api/files/data/existing.txt/copy", copyBody),
+		httptest.NewRequest("POST", "/api/mkdir", strings.NewReader(`{"path": "/data/new"}`)),
+	}
+	for _, req := range requests {
+		rec := serve(req)
+		assert.Equal(t, http.StatusInsufficientStorage, rec.Code, req.URL.Path)
+		assert.Contains(t, rec.Body.String(), "too many entries in directory", req.URL.Path)
+	}
+}
+
+func TestRenameEndpoint(t *testing.T) {
+	tmpDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "a.txt"), []byte("a"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "b.txt"), []byte("b"), 0600))
+
+	srv := New(&config.Config{
+		Directories: []config.DirMapping{{Source: tmpDir, Virtual: "/data"}},
+	})
+	rename := func(path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, httptest.NewRequest("POST", "/api/files/"+path+"/rename", strings.NewReader(body)))
+		return rec
+	}
+
+	rec := rename("data/a.txt", `{"newName": "c.txt"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.JSONEq(t, `{"status": "renamed", "path": "/data/c.txt"}`, rec.Body.String())
+	assert.FileExists(t, filepath.Join(tmpDir, "c.txt"))
+
+	rec = rename("data/b.txt", `{"newName": "c.txt"}`)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	for _, body := range []string{`{"newName": ""}`, `{"newName": "../x"}`, `{"newName": ".."}`, `{`} {
+		rec = rename("data/b.txt", body)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, body)
+	}
+
+	rec = rename("data/missing.txt", `{"newName": "x.txt"}`)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = rename("data", `{"newName": "x"}`)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+}
+
+func TestQuotaDetail(t *testing.T) {
+	tenantA := t.TempDir()
+	tenantB := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(tenantA, "a.txt"), []byte("12345"), 0600))
+
+	srv := New(&config.Config{
+		Directories: []config.DirMapping{
+			{Source: tenantA, Virtual: "/a", QuotaBytes: 1024},
+			{Source: tenantB, Virtual: "/b"},
+		},
+	})
+	quota := func(query string) filesystem.QuotaInfo {
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, httptest.NewRequest("GET", "/api/quota"+query, nil))
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var info filesystem.QuotaInfo
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &info))
+		return info
+	}
+
+	assert.Nil(t, quota("").PerDirectory)
+
+	info := quota("?detail=true")
+	assert.Equal(t, int64(5), info.Used)
+	require.Len(t, info.PerDirectory, 2)
+	assert.Equal(t, int64(5), info.PerDirectory["/a"].Used)
+	assert.Equal(t, int64(1024), info.PerDirectory["/a"].Limit)
+	assert.Equal(t, int64(0), info.PerDirectory["/b"].Used)
+	assert.Equal(t, "unlimited", info.PerDirectory["/b"].LimitHuman)
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+)
+
+// defaultShutdownTimeout is used when main.shutdown_timeout is not configured
+const defaultShutdownTimeout = 30 * time.Second
+
+// shutdownTimeout returns how long requests in flight may take to finish
+// once the server shuts down
+func (s *Server) shutdownTimeout() time.Duration {
+	if s.Config.Main.ShutdownTimeout > 0 {
+		return time.Duration(s.Config.Main.ShutdownTimeout) * time.Second
+	}
+	return defaultShutdownTimeout
+}
+
+// ListenAndServe runs httpServer until ctx is done and then shuts it down
+// gracefully: no new connections are accepted, and requests in flight get
+// the shutdown timeout to finish before their connections are closed.
+// Unfinished resumable uploads are discarded afterwards, as their sessions
+// only live in memory. HTTPS is served when a certificate is configured,
+// together with the HTTP redirect listener if enabled. It returns
+// http.ErrServerClosed after a shutdown.
+func (s *Server) ListenAndServe(ctx context.Context, httpServer *http.Server) error {
+	errc := make(chan error, 1)
+	go func() {
+		if s.tlsEnabled() {
+			errc <- httpServer.ListenAndServeTLS(s.Config.Main.TLSCert, s.Config.Main.TLSKey)
+			return
+		}
+		errc <- httpServer.ListenAndServe()
+	}()
+
+	redirect := s.redirectServer()
+	redirectErrc := make(chan error, 1)
+	if redirect != nil {
+		go func() {
+			redirectErrc <- redirect.ListenAndServe()
+		}()
+	}
+
+	var err error
+	select {
+	case err = <-errc:
+	case err = <-redirectErrc:
+	case <-ctx.Done():
+		log.Printf("Shutting down, draining active connections for up to %s", s.shutdownTimeout())
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout())
+	defer cancel()
+	for _, srv := range []*http.Server{httpServer, redirect} {
+		if srv == nil {
+			continue
+		}
+		if serr := srv.Shutdown(shutdownCtx); serr != nil {
+			log.Printf("Graceful shutdown incomplete, closing remaining connections: %v", serr)
+			_ = srv.Close()
+		}
+	}
+
+	for _, id := range s.uploads.Clear() {
+		log.Printf("Upload session %s discarded on shutdown", id)
+	}
+
+	if err != nil {
+		// A listener failed to start
+		return err
+	}
+	err = <-errc
+	if errors.Is(err, http.ErrServerClosed) {
+		log.Printf("Server stopped")
+	}
+	return err
+}
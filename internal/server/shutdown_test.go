@@ -0,0 +1,120 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"dendrite/internal/config"
+)
+
+// freeAddr returns a loopback address with a port that was free a moment ago
+func freeAddr(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := ln.Addr().String()
+	require.NoError(t, ln.Close())
+	return addr
+}
+
+func TestGracefulShutdown(t *testing.T) {
+	tmpDir := t.TempDir()
+	srv := New(&config.Config{Directories: []config.DirMapping{{Source: tmpDir, Virtual: "/data"}}})
+
+	// An unfinished resumable upload leaves a partial file behind
+	rec := httptest.NewRecorder()
+	srv.Router.ServeHTTP(rec, httptest.NewRequest("POST", "/api/uploads",
+		strings.NewReader(`{"path": "/data/video.mp4", "size": 12}`)))
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, _ *http.Request) {
+		close(started)
+		<-release
+		_, _ = io.WriteString(w, "done")
+	})
+	mux.Handle("/", srv.Router)
+
+	addr := freeAddr(t)
+	httpServer := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: time.Second}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errc := make(chan error, 1)
+	go func() {
+		errc <- srv.ListenAndServe(ctx, httpServer)
+	}()
+
+	var resp *http.Response
+	require.Eventually(t, func() bool {
+		var err error
+		resp, err = http.Get("http://" + addr + "/healthz")
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+	var health map[string]string
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&health))
+	require.NoError(t, resp.Body.Close())
+	assert.Equal(t, "ok", health["status"])
+
+	// A request in flight when the shutdown starts is completed
+	slow := make(chan string, 1)
+	go func() {
+		resp, err := http.Get("http://" + addr + "/slow")
+		if err != nil {
+			slow <- err.Error()
+			return
+		}
+		body, _ := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		slow <- string(body)
+	}()
+	<-started
+	cancel()
+
+	select {
+	case err := <-errc:
+		t.Fatalf("server stopped with a request in flight: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	close(release)
+	assert.Equal(t, "done", <-slow)
+
+	select {
+	case err := <-errc:
+		assert.ErrorIs(t, err, http.ErrServerClosed)
+	case <-time.After(5 * time.Second):
+		t.Fatal("server did not shut down")
+	}
+
+	entries, err := os.ReadDir(tmpDir)
+	require.NoError(t, err)
+	assert.Empty(t, entries, "partial upload should be removed")
+
+	_, err = http.Get("http://" + addr + "/healthz")
+	assert.Error(t, err)
+}
+
+func TestListenAndServeError(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() {
+		_ = ln.Close()
+	}()
+
+	srv := New(&config.Config{Directories: []config.DirMapping{{Source: t.TempDir(), Virtual: "/data"}}})
+	httpServer := &http.Server{Addr: ln.Addr().String(), Handler: srv.Router, ReadHeaderTimeout: time.Second}
+	err = srv.ListenAndServe(context.Background(), httpServer)
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, http.ErrServerClosed)
+}
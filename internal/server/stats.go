@@ -0,0 +1,93 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"dendrite/internal/filesystem"
+)
+
+// Operation names used for the statistics counters
+const (
+	opList     = "list"
+	opUpload   = "upload"
+	opDownload = "download"
+	opZip      = "zip"
+	opTar      = "tar"
+	opTarGz    = "targz"
+	opDelete   = "delete"
+	opMove     = "move"
+	opCopy     = "copy"
+	opMkdir    = "mkdir"
+	opSave     = "save"
+)
+
+// Stats is the JSON representation of the server statistics
+type Stats struct {
+	UploadedBytes   int64            `json:"uploadedBytes"`
+	DownloadedBytes int64            `json:"downloadedBytes"`
+	Operations      map[string]int64 `json:"operations"`
+	QuotaDenials    int64            `json:"quotaDenials"`
+	StartedAt       time.Time        `json:"startedAt"`
+	UptimeSeconds   int64            `json:"uptimeSeconds"`
+}
+
+// serverStats aggregates transfer volumes and operation counts in memory.
+// Counters are reset when the server restarts.
+type serverStats struct {
+	mu         sync.Mutex
+	startedAt  time.Time
+	uploaded   int64
+	downloaded int64
+	operations map[string]int64
+
+	// now is replaceable for tests
+	now func() time.Time
+}
+
+func newServerStats() *serverStats {
+	return &serverStats{
+		startedAt:  time.Now(),
+		operations: make(map[string]int64),
+		now:        time.Now,
+	}
+}
+
+// record counts a successful operation together with the bytes it moved
+func (st *serverStats) record(op string, uploaded, downloaded int64) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	st.operations[op]++
+	st.uploaded += uploaded
+	st.downloaded += downloaded
+}
+
+// snapshot returns a copy of the current counters
+func (st *serverStats) snapshot() Stats {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	ops := make(map[string]int64, len(st.operations))
+	for op, count := range st.operations {
+		ops[op] = count
+	}
+
+	return Stats{
+		UploadedBytes:   st.uploaded,
+		DownloadedBytes: st.downloaded,
+		Operations:      ops,
+		QuotaDenials:    filesystem.QuotaDenials(),
+		StartedAt:       st.startedAt,
+		UptimeSeconds:   int64(st.now().Sub(st.startedAt).Seconds()),
+	}
+}
+
+func (s *Server) getStats(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(s.stats.snapshot()); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
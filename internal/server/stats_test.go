@@ -0,0 +1,72 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"dendrite/internal/config"
+)
+
+func TestStats(t *testing.T) {
+	tmpDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "existing.txt"), []byte("0123456789"), 0600))
+
+	srv := New(&config.Config{
+		Directories: []config.DirMapping{
+			{Source: tmpDir, Virtual: "/data"},
+		},
+	})
+	srv.stats.now = func() time.Time { return srv.stats.startedAt.Add(90 * time.Second) }
+
+	serve := func(req *http.Request) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	require.Equal(t, http.StatusOK, serve(uploadRequest(t, "/data", "a.txt", "hello")).Code)
+	require.Equal(t, http.StatusCreated, serve(httptest.NewRequest("PUT", "/api/raw/data/b.txt",
+		strings.NewReader("world!"))).Code)
+	require.Equal(t, http.StatusOK, serve(httptest.NewRequest("GET", "/api/files/data/existing.txt", nil)).Code)
+	require.Equal(t, http.StatusOK, serve(httptest.NewRequest("GET", "/api/files?path=/data", nil)).Code)
+	require.Equal(t, http.StatusOK, serve(httptest.NewRequest("POST", "/api/mkdir",
+		strings.NewReader(`{"path": "/data/dir"}`))).Code)
+	require.Equal(t, http.StatusOK, serve(httptest.NewRequest("DELETE", "/api/files/data/a.txt", nil)).Code)
+	for _, archive := range []string{"zip", "tar", "targz", "targz"} {
+		require.Equal(t, http.StatusOK, serve(httptest.NewRequest("POST", "/api/download/"+archive,
+			strings.NewReader(`{"paths": ["/data/existing.txt"]}`))).Code, archive)
+	}
+
+	// Failed operations are not counted
+	require.Equal(t, http.StatusNotFound, serve(httptest.NewRequest("GET", "/api/files/data/missing.txt", nil)).Code)
+	require.Equal(t, http.StatusConflict, serve(httptest.NewRequest("POST", "/api/mkdir",
+		strings.NewReader(`{"path": "/data/dir"}`))).Code)
+
+	rec := serve(httptest.NewRequest("GET", "/api/stats", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var stats Stats
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &stats))
+	assert.Equal(t, int64(11), stats.UploadedBytes)
+	assert.Greater(t, stats.DownloadedBytes, int64(10), "archives count as downloads")
+	assert.Equal(t, map[string]int64{
+		opUpload:   2,
+		opDownload: 1,
+		opZip:      1,
+		opTar:      1,
+		opTarGz:    2,
+		opList:     1,
+		opMkdir:    1,
+		opDelete:   1,
+	}, stats.Operations)
+	assert.Equal(t, int64(90), stats.UptimeSeconds)
+}
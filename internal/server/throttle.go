@@ -0,0 +1,100 @@
+package server
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"dendrite/internal/auth"
+	"dendrite/internal/config"
+)
+
+// throttleSlices is the number of chunks written per second of transfer, so
+// that a throttled download progresses smoothly instead of in bursts
+const throttleSlices = 10
+
+// throttleWriteTimeout bounds the write of a single chunk. The server's
+// WriteTimeout covers the whole response, which a throttled download of more
+// than a few megabytes outlasts, so it is replaced chunk by chunk.
+const throttleWriteTimeout = 30 * time.Second
+
+// throttledWriter limits the rate at which a response body is written
+type throttledWriter struct {
+	http.ResponseWriter
+	ctx     context.Context
+	rc      *http.ResponseController
+	rate    int64
+	start   time.Time
+	written int64
+}
+
+// Write sends p in chunks and sleeps whenever the transfer is ahead of the
+// configured rate
+func (t *throttledWriter) Write(p []byte) (int, error) {
+	if t.start.IsZero() {
+		t.start = time.Now()
+	}
+
+	chunk := max(t.rate/throttleSlices, 1)
+	total := 0
+	for len(p) > 0 {
+		n := int(min(int64(len(p)), chunk))
+		// Writers without deadlines, such as test recorders, have no timeout to extend
+		_ = t.rc.SetWriteDeadline(time.Now().Add(throttleWriteTimeout))
+		written, err := t.ResponseWriter.Write(p[:n])
+		total += written
+		t.written += int64(written)
+		if err != nil {
+			return total, err
+		}
+		p = p[n:]
+
+		due := t.start.Add(time.Duration(float64(t.written) / float64(t.rate) * float64(time.Second)))
+		if wait := time.Until(due); wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-timer.C:
+			case <-t.ctx.Done():
+				timer.Stop()
+				return total, t.ctx.Err()
+			}
+		}
+	}
+	return total, nil
+}
+
+// Unwrap returns the underlying writer for http.ResponseController
+func (t *throttledWriter) Unwrap() http.ResponseWriter {
+	return t.ResponseWriter
+}
+
+// Flush sends buffered data to the client if the writer supports it
+func (t *throttledWriter) Flush() {
+	if f, ok := t.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// downloadRate returns the download rate limit for a request in bytes per
+// second, or 0 when downloads are unlimited. A rate in the JWT overrides the
+// configured limit.
+func (s *Server) downloadRate(r *http.Request) int64 {
+	if claims, ok := auth.GetClaimsFromContext(r.Context()); ok && claims.DownloadRate != "" {
+		rate, err := config.ParseRate(claims.DownloadRate)
+		if err == nil {
+			return rate
+		}
+		log.Printf("Ignoring invalid downloadRate in token: %v", err)
+	}
+	return s.Config.Download.RateLimitBytes
+}
+
+// throttle wraps w in a rate-limited writer when a download rate applies
+func (s *Server) throttle(w http.ResponseWriter, r *http.Request) http.ResponseWriter {
+	rate := s.downloadRate(r)
+	if rate <= 0 {
+		return w
+	}
+	return &throttledWriter{ResponseWriter: w, ctx: r.Context(), rc: http.NewResponseController(w), rate: rate}
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"dendrite/internal/auth"
+	"dendrite/internal/config"
+)
+
+func TestDownloadThrottling(t *testing.T) {
+	tmpDir := t.TempDir()
+	content := bytes.Repeat([]byte("x"), 2048)
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "file.bin"), content, 0600))
+
+	srv := New(&config.Config{
+		Download:    config.DownloadConfig{RateLimitBytes: 4096},
+		Directories: []config.DirMapping{{Source: tmpDir, Virtual: "/data"}},
+	})
+
+	start := time.Now()
+	rec := httptest.NewRecorder()
+	srv.Router.ServeHTTP(rec, httptest.NewRequest("GET", "/api/files/data/file.bin", nil))
+	elapsed := time.Since(start)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, content, rec.Body.Bytes())
+	// 2 KB at 4 KB/s take half a second
+	assert.GreaterOrEqual(t, elapsed, 450*time.Millisecond)
+}
+
+func TestDownloadThrottlingOutlastsWriteTimeout(t *testing.T) {
+	tmpDir := t.TempDir()
+	content := bytes.Repeat([]byte("x"), 8192)
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "file.bin"), content, 0600))
+
+	srv := New(&config.Config{
+		Download:    config.DownloadConfig{RateLimitBytes: 4096},
+		Directories: []config.DirMapping{{Source: tmpDir, Virtual: "/data"}},
+	})
+	// 8 KB at 4 KB/s take two seconds, twice the server's write timeout
+	ts := httptest.NewUnstartedServer(srv.Router)
+	ts.Config.WriteTimeout = time.Second
+	ts.Start()
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/api/files/data/file.bin")
+	require.NoError(t, err)
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, content, body)
+}
+
+func TestDownloadRateFromToken(t *testing.T) {
+	baseDir := t.TempDir()
+
+	cfg := &config.Config{
+		JWTSecret: "test-secret-that-is-at-least-32-characters-long",
+		BaseDir:   baseDir,
+		Download:  config.DownloadConfig{RateLimitBytes: 1024 * 1024},
+	}
+	srv := New(cfg)
+
+	claims := &auth.Claims{Directories: []auth.DirMapping{{Source: "shared", Virtual: "/shared"}}}
+	req := httptest.NewRequest("GET", "/", nil)
+	assert.Equal(t, int64(1024*1024), srv.downloadRate(req), "server default without claims")
+
+	claims.DownloadRate = "2KB"
+	req = req.WithContext(context.WithValue(req.Context(), auth.ClaimsContextKey, claims))
+	assert.Equal(t, int64(2048), srv.downloadRate(req), "token overrides the default")
+
+	claims.DownloadRate = "fast"
+	assert.Equal(t, int64(1024*1024), srv.downloadRate(req), "invalid rates are ignored")
+}
@@ -0,0 +1,50 @@
+package server
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// defaultTLSRedirectListen is used when main.tls_redirect_listen is not
+// configured
+const defaultTLSRedirectListen = ":80"
+
+// tlsEnabled reports whether the server answers HTTPS
+func (s *Server) tlsEnabled() bool {
+	return s.Config.Main.TLSCert != "" && s.Config.Main.TLSKey != ""
+}
+
+// redirectServer returns the plain HTTP server that redirects to HTTPS, or
+// nil when no redirect is configured
+func (s *Server) redirectServer() *http.Server {
+	if !s.tlsEnabled() || !s.Config.Main.TLSAutoRedirect {
+		return nil
+	}
+	addr := s.Config.Main.TLSRedirectListen
+	if addr == "" {
+		addr = defaultTLSRedirectListen
+	}
+	return &http.Server{
+		Addr:              addr,
+		Handler:           http.HandlerFunc(s.redirectToHTTPS),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+}
+
+// redirectToHTTPS answers a plain HTTP request with a permanent redirect to
+// the same URL on the HTTPS listener
+func (s *Server) redirectToHTTPS(w http.ResponseWriter, r *http.Request) {
+	host, _, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		host = strings.Trim(r.Host, "[]")
+	}
+	if _, port, err := net.SplitHostPort(s.Config.Listen); err == nil && port != "443" && port != "" {
+		host = net.JoinHostPort(host, port)
+	} else if strings.Contains(host, ":") {
+		// An IPv6 address keeps its brackets
+		host = "[" + host + "]"
+	}
+	http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+}
@@ -0,0 +1,137 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"dendrite/internal/config"
+)
+
+// writeTestCertificate writes a self-signed certificate and its key to dir
+func writeTestCertificate(t *testing.T, dir string) (certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	require.NoError(t, os.WriteFile(certFile, certPEM, 0600))
+	require.NoError(t, os.WriteFile(keyFile, keyPEM, 0600))
+	return certFile, keyFile
+}
+
+func TestRedirectToHTTPS(t *testing.T) {
+	tests := []struct {
+		listen string
+		host   string
+		want   string
+	}{
+		{listen: ":443", host: "example.com", want: "https://example.com/api/files?path=%2Fdata"},
+		{listen: ":443", host: "example.com:80", want: "https://example.com/api/files?path=%2Fdata"},
+		{listen: "0.0.0.0:8443", host: "example.com", want: "https://example.com:8443/api/files?path=%2Fdata"},
+		{listen: ":443", host: "[::1]:80", want: "https://[::1]/api/files?path=%2Fdata"},
+		{listen: ":8443", host: "[::1]", want: "https://[::1]:8443/api/files?path=%2Fdata"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.listen+" "+tt.host, func(t *testing.T) {
+			srv := &Server{Config: &config.Config{Listen: tt.listen}}
+			req := httptest.NewRequest("GET", "/api/files?path=%2Fdata", nil)
+			req.Host = tt.host
+			rec := httptest.NewRecorder()
+			srv.redirectToHTTPS(rec, req)
+
+			assert.Equal(t, http.StatusMovedPermanently, rec.Code)
+			assert.Equal(t, tt.want, rec.Header().Get("Location"))
+		})
+	}
+}
+
+func TestListenAndServeTLS(t *testing.T) {
+	tmpDir := t.TempDir()
+	certFile, keyFile := writeTestCertificate(t, t.TempDir())
+
+	addr := freeAddr(t)
+	redirectAddr := freeAddr(t)
+	cfg := &config.Config{
+		Listen:      addr,
+		Directories: []config.DirMapping{{Source: tmpDir, Virtual: "/data"}},
+	}
+	cfg.Main.TLSCert = certFile
+	cfg.Main.TLSKey = keyFile
+	cfg.Main.TLSAutoRedirect = true
+	cfg.Main.TLSRedirectListen = redirectAddr
+	srv := New(cfg)
+
+	httpServer := &http.Server{Addr: addr, Handler: srv.Router, ReadHeaderTimeout: time.Second}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errc := make(chan error, 1)
+	go func() {
+		errc <- srv.ListenAndServe(ctx, httpServer)
+	}()
+
+	pemData, err := os.ReadFile(certFile)
+	require.NoError(t, err)
+	roots := x509.NewCertPool()
+	require.True(t, roots.AppendCertsFromPEM(pemData))
+	client := &http.Client{
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, MinVersion: tls.VersionTLS12}},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	require.Eventually(t, func() bool {
+		resp, err := client.Get("https://" + addr + "/healthz")
+		if err != nil {
+			return false
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode == http.StatusOK
+	}, 5*time.Second, 10*time.Millisecond)
+
+	resp, err := client.Get("http://" + redirectAddr + "/api/files")
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusMovedPermanently, resp.StatusCode)
+	assert.Equal(t, "https://"+addr+"/api/files", resp.Header.Get("Location"))
+
+	cancel()
+	select {
+	case err := <-errc:
+		assert.ErrorIs(t, err, http.ErrServerClosed)
+	case <-time.After(5 * time.Second):
+		t.Fatal("server did not shut down")
+	}
+
+	_, err = client.Get("http://" + redirectAddr + "/")
+	assert.Error(t, err)
+}
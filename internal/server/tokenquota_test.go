@@ -0,0 +1,92 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"dendrite/internal/auth"
+	"dendrite/internal/config"
+	"dendrite/internal/filesystem"
+)
+
+// quotaToken creates a JWT for the shared directory with the given quota claim
+func quotaToken(t *testing.T, secret, quota string) string {
+	t.Helper()
+
+	claims := &auth.Claims{
+		Directories: []auth.DirMapping{{Source: "shared", Virtual: "/shared"}},
+		Quota:       quota,
+		Expires:     time.Now().Add(time.Hour).Format(time.RFC3339),
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	require.NoError(t, err)
+	return token
+}
+
+func TestTokenQuota(t *testing.T) {
+	baseDir := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(baseDir, "shared"), 0750))
+
+	secret := "test-secret-that-is-at-least-32-characters-long"
+	srv := New(&config.Config{JWTSecret: secret, BaseDir: baseDir})
+	serve := func(req *http.Request, token string) *httptest.ResponseRecorder {
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+		return rec
+	}
+	chunk := bytes.Repeat([]byte("x"), 600*1024)
+	upload := func(name, token string) *httptest.ResponseRecorder {
+		return serve(httptest.NewRequest("PUT", "/api/raw/shared/"+name, bytes.NewReader(chunk)), token)
+	}
+
+	limited := quotaToken(t, secret, "1MB")
+
+	rec := upload("first.bin", limited)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	t.Run("uploads past the token quota are rejected", func(t *testing.T) {
+		rec := upload("second.bin", limited)
+		assert.Equal(t, http.StatusInsufficientStorage, rec.Code)
+		assert.Contains(t, rec.Body.String(), "quota exceeded")
+		assert.NoFileExists(t, filepath.Join(baseDir, "shared", "second.bin"))
+
+		rec = serve(uploadRequest(t, "/shared", "second.bin", string(chunk)), limited)
+		assert.Equal(t, http.StatusInsufficientStorage, rec.Code)
+
+		body := strings.NewReader(`{"destPath": "/shared/copy.bin"}`)
+		rec = serve(httptest.NewRequest("POST", "/api/files/shared/first.bin/copy", body), limited)
+		assert.Equal(t, http.StatusInsufficientStorage, rec.Code)
+	})
+
+	t.Run("the quota endpoint reports the token quota", func(t *testing.T) {
+		rec := serve(httptest.NewRequest("GET", "/api/quota", nil), limited)
+		require.Equal(t, http.StatusOK, rec.Code)
+		var info filesystem.QuotaInfo
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &info))
+		assert.Equal(t, int64(1024*1024), info.Limit)
+		assert.Equal(t, int64(len(chunk)), info.Used)
+	})
+
+	t.Run("tokens without quota use the server quota", func(t *testing.T) {
+		rec := upload("second.bin", quotaToken(t, secret, ""))
+		assert.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	})
+
+	t.Run("invalid quota claims are rejected", func(t *testing.T) {
+		rec := serve(httptest.NewRequest("GET", "/api/quota", nil), quotaToken(t, secret, "lots"))
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), "invalid quota in token: lots")
+	})
+}
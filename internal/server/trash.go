@@ -0,0 +1,114 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"dendrite/internal/filesystem"
+)
+
+// writeTrashError maps errors of the trash operations to status codes. A
+// disabled trash is answered like an unknown route.
+func writeTrashError(w http.ResponseWriter, err error) {
+	switch {
+	case strings.Contains(err.Error(), "trash is disabled"), strings.Contains(err.Error(), "not found"):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case strings.Contains(err.Error(), "access denied"):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case strings.Contains(err.Error(), "already exists"):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// listTrash lists the deleted items of the mappings available to the
+// request, most recently deleted first
+func (s *Server) listTrash(w http.ResponseWriter, r *http.Request) {
+	fs, err := s.getFilesystemForRequest(r)
+	if err != nil {
+		handleFilesystemError(w, err)
+		return
+	}
+
+	items, err := fs.ListTrash()
+	if err != nil {
+		writeTrashError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(items); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+	}
+}
+
+// restoreTrash moves a deleted item back to its original path. An item that
+// has been created there since is handled by the overwrite policy of the
+// request like for moves, "error" (409) unless given.
+func (s *Server) restoreTrash(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ID        string `json:"id"`
+		Overwrite string `json:"overwrite"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.ID == "" {
+		http.Error(w, "id is required", http.StatusBadRequest)
+		return
+	}
+	policy, err := filesystem.ParseOverwritePolicy(req.Overwrite)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	fs, err := s.getFilesystemForRequest(r)
+	if err != nil {
+		handleFilesystemError(w, err)
+		return
+	}
+
+	restoredPath, err := fs.RestoreTrash(req.ID, filesystem.TransferOptions{Overwrite: policy})
+	if err != nil {
+		writeTrashError(w, err)
+		return
+	}
+	s.invalidateListings(fs, restoredPath)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]string{"status": "restored", "path": restoredPath}); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+	}
+}
+
+// emptyTrash permanently removes the deleted items of the mappings available
+// to the request, or only the item given with the id parameter
+func (s *Server) emptyTrash(w http.ResponseWriter, r *http.Request) {
+	fs, err := s.getFilesystemForRequest(r)
+	if err != nil {
+		handleFilesystemError(w, err)
+		return
+	}
+
+	removed := 1
+	if id := r.URL.Query().Get("id"); id != "" {
+		err = fs.PurgeTrash(id)
+	} else {
+		removed, err = fs.EmptyTrash()
+	}
+	if err != nil {
+		writeTrashError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]int{"removed": removed}); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+	}
+}
@@ -0,0 +1,108 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"dendrite/internal/config"
+	"dendrite/internal/filesystem"
+)
+
+func TestTrash(t *testing.T) {
+	setup := func(t *testing.T, trashDir string) (*Server, string) {
+		tmpDir := t.TempDir()
+		for _, name := range []string{"a.txt", "b.txt"} {
+			require.NoError(t, os.WriteFile(filepath.Join(tmpDir, name), []byte(name), 0600))
+		}
+		cfg := &config.Config{Directories: []config.DirMapping{{Source: tmpDir, Virtual: "/test"}}}
+		cfg.Main.TrashDir = trashDir
+		return New(cfg), tmpDir
+	}
+	serve := func(srv *Server, method, target, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return rec
+	}
+	list := func(t *testing.T, srv *Server) []filesystem.TrashItem {
+		rec := serve(srv, "GET", "/api/trash", "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		var items []filesystem.TrashItem
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &items))
+		return items
+	}
+
+	t.Run("delete, list and restore", func(t *testing.T) {
+		srv, tmpDir := setup(t, ".trash")
+		assert.Empty(t, list(t, srv))
+		require.Equal(t, http.StatusOK, serve(srv, "DELETE", "/api/files/test/a.txt", "").Code)
+		assert.NoFileExists(t, filepath.Join(tmpDir, "a.txt"))
+
+		rec := serve(srv, "GET", "/api/files?path=/test", "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.NotContains(t, rec.Body.String(), ".trash")
+		assert.Equal(t, http.StatusForbidden, serve(srv, "GET", "/api/files?path=/test/.trash", "").Code)
+
+		items := list(t, srv)
+		require.Len(t, items, 1)
+		assert.Equal(t, "/test/a.txt", items[0].OriginalPath)
+		assert.Equal(t, "a.txt", items[0].Name)
+
+		rec = serve(srv, "POST", "/api/trash/restore", `{"id":"`+items[0].ID+`"}`)
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"path":"/test/a.txt"`)
+		assert.FileExists(t, filepath.Join(tmpDir, "a.txt"))
+		assert.Empty(t, list(t, srv))
+		assert.Equal(t, http.StatusNotFound, serve(srv, "POST", "/api/trash/restore", `{"id":"`+items[0].ID+`"}`).Code)
+	})
+
+	t.Run("restore conflict", func(t *testing.T) {
+		srv, tmpDir := setup(t, ".trash")
+		require.Equal(t, http.StatusOK, serve(srv, "DELETE", "/api/files/test/a.txt", "").Code)
+		require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "a.txt"), []byte("new"), 0600))
+		id := list(t, srv)[0].ID
+
+		assert.Equal(t, http.StatusConflict, serve(srv, "POST", "/api/trash/restore", `{"id":"`+id+`"}`).Code)
+		assert.Equal(t, http.StatusBadRequest,
+			serve(srv, "POST", "/api/trash/restore", `{"id":"`+id+`","overwrite":"merge"}`).Code)
+		rec := serve(srv, "POST", "/api/trash/restore", `{"id":"`+id+`","overwrite":"rename"}`)
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"path":"/test/a (1).txt"`)
+	})
+
+	t.Run("permanent purge", func(t *testing.T) {
+		srv, tmpDir := setup(t, ".trash")
+		rec := serve(srv, "POST", "/api/files/delete", `{"paths":["/test/a.txt","/test/b.txt"]}`)
+		require.Equal(t, http.StatusOK, rec.Code)
+		items := list(t, srv)
+		require.Len(t, items, 2)
+
+		rec = serve(srv, "DELETE", "/api/trash?id="+items[0].ID, "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"removed":1}`, rec.Body.String())
+		assert.Equal(t, http.StatusNotFound, serve(srv, "DELETE", "/api/trash?id="+items[0].ID, "").Code)
+
+		rec = serve(srv, "DELETE", "/api/trash", "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"removed":1}`, rec.Body.String())
+		assert.Empty(t, list(t, srv))
+		entries, err := os.ReadDir(filepath.Join(tmpDir, ".trash"))
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		srv, tmpDir := setup(t, "")
+		require.Equal(t, http.StatusOK, serve(srv, "DELETE", "/api/files/test/a.txt", "").Code)
+		assert.NoFileExists(t, filepath.Join(tmpDir, "a.txt"))
+		assert.Equal(t, http.StatusNotFound, serve(srv, "GET", "/api/trash", "").Code)
+		assert.Equal(t, http.StatusNotFound, serve(srv, "DELETE", "/api/trash", "").Code)
+	})
+}
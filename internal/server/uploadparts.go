@@ -0,0 +1,139 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"strings"
+)
+
+// defaultMaxFilesPerUpload applies when max_files_per_upload is not set
+const defaultMaxFilesPerUpload = 100
+
+// maxPartHeaderSize bounds the part header kept for inspection; the
+// Content-Disposition line comes first in practice
+const maxPartHeaderSize = 16 << 10
+
+// maxFilesPerUpload returns the configured limit of file parts per upload
+// request, or -1 when the limit is disabled
+func (s *Server) maxFilesPerUpload() int {
+	limit := s.Config.Main.MaxFilesPerUpload
+	if limit == 0 {
+		return defaultMaxFilesPerUpload
+	}
+	if limit < 0 {
+		return -1
+	}
+	return limit
+}
+
+// isTooManyFiles reports whether err was caused by an upload carrying more
+// file parts than allowed; such requests are answered with 413
+func isTooManyFiles(err error) bool {
+	return strings.Contains(err.Error(), "too many files in upload")
+}
+
+// partLimitReader counts the file parts of a multipart body while it is read
+// and fails once there are more than limit, before the parser has spooled
+// the excess parts
+type partLimitReader struct {
+	r        io.Reader
+	delim    []byte
+	limit    int
+	files    int
+	scratch  []byte
+	tail     []byte
+	header   []byte
+	inHeader bool
+}
+
+// limitUploadParts wraps a multipart body with the given Content-Type so that
+// reading it fails after more than limit file parts. Bodies that are not
+// multipart are returned unchanged and left to the parser to reject.
+func limitUploadParts(body io.Reader, contentType string, limit int) io.Reader {
+	if limit < 0 {
+		return body
+	}
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil || !strings.HasPrefix(mediaType, "multipart/") || params["boundary"] == "" {
+		return body
+	}
+	return &partLimitReader{
+		r:     body,
+		delim: []byte("\r\n--" + params["boundary"]),
+		limit: limit,
+		// The first delimiter is not preceded by a line break
+		tail: []byte("\r\n"),
+	}
+}
+
+func (l *partLimitReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	if n > 0 {
+		if scanErr := l.scan(p[:n]); scanErr != nil {
+			return 0, scanErr
+		}
+	}
+	return n, err
+}
+
+// scan looks for part delimiters in data and inspects the header following
+// each of them. Bytes that may belong to a delimiter or header end split
+// across reads are carried over to the next call.
+func (l *partLimitReader) scan(data []byte) error {
+	buf := append(append(l.scratch[:0], l.tail...), data...)
+	l.scratch = buf
+	pos := 0
+	for {
+		if l.inHeader {
+			end := bytes.Index(buf[pos:], []byte("\r\n\r\n"))
+			if end < 0 {
+				keep := max(len(buf)-3, pos)
+				l.appendHeader(buf[pos:keep])
+				l.tail = append(l.tail[:0], buf[keep:]...)
+				return nil
+			}
+			l.appendHeader(buf[pos : pos+end])
+			l.inHeader = false
+			if isFilePart(l.header) {
+				l.files++
+				if l.files > l.limit {
+					return fmt.Errorf("too many files in upload: more than %d", l.limit)
+				}
+			}
+			l.header = l.header[:0]
+			pos += end + 4
+			continue
+		}
+
+		idx := bytes.Index(buf[pos:], l.delim)
+		if idx < 0 {
+			keep := max(len(buf)-(len(l.delim)-1), pos)
+			l.tail = append(l.tail[:0], buf[keep:]...)
+			return nil
+		}
+		pos += idx + len(l.delim)
+		l.inHeader = true
+	}
+}
+
+func (l *partLimitReader) appendHeader(b []byte) {
+	if room := maxPartHeaderSize - len(l.header); room > 0 {
+		l.header = append(l.header, b[:min(len(b), room)]...)
+	}
+}
+
+// isFilePart reports whether a raw part header carries a file name in its
+// Content-Disposition, as the multipart parser does for file parts
+func isFilePart(header []byte) bool {
+	for _, line := range strings.Split(string(header), "\r\n") {
+		name, value, ok := strings.Cut(line, ":")
+		if !ok || !strings.EqualFold(strings.TrimSpace(name), "Content-Disposition") {
+			continue
+		}
+		_, params, err := mime.ParseMediaType(strings.TrimSpace(value))
+		return err == nil && params["filename"] != ""
+	}
+	return false
+}
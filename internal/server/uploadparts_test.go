@@ -0,0 +1,100 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"dendrite/internal/config"
+)
+
+// multiFileBody builds a multipart upload with a path field and the given
+// number of file parts
+func multiFileBody(t *testing.T, files int) (*bytes.Buffer, string) {
+	t.Helper()
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	require.NoError(t, writer.WriteField("path", "/data"))
+	for i := range files {
+		part, err := writer.CreateFormFile("file", fmt.Sprintf("file%d.txt", i))
+		require.NoError(t, err)
+		_, err = part.Write(bytes.Repeat([]byte("content\r\n"), 100))
+		require.NoError(t, err)
+	}
+	require.NoError(t, writer.Close())
+	return &body, writer.FormDataContentType()
+}
+
+func TestMaxFilesPerUpload(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg := &config.Config{
+		Directories: []config.DirMapping{{Source: tmpDir, Virtual: "/data"}},
+	}
+	cfg.Main.MaxFilesPerUpload = 3
+	srv := New(cfg)
+
+	upload := func(files int) *httptest.ResponseRecorder {
+		body, contentType := multiFileBody(t, files)
+		req := httptest.NewRequest("POST", "/api/files", body)
+		req.Header.Set("Content-Type", contentType)
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("requests within the limit are accepted", func(t *testing.T) {
+		rec := upload(3)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.FileExists(t, tmpDir+"/file0.txt")
+		require.NoError(t, os.Remove(tmpDir+"/file0.txt"))
+	})
+
+	t.Run("more file parts are rejected", func(t *testing.T) {
+		rec := upload(4)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+		assert.Contains(t, rec.Body.String(), "too many files in upload")
+
+		entries, err := os.ReadDir(tmpDir)
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+
+	t.Run("the limit can be disabled", func(t *testing.T) {
+		cfg.Main.MaxFilesPerUpload = -1
+		defer func() { cfg.Main.MaxFilesPerUpload = 3 }()
+		rec := upload(defaultMaxFilesPerUpload + 1)
+		assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	})
+}
+
+func TestPartLimitReader(t *testing.T) {
+	body, contentType := multiFileBody(t, 5)
+	raw := body.Bytes()
+
+	t.Run("counts parts split across reads", func(t *testing.T) {
+		r := limitUploadParts(iotest.OneByteReader(bytes.NewReader(raw)), contentType, 4)
+		_, err := io.ReadAll(r)
+		assert.ErrorContains(t, err, "too many files in upload: more than 4")
+	})
+
+	t.Run("fields do not count", func(t *testing.T) {
+		r := limitUploadParts(iotest.HalfReader(bytes.NewReader(raw)), contentType, 5)
+		data, err := io.ReadAll(r)
+		require.NoError(t, err)
+		assert.Equal(t, raw, data)
+	})
+
+	t.Run("other bodies are passed through", func(t *testing.T) {
+		r := bytes.NewReader(raw)
+		assert.Same(t, io.Reader(r), limitUploadParts(r, "application/json", 1))
+	})
+}
@@ -2,9 +2,14 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"dendrite/internal/config"
@@ -19,6 +24,9 @@ func main() {
 	}
 
 	fmt.Printf("Starting Dendrite file manager on %s\n", cfg.Listen)
+	if cfg.Main.TLSCert != "" {
+		fmt.Printf("TLS enabled with certificate %s\n", cfg.Main.TLSCert)
+	}
 	if cfg.QuotaBytes > 0 {
 		fmt.Printf("Quota limit: %s (%d bytes)\n", cfg.Quota, cfg.QuotaBytes)
 	}
@@ -29,7 +37,13 @@ func main() {
 		fmt.Printf("Serving %d directories\n", len(cfg.Directories))
 	}
 
+	// SIGINT and SIGTERM shut the server down gracefully
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	srv := server.New(cfg)
+	srv.StartTempJanitor(ctx)
+	srv.StartUploadExpiry(ctx)
 
 	// Create HTTP server with timeouts
 	httpServer := &http.Server{
@@ -40,5 +54,7 @@ func main() {
 		IdleTimeout:  120 * time.Second,
 	}
 
-	log.Fatal(httpServer.ListenAndServe())
+	if err := srv.ListenAndServe(ctx, httpServer); !errors.Is(err, http.ErrServerClosed) {
+		log.Fatal(err)
+	}
 }